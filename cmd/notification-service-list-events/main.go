@@ -46,26 +46,26 @@ func run() error {
 	}
 	defer cleanup()
 
-	tokensSet := internal.NewEmptySet[domain.APNSToken]()
+	tokensSet := internal.NewEmptySet[domain.PushToken]()
 
 	if err := listTokens(ctx, service, publicKey, tokensSet); err != nil {
-		return errors.Wrap(err, "error listing APNs tokens")
+		return errors.Wrap(err, "error listing push tokens")
 	}
 
 	if err := listEvents(ctx, service, publicKey, tokensSet); err != nil {
-		return errors.Wrap(err, "error listing APNs tokens")
+		return errors.Wrap(err, "error listing push tokens")
 	}
 
 	return nil
 }
 
-func listTokens(ctx context.Context, service di.Service, publicKey domain.PublicKey, tokensSet *internal.Set[domain.APNSToken]) error {
+func listTokens(ctx context.Context, service di.Service, publicKey domain.PublicKey, tokensSet *internal.Set[domain.PushToken]) error {
 	fmt.Println()
-	fmt.Println("listing stored APNs tokens related to public key", publicKey.Hex())
+	fmt.Println("listing stored push tokens related to public key", publicKey.Hex())
 
 	tokens, err := service.App().Queries.GetTokens.Handle(ctx, publicKey)
 	if err != nil {
-		return errors.Wrap(err, "error getting APNs tokens")
+		return errors.Wrap(err, "error getting push tokens")
 	}
 
 	if len(tokens) == 0 {
@@ -73,8 +73,8 @@ func listTokens(ctx context.Context, service di.Service, publicKey domain.Public
 	}
 
 	for _, token := range tokens {
-		tokensSet.Put(token)
-		fmt.Println("token", token.Hex())
+		tokensSet.Put(token.PushToken())
+		fmt.Println("token", token.PushToken().String())
 	}
 
 	return nil
@@ -85,7 +85,7 @@ type eventWithNotifications struct {
 	Notifications []notifications.Notification
 }
 
-func listEvents(ctx context.Context, service di.Service, publicKey domain.PublicKey, tokensSet *internal.Set[domain.APNSToken]) error {
+func listEvents(ctx context.Context, service di.Service, publicKey domain.PublicKey, tokensSet *internal.Set[domain.PushToken]) error {
 	fmt.Println()
 	fmt.Println("listing stored events related to public key", publicKey.Hex())
 
@@ -155,7 +155,7 @@ func listEvents(ctx context.Context, service di.Service, publicKey domain.Public
 
 		for _, notification := range eventWithNotifications.Notifications {
 			fmt.Printf("-> notification %s created at %s", notification.UUID(), notification.CreatedAt())
-			if !tokensSet.Contains(notification.APNSToken()) {
+			if !tokensSet.Contains(notification.PushToken()) {
 				fmt.Print(" (for someone else)")
 			}
 			fmt.Println()
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/cmd/notification-service/di"
+	configadapters "github.com/planetary-social/go-notification-service/service/adapters/config"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const defaultLimit = 1000
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx := context.Background()
+
+	since := flag.String("since", "", "only replay events created at or after this time (RFC 3339)")
+	until := flag.String("until", "", "only replay events created at or before this time (RFC 3339)")
+	author := flag.String("author", "", "only replay events created by this npub")
+	mention := flag.String("mention", "", "only replay events which mention this npub")
+	limit := flag.Int("limit", defaultLimit, "maximum number of events to replay")
+	dryRun := flag.Bool("dry-run", false, "print notifications which would be generated without sending them")
+	flag.Parse()
+
+	filters, err := createFilters(*since, *until, *author, *mention, *limit)
+	if err != nil {
+		return errors.Wrap(err, "error creating filters")
+	}
+
+	cfg, err := configadapters.NewEnvironmentConfigLoader().Load()
+	if err != nil {
+		return errors.Wrap(err, "error creating a config")
+	}
+
+	service, cleanup, err := di.BuildService(ctx, cfg)
+	if err != nil {
+		return errors.Wrap(err, "error building a service")
+	}
+	defer cleanup()
+
+	replayed, err := service.App().Commands.ReplayEvents.Handle(ctx, app.NewReplayEvents(filters, *dryRun))
+	if err != nil {
+		return errors.Wrap(err, "error replaying events")
+	}
+
+	for _, v := range replayed {
+		evt := v.Event()
+		fmt.Println("event", evt.Id().Hex(), "type", evt.Kind().Int(), "created at", evt.CreatedAt())
+
+		for _, notification := range v.Notifications() {
+			fmt.Printf("-> would send notification to %s with priority %s", notification.PushToken().String(), notification.Priority().String())
+			if alert := notification.Alert(); !alert.IsZero() {
+				fmt.Printf(" and alert %s %v", alert.LocKey(), alert.LocArgs())
+			}
+			fmt.Println()
+		}
+	}
+
+	if *dryRun {
+		fmt.Println("dry run, found", len(replayed), "events")
+	} else {
+		fmt.Println("scheduled", len(replayed), "events for processing")
+	}
+
+	return nil
+}
+
+func createFilters(since, until, author, mention string, limit int) (domain.Filters, error) {
+	if author == "" && mention == "" && since == "" && until == "" {
+		return domain.Filters{}, errors.New("specify at least one of the filters")
+	}
+
+	filter := nostr.Filter{
+		Limit: limit,
+	}
+
+	if since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return domain.Filters{}, errors.Wrap(err, "error parsing since")
+		}
+		timestamp := nostr.Timestamp(t.Unix())
+		filter.Since = &timestamp
+	}
+
+	if until != "" {
+		t, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			return domain.Filters{}, errors.Wrap(err, "error parsing until")
+		}
+		timestamp := nostr.Timestamp(t.Unix())
+		filter.Until = &timestamp
+	}
+
+	if author != "" {
+		publicKey, err := domain.NewPublicKeyFromNpub(author)
+		if err != nil {
+			return domain.Filters{}, errors.Wrap(err, "error decoding the author")
+		}
+		filter.Authors = []string{publicKey.Hex()}
+	}
+
+	if mention != "" {
+		publicKey, err := domain.NewPublicKeyFromNpub(mention)
+		if err != nil {
+			return domain.Filters{}, errors.Wrap(err, "error decoding the mention")
+		}
+		filter.Tags = map[string][]string{
+			"p": {publicKey.Hex()},
+		}
+	}
+
+	return domain.NewFilters(nostr.Filters{filter})
+}
@@ -2,21 +2,62 @@ package di
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"os"
 
 	googlefirestore "cloud.google.com/go/firestore"
+	"github.com/ThreeDotsLabs/watermill"
 	watermillfirestore "github.com/ThreeDotsLabs/watermill-firestore/pkg/firestore"
 	"github.com/boreq/errors"
 	"github.com/google/wire"
 	"github.com/planetary-social/go-notification-service/internal/logging"
 	"github.com/planetary-social/go-notification-service/service/adapters"
 	"github.com/planetary-social/go-notification-service/service/adapters/apns"
+	"github.com/planetary-social/go-notification-service/service/adapters/bolt"
+	"github.com/planetary-social/go-notification-service/service/adapters/fcm"
 	"github.com/planetary-social/go-notification-service/service/adapters/firestore"
+	"github.com/planetary-social/go-notification-service/service/adapters/postgres"
 	"github.com/planetary-social/go-notification-service/service/adapters/prometheus"
+	"github.com/planetary-social/go-notification-service/service/adapters/webpush"
 	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/planetary-social/go-notification-service/service/domain"
 	"github.com/planetary-social/go-notification-service/service/ports/firestorepubsub"
+	"github.com/planetary-social/go-notification-service/service/ports/http"
+	"github.com/redis/go-redis/v9"
+	"go.etcd.io/bbolt"
 )
 
+var storageSet = wire.NewSet(
+	newStorage,
+	wire.FieldsOf(new(storage), "TransactionProvider", "Subscriber"),
+)
+
+// storage groups the adapters which depend on the selected storage backend.
+type storage struct {
+	TransactionProvider app.TransactionProvider
+	Subscriber          firestorepubsub.Subscriber
+}
+
+func newStorage(
+	ctx context.Context,
+	cfg config.Config,
+	loggerAdapter watermill.LoggerAdapter,
+	logger logging.Logger,
+) (storage, func(), error) {
+	switch cfg.StorageBackend() {
+	case config.StorageBackendFirestore:
+		return buildFirestoreStorage(ctx, cfg, loggerAdapter, logger)
+	case config.StorageBackendPostgres:
+		return buildPostgresStorage(ctx, cfg, logger)
+	case config.StorageBackendBolt:
+		return buildBoltStorage(cfg, logger)
+	default:
+		return storage{}, nil, fmt.Errorf("unknown storage backend '%s'", cfg.StorageBackend().String())
+	}
+}
+
 var firestoreAdaptersSet = wire.NewSet(
 	newFirestoreClient,
 
@@ -26,7 +67,7 @@ var firestoreAdaptersSet = wire.NewSet(
 	newAdaptersFactoryFn,
 
 	firestore.NewWatermillSubscriber,
-	wire.Bind(new(firestorepubsub.FirestoreSubscriber), new(*watermillfirestore.Subscriber)),
+	wire.Bind(new(firestorepubsub.Subscriber), new(*watermillfirestore.Subscriber)),
 
 	wire.Struct(new(buildTransactionFirestoreAdaptersDependencies), "*"),
 )
@@ -53,22 +94,118 @@ var firestoreTxAdaptersSet = wire.NewSet(
 	firestore.NewTagRepository,
 	wire.Bind(new(app.TagRepository), new(*firestore.TagRepository)),
 
+	firestore.NewMuteListRepository,
+	wire.Bind(new(app.MuteListRepository), new(*firestore.MuteListRepository)),
+
 	firestore.NewWatermillPublisher,
 	firestore.NewPublisher,
 	wire.Bind(new(app.Publisher), new(*firestore.Publisher)),
 )
 
+var postgresAdaptersSet = wire.NewSet(
+	newPostgresDB,
+
+	postgres.NewTransactionProvider,
+	wire.Bind(new(app.TransactionProvider), new(*postgres.TransactionProvider)),
+
+	newPostgresAdaptersFactoryFn,
+
+	postgres.NewSubscriber,
+	wire.Bind(new(firestorepubsub.Subscriber), new(*postgres.Subscriber)),
+)
+
+func newPostgresAdaptersFactoryFn() postgres.AdaptersFactoryFn {
+	return func(tx *sql.Tx) (app.Adapters, error) {
+		return buildTransactionPostgresAdapters(tx)
+	}
+}
+
+var postgresTxAdaptersSet = wire.NewSet(
+	postgres.NewRegistrationRepository,
+	wire.Bind(new(app.RegistrationRepository), new(*postgres.RegistrationRepository)),
+
+	postgres.NewEventRepository,
+	wire.Bind(new(app.EventRepository), new(*postgres.EventRepository)),
+
+	postgres.NewRelayRepository,
+	wire.Bind(new(app.RelayRepository), new(*postgres.RelayRepository)),
+
+	postgres.NewPublicKeyRepository,
+	wire.Bind(new(app.PublicKeyRepository), new(*postgres.PublicKeyRepository)),
+
+	postgres.NewTagRepository,
+	wire.Bind(new(app.TagRepository), new(*postgres.TagRepository)),
+
+	postgres.NewMuteListRepository,
+	wire.Bind(new(app.MuteListRepository), new(*postgres.MuteListRepository)),
+
+	postgres.NewPublisher,
+	wire.Bind(new(app.Publisher), new(*postgres.Publisher)),
+)
+
+var boltAdaptersSet = wire.NewSet(
+	newBoltDB,
+
+	bolt.NewTransactionProvider,
+	wire.Bind(new(app.TransactionProvider), new(*bolt.TransactionProvider)),
+
+	newBoltAdaptersFactoryFn,
+
+	bolt.NewSubscriber,
+	wire.Bind(new(firestorepubsub.Subscriber), new(*bolt.Subscriber)),
+)
+
+func newBoltAdaptersFactoryFn(subscriber *bolt.Subscriber) bolt.AdaptersFactoryFn {
+	return func(tx *bbolt.Tx) (app.Adapters, error) {
+		return buildTransactionBoltAdapters(tx, subscriber)
+	}
+}
+
+var boltTxAdaptersSet = wire.NewSet(
+	bolt.NewRegistrationRepository,
+	wire.Bind(new(app.RegistrationRepository), new(*bolt.RegistrationRepository)),
+
+	bolt.NewEventRepository,
+	wire.Bind(new(app.EventRepository), new(*bolt.EventRepository)),
+
+	bolt.NewRelayRepository,
+	wire.Bind(new(app.RelayRepository), new(*bolt.RelayRepository)),
+
+	bolt.NewPublicKeyRepository,
+	wire.Bind(new(app.PublicKeyRepository), new(*bolt.PublicKeyRepository)),
+
+	bolt.NewTagRepository,
+	wire.Bind(new(app.TagRepository), new(*bolt.TagRepository)),
+
+	bolt.NewMuteListRepository,
+	wire.Bind(new(app.MuteListRepository), new(*bolt.MuteListRepository)),
+
+	bolt.NewPublisher,
+	wire.Bind(new(app.Publisher), new(*bolt.Publisher)),
+)
+
 var adaptersSet = wire.NewSet(
 	apns.NewAPNS,
-	wire.Bind(new(app.APNS), new(*apns.APNS)),
+
+	newPushNotificationRouter,
+	wire.Bind(new(app.APNS), new(*adapters.PushNotificationRouter)),
 
 	prometheus.NewPrometheus,
 	wire.Bind(new(app.Metrics), new(*prometheus.Prometheus)),
 	wire.Bind(new(firestorepubsub.Metrics), new(*prometheus.Prometheus)),
+	wire.Bind(new(http.Metrics), new(*prometheus.Prometheus)),
 	wire.Bind(new(apns.Metrics), new(*prometheus.Prometheus)),
+	wire.Bind(new(fcm.Metrics), new(*prometheus.Prometheus)),
+	wire.Bind(new(webpush.Metrics), new(*prometheus.Prometheus)),
+
+	newEventWasAlreadySavedCache,
+	wire.Bind(new(app.EventWasAlreadySavedCache), new(eventWasAlreadySavedCache)),
 
-	adapters.NewMemoryEventWasAlreadySavedCache,
-	wire.Bind(new(app.EventWasAlreadySavedCache), new(*adapters.MemoryEventWasAlreadySavedCache)),
+	adapters.NewRelayMetadataProvider,
+	wire.Bind(new(app.MetadataProvider), new(*adapters.RelayMetadataProvider)),
+
+	adapters.NewNIP11RelayInformationProvider,
+	wire.Bind(new(app.RelayInformationProvider), new(*adapters.NIP11RelayInformationProvider)),
 )
 
 var integrationAdaptersSet = wire.NewSet(
@@ -78,12 +215,134 @@ var integrationAdaptersSet = wire.NewSet(
 	prometheus.NewPrometheus,
 	wire.Bind(new(app.Metrics), new(*prometheus.Prometheus)),
 	wire.Bind(new(firestorepubsub.Metrics), new(*prometheus.Prometheus)),
+	wire.Bind(new(http.Metrics), new(*prometheus.Prometheus)),
 	wire.Bind(new(apns.Metrics), new(*prometheus.Prometheus)),
 
-	adapters.NewMemoryEventWasAlreadySavedCache,
-	wire.Bind(new(app.EventWasAlreadySavedCache), new(*adapters.MemoryEventWasAlreadySavedCache)),
+	newEventWasAlreadySavedCache,
+	wire.Bind(new(app.EventWasAlreadySavedCache), new(eventWasAlreadySavedCache)),
+
+	adapters.NewRelayMetadataProvider,
+	wire.Bind(new(app.MetadataProvider), new(*adapters.RelayMetadataProvider)),
+
+	adapters.NewNIP11RelayInformationProvider,
+	wire.Bind(new(app.RelayInformationProvider), new(*adapters.NIP11RelayInformationProvider)),
 )
 
+func newPushNotificationRouter(
+	ctx context.Context,
+	config config.Config,
+	apns *apns.APNS,
+	fcmMetrics fcm.Metrics,
+	webPushMetrics webpush.Metrics,
+	logger logging.Logger,
+) (*adapters.PushNotificationRouter, error) {
+	senders := map[domain.PushTokenPlatform]app.APNS{
+		domain.PushTokenPlatformAPNS: apns,
+	}
+
+	if config.FCMEnabled() {
+		v, err := fcm.NewFCM(ctx, config, fcmMetrics, logger)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the fcm adapter")
+		}
+		senders[domain.PushTokenPlatformFCM] = v
+	}
+
+	if config.WebPushEnabled() {
+		senders[domain.PushTokenPlatformWebPush] = webpush.NewWebPush(config, webPushMetrics, logger)
+	}
+
+	return adapters.NewPushNotificationRouter(senders), nil
+}
+
+type eventWasAlreadySavedCache interface {
+	app.EventWasAlreadySavedCache
+	Run(ctx context.Context) error
+}
+
+func newEventWasAlreadySavedCache(cfg config.Config, logger logging.Logger) (eventWasAlreadySavedCache, func(), error) {
+	switch cfg.EventCacheBackend() {
+	case config.EventCacheBackendMemory:
+		return adapters.NewMemoryEventWasAlreadySavedCache(cfg.EventCacheTTL()), func() {}, nil
+	case config.EventCacheBackendRedis:
+		client, cleanup, err := newRedisClient(cfg, logger)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error creating the redis client")
+		}
+		return adapters.NewRedisEventWasAlreadySavedCache(client, cfg.Environment(), cfg.EventCacheTTL()), cleanup, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown event cache backend '%s'", cfg.EventCacheBackend().String())
+	}
+}
+
+func newReplicaRegistry(cfg config.Config, logger logging.Logger) (app.ReplicaRegistry, func(), error) {
+	switch cfg.ReplicaRegistryBackend() {
+	case config.ReplicaRegistryBackendMemory:
+		return adapters.NewMemoryReplicaRegistry(), func() {}, nil
+	case config.ReplicaRegistryBackendRedis:
+		client, cleanup, err := newRedisClient(cfg, logger)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "error creating the redis client")
+		}
+		return adapters.NewRedisReplicaRegistry(client, cfg.Environment()), cleanup, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown replica registry backend '%s'", cfg.ReplicaRegistryBackend().String())
+	}
+}
+
+func newReplicaID() app.ReplicaID {
+	hostname, _ := os.Hostname()
+	return app.NewRandomReplicaID(hostname)
+}
+
+func newDownloaderConfig(cfg config.Config) (app.DownloaderConfig, error) {
+	return app.NewDownloaderConfig(
+		cfg.DownloaderMaxLookback(),
+		cfg.DownloaderMaxCursorAge(),
+		cfg.DownloaderCursorOverlap(),
+	)
+}
+
+func newRedisClient(cfg config.Config, logger logging.Logger) (*redis.Client, func(), error) {
+	options, err := redis.ParseURL(cfg.RedisURL())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error parsing the redis url")
+	}
+
+	v := redis.NewClient(options)
+	return v, func() {
+		if err := v.Close(); err != nil {
+			logger.Error().WithError(err).Message("error closing redis")
+		}
+	}, nil
+}
+
+func newPostgresDB(ctx context.Context, config config.Config, logger logging.Logger) (*sql.DB, func(), error) {
+	v, err := postgres.NewDB(ctx, config)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error creating the postgres client")
+	}
+
+	return v, func() {
+		if err := v.Close(); err != nil {
+			logger.Error().WithError(err).Message("error closing postgres")
+		}
+	}, nil
+}
+
+func newBoltDB(config config.Config, logger logging.Logger) (*bbolt.DB, func(), error) {
+	v, err := bolt.NewDB(config)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error creating the bolt database")
+	}
+
+	return v, func() {
+		if err := v.Close(); err != nil {
+			logger.Error().WithError(err).Message("error closing bolt")
+		}
+	}, nil
+}
+
 func newFirestoreClient(ctx context.Context, config config.Config, logger logging.Logger) (*googlefirestore.Client, func(), error) {
 	v, err := firestore.NewClient(ctx, config)
 	if err != nil {
@@ -18,6 +18,11 @@ var commandsSet = wire.NewSet(
 	wire.Struct(new(app.Commands), "*"),
 
 	app.NewSaveRegistrationHandler,
+	app.NewRemoveRegistrationHandler,
+	app.NewRemoveInvalidPushTokenHandler,
+	app.NewDeletePublicKeyHandler,
+	app.NewReprocessEventHandler,
+	app.NewReplayEventsHandler,
 
 	app.NewSaveReceivedEventHandler,
 	wire.Bind(new(memorypubsub.SaveReceivedEventHandler), new(*app.SaveReceivedEventHandler)),
@@ -34,4 +39,6 @@ var queriesSet = wire.NewSet(
 	app.NewGetTokensHandler,
 	app.NewGetEventsHandler,
 	app.NewGetNotificationsHandler,
+	app.NewGetRelayHealthHandler,
+	app.NewGetRecentNotificationsHandler,
 )
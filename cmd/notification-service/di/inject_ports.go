@@ -10,6 +10,7 @@ import (
 var portsSet = wire.NewSet(
 	http.NewServer,
 	http.NewMetricsServer,
+	http.NewAdminServer,
 
 	memorypubsub.NewReceivedEventSubscriber,
 	firestorepubsub.NewEventSavedSubscriber,
@@ -5,7 +5,6 @@ import (
 
 	"github.com/boreq/errors"
 	"github.com/hashicorp/go-multierror"
-	"github.com/planetary-social/go-notification-service/service/adapters"
 	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/ports/firestorepubsub"
 	"github.com/planetary-social/go-notification-service/service/ports/http"
@@ -16,31 +15,34 @@ type Service struct {
 	app                            app.Application
 	server                         http.Server
 	metricsServer                  http.MetricsServer
+	adminServer                    http.AdminServer
 	downloader                     *app.Downloader
 	followChangePuller             *app.FollowChangePuller
 	vanishSubscriber               *app.VanishSubscriber
 	receivedEventSubscriber        *memorypubsub.ReceivedEventSubscriber
 	externalFollowChangeSubscriber app.ExternalFollowChangeSubscriber
 	eventSavedSubscriber           *firestorepubsub.EventSavedSubscriber
-	eventWasAlreadySavedCache      *adapters.MemoryEventWasAlreadySavedCache
+	eventWasAlreadySavedCache      eventWasAlreadySavedCache
 }
 
 func NewService(
 	app app.Application,
 	server http.Server,
 	metricsServer http.MetricsServer,
+	adminServer http.AdminServer,
 	downloader *app.Downloader,
 	followChangePuller *app.FollowChangePuller,
 	vanishSubscriber *app.VanishSubscriber,
 	receivedEventSubscriber *memorypubsub.ReceivedEventSubscriber,
 	externalFollowChangeSubscriber app.ExternalFollowChangeSubscriber,
 	eventSavedSubscriber *firestorepubsub.EventSavedSubscriber,
-	eventWasAlreadySavedCache *adapters.MemoryEventWasAlreadySavedCache,
+	eventWasAlreadySavedCache eventWasAlreadySavedCache,
 ) Service {
 	return Service{
 		app:                            app,
 		server:                         server,
 		metricsServer:                  metricsServer,
+		adminServer:                    adminServer,
 		downloader:                     downloader,
 		followChangePuller:             followChangePuller,
 		vanishSubscriber:               vanishSubscriber,
@@ -72,6 +74,11 @@ func (s Service) Run(ctx context.Context) error {
 		errCh <- errors.Wrap(s.metricsServer.ListenAndServe(ctx), "metrics server error")
 	}()
 
+	runners++
+	go func() {
+		errCh <- errors.Wrap(s.adminServer.ListenAndServe(ctx), "admin server error")
+	}()
+
 	runners++
 	go func() {
 		errCh <- errors.Wrap(s.downloader.Run(ctx), "downloader error")
@@ -5,14 +5,18 @@ package di
 
 import (
 	"context"
+	"database/sql"
 
 	googlefirestore "cloud.google.com/go/firestore"
 	"github.com/ThreeDotsLabs/watermill"
 	"github.com/google/wire"
+	"github.com/planetary-social/go-notification-service/internal/logging"
 	"github.com/planetary-social/go-notification-service/service/adapters/apns"
+	"github.com/planetary-social/go-notification-service/service/adapters/bolt"
 	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/config"
 	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+	"go.etcd.io/bbolt"
 )
 
 func BuildService(context.Context, config.Config) (Service, func(), error) {
@@ -21,7 +25,7 @@ func BuildService(context.Context, config.Config) (Service, func(), error) {
 
 		portsSet,
 		applicationSet,
-		firestoreAdaptersSet,
+		storageSet,
 		downloaderSet,
 		generatorSet,
 		pubsubSet,
@@ -47,7 +51,7 @@ func BuildIntegrationService(context.Context, config.Config) (IntegrationService
 
 		portsSet,
 		applicationSet,
-		firestoreAdaptersSet,
+		storageSet,
 		downloaderSet,
 		followChangePullerSet,
 		vanishSubscriberSet,
@@ -59,6 +63,33 @@ func BuildIntegrationService(context.Context, config.Config) (IntegrationService
 	return IntegrationService{}, nil, nil
 }
 
+func buildFirestoreStorage(context.Context, config.Config, watermill.LoggerAdapter, logging.Logger) (storage, func(), error) {
+	wire.Build(
+		wire.Struct(new(storage), "*"),
+
+		firestoreAdaptersSet,
+	)
+	return storage{}, nil, nil
+}
+
+func buildPostgresStorage(context.Context, config.Config, logging.Logger) (storage, func(), error) {
+	wire.Build(
+		wire.Struct(new(storage), "*"),
+
+		postgresAdaptersSet,
+	)
+	return storage{}, nil, nil
+}
+
+func buildBoltStorage(config.Config, logging.Logger) (storage, func(), error) {
+	wire.Build(
+		wire.Struct(new(storage), "*"),
+
+		boltAdaptersSet,
+	)
+	return storage{}, nil, nil
+}
+
 type buildTransactionFirestoreAdaptersDependencies struct {
 	LoggerAdapter watermill.LoggerAdapter
 }
@@ -74,8 +105,33 @@ func buildTransactionFirestoreAdapters(client *googlefirestore.Client, tx *googl
 
 }
 
+func buildTransactionPostgresAdapters(tx *sql.Tx) (app.Adapters, error) {
+	wire.Build(
+		wire.Struct(new(app.Adapters), "*"),
+
+		postgresTxAdaptersSet,
+	)
+	return app.Adapters{}, nil
+}
+
+func buildTransactionBoltAdapters(tx *bbolt.Tx, subscriber *bolt.Subscriber) (app.Adapters, error) {
+	wire.Build(
+		wire.Struct(new(app.Adapters), "*"),
+
+		boltTxAdaptersSet,
+	)
+	return app.Adapters{}, nil
+}
+
 var downloaderSet = wire.NewSet(
 	app.NewDownloader,
+	wire.Bind(new(app.RelayHealthProvider), new(*app.Downloader)),
+
+	app.NewRelayShards,
+	app.NewRelayInformationCache,
+	newReplicaRegistry,
+	newReplicaID,
+	newDownloaderConfig,
 )
 
 var followChangePullerSet = wire.NewSet(
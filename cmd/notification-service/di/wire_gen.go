@@ -8,6 +8,7 @@ package di
 
 import (
 	"context"
+	"database/sql"
 
 	firestore2 "cloud.google.com/go/firestore"
 	"github.com/ThreeDotsLabs/watermill"
@@ -15,7 +16,9 @@ import (
 	"github.com/planetary-social/go-notification-service/internal/logging"
 	"github.com/planetary-social/go-notification-service/service/adapters"
 	"github.com/planetary-social/go-notification-service/service/adapters/apns"
+	"github.com/planetary-social/go-notification-service/service/adapters/bolt"
 	"github.com/planetary-social/go-notification-service/service/adapters/firestore"
+	"github.com/planetary-social/go-notification-service/service/adapters/postgres"
 	"github.com/planetary-social/go-notification-service/service/adapters/prometheus"
 	"github.com/planetary-social/go-notification-service/service/adapters/pubsub"
 	"github.com/planetary-social/go-notification-service/service/app"
@@ -24,168 +27,303 @@ import (
 	"github.com/planetary-social/go-notification-service/service/ports/firestorepubsub"
 	"github.com/planetary-social/go-notification-service/service/ports/http"
 	"github.com/planetary-social/go-notification-service/service/ports/memorypubsub"
+	"go.etcd.io/bbolt"
 )
 
 // Injectors from wire.go:
 
 func BuildService(contextContext context.Context, configConfig config.Config) (Service, func(), error) {
-	memoryEventWasAlreadySavedCache := adapters.NewMemoryEventWasAlreadySavedCache()
 	logger, err := newLogger(configConfig)
 	if err != nil {
 		return Service{}, nil, err
 	}
-	client, cleanup, err := newFirestoreClient(contextContext, configConfig, logger)
+	diEventWasAlreadySavedCache, cleanup, err := newEventWasAlreadySavedCache(configConfig, logger)
 	if err != nil {
 		return Service{}, nil, err
 	}
 	watermillAdapter := logging.NewWatermillAdapter(logger)
-	diBuildTransactionFirestoreAdaptersDependencies := buildTransactionFirestoreAdaptersDependencies{
-		LoggerAdapter: watermillAdapter,
+	diStorage, cleanup2, err := newStorage(contextContext, configConfig, watermillAdapter, logger)
+	if err != nil {
+		cleanup()
+		return Service{}, nil, err
 	}
-	adaptersFactoryFn := newAdaptersFactoryFn(diBuildTransactionFirestoreAdaptersDependencies)
-	transactionProvider := firestore.NewTransactionProvider(client, adaptersFactoryFn)
+	transactionProvider := diStorage.TransactionProvider
 	prometheusPrometheus, err := prometheus.NewPrometheus(logger)
 	if err != nil {
+		cleanup2()
 		cleanup()
 		return Service{}, nil, err
 	}
-	saveReceivedEventHandler := app.NewSaveReceivedEventHandler(memoryEventWasAlreadySavedCache, transactionProvider, logger, prometheusPrometheus)
+	saveReceivedEventHandler := app.NewSaveReceivedEventHandler(diEventWasAlreadySavedCache, transactionProvider, logger, prometheusPrometheus)
 	saveRegistrationHandler := app.NewSaveRegistrationHandler(transactionProvider, logger, prometheusPrometheus)
+	removeRegistrationHandler := app.NewRemoveRegistrationHandler(transactionProvider, logger, prometheusPrometheus)
+	removeInvalidPushTokenHandler := app.NewRemoveInvalidPushTokenHandler(transactionProvider, logger, prometheusPrometheus)
+	deletePublicKeyHandler := app.NewDeletePublicKeyHandler(transactionProvider, logger, prometheusPrometheus)
+	reprocessEventHandler := app.NewReprocessEventHandler(transactionProvider, logger, prometheusPrometheus)
+	generator := notifications.NewGenerator(logger)
+	apnsAPNS, err := apns.NewAPNS(configConfig, prometheusPrometheus, logger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return Service{}, nil, err
+	}
+	pushNotificationRouter, err := newPushNotificationRouter(contextContext, configConfig, apnsAPNS, prometheusPrometheus, prometheusPrometheus, logger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return Service{}, nil, err
+	}
+	relayMetadataProvider := adapters.NewRelayMetadataProvider(configConfig, logger)
+	externalEventPublisher, err := newExternalEventPublisher(configConfig, watermillAdapter)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return Service{}, nil, err
+	}
+	processSavedEventHandler := app.NewProcessSavedEventHandler(transactionProvider, generator, pushNotificationRouter, relayMetadataProvider, logger, prometheusPrometheus, externalEventPublisher, removeInvalidPushTokenHandler)
+	replayEventsHandler := app.NewReplayEventsHandler(transactionProvider, processSavedEventHandler, reprocessEventHandler, logger, prometheusPrometheus)
 	commands := app.Commands{
-		SaveReceivedEvent: saveReceivedEventHandler,
-		SaveRegistration:  saveRegistrationHandler,
+		SaveReceivedEvent:      saveReceivedEventHandler,
+		SaveRegistration:       saveRegistrationHandler,
+		RemoveRegistration:     removeRegistrationHandler,
+		RemoveInvalidPushToken: removeInvalidPushTokenHandler,
+		DeletePublicKey:        deletePublicKeyHandler,
+		ReprocessEvent:         reprocessEventHandler,
+		ReplayEvents:           replayEventsHandler,
 	}
-	getRelaysHandler := app.NewGetRelaysHandler(transactionProvider, prometheusPrometheus)
+	nip11RelayInformationProvider := adapters.NewNIP11RelayInformationProvider()
+	relayInformationCache := app.NewRelayInformationCache(nip11RelayInformationProvider, logger)
+	getRelaysHandler := app.NewGetRelaysHandler(transactionProvider, relayInformationCache, prometheusPrometheus)
 	getPublicKeysHandler := app.NewGetPublicKeysHandler(transactionProvider, prometheusPrometheus)
 	getTokensHandler := app.NewGetTokensHandler(transactionProvider, prometheusPrometheus)
 	receivedEventPubSub := pubsub.NewReceivedEventPubSub()
 	getEventsHandler := app.NewGetEventsHandler(transactionProvider, receivedEventPubSub, prometheusPrometheus)
 	getNotificationsHandler := app.NewGetNotificationsHandler(transactionProvider, prometheusPrometheus)
-	queries := app.Queries{
-		GetRelays:        getRelaysHandler,
-		GetPublicKeys:    getPublicKeysHandler,
-		GetTokens:        getTokensHandler,
-		GetEvents:        getEventsHandler,
-		GetNotifications: getNotificationsHandler,
-	}
-	application := app.Application{
-		Commands: commands,
-		Queries:  queries,
-	}
-	server := http.NewServer(configConfig, application, logger)
-	metricsServer := http.NewMetricsServer(prometheusPrometheus, configConfig, logger)
-	downloader := app.NewDownloader(memoryEventWasAlreadySavedCache, transactionProvider, receivedEventPubSub, logger, prometheusPrometheus)
-	externalFollowChangeSubscriber, err := newExternalFollowChangeSubscriber(configConfig, watermillAdapter)
+	downloaderConfig, err := newDownloaderConfig(configConfig)
 	if err != nil {
+		cleanup2()
 		cleanup()
 		return Service{}, nil, err
 	}
-	apnsAPNS, err := apns.NewAPNS(configConfig, prometheusPrometheus, logger)
+	replicaRegistry, cleanup3, err := newReplicaRegistry(configConfig, logger)
 	if err != nil {
+		cleanup2()
 		cleanup()
 		return Service{}, nil, err
 	}
-	followChangePuller := app.NewFollowChangePuller(externalFollowChangeSubscriber, apnsAPNS, queries, logger, prometheusPrometheus)
-	vanishSubscriber := app.NewVanishSubscriber(transactionProvider, queries, logger)
-	receivedEventSubscriber := memorypubsub.NewReceivedEventSubscriber(receivedEventPubSub, saveReceivedEventHandler, logger)
-	subscriber, err := firestore.NewWatermillSubscriber(client, watermillAdapter)
-	if err != nil {
-		cleanup()
-		return Service{}, nil, err
+	replicaID := newReplicaID()
+	relayShards := app.NewRelayShards(replicaRegistry, replicaID, logger)
+	downloader := app.NewDownloader(downloaderConfig, diEventWasAlreadySavedCache, transactionProvider, receivedEventPubSub, relayInformationCache, relayShards, logger, prometheusPrometheus)
+	getRelayHealthHandler := app.NewGetRelayHealthHandler(downloader, prometheusPrometheus)
+	getRecentNotificationsHandler := app.NewGetRecentNotificationsHandler(transactionProvider, prometheusPrometheus)
+	queries := app.Queries{
+		GetRelays:              getRelaysHandler,
+		GetPublicKeys:          getPublicKeysHandler,
+		GetTokens:              getTokensHandler,
+		GetEvents:              getEventsHandler,
+		GetNotifications:       getNotificationsHandler,
+		GetRelayHealth:         getRelayHealthHandler,
+		GetRecentNotifications: getRecentNotificationsHandler,
 	}
-	generator := notifications.NewGenerator(logger)
-	externalEventPublisher, err := newExternalEventPublisher(configConfig, watermillAdapter)
+	application := app.Application{
+		Commands: commands,
+		Queries:  queries,
+	}
+	server := http.NewServer(configConfig, application, prometheusPrometheus, logger)
+	metricsServer := http.NewMetricsServer(prometheusPrometheus, configConfig, logger)
+	adminServer := http.NewAdminServer(configConfig, application, logger)
+	externalFollowChangeSubscriber, err := newExternalFollowChangeSubscriber(configConfig, watermillAdapter)
 	if err != nil {
+		cleanup3()
+		cleanup2()
 		cleanup()
 		return Service{}, nil, err
 	}
-	processSavedEventHandler := app.NewProcessSavedEventHandler(transactionProvider, generator, apnsAPNS, logger, prometheusPrometheus, externalEventPublisher)
+	followChangePuller := app.NewFollowChangePuller(externalFollowChangeSubscriber, pushNotificationRouter, queries, removeInvalidPushTokenHandler, logger, prometheusPrometheus)
+	vanishSubscriber := app.NewVanishSubscriber(deletePublicKeyHandler, logger)
+	receivedEventSubscriber := memorypubsub.NewReceivedEventSubscriber(receivedEventPubSub, saveReceivedEventHandler, logger)
+	subscriber := diStorage.Subscriber
 	eventSavedSubscriber := firestorepubsub.NewEventSavedSubscriber(subscriber, processSavedEventHandler, prometheusPrometheus, logger)
-	service := NewService(application, server, metricsServer, downloader, followChangePuller, vanishSubscriber, receivedEventSubscriber, externalFollowChangeSubscriber, eventSavedSubscriber, memoryEventWasAlreadySavedCache)
+	service := NewService(application, server, metricsServer, adminServer, downloader, followChangePuller, vanishSubscriber, receivedEventSubscriber, externalFollowChangeSubscriber, eventSavedSubscriber, diEventWasAlreadySavedCache)
 	return service, func() {
+		cleanup3()
+		cleanup2()
 		cleanup()
 	}, nil
 }
 
 func BuildIntegrationService(contextContext context.Context, configConfig config.Config) (IntegrationService, func(), error) {
-	memoryEventWasAlreadySavedCache := adapters.NewMemoryEventWasAlreadySavedCache()
 	logger, err := newLogger(configConfig)
 	if err != nil {
 		return IntegrationService{}, nil, err
 	}
-	client, cleanup, err := newFirestoreClient(contextContext, configConfig, logger)
+	diEventWasAlreadySavedCache, cleanup, err := newEventWasAlreadySavedCache(configConfig, logger)
 	if err != nil {
 		return IntegrationService{}, nil, err
 	}
 	watermillAdapter := logging.NewWatermillAdapter(logger)
-	diBuildTransactionFirestoreAdaptersDependencies := buildTransactionFirestoreAdaptersDependencies{
-		LoggerAdapter: watermillAdapter,
+	diStorage, cleanup2, err := newStorage(contextContext, configConfig, watermillAdapter, logger)
+	if err != nil {
+		cleanup()
+		return IntegrationService{}, nil, err
 	}
-	adaptersFactoryFn := newAdaptersFactoryFn(diBuildTransactionFirestoreAdaptersDependencies)
-	transactionProvider := firestore.NewTransactionProvider(client, adaptersFactoryFn)
+	transactionProvider := diStorage.TransactionProvider
 	prometheusPrometheus, err := prometheus.NewPrometheus(logger)
 	if err != nil {
+		cleanup2()
 		cleanup()
 		return IntegrationService{}, nil, err
 	}
-	saveReceivedEventHandler := app.NewSaveReceivedEventHandler(memoryEventWasAlreadySavedCache, transactionProvider, logger, prometheusPrometheus)
+	saveReceivedEventHandler := app.NewSaveReceivedEventHandler(diEventWasAlreadySavedCache, transactionProvider, logger, prometheusPrometheus)
 	saveRegistrationHandler := app.NewSaveRegistrationHandler(transactionProvider, logger, prometheusPrometheus)
+	removeRegistrationHandler := app.NewRemoveRegistrationHandler(transactionProvider, logger, prometheusPrometheus)
+	removeInvalidPushTokenHandler := app.NewRemoveInvalidPushTokenHandler(transactionProvider, logger, prometheusPrometheus)
+	deletePublicKeyHandler := app.NewDeletePublicKeyHandler(transactionProvider, logger, prometheusPrometheus)
+	reprocessEventHandler := app.NewReprocessEventHandler(transactionProvider, logger, prometheusPrometheus)
+	generator := notifications.NewGenerator(logger)
+	apnsMock, err := apns.NewAPNSMock(configConfig, logger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return IntegrationService{}, nil, err
+	}
+	relayMetadataProvider := adapters.NewRelayMetadataProvider(configConfig, logger)
+	externalEventPublisher, err := newExternalEventPublisher(configConfig, watermillAdapter)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return IntegrationService{}, nil, err
+	}
+	processSavedEventHandler := app.NewProcessSavedEventHandler(transactionProvider, generator, apnsMock, relayMetadataProvider, logger, prometheusPrometheus, externalEventPublisher, removeInvalidPushTokenHandler)
+	replayEventsHandler := app.NewReplayEventsHandler(transactionProvider, processSavedEventHandler, reprocessEventHandler, logger, prometheusPrometheus)
 	commands := app.Commands{
-		SaveReceivedEvent: saveReceivedEventHandler,
-		SaveRegistration:  saveRegistrationHandler,
+		SaveReceivedEvent:      saveReceivedEventHandler,
+		SaveRegistration:       saveRegistrationHandler,
+		RemoveRegistration:     removeRegistrationHandler,
+		RemoveInvalidPushToken: removeInvalidPushTokenHandler,
+		DeletePublicKey:        deletePublicKeyHandler,
+		ReprocessEvent:         reprocessEventHandler,
+		ReplayEvents:           replayEventsHandler,
 	}
-	getRelaysHandler := app.NewGetRelaysHandler(transactionProvider, prometheusPrometheus)
+	nip11RelayInformationProvider := adapters.NewNIP11RelayInformationProvider()
+	relayInformationCache := app.NewRelayInformationCache(nip11RelayInformationProvider, logger)
+	getRelaysHandler := app.NewGetRelaysHandler(transactionProvider, relayInformationCache, prometheusPrometheus)
 	getPublicKeysHandler := app.NewGetPublicKeysHandler(transactionProvider, prometheusPrometheus)
 	getTokensHandler := app.NewGetTokensHandler(transactionProvider, prometheusPrometheus)
 	receivedEventPubSub := pubsub.NewReceivedEventPubSub()
 	getEventsHandler := app.NewGetEventsHandler(transactionProvider, receivedEventPubSub, prometheusPrometheus)
 	getNotificationsHandler := app.NewGetNotificationsHandler(transactionProvider, prometheusPrometheus)
+	downloaderConfig, err := newDownloaderConfig(configConfig)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return IntegrationService{}, nil, err
+	}
+	replicaRegistry, cleanup3, err := newReplicaRegistry(configConfig, logger)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return IntegrationService{}, nil, err
+	}
+	replicaID := newReplicaID()
+	relayShards := app.NewRelayShards(replicaRegistry, replicaID, logger)
+	downloader := app.NewDownloader(downloaderConfig, diEventWasAlreadySavedCache, transactionProvider, receivedEventPubSub, relayInformationCache, relayShards, logger, prometheusPrometheus)
+	getRelayHealthHandler := app.NewGetRelayHealthHandler(downloader, prometheusPrometheus)
+	getRecentNotificationsHandler := app.NewGetRecentNotificationsHandler(transactionProvider, prometheusPrometheus)
 	queries := app.Queries{
-		GetRelays:        getRelaysHandler,
-		GetPublicKeys:    getPublicKeysHandler,
-		GetTokens:        getTokensHandler,
-		GetEvents:        getEventsHandler,
-		GetNotifications: getNotificationsHandler,
+		GetRelays:              getRelaysHandler,
+		GetPublicKeys:          getPublicKeysHandler,
+		GetTokens:              getTokensHandler,
+		GetEvents:              getEventsHandler,
+		GetNotifications:       getNotificationsHandler,
+		GetRelayHealth:         getRelayHealthHandler,
+		GetRecentNotifications: getRecentNotificationsHandler,
 	}
 	application := app.Application{
 		Commands: commands,
 		Queries:  queries,
 	}
-	server := http.NewServer(configConfig, application, logger)
+	server := http.NewServer(configConfig, application, prometheusPrometheus, logger)
 	metricsServer := http.NewMetricsServer(prometheusPrometheus, configConfig, logger)
-	downloader := app.NewDownloader(memoryEventWasAlreadySavedCache, transactionProvider, receivedEventPubSub, logger, prometheusPrometheus)
+	adminServer := http.NewAdminServer(configConfig, application, logger)
 	externalFollowChangeSubscriber, err := newExternalFollowChangeSubscriber(configConfig, watermillAdapter)
 	if err != nil {
+		cleanup3()
+		cleanup2()
 		cleanup()
 		return IntegrationService{}, nil, err
 	}
-	apnsMock, err := apns.NewAPNSMock(configConfig, logger)
-	if err != nil {
+	followChangePuller := app.NewFollowChangePuller(externalFollowChangeSubscriber, apnsMock, queries, removeInvalidPushTokenHandler, logger, prometheusPrometheus)
+	vanishSubscriber := app.NewVanishSubscriber(deletePublicKeyHandler, logger)
+	receivedEventSubscriber := memorypubsub.NewReceivedEventSubscriber(receivedEventPubSub, saveReceivedEventHandler, logger)
+	subscriber := diStorage.Subscriber
+	eventSavedSubscriber := firestorepubsub.NewEventSavedSubscriber(subscriber, processSavedEventHandler, prometheusPrometheus, logger)
+	service := NewService(application, server, metricsServer, adminServer, downloader, followChangePuller, vanishSubscriber, receivedEventSubscriber, externalFollowChangeSubscriber, eventSavedSubscriber, diEventWasAlreadySavedCache)
+	integrationService := IntegrationService{
+		Service:  service,
+		MockAPNS: apnsMock,
+	}
+	return integrationService, func() {
+		cleanup3()
+		cleanup2()
 		cleanup()
-		return IntegrationService{}, nil, err
+	}, nil
+}
+
+func buildFirestoreStorage(contextContext context.Context, configConfig config.Config, loggerAdapter watermill.LoggerAdapter, logger logging.Logger) (storage, func(), error) {
+	client, cleanup, err := newFirestoreClient(contextContext, configConfig, logger)
+	if err != nil {
+		return storage{}, nil, err
 	}
-	followChangePuller := app.NewFollowChangePuller(externalFollowChangeSubscriber, apnsMock, queries, logger, prometheusPrometheus)
-	vanishSubscriber := app.NewVanishSubscriber(transactionProvider, queries, logger)
-	receivedEventSubscriber := memorypubsub.NewReceivedEventSubscriber(receivedEventPubSub, saveReceivedEventHandler, logger)
-	subscriber, err := firestore.NewWatermillSubscriber(client, watermillAdapter)
+	diBuildTransactionFirestoreAdaptersDependencies := buildTransactionFirestoreAdaptersDependencies{
+		LoggerAdapter: loggerAdapter,
+	}
+	adaptersFactoryFn := newAdaptersFactoryFn(diBuildTransactionFirestoreAdaptersDependencies)
+	transactionProvider := firestore.NewTransactionProvider(client, adaptersFactoryFn)
+	subscriber, err := firestore.NewWatermillSubscriber(client, loggerAdapter)
 	if err != nil {
 		cleanup()
-		return IntegrationService{}, nil, err
+		return storage{}, nil, err
 	}
-	generator := notifications.NewGenerator(logger)
-	externalEventPublisher, err := newExternalEventPublisher(configConfig, watermillAdapter)
+	diStorage := storage{
+		TransactionProvider: transactionProvider,
+		Subscriber:          subscriber,
+	}
+	return diStorage, func() {
+		cleanup()
+	}, nil
+}
+
+func buildPostgresStorage(contextContext context.Context, configConfig config.Config, logger logging.Logger) (storage, func(), error) {
+	db, cleanup, err := newPostgresDB(contextContext, configConfig, logger)
 	if err != nil {
+		return storage{}, nil, err
+	}
+	adaptersFactoryFn := newPostgresAdaptersFactoryFn()
+	transactionProvider := postgres.NewTransactionProvider(db, adaptersFactoryFn, logger)
+	subscriber := postgres.NewSubscriber(db, logger)
+	diStorage := storage{
+		TransactionProvider: transactionProvider,
+		Subscriber:          subscriber,
+	}
+	return diStorage, func() {
 		cleanup()
-		return IntegrationService{}, nil, err
+	}, nil
+}
+
+func buildBoltStorage(configConfig config.Config, logger logging.Logger) (storage, func(), error) {
+	db, cleanup, err := newBoltDB(configConfig, logger)
+	if err != nil {
+		return storage{}, nil, err
 	}
-	processSavedEventHandler := app.NewProcessSavedEventHandler(transactionProvider, generator, apnsMock, logger, prometheusPrometheus, externalEventPublisher)
-	eventSavedSubscriber := firestorepubsub.NewEventSavedSubscriber(subscriber, processSavedEventHandler, prometheusPrometheus, logger)
-	service := NewService(application, server, metricsServer, downloader, followChangePuller, vanishSubscriber, receivedEventSubscriber, externalFollowChangeSubscriber, eventSavedSubscriber, memoryEventWasAlreadySavedCache)
-	integrationService := IntegrationService{
-		Service:  service,
-		MockAPNS: apnsMock,
+	subscriber := bolt.NewSubscriber(db, logger)
+	adaptersFactoryFn := newBoltAdaptersFactoryFn(subscriber)
+	transactionProvider := bolt.NewTransactionProvider(db, adaptersFactoryFn)
+	diStorage := storage{
+		TransactionProvider: transactionProvider,
+		Subscriber:          subscriber,
 	}
-	return integrationService, func() {
+	return diStorage, func() {
 		cleanup()
 	}, nil
 }
@@ -196,6 +334,7 @@ func buildTransactionFirestoreAdapters(client *firestore2.Client, tx *firestore2
 	registrationRepository := firestore.NewRegistrationRepository(client, tx, relayRepository, publicKeyRepository)
 	tagRepository := firestore.NewTagRepository(client, tx)
 	eventRepository := firestore.NewEventRepository(client, tx, relayRepository, tagRepository)
+	muteListRepository := firestore.NewMuteListRepository(client, tx)
 	loggerAdapter := deps.LoggerAdapter
 	publisher, err := firestore.NewWatermillPublisher(client, loggerAdapter)
 	if err != nil {
@@ -208,11 +347,52 @@ func buildTransactionFirestoreAdapters(client *firestore2.Client, tx *firestore2
 		PublicKeys:    publicKeyRepository,
 		Events:        eventRepository,
 		Tags:          tagRepository,
+		MuteLists:     muteListRepository,
 		Publisher:     firestorePublisher,
 	}
 	return appAdapters, nil
 }
 
+func buildTransactionPostgresAdapters(tx *sql.Tx) (app.Adapters, error) {
+	relayRepository := postgres.NewRelayRepository(tx)
+	publicKeyRepository := postgres.NewPublicKeyRepository(tx)
+	registrationRepository := postgres.NewRegistrationRepository(relayRepository, publicKeyRepository)
+	eventRepository := postgres.NewEventRepository(tx)
+	tagRepository := postgres.NewTagRepository(tx)
+	muteListRepository := postgres.NewMuteListRepository(tx)
+	publisher := postgres.NewPublisher(tx)
+	appAdapters := app.Adapters{
+		Registrations: registrationRepository,
+		Relays:        relayRepository,
+		PublicKeys:    publicKeyRepository,
+		Events:        eventRepository,
+		Tags:          tagRepository,
+		MuteLists:     muteListRepository,
+		Publisher:     publisher,
+	}
+	return appAdapters, nil
+}
+
+func buildTransactionBoltAdapters(tx *bbolt.Tx, subscriber *bolt.Subscriber) (app.Adapters, error) {
+	relayRepository := bolt.NewRelayRepository(tx)
+	publicKeyRepository := bolt.NewPublicKeyRepository(tx)
+	registrationRepository := bolt.NewRegistrationRepository(relayRepository, publicKeyRepository)
+	eventRepository := bolt.NewEventRepository(tx)
+	tagRepository := bolt.NewTagRepository(tx)
+	muteListRepository := bolt.NewMuteListRepository(tx)
+	publisher := bolt.NewPublisher(tx, subscriber)
+	appAdapters := app.Adapters{
+		Registrations: registrationRepository,
+		Relays:        relayRepository,
+		PublicKeys:    publicKeyRepository,
+		Events:        eventRepository,
+		Tags:          tagRepository,
+		MuteLists:     muteListRepository,
+		Publisher:     publisher,
+	}
+	return appAdapters, nil
+}
+
 // wire.go:
 
 type IntegrationService struct {
@@ -225,7 +405,10 @@ type buildTransactionFirestoreAdaptersDependencies struct {
 	LoggerAdapter watermill.LoggerAdapter
 }
 
-var downloaderSet = wire.NewSet(app.NewDownloader)
+var downloaderSet = wire.NewSet(app.NewDownloader, wire.Bind(new(app.RelayHealthProvider), new(*app.Downloader)), app.NewRelayShards, app.NewRelayInformationCache, newReplicaRegistry,
+	newReplicaID,
+	newDownloaderConfig,
+)
 
 var followChangePullerSet = wire.NewSet(app.NewFollowChangePuller)
 
@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 
+	// the image doesn't contain timezone data which is needed for quiet hours
+	_ "time/tzdata"
+
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/cmd/notification-service/di"
 	configadapters "github.com/planetary-social/go-notification-service/service/adapters/config"
@@ -0,0 +1,172 @@
+//go:build test_integration
+
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdmin_RequestsWithoutTokenAreRejected(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	for _, token := range []string{"", "invalid-token"} {
+		statusCode, _ := adminRequestWithToken(ctx, t, config, http.MethodGet, "/relays", token)
+		require.Equal(t, http.StatusUnauthorized, statusCode)
+	}
+}
+
+func TestAdmin_ListRelays(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	statusCode, body := adminRequest(ctx, t, config, http.MethodGet, "/relays")
+	require.Equal(t, http.StatusOK, statusCode)
+
+	var relays []map[string]any
+	err := json.Unmarshal(body, &relays)
+	require.NoError(t, err)
+}
+
+func TestAdmin_LookUpAndDeletePublicKey(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	publicKey, secretKey := fixtures.SomeKeyPair()
+	npub, err := nip19.EncodePublicKey(publicKey.Hex())
+	require.NoError(t, err)
+
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	event := registrationEvent(t, secretKey)
+	err = conn.WriteJSON(nostr.EventEnvelope{Event: event})
+	require.NoError(t, err)
+	requireMessage(t, conn, &nostr.OKEnvelope{EventID: event.ID, OK: true})
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		statusCode, body := adminRequest(ctx, t, config, http.MethodGet, "/tokens?npub="+npub)
+		assert.Equal(c, http.StatusOK, statusCode)
+
+		var tokens []map[string]any
+		if assert.NoError(c, json.Unmarshal(body, &tokens)) {
+			assert.Len(c, tokens, 1)
+		}
+	}, durationTimeout, durationTick)
+
+	statusCode, body := adminRequest(ctx, t, config, http.MethodGet, "/notifications?npub="+npub)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.JSONEq(t, `[]`, string(body))
+
+	statusCode, _ = adminRequest(ctx, t, config, http.MethodPost, "/delete-public-key?npub="+npub)
+	require.Equal(t, http.StatusNoContent, statusCode)
+
+	statusCode, body = adminRequest(ctx, t, config, http.MethodGet, "/tokens?npub="+npub)
+	require.Equal(t, http.StatusOK, statusCode)
+	require.JSONEq(t, `[]`, string(body))
+}
+
+func TestAdmin_InvalidRequestsAreRejected(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	testCases := []struct {
+		Name   string
+		Method string
+		Path   string
+
+		ExpectedStatusCode int
+	}{
+		{
+			Name:               "missing_npub",
+			Method:             http.MethodGet,
+			Path:               "/tokens",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:               "invalid_npub",
+			Method:             http.MethodGet,
+			Path:               "/tokens?npub=npub1invalid",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:               "invalid_event_id",
+			Method:             http.MethodPost,
+			Path:               "/reprocess-event?id=invalid",
+			ExpectedStatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:               "unknown_event",
+			Method:             http.MethodPost,
+			Path:               "/reprocess-event?id=" + fixtures.SomeEventID().Hex(),
+			ExpectedStatusCode: http.StatusNotFound,
+		},
+		{
+			Name:               "wrong_method",
+			Method:             http.MethodGet,
+			Path:               "/delete-public-key",
+			ExpectedStatusCode: http.StatusMethodNotAllowed,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			statusCode, _ := adminRequest(ctx, t, config, testCase.Method, testCase.Path)
+			require.Equal(t, testCase.ExpectedStatusCode, statusCode)
+		})
+	}
+}
+
+func adminRequest(ctx context.Context, tb testing.TB, config config.Config, method, path string) (int, []byte) {
+	return adminRequestWithToken(ctx, tb, config, method, path, config.AdminToken())
+}
+
+// adminRequestWithToken retries until the admin server starts listening.
+func adminRequestWithToken(ctx context.Context, tb testing.TB, config config.Config, method, path, token string) (int, []byte) {
+	addr := config.AdminListenAddress()
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+
+	var statusCode int
+	var body []byte
+
+	require.EventuallyWithT(tb, func(c *assert.CollectT) {
+		req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("http://%s%s", addr, path), nil)
+		if !assert.NoError(c, err) {
+			return
+		}
+
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(c, err) {
+			return
+		}
+		defer resp.Body.Close()
+
+		b, err := io.ReadAll(resp.Body)
+		if !assert.NoError(c, err) {
+			return
+		}
+
+		statusCode = resp.StatusCode
+		body = b
+	}, durationTimeout, durationTick)
+
+	return statusCode, body
+}
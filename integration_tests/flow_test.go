@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -27,6 +29,10 @@ import (
 const (
 	durationTimeout = 1 * time.Second
 	durationTick    = 100 * time.Millisecond
+
+	firestoreEmulatorHostEnv = "FIRESTORE_EMULATOR_HOST"
+
+	adminToken = "some-admin-token"
 )
 
 func TestFlow(t *testing.T) {
@@ -34,7 +40,7 @@ func TestFlow(t *testing.T) {
 	config, service := createService(ctx, t)
 
 	publicKey, privateKeyHex := fixtures.SomeKeyPair()
-	token := fixtures.SomeAPNSToken()
+	token := fixtures.SomeAPNSPushToken()
 
 	env := testEnvironment{
 		config:            config,
@@ -42,10 +48,12 @@ func TestFlow(t *testing.T) {
 		registerPublicKey: publicKey,
 		registerSecretKey: privateKeyHex,
 		token:             token,
+		relayAddress:      fixtures.SomeRelayAddress(),
 	}
 
 	testAddRegistration(t, ctx, env)
 	testIngestEventAndSendOutNotifications(t, ctx, env)
+	testRemoveRegistration(t, ctx, env)
 }
 
 type testEnvironment struct {
@@ -54,16 +62,17 @@ type testEnvironment struct {
 
 	registerPublicKey domain.PublicKey
 	registerSecretKey string
-	token             domain.APNSToken
+	token             domain.PushToken
+	relayAddress      domain.RelayAddress
 }
 
 func testAddRegistration(t *testing.T, ctx context.Context, env testEnvironment) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	conn := createClient(ctx, t, env.config)
+	conn := createAuthenticatedClient(ctx, t, env.config, env.registerSecretKey)
 
-	relayAddress := fixtures.SomeRelayAddress()
+	relayAddress := env.relayAddress
 
 	event := nostr.Event{
 		CreatedAt: nostr.Now(),
@@ -82,7 +91,7 @@ func testAddRegistration(t *testing.T, ctx context.Context, env testEnvironment)
 `,
 			env.registerPublicKey.Hex(),
 			relayAddress.String(),
-			env.token.Hex(),
+			env.token.Token(),
 		),
 	}
 
@@ -100,10 +109,17 @@ func testAddRegistration(t *testing.T, ctx context.Context, env testEnvironment)
 	err = conn.WriteMessage(websocket.TextMessage, j)
 	require.NoError(t, err)
 
+	requireMessage(t, conn, &nostr.OKEnvelope{EventID: event.ID, OK: true})
+
 	require.EventuallyWithT(t, func(c *assert.CollectT) {
 		relays, err := env.service.Service.App().Queries.GetRelays.Handle(ctx)
 		assert.NoError(c, err)
-		assert.Contains(c, relays, relayAddress)
+
+		var addresses []domain.RelayAddress
+		for _, relay := range relays {
+			addresses = append(addresses, relay.Address())
+		}
+		assert.Contains(c, addresses, relayAddress)
 	}, durationTimeout, durationTick)
 
 	require.EventuallyWithT(t, func(c *assert.CollectT) {
@@ -115,7 +131,62 @@ func testAddRegistration(t *testing.T, ctx context.Context, env testEnvironment)
 	require.EventuallyWithT(t, func(c *assert.CollectT) {
 		tokens, err := env.service.Service.App().Queries.GetTokens.Handle(ctx, env.registerPublicKey)
 		assert.NoError(c, err)
-		assert.Contains(c, tokens, env.token)
+
+		var pushTokens []domain.PushToken
+		for _, token := range tokens {
+			pushTokens = append(pushTokens, token.PushToken())
+		}
+		assert.Contains(c, pushTokens, env.token)
+	}, durationTimeout, durationTick)
+}
+
+func testRemoveRegistration(t *testing.T, ctx context.Context, env testEnvironment) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn := createAuthenticatedClient(ctx, t, env.config, env.registerSecretKey)
+
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindUnregistration.Int(),
+		Tags:      nostr.Tags{},
+		Content: fmt.Sprintf(`
+{
+  "publicKey": "%s",
+  "apnsToken": "%s"
+}
+`,
+			env.registerPublicKey.Hex(),
+			env.token.Token(),
+		),
+	}
+
+	err := event.Sign(env.registerSecretKey)
+	require.NoError(t, err)
+
+	envelope := nostr.EventEnvelope{
+		SubscriptionID: nil,
+		Event:          event,
+	}
+
+	j, err := envelope.MarshalJSON()
+	require.NoError(t, err)
+
+	err = conn.WriteMessage(websocket.TextMessage, j)
+	require.NoError(t, err)
+
+	requireMessage(t, conn, &nostr.OKEnvelope{EventID: event.ID, OK: true})
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		tokens, err := env.service.Service.App().Queries.GetTokens.Handle(ctx, env.registerPublicKey)
+		assert.NoError(c, err)
+		assert.Empty(c, tokens)
+	}, durationTimeout, durationTick)
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		publicKeys, err := env.service.Service.App().Queries.GetPublicKeys.Handle(ctx, env.relayAddress)
+		assert.NoError(c, err)
+		assert.NotContains(c, publicKeys, env.registerPublicKey)
 	}, durationTimeout, durationTick)
 }
 
@@ -147,7 +218,7 @@ func testIngestEventAndSendOutNotifications(t *testing.T, ctx context.Context, e
 	// event is eventually available through websockets
 	require.EventuallyWithT(t, func(c *assert.CollectT) {
 		someSubscriptionId := "some-subscription-id"
-		client := createClient(ctx, t, env.config)
+		client := createAuthenticatedClient(ctx, t, env.config, env.registerSecretKey)
 
 		since := nostr.Timestamp(time.Now().Add(-1 * time.Hour).Unix())
 
@@ -210,8 +281,8 @@ func testIngestEventAndSendOutNotifications(t *testing.T, ctx context.Context, e
 		assert.Greater(t, len(env.service.MockAPNS.SentNotifications()), 0)
 
 		for _, notification := range env.service.MockAPNS.SentNotifications() {
-			assert.Equal(t, env.token, notification.APNSToken())
-			assert.Equal(t, `{"aps":{"content-available":1}}`, string(notification.Payload()))
+			assert.Equal(t, env.token, notification.PushToken())
+			assert.Equal(t, `{"aps":{"content-available":1},"type":"mention"}`, string(notification.Payload()))
 
 		}
 	}, durationTimeout, durationTick)
@@ -226,18 +297,72 @@ func testIngestEventAndSendOutNotifications(t *testing.T, ctx context.Context, e
 }
 
 func createClient(ctx context.Context, tb testing.TB, config config.Config) *websocket.Conn {
-	addr := config.NostrListenAddress()
-	if strings.HasPrefix(addr, ":") {
-		addr = "localhost" + addr
-	}
-	addr = fmt.Sprintf("ws://%s", addr)
+	addr := fmt.Sprintf("ws://%s", listenAddress(config))
 
 	conn, _, err := websocket.DefaultDialer.DialContext(ctx, addr, nil)
 	require.NoError(tb, err)
 	return conn
 }
 
+// createAuthenticatedClient responds to the authentication challenge, see
+// NIP-42.
+func createAuthenticatedClient(ctx context.Context, tb testing.TB, config config.Config, secretKeyHex string) *websocket.Conn {
+	conn := createClient(ctx, tb, config)
+
+	event := authenticationEvent(tb, config, readChallenge(tb, conn), secretKeyHex)
+
+	err := conn.WriteJSON(nostr.AuthEnvelope{Event: event})
+	require.NoError(tb, err)
+
+	requireMessage(tb, conn, &nostr.OKEnvelope{EventID: event.ID, OK: true})
+	return conn
+}
+
+func authenticationEvent(tb testing.TB, config config.Config, challenge string, secretKeyHex string) nostr.Event {
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindClientAuthentication.Int(),
+		Tags: nostr.Tags{
+			{"relay", fmt.Sprintf("ws://%s", listenAddress(config))},
+			{"challenge", challenge},
+		},
+	}
+
+	err := event.Sign(secretKeyHex)
+	require.NoError(tb, err)
+
+	return event
+}
+
+// readChallenge reads the authentication challenge which is sent to every
+// client after connecting.
+func readChallenge(tb testing.TB, conn *websocket.Conn) string {
+	_, msg, err := conn.ReadMessage()
+	require.NoError(tb, err)
+
+	envelope, ok := nostr.ParseMessage(msg).(*nostr.AuthEnvelope)
+	require.True(tb, ok)
+	require.NotNil(tb, envelope.Challenge)
+	return *envelope.Challenge
+}
+
+func requireMessage(tb testing.TB, conn *websocket.Conn, expected nostr.Envelope) {
+	_, msg, err := conn.ReadMessage()
+	require.NoError(tb, err)
+	require.Equal(tb, expected, nostr.ParseMessage(msg))
+}
+
+func listenAddress(config config.Config) string {
+	addr := config.NostrListenAddress()
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return addr
+}
+
 func createService(ctx context.Context, tb testing.TB) (config.Config, di.IntegrationService) {
+	storageBackend, boltPath := selectStorageBackend(tb)
+
 	config, err := config.NewConfig(
 		fmt.Sprintf(":%d", 8000+rand.Int()%1000),
 		fmt.Sprintf(":%d", 8000+rand.Int()%1000),
@@ -248,6 +373,35 @@ func createService(ctx context.Context, tb testing.TB) (config.Config, di.Integr
 		"someAPNSCertPassword",
 		config.EnvironmentDevelopment,
 		logging.LevelTrace,
+		false,
+		"",
+		nil,
+		false,
+		"",
+		nil,
+		false,
+		"",
+		"",
+		"",
+		storageBackend,
+		"",
+		boltPath,
+		nil,
+		config.RateLimit{},
+		config.RateLimit{},
+		config.RateLimit{},
+		nil,
+		0,
+		0,
+		fmt.Sprintf(":%d", 8000+rand.Int()%1000),
+		adminToken,
+		config.EventCacheBackendMemory,
+		0,
+		"",
+		config.ReplicaRegistryBackendMemory,
+		0,
+		0,
+		0,
 	)
 	require.NoError(tb, err)
 
@@ -271,5 +425,21 @@ func createService(ctx context.Context, tb testing.TB) (config.Config, di.Integr
 		terminatedCh <- service.Service.Run(runCtx)
 	}()
 
+	require.EventuallyWithT(tb, func(c *assert.CollectT) {
+		conn, err := net.Dial("tcp", listenAddress(config))
+		if assert.NoError(c, err) {
+			assert.NoError(c, conn.Close())
+		}
+	}, durationTimeout, durationTick)
+
 	return config, service
 }
+
+// selectStorageBackend uses Firestore if the emulator is available and falls
+// back to the embedded database otherwise.
+func selectStorageBackend(tb testing.TB) (config.StorageBackend, string) {
+	if os.Getenv(firestoreEmulatorHostEnv) != "" {
+		return config.StorageBackendFirestore, ""
+	}
+	return config.StorageBackendBolt, filepath.Join(tb.TempDir(), "notifications.db")
+}
@@ -0,0 +1,118 @@
+//go:build test_integration
+
+package integration_tests
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/cmd/notification-service/di"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRemoveRegistration_RemovesPublicKeyFromRelays uses a collection group
+// query when running against the Firestore emulator.
+func TestRemoveRegistration_RemovesPublicKeyFromRelays(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, service := createService(ctx, t)
+
+	publicKey, secretKey := fixtures.SomeKeyPair()
+	otherPublicKey, otherSecretKey := fixtures.SomeKeyPair()
+	token := fixtures.SomeAPNSPushToken()
+	otherToken := fixtures.SomeAPNSPushToken()
+	relay1 := fixtures.SomeRelayAddress()
+	relay2 := fixtures.SomeRelayAddress()
+
+	// registrations are accepted regardless of their kind
+	registrationKind := domain.MustNewEventKind(12345)
+
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+	otherConn := createAuthenticatedClient(ctx, t, config, otherSecretKey)
+
+	sendSignedEvent(t, conn, secretKey, registrationKind, map[string]any{
+		"publicKey": publicKey.Hex(),
+		"relays":    relayTransports(relay1, relay2),
+		"apnsToken": token.Token(),
+	})
+	sendSignedEvent(t, conn, secretKey, registrationKind, map[string]any{
+		"publicKey": publicKey.Hex(),
+		"relays":    relayTransports(relay1, relay2),
+		"apnsToken": otherToken.Token(),
+	})
+	sendSignedEvent(t, otherConn, otherSecretKey, registrationKind, map[string]any{
+		"publicKey": otherPublicKey.Hex(),
+		"relays":    relayTransports(relay1, relay2),
+		"apnsToken": fixtures.SomeAPNSPushToken().Token(),
+	})
+
+	requirePublicKeysEventually(t, ctx, service, relay1, []domain.PublicKey{publicKey, otherPublicKey}, nil)
+	requirePublicKeysEventually(t, ctx, service, relay2, []domain.PublicKey{publicKey, otherPublicKey}, nil)
+
+	// other tokens remain so only the listed relays are affected
+	sendSignedEvent(t, conn, secretKey, domain.EventKindUnregistration, map[string]any{
+		"publicKey": publicKey.Hex(),
+		"relays":    relayTransports(relay1),
+		"apnsToken": token.Token(),
+	})
+
+	requirePublicKeysEventually(t, ctx, service, relay1, []domain.PublicKey{otherPublicKey}, []domain.PublicKey{publicKey})
+	requirePublicKeysEventually(t, ctx, service, relay2, []domain.PublicKey{publicKey, otherPublicKey}, nil)
+
+	// no tokens remain so all relays are affected
+	sendSignedEvent(t, conn, secretKey, domain.EventKindUnregistration, map[string]any{
+		"publicKey": publicKey.Hex(),
+		"apnsToken": otherToken.Token(),
+	})
+
+	requirePublicKeysEventually(t, ctx, service, relay1, []domain.PublicKey{otherPublicKey}, []domain.PublicKey{publicKey})
+	requirePublicKeysEventually(t, ctx, service, relay2, []domain.PublicKey{otherPublicKey}, []domain.PublicKey{publicKey})
+}
+
+func sendSignedEvent(tb testing.TB, conn *websocket.Conn, secretKey string, kind domain.EventKind, content map[string]any) {
+	contentJSON, err := json.Marshal(content)
+	require.NoError(tb, err)
+
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      kind.Int(),
+		Tags:      nostr.Tags{},
+		Content:   string(contentJSON),
+	}
+
+	err = event.Sign(secretKey)
+	require.NoError(tb, err)
+
+	err = conn.WriteJSON(nostr.EventEnvelope{Event: event})
+	require.NoError(tb, err)
+
+	requireMessage(tb, conn, &nostr.OKEnvelope{EventID: event.ID, OK: true})
+}
+
+func relayTransports(addresses ...domain.RelayAddress) []map[string]string {
+	var result []map[string]string
+	for _, address := range addresses {
+		result = append(result, map[string]string{"address": address.String()})
+	}
+	return result
+}
+
+func requirePublicKeysEventually(tb testing.TB, ctx context.Context, service di.IntegrationService, address domain.RelayAddress, contains []domain.PublicKey, notContains []domain.PublicKey) {
+	require.EventuallyWithT(tb, func(c *assert.CollectT) {
+		publicKeys, err := service.Service.App().Queries.GetPublicKeys.Handle(ctx, address)
+		if !assert.NoError(c, err) {
+			return
+		}
+		for _, publicKey := range contains {
+			assert.Contains(c, publicKeys, publicKey)
+		}
+		for _, publicKey := range notContains {
+			assert.NotContains(c, publicKeys, publicKey)
+		}
+	}, durationTimeout, durationTick)
+}
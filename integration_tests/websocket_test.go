@@ -0,0 +1,348 @@
+//go:build test_integration
+
+package integration_tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebsocket_MalformedMessagesAreAnsweredWithNotice(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	conn := createClient(ctx, t, config)
+	readChallenge(t, conn)
+
+	err := conn.WriteMessage(websocket.TextMessage, []byte(`["EVENT", "not an event"]`))
+	require.NoError(t, err)
+
+	notice := nostr.NoticeEnvelope("invalid: failed to parse the message")
+	requireMessage(t, conn, &notice)
+
+	// the connection remains open
+	err = conn.WriteMessage(websocket.TextMessage, []byte(`not even json`))
+	require.NoError(t, err)
+
+	requireMessage(t, conn, &notice)
+}
+
+func TestWebsocket_InvalidAuthenticationIsRejected(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	conn := createClient(ctx, t, config)
+	readChallenge(t, conn)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	event := authenticationEvent(t, config, "some-other-challenge", secretKey)
+
+	err := conn.WriteJSON(nostr.AuthEnvelope{Event: event})
+	require.NoError(t, err)
+
+	requireRejected(t, conn, event.ID, "invalid")
+
+	registration := registrationEvent(t, secretKey)
+
+	err = conn.WriteJSON(nostr.EventEnvelope{Event: registration})
+	require.NoError(t, err)
+
+	requireRejected(t, conn, registration.ID, "auth-required")
+}
+
+func TestWebsocket_RegistrationsRequireAuthentication(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	conn := createClient(ctx, t, config)
+	readChallenge(t, conn)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	event := registrationEvent(t, secretKey)
+
+	err := conn.WriteJSON(nostr.EventEnvelope{Event: event})
+	require.NoError(t, err)
+
+	requireRejected(t, conn, event.ID, "auth-required")
+}
+
+func TestWebsocket_RegistrationsMustComeFromTheAuthenticatedKey(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	_, otherSecretKey := fixtures.SomeKeyPair()
+
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	event := registrationEvent(t, otherSecretKey)
+
+	err := conn.WriteJSON(nostr.EventEnvelope{Event: event})
+	require.NoError(t, err)
+
+	requireRejected(t, conn, event.ID, "restricted")
+}
+
+func TestWebsocket_InvalidRegistrationsAreRejected(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      12345,
+		Tags:      nostr.Tags{},
+		Content:   `{}`,
+	}
+	err := event.Sign(secretKey)
+	require.NoError(t, err)
+
+	err = conn.WriteJSON(nostr.EventEnvelope{Event: event})
+	require.NoError(t, err)
+
+	requireRejected(t, conn, event.ID, "invalid")
+}
+
+func TestWebsocket_TamperedRegistrationsAreRejected(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	tamperedID := registrationEvent(t, secretKey)
+	tamperedID.ID = registrationEvent(t, secretKey).ID
+
+	tamperedSignature := registrationEvent(t, secretKey)
+	tamperedSignature.Sig = registrationEvent(t, secretKey).Sig
+
+	for _, event := range []nostr.Event{tamperedID, tamperedSignature} {
+		err := conn.WriteJSON(nostr.EventEnvelope{Event: event})
+		require.NoError(t, err)
+
+		requireRejected(t, conn, event.ID, "invalid")
+	}
+}
+
+func TestWebsocket_SubscriptionsRequireAuthentication(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	conn := createClient(ctx, t, config)
+	readChallenge(t, conn)
+
+	publicKey, _ := fixtures.SomeKeyPair()
+
+	err := conn.WriteJSON(nostr.ReqEnvelope{
+		SubscriptionID: "some-subscription-id",
+		Filters: nostr.Filters{
+			{
+				Tags: nostr.TagMap{"p": {publicKey.Hex()}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	requireClosed(t, conn, "some-subscription-id", "auth-required")
+}
+
+func TestWebsocket_SubscriptionsAreRestrictedToEventsTaggingTheAuthenticatedKey(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	someoneElse, _ := fixtures.SomeKeyPair()
+
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	err := conn.WriteJSON(nostr.ReqEnvelope{
+		SubscriptionID: "some-subscription-id",
+		Filters: nostr.Filters{
+			{
+				Tags: nostr.TagMap{"p": {someoneElse.Hex()}},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	requireClosed(t, conn, "some-subscription-id", "restricted")
+}
+
+func TestWebsocket_InvalidSubscriptionsAreClosed(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	err := conn.WriteJSON(nostr.ReqEnvelope{
+		SubscriptionID: "some-subscription-id",
+		Filters: nostr.Filters{
+			{
+				IDs: []string{"prefix"},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	requireClosed(t, conn, "some-subscription-id", "invalid")
+}
+
+func TestWebsocket_SubscriptionsWithTooManyFiltersAreClosed(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	publicKey, secretKey := fixtures.SomeKeyPair()
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	var filters nostr.Filters
+	for i := 0; i < config.MaxFiltersPerSubscription()+1; i++ {
+		filters = append(filters, nostr.Filter{
+			Tags: nostr.TagMap{"p": {publicKey.Hex()}},
+		})
+	}
+
+	err := conn.WriteJSON(nostr.ReqEnvelope{
+		SubscriptionID: "some-subscription-id",
+		Filters:        filters,
+	})
+	require.NoError(t, err)
+
+	requireClosed(t, conn, "some-subscription-id", "rate-limited")
+}
+
+func TestWebsocket_NumberOfSubscriptionsIsLimited(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	publicKey, secretKey := fixtures.SomeKeyPair()
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	for i := 0; i < config.MaxSubscriptionsPerConnection()+1; i++ {
+		err := conn.WriteJSON(nostr.ReqEnvelope{
+			SubscriptionID: fmt.Sprintf("subscription-%d", i),
+			Filters: nostr.Filters{
+				{
+					Tags: nostr.TagMap{"p": {publicKey.Hex()}},
+				},
+			},
+		})
+		require.NoError(t, err)
+	}
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		if _, ok := nostr.ParseMessage(msg).(*nostr.EOSEEnvelope); ok {
+			continue
+		}
+
+		var closed []string
+		err = json.Unmarshal(msg, &closed)
+		require.NoError(t, err)
+		require.Equal(t, []string{"CLOSED", fmt.Sprintf("subscription-%d", config.MaxSubscriptionsPerConnection()), "rate-limited: too many concurrent subscriptions"}, closed)
+		return
+	}
+}
+
+func TestWebsocket_EventsAreRateLimited(t *testing.T) {
+	ctx := fixtures.Context(t)
+	config, _ := createService(ctx, t)
+
+	_, secretKey := fixtures.SomeKeyPair()
+	conn := createAuthenticatedClient(ctx, t, config, secretKey)
+
+	// buckets are refilled while the test is running
+	n := 2 * config.PublicKeyRateLimit().Burst()
+
+	var events []nostr.Event
+	for i := 0; i < n; i++ {
+		event := nostr.Event{
+			CreatedAt: nostr.Now(),
+			Kind:      12345,
+			Tags:      nostr.Tags{},
+			Content:   fmt.Sprintf(`{"invalid": %d}`, i),
+		}
+		err := event.Sign(secretKey)
+		require.NoError(t, err)
+
+		events = append(events, event)
+	}
+
+	for _, event := range events {
+		err := conn.WriteJSON(nostr.EventEnvelope{Event: event})
+		require.NoError(t, err)
+	}
+
+	var reasons []string
+	for _, event := range events {
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+
+		envelope, ok := nostr.ParseMessage(msg).(*nostr.OKEnvelope)
+		require.True(t, ok)
+		require.Equal(t, event.ID, envelope.EventID)
+		require.False(t, envelope.OK)
+		reasons = append(reasons, envelope.Reason)
+	}
+
+	for _, reason := range reasons[:config.PublicKeyRateLimit().Burst()] {
+		require.Regexp(t, "^invalid: ", reason)
+	}
+	require.Contains(t, reasons, "rate-limited: slow down")
+}
+
+func registrationEvent(tb testing.TB, secretKeyHex string) nostr.Event {
+	publicKey, err := nostr.GetPublicKey(secretKeyHex)
+	require.NoError(tb, err)
+
+	event := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      12345,
+		Tags:      nostr.Tags{},
+		Content: fmt.Sprintf(
+			`{"publicKey": "%s", "relays": [{"address": "%s"}], "apnsToken": "%s"}`,
+			publicKey,
+			fixtures.SomeRelayAddress().String(),
+			fixtures.SomeAPNSPushToken().Token(),
+		),
+	}
+
+	err = event.Sign(secretKeyHex)
+	require.NoError(tb, err)
+
+	return event
+}
+
+func requireRejected(tb testing.TB, conn *websocket.Conn, eventID string, prefix string) {
+	_, msg, err := conn.ReadMessage()
+	require.NoError(tb, err)
+
+	envelope, ok := nostr.ParseMessage(msg).(*nostr.OKEnvelope)
+	require.True(tb, ok)
+	require.Equal(tb, eventID, envelope.EventID)
+	require.False(tb, envelope.OK)
+	require.Regexp(tb, fmt.Sprintf("^%s: ", prefix), envelope.Reason)
+}
+
+func requireClosed(tb testing.TB, conn *websocket.Conn, subscriptionID string, prefix string) {
+	_, msg, err := conn.ReadMessage()
+	require.NoError(tb, err)
+
+	var closed []string
+	err = json.Unmarshal(msg, &closed)
+	require.NoError(tb, err)
+	require.Len(tb, closed, 3)
+	require.Equal(tb, "CLOSED", closed[0])
+	require.Equal(tb, subscriptionID, closed[1])
+	require.Regexp(tb, fmt.Sprintf("^%s: ", prefix), closed[2])
+}
@@ -2,16 +2,20 @@ package fixtures
 
 import (
 	"context"
+	"crypto/ecdh"
 	cryptorand "crypto/rand"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/planetary-social/go-notification-service/internal"
 	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
 )
 
 func Context(tb testing.TB) context.Context {
@@ -61,6 +65,52 @@ func SomeAPNSToken() domain.APNSToken {
 	return v
 }
 
+func SomeAPNSPushToken() domain.PushToken {
+	return domain.NewAPNSPushToken(SomeAPNSToken())
+}
+
+func SomeFCMPushToken() domain.PushToken {
+	v, err := domain.NewFCMPushToken(fmt.Sprintf("%s:%s", SomeString(), SomeHexBytesOfLen(70)))
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func SomeWebPushSubscription() domain.WebPushSubscription {
+	privateKey, err := ecdh.P256().GenerateKey(cryptorand.Reader)
+	if err != nil {
+		panic(err)
+	}
+
+	auth := make([]byte, 16)
+	if _, err := cryptorand.Read(auth); err != nil {
+		panic(err)
+	}
+
+	v, err := domain.NewWebPushSubscription(
+		fmt.Sprintf("https://push.example.com/%s", SomeHexBytesOfLen(20)),
+		base64.RawURLEncoding.EncodeToString(privateKey.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(auth),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func SomeWebPushPushToken() domain.PushToken {
+	v, err := domain.NewWebPushPushToken(SomeWebPushSubscription())
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func SomeEventID() domain.EventId {
+	return domain.MustNewEventId(SomeHexBytesOfLen(32))
+}
+
 func SomeHexBytesOfLen(l int) string {
 	b := make([]byte, l)
 	n, err := cryptorand.Read(b)
@@ -73,6 +123,147 @@ func SomeHexBytesOfLen(l int) string {
 	return hex.EncodeToString(b)
 }
 
+// ZapReceipt creates a zap receipt published by a random wallet for a zap
+// request signed using the provided key.
+func ZapReceipt(tb testing.TB, senderSecretKeyHex string, recipient domain.PublicKey, bolt11 string) domain.Event {
+	request := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      9734,
+		Tags: nostr.Tags{
+			{"p", recipient.Hex()},
+			{"relays", "wss://example.com"},
+		},
+	}
+
+	err := request.Sign(senderSecretKeyHex)
+	require.NoError(tb, err)
+
+	requestJSON, err := request.MarshalJSON()
+	require.NoError(tb, err)
+
+	receipt := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindZapReceipt.Int(),
+		Tags: nostr.Tags{
+			{"p", recipient.Hex()},
+			{"P", request.PubKey},
+			{"bolt11", bolt11},
+			{"description", string(requestJSON)},
+		},
+	}
+
+	_, walletSecretKeyHex := SomeKeyPair()
+	err = receipt.Sign(walletSecretKeyHex)
+	require.NoError(tb, err)
+
+	event, err := domain.NewEvent(receipt)
+	require.NoError(tb, err)
+
+	return event
+}
+
+// MuteListEvent creates a mute list signed using the provided key.
+func MuteListEvent(tb testing.TB, secretKeyHex string, createdAt time.Time, tags nostr.Tags) domain.Event {
+	libevent := nostr.Event{
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Kind:      domain.EventKindMuteList.Int(),
+		Tags:      tags,
+	}
+
+	err := libevent.Sign(secretKeyHex)
+	require.NoError(tb, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(tb, err)
+
+	return event
+}
+
+// RelayListEvent creates a relay list signed using the provided key.
+func RelayListEvent(tb testing.TB, secretKeyHex string, createdAt time.Time, tags nostr.Tags) domain.Event {
+	libevent := nostr.Event{
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Kind:      domain.EventKindRelayList.Int(),
+		Tags:      tags,
+	}
+
+	err := libevent.Sign(secretKeyHex)
+	require.NoError(tb, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(tb, err)
+
+	return event
+}
+
+// Registration creates a registration of the public key under the provided
+// relay signed using the provided key.
+func Registration(tb testing.TB, secretKeyHex string, relay domain.RelayAddress) domain.Registration {
+	publicKeyHex, err := nostr.GetPublicKey(secretKeyHex)
+	require.NoError(tb, err)
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      12345,
+		Content: fmt.Sprintf(
+			`{"publicKey": "%s", "relays": [{"address": "%s"}], "apnsToken": "%s"}`,
+			publicKeyHex,
+			relay.String(),
+			SomeAPNSToken().Hex(),
+		),
+	}
+
+	err = libevent.Sign(secretKeyHex)
+	require.NoError(tb, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(tb, err)
+
+	registration, err := domain.NewRegistrationFromEvent(event)
+	require.NoError(tb, err)
+
+	return registration
+}
+
+// SomeLibevent creates a text note signed using a random key.
+func SomeLibevent(tb testing.TB) nostr.Event {
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Content:   SomeString(),
+	}
+
+	_, secretKeyHex := SomeKeyPair()
+	err := libevent.Sign(secretKeyHex)
+	require.NoError(tb, err)
+
+	return libevent
+}
+
+// LibeventWithTamperedID creates an event with a valid signature whose id was
+// replaced with an id of a different event.
+func LibeventWithTamperedID(tb testing.TB) nostr.Event {
+	libevent := SomeLibevent(tb)
+	libevent.ID = SomeLibevent(tb).ID
+	return libevent
+}
+
+// LibeventWithTamperedContent creates an event whose content was modified after
+// it was signed.
+func LibeventWithTamperedContent(tb testing.TB) nostr.Event {
+	libevent := SomeLibevent(tb)
+	libevent.Content = SomeString()
+	return libevent
+}
+
+// LibeventWithTamperedSignature creates an event with a correct id whose
+// signature was replaced with a signature of a different event.
+func LibeventWithTamperedSignature(tb testing.TB) nostr.Event {
+	libevent := SomeLibevent(tb)
+	libevent.Sig = SomeLibevent(tb).Sig
+	return libevent
+}
+
 func PublicKeyAndNpub() (domain.PublicKey, string) {
 	pk, _ := SomeKeyPair()
 	npub, _ := nip19.EncodePublicKey(pk.Hex())
@@ -3,12 +3,14 @@ package apns
 import (
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 
 	"github.com/boreq/errors"
 	"github.com/google/uuid"
 	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/config"
 	"github.com/planetary-social/go-notification-service/service/domain"
 	"github.com/planetary-social/go-notification-service/service/domain/notifications"
@@ -59,14 +61,30 @@ func newClient(cfg config.Config) (*apns2.Client, error) {
 }
 
 func (a *APNS) SendNotification(notification notifications.Notification) error {
+	apnsToken, err := notification.PushToken().APNSToken()
+	if err != nil {
+		return errors.Wrap(err, "error getting the apns token")
+	}
+
 	n := &apns2.Notification{}
 	n.PushType = apns2.PushTypeBackground
 	n.ApnsID = notification.UUID().String()
-	n.DeviceToken = notification.APNSToken().Hex()
+	n.DeviceToken = apnsToken.Hex()
 	n.Topic = a.cfg.APNSTopic()
 	n.Payload = notification.Payload()
 	n.Priority = apns2.PriorityLow
 
+	if !notification.Alert().IsZero() {
+		n.PushType = apns2.PushTypeAlert
+	}
+
+	// high priority notifications display alerts, background notifications
+	// can't be sent with high priority
+	if notification.Priority() == notifications.PriorityHigh {
+		n.PushType = apns2.PushTypeAlert
+		n.Priority = apns2.PriorityHigh
+	}
+
 	resp, err := a.client.Push(n)
 	//a.metrics.ReportCallToAPNS(resp.StatusCode, err)
 	if err != nil {
@@ -80,12 +98,13 @@ func (a *APNS) SendNotification(notification notifications.Notification) error {
 		WithField("host", a.client.Host).
 		Message("sent a notification")
 
-	return nil
+	return invalidTokenError(resp)
 }
 
-func (a *APNS) SendFollowChangeNotification(followChange domain.FollowChangeBatch, apnsToken domain.APNSToken) error {
-	if apnsToken.Hex() == "" {
-		return errors.New("invalid APNs token")
+func (a *APNS) SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	apnsToken, err := token.APNSToken()
+	if err != nil {
+		return errors.Wrap(err, "invalid APNs token")
 	}
 	n, err := a.buildFollowChangeNotification(followChange, apnsToken)
 	if err != nil {
@@ -113,12 +132,13 @@ func (a *APNS) SendFollowChangeNotification(followChange domain.FollowChangeBatc
 			Message("failed to send a follow change notification")
 	}
 
-	return nil
+	return invalidTokenError(resp)
 }
 
-func (a *APNS) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, apnsToken domain.APNSToken) error {
-	if apnsToken.Hex() == "" {
-		return errors.New("invalid APNs token")
+func (a *APNS) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	apnsToken, err := token.APNSToken()
+	if err != nil {
+		return errors.Wrap(err, "invalid APNs token")
 	}
 	n, err := a.buildSilentFollowChangeNotification(followChange, apnsToken)
 	if err != nil {
@@ -146,6 +166,15 @@ func (a *APNS) SendSilentFollowChangeNotification(followChange domain.FollowChan
 			Message("failed to send a silent follow change notification")
 	}
 
+	return invalidTokenError(resp)
+}
+
+// invalidTokenError returns an error if APNs reported that the token will never
+// be valid again. Other failures are only logged.
+func invalidTokenError(resp *apns2.Response) error {
+	if resp.StatusCode == http.StatusGone || resp.Reason == apns2.ReasonUnregistered || resp.Reason == apns2.ReasonBadDeviceToken {
+		return app.NewInvalidPushTokenError(resp.Reason)
+	}
 	return nil
 }
 
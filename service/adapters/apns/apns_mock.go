@@ -35,13 +35,13 @@ func (a *APNSMock) SendNotification(notification notifications.Notification) err
 	return nil
 }
 
-func (a *APNSMock) SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.APNSToken) error {
+func (a *APNSMock) SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
 	notification := notifications.Notification{}
 
 	return a.SendNotification(notification)
 }
 
-func (a *APNSMock) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.APNSToken) error {
+func (a *APNSMock) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
 	notification := notifications.Notification{}
 
 	return a.SendNotification(notification)
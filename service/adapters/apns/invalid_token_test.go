@@ -0,0 +1,57 @@
+package apns
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/sideshow/apns2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidTokenError(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Response apns2.Response
+
+		ExpectedInvalidToken bool
+	}{
+		{
+			Name:     "sent",
+			Response: apns2.Response{StatusCode: http.StatusOK},
+		},
+		{
+			Name:                 "unregistered",
+			Response:             apns2.Response{StatusCode: http.StatusGone, Reason: apns2.ReasonUnregistered},
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:                 "bad_device_token",
+			Response:             apns2.Response{StatusCode: http.StatusBadRequest, Reason: apns2.ReasonBadDeviceToken},
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:     "other_bad_request",
+			Response: apns2.Response{StatusCode: http.StatusBadRequest, Reason: apns2.ReasonBadTopic},
+		},
+		{
+			Name:     "too_many_requests",
+			Response: apns2.Response{StatusCode: http.StatusTooManyRequests, Reason: apns2.ReasonTooManyRequests},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := invalidTokenError(&testCase.Response)
+			if !testCase.ExpectedInvalidToken {
+				require.NoError(t, err)
+				return
+			}
+
+			var invalidPushTokenErr *app.InvalidPushTokenError
+			require.True(t, errors.As(err, &invalidPushTokenErr))
+			require.Equal(t, testCase.Response.Reason, invalidPushTokenErr.Reason())
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	fileMode    = 0600
+	openTimeout = 5 * time.Second
+
+	// keySeparator is used to build composite keys. Values joined with it
+	// never contain it.
+	keySeparator = "\x00"
+)
+
+var (
+	bucketRelays               = []byte("relays")
+	bucketRelaysPublicKeys     = []byte("relays_public_keys")
+	bucketPublicKeysPushTokens = []byte("public_keys_push_tokens")
+	bucketEvents               = []byte("events")
+	bucketEventsByPublicKey    = []byte("events_by_public_key")
+	bucketEventsNotifications  = []byte("events_notifications")
+	bucketEventsDeliveries     = []byte("events_deliveries")
+	bucketTags                 = []byte("tags")
+	bucketMuteLists            = []byte("mute_lists")
+	bucketRelayLists           = []byte("relay_lists")
+	bucketPubSub               = []byte("pubsub")
+
+	topLevelBuckets = [][]byte{
+		bucketRelays,
+		bucketRelaysPublicKeys,
+		bucketPublicKeysPushTokens,
+		bucketEvents,
+		bucketEventsByPublicKey,
+		bucketEventsNotifications,
+		bucketEventsDeliveries,
+		bucketTags,
+		bucketMuteLists,
+		bucketRelayLists,
+		bucketPubSub,
+	}
+)
+
+func NewDB(config config.Config) (*bbolt.DB, error) {
+	db, err := bbolt.Open(config.BoltPath(), fileMode, &bbolt.Options{Timeout: openTimeout})
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the database")
+	}
+
+	if err := db.Update(createBuckets); err != nil {
+		if closeErr := db.Close(); closeErr != nil {
+			return nil, errors.Wrapf(err, "error creating buckets (closing the database also failed: %s)", closeErr)
+		}
+		return nil, errors.Wrap(err, "error creating buckets")
+	}
+
+	return db, nil
+}
+
+func createBuckets(tx *bbolt.Tx) error {
+	for _, name := range topLevelBuckets {
+		if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+			return errors.Wrapf(err, "error creating bucket '%s'", string(name))
+		}
+	}
+	return nil
+}
+
+type AdaptersFactoryFn func(*bbolt.Tx) (app.Adapters, error)
+
+// TransactionProvider runs read-write transactions one at a time so they are
+// serializable. Read-only transactions run concurrently with each other and
+// with the read-write transaction.
+type TransactionProvider struct {
+	db *bbolt.DB
+	fn AdaptersFactoryFn
+}
+
+func NewTransactionProvider(db *bbolt.DB, fn AdaptersFactoryFn) *TransactionProvider {
+	return &TransactionProvider{
+		db: db,
+		fn: fn,
+	}
+}
+
+func (t *TransactionProvider) Transact(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	if err := t.db.Update(t.run(ctx, f)); err != nil {
+		return errors.Wrap(err, "transaction returned an error")
+	}
+	return nil
+}
+
+func (t *TransactionProvider) TransactReadOnly(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	if err := t.db.View(t.run(ctx, f)); err != nil {
+		return errors.Wrap(err, "transaction returned an error")
+	}
+	return nil
+}
+
+func (t *TransactionProvider) run(ctx context.Context, f func(context.Context, app.Adapters) error) func(tx *bbolt.Tx) error {
+	return func(tx *bbolt.Tx) error {
+		adapters, err := t.fn(tx)
+		if err != nil {
+			return errors.Wrap(err, "error building the adapters")
+		}
+
+		if err := f(ctx, adapters); err != nil {
+			return errors.Wrap(err, "error calling the provided function")
+		}
+
+		return nil
+	}
+}
+
+// nestedBucket returns a bucket nested in one of the top level buckets or nil
+// if it doesn't exist.
+func nestedBucket(tx *bbolt.Tx, topLevelBucket []byte, key string) *bbolt.Bucket {
+	return tx.Bucket(topLevelBucket).Bucket([]byte(key))
+}
+
+func createNestedBucket(tx *bbolt.Tx, topLevelBucket []byte, key string) (*bbolt.Bucket, error) {
+	return tx.Bucket(topLevelBucket).CreateBucketIfNotExists([]byte(key))
+}
+
+func deleteNestedBucket(tx *bbolt.Tx, topLevelBucket []byte, key string) error {
+	if err := tx.Bucket(topLevelBucket).DeleteBucket([]byte(key)); err != nil && !errors.Is(err, bbolt.ErrBucketNotFound) {
+		return errors.Wrap(err, "error deleting the bucket")
+	}
+	return nil
+}
+
+func putJSON(bucket *bbolt.Bucket, key []byte, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling")
+	}
+	return bucket.Put(key, b)
+}
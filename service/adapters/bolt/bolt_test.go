@@ -0,0 +1,463 @@
+package bolt
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters/pubsub"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/bbolt"
+)
+
+func TestEventRepository_GetEventsMatchesFilters(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	author1, sk1 := fixtures.SomeKeyPair()
+	_, sk2 := fixtures.SomeKeyPair()
+	mention, _ := fixtures.SomeKeyPair()
+
+	older := someEvent(t, sk1, domain.EventKindNote, time.Unix(1000, 0), mention)
+	newer := someEvent(t, sk1, domain.EventKindNote, time.Unix(2000, 0))
+	other := someEvent(t, sk2, domain.EventKindReaction, time.Unix(3000, 0), mention)
+
+	err := adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		for _, event := range []domain.Event{older, newer, other} {
+			if err := adapters.Events.Save(event); err != nil {
+				return err
+			}
+			if err := adapters.Tags.Save(event, event.Tags()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		filter   nostr.Filter
+		expected []domain.Event
+	}{
+		{
+			name:     "ids",
+			filter:   nostr.Filter{IDs: []string{newer.Id().Hex()}},
+			expected: []domain.Event{newer},
+		},
+		{
+			name:     "authors",
+			filter:   nostr.Filter{Authors: []string{author1.Hex()}},
+			expected: []domain.Event{older, newer},
+		},
+		{
+			name:     "authors_with_limit",
+			filter:   nostr.Filter{Authors: []string{author1.Hex()}, Limit: 1},
+			expected: []domain.Event{newer},
+		},
+		{
+			name:     "tags",
+			filter:   nostr.Filter{Tags: nostr.TagMap{"p": []string{mention.Hex()}}},
+			expected: []domain.Event{older, other},
+		},
+		{
+			name:     "tags_and_kinds",
+			filter:   nostr.Filter{Kinds: []int{domain.EventKindReaction.Int()}, Tags: nostr.TagMap{"p": []string{mention.Hex()}}},
+			expected: []domain.Event{other},
+		},
+		{
+			name:     "kinds",
+			filter:   nostr.Filter{Kinds: []int{domain.EventKindNote.Int()}},
+			expected: []domain.Event{older, newer},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			filters, err := domain.NewFilters(nostr.Filters{testCase.filter})
+			require.NoError(t, err)
+
+			var events []domain.Event
+			err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+				for eventOrError := range adapters.Events.GetEvents(ctx, filters) {
+					if err := eventOrError.Err(); err != nil {
+						return err
+					}
+					events = append(events, eventOrError.Event())
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			require.ElementsMatch(t, eventIds(testCase.expected), eventIds(events))
+		})
+	}
+}
+
+func TestEventRepository_DeleteByPublicKeyRemovesEventsAndTags(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	author, sk := fixtures.SomeKeyPair()
+	mention, _ := fixtures.SomeKeyPair()
+	event := someEvent(t, sk, domain.EventKindNote, time.Unix(1000, 0), mention)
+
+	err := adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		if err := adapters.Events.Save(event); err != nil {
+			return err
+		}
+		return adapters.Tags.Save(event, event.Tags())
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		return adapters.Events.DeleteByPublicKey(ctx, author)
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		exists, err := adapters.Events.Exists(ctx, event.Id())
+		require.NoError(t, err)
+		require.False(t, exists)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = adapters.db.View(func(tx *bbolt.Tx) error {
+		ids, err := getEventIdsForTag(tx, domain.MustNewEventTagName("p"), mention.Hex())
+		require.NoError(t, err)
+		require.Empty(t, ids)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestSubscriber_DeliversOnlyMessagesFromCommittedTransactions(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	_, sk := fixtures.SomeKeyPair()
+	committed := someEvent(t, sk, domain.EventKindNote, time.Unix(1000, 0))
+	rolledBack := someEvent(t, sk, domain.EventKindNote, time.Unix(2000, 0))
+
+	ch, err := adapters.subscriber.Subscribe(ctx, pubsub.TopicEventSaved)
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		if err := adapters.Publisher.PublishEventSaved(ctx, rolledBack.Id()); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	require.Error(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		return adapters.Publisher.PublishEventSaved(ctx, committed.Id())
+	})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-ch:
+		require.JSONEq(t, `{"eventId":"`+committed.Id().Hex()+`"}`, string(msg.Payload))
+		msg.Ack()
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+
+	require.Eventually(t, func() bool {
+		n, err := adapters.subscriber.QueueLength(pubsub.TopicEventSaved)
+		return err == nil && n == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestTransactionProvider_ReadOnlyTransactionsDoNotWaitForReadWriteTransactions(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	_, sk := fixtures.SomeKeyPair()
+	event := someEvent(t, sk, domain.EventKindNote, time.Unix(1000, 0))
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	done := make(chan error)
+
+	go func() {
+		done <- adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+			close(started)
+			<-finish
+			return nil
+		})
+	}()
+
+	<-started
+
+	err := adapters.TransactReadOnly(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		exists, err := adapters.Events.Exists(ctx, event.Id())
+		require.NoError(t, err)
+		require.False(t, exists)
+
+		return adapters.Events.Save(event)
+	})
+	require.Error(t, err, "read-only transactions shouldn't be able to write")
+
+	close(finish)
+	require.NoError(t, <-done)
+}
+
+func TestMuteListRepository_SaveGetAndDelete(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	owner, ownerSecretKey := fixtures.SomeKeyPair()
+	muted, _ := fixtures.SomeKeyPair()
+
+	muteList, err := domain.NewMuteList(fixtures.MuteListEvent(t, ownerSecretKey, time.Unix(1000, 0), nostr.Tags{
+		{"p", muted.Hex()},
+	}))
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		_, ok, err := adapters.MuteLists.Get(ctx, owner)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		return adapters.MuteLists.Save(muteList)
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		result, ok, err := adapters.MuteLists.Get(ctx, owner)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, muteList.Event().Id(), result.Event().Id())
+
+		return adapters.MuteLists.DeleteByPublicKey(ctx, owner)
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		_, ok, err := adapters.MuteLists.Get(ctx, owner)
+		require.NoError(t, err)
+		require.False(t, ok)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestRelayRepository_Cursors(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	relay := fixtures.SomeRelayAddress()
+	publicKey, secretKeyHex := fixtures.SomeKeyPair()
+	unregisteredPublicKey, _ := fixtures.SomeKeyPair()
+
+	registration := fixtures.Registration(t, secretKeyHex, relay)
+	cursor := time.Unix(1000, 0).UTC()
+
+	err := adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		require.NoError(t, adapters.Registrations.Save(registration))
+
+		cursors, err := adapters.Relays.GetCursors(ctx, relay)
+		require.NoError(t, err)
+		require.Empty(t, cursors)
+
+		return adapters.Relays.SaveCursors(ctx, relay, map[domain.PublicKey]time.Time{
+			publicKey:             cursor,
+			unregisteredPublicKey: cursor,
+		})
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		// cursors are never moved back and survive registrations being saved again
+		require.NoError(t, adapters.Registrations.Save(registration))
+
+		return adapters.Relays.SaveCursors(ctx, relay, map[domain.PublicKey]time.Time{
+			publicKey: cursor.Add(-time.Hour),
+		})
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		cursors, err := adapters.Relays.GetCursors(ctx, relay)
+		require.NoError(t, err)
+		require.Len(t, cursors, 1)
+		require.True(t, cursor.Equal(cursors[publicKey]))
+
+		publicKeys, err := adapters.Relays.GetPublicKeys(ctx, relay, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, []domain.PublicKey{publicKey}, publicKeys)
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestRelayRepository_RelayLists(t *testing.T) {
+	ctx := fixtures.Context(t)
+	adapters := newTestAdapters(t)
+
+	relay1 := fixtures.SomeRelayAddress()
+	relay2 := fixtures.SomeRelayAddress()
+	relay3 := fixtures.SomeRelayAddress()
+	publicKey, secretKeyHex := fixtures.SomeKeyPair()
+
+	relayList := func(createdAt int64, relays ...domain.RelayAddress) domain.RelayList {
+		var tags nostr.Tags
+		for _, relay := range relays {
+			tags = append(tags, nostr.Tag{"r", relay.String()})
+		}
+		relayList, err := domain.NewRelayList(fixtures.RelayListEvent(t, secretKeyHex, time.Unix(createdAt, 0), tags))
+		require.NoError(t, err)
+		return relayList
+	}
+
+	err := adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		require.NoError(t, adapters.Registrations.Save(fixtures.Registration(t, secretKeyHex, relay1)))
+		return adapters.Relays.SaveRelayList(ctx, relayList(1000, relay1, relay2))
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		require.Equal(t,
+			map[domain.RelayAddress][]domain.RelaySource{
+				relay1: {domain.RelaySourceRegistration, domain.RelaySourceRelayList},
+				relay2: {domain.RelaySourceRelayList},
+			},
+			getRelaySources(t, ctx, adapters),
+		)
+
+		requirePublicKeys(t, ctx, adapters, relay2, publicKey)
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		require.NoError(t, adapters.Relays.SaveRelayList(ctx, relayList(2000, relay1, relay3)))
+		require.NoError(t, adapters.Relays.SaveRelayList(ctx, relayList(500, relay2)))
+
+		// the public key is still listed in the relay list
+		return adapters.Relays.DeletePublicKeyFromRelays(ctx, publicKey, []domain.RelayAddress{relay1})
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		requirePublicKeys(t, ctx, adapters, relay1, publicKey)
+		requirePublicKeys(t, ctx, adapters, relay2)
+		requirePublicKeys(t, ctx, adapters, relay3, publicKey)
+
+		return adapters.Relays.SaveRelayList(ctx, relayList(3000, relay3))
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		requirePublicKeys(t, ctx, adapters, relay1)
+		requirePublicKeys(t, ctx, adapters, relay3, publicKey)
+
+		require.NoError(t, adapters.Relays.DeletePublicKeyFromAllRelays(ctx, publicKey))
+		requirePublicKeys(t, ctx, adapters, relay3)
+
+		// the relay list was deleted so an older one can be saved again
+		return adapters.Relays.SaveRelayList(ctx, relayList(1000, relay2))
+	})
+	require.NoError(t, err)
+
+	err = adapters.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		requirePublicKeys(t, ctx, adapters, relay2, publicKey)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func getRelaySources(tb testing.TB, ctx context.Context, adapters app.Adapters) map[domain.RelayAddress][]domain.RelaySource {
+	relays, err := adapters.Relays.GetRelays(ctx, time.Time{})
+	require.NoError(tb, err)
+
+	result := make(map[domain.RelayAddress][]domain.RelaySource)
+	for _, relay := range relays {
+		result[relay.Address()] = relay.Sources()
+	}
+	return result
+}
+
+func requirePublicKeys(tb testing.TB, ctx context.Context, adapters app.Adapters, relay domain.RelayAddress, expected ...domain.PublicKey) {
+	publicKeys, err := adapters.Relays.GetPublicKeys(ctx, relay, time.Time{})
+	require.NoError(tb, err)
+	require.ElementsMatch(tb, expected, publicKeys)
+}
+
+type testAdapters struct {
+	*TransactionProvider
+	db         *bbolt.DB
+	subscriber *Subscriber
+}
+
+func newTestAdapters(t *testing.T) testAdapters {
+	db, err := bbolt.Open(filepath.Join(t.TempDir(), "test.db"), fileMode, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, db.Close())
+	})
+
+	err = db.Update(createBuckets)
+	require.NoError(t, err)
+
+	subscriber := NewSubscriber(db, logging.NewDevNullLogger())
+
+	transactionProvider := NewTransactionProvider(db, func(tx *bbolt.Tx) (app.Adapters, error) {
+		relays := NewRelayRepository(tx)
+		publicKeys := NewPublicKeyRepository(tx)
+		return app.Adapters{
+			Registrations: NewRegistrationRepository(relays, publicKeys),
+			Relays:        relays,
+			PublicKeys:    publicKeys,
+			Events:        NewEventRepository(tx),
+			Tags:          NewTagRepository(tx),
+			MuteLists:     NewMuteListRepository(tx),
+			Publisher:     NewPublisher(tx, subscriber),
+		}, nil
+	})
+
+	return testAdapters{
+		TransactionProvider: transactionProvider,
+		db:                  db,
+		subscriber:          subscriber,
+	}
+}
+
+func someEvent(t *testing.T, sk string, kind domain.EventKind, createdAt time.Time, mentions ...domain.PublicKey) domain.Event {
+	tags := nostr.Tags{}
+	for _, mention := range mentions {
+		tags = append(tags, nostr.Tag{"p", mention.Hex()})
+	}
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Kind:      kind.Int(),
+		Tags:      tags,
+		Content:   "some content",
+	}
+
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	return event
+}
+
+func eventIds(events []domain.Event) []domain.EventId {
+	var result []domain.EventId
+	for _, event := range events {
+		result = append(result, event.Id())
+	}
+	return result
+}
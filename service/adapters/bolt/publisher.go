@@ -0,0 +1,61 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/adapters/pubsub"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"go.etcd.io/bbolt"
+)
+
+type Publisher struct {
+	tx         *bbolt.Tx
+	subscriber *Subscriber
+}
+
+func NewPublisher(tx *bbolt.Tx, subscriber *Subscriber) *Publisher {
+	return &Publisher{tx: tx, subscriber: subscriber}
+}
+
+func (p *Publisher) PublishEventSaved(ctx context.Context, id domain.EventId) error {
+	payload := pubsub.EventSavedPayload{EventId: id.Hex()}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the payload")
+	}
+
+	return p.publishInTransaction(pubsub.TopicEventSaved, payloadJSON)
+}
+
+// publishInTransaction stores the message so that it is delivered only if the
+// transaction is committed. The subscriber is notified after the commit.
+func (p *Publisher) publishInTransaction(topic string, payload []byte) error {
+	bucket, err := createNestedBucket(p.tx, bucketPubSub, topic)
+	if err != nil {
+		return errors.Wrap(err, "error creating the topic bucket")
+	}
+
+	seq, err := bucket.NextSequence()
+	if err != nil {
+		return errors.Wrap(err, "error getting the next sequence")
+	}
+
+	value := messageTransport{
+		UUID:    watermill.NewULID(),
+		Payload: payload,
+	}
+
+	if err := putJSON(bucket, messageKey(seq), value); err != nil {
+		return errors.Wrap(err, "error saving the message")
+	}
+
+	p.tx.OnCommit(func() {
+		p.subscriber.notify(topic)
+	})
+
+	return nil
+}
@@ -0,0 +1,424 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+	"go.etcd.io/bbolt"
+)
+
+type EventRepository struct {
+	tx *bbolt.Tx
+}
+
+func NewEventRepository(tx *bbolt.Tx) *EventRepository {
+	return &EventRepository{tx: tx}
+}
+
+func (e *EventRepository) Save(event domain.Event) error {
+	if err := e.tx.Bucket(bucketEvents).Put([]byte(event.Id().Hex()), event.Raw()); err != nil {
+		return errors.Wrap(err, "error saving the event")
+	}
+
+	byPublicKey, err := createNestedBucket(e.tx, bucketEventsByPublicKey, event.PubKey().Hex())
+	if err != nil {
+		return errors.Wrap(err, "error creating the public key bucket")
+	}
+
+	if err := byPublicKey.Put([]byte(event.Id().Hex()), nil); err != nil {
+		return errors.Wrap(err, "error saving the event under the public key")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) Exists(ctx context.Context, id domain.EventId) (bool, error) {
+	return e.tx.Bucket(bucketEvents).Get([]byte(id.Hex())) != nil, nil
+}
+
+func (e *EventRepository) Get(ctx context.Context, id domain.EventId) (domain.Event, error) {
+	raw := e.tx.Bucket(bucketEvents).Get([]byte(id.Hex()))
+	if raw == nil {
+		return domain.Event{}, errors.New("event not found")
+	}
+
+	event, err := domain.NewEventFromRaw(raw)
+	if err != nil {
+		return domain.Event{}, errors.Wrap(err, "error creating the event")
+	}
+
+	return event, nil
+}
+
+func (e *EventRepository) SaveNotificationForEvent(notification notifications.Notification) error {
+	createdAt := notification.CreatedAt()
+	if createdAt == nil {
+		return errors.New("new notifications should always have the createdAt value populated")
+	}
+
+	bucket, err := createNestedBucket(e.tx, bucketEventsNotifications, notification.Event().Id().Hex())
+	if err != nil {
+		return errors.Wrap(err, "error creating the notifications bucket")
+	}
+
+	value := notificationTransport{
+		Platform:  notification.PushToken().Platform().String(),
+		Token:     notification.PushToken().Token(),
+		Payload:   notification.Payload(),
+		CreatedAt: *createdAt,
+	}
+
+	if err := putJSON(bucket, []byte(notification.UUID().String()), value); err != nil {
+		return errors.Wrap(err, "error saving the notification")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) GetNotifications(ctx context.Context, id domain.EventId) ([]notifications.Notification, error) {
+	event, err := e.Get(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the event")
+	}
+
+	bucket := nestedBucket(e.tx, bucketEventsNotifications, id.Hex())
+	if bucket == nil {
+		return nil, nil
+	}
+
+	var result []notifications.Notification
+	if err := bucket.ForEach(func(k, v []byte) error {
+		var transport notificationTransport
+		if err := json.Unmarshal(v, &transport); err != nil {
+			return errors.Wrap(err, "error unmarshaling")
+		}
+
+		uuid, err := notifications.NewNotificationUUIDFromString(string(k))
+		if err != nil {
+			return errors.Wrap(err, "error creating an uuid")
+		}
+
+		pushToken, err := readPushToken(transport.Platform, transport.Token)
+		if err != nil {
+			return errors.Wrap(err, "error creating a token")
+		}
+
+		notification, err := notifications.NewNotificationFromHistory(event, uuid, pushToken, transport.Payload, &transport.CreatedAt)
+		if err != nil {
+			return errors.Wrap(err, "error creating a notification")
+		}
+
+		result = append(result, notification)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over notifications")
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].CreatedAt().Before(*result[j].CreatedAt())
+	})
+
+	return result, nil
+}
+
+func (e *EventRepository) ClaimDelivery(delivery notifications.Delivery) error {
+	bucket, err := createNestedBucket(e.tx, bucketEventsDeliveries, delivery.EventId().Hex())
+	if err != nil {
+		return errors.Wrap(err, "error creating the deliveries bucket")
+	}
+
+	if err := putJSON(bucket, deliveryKey(delivery), deliveryTransport{CreatedAt: time.Now()}); err != nil {
+		return errors.Wrap(err, "error saving the delivery")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) MarkDeliverySent(delivery notifications.Delivery) error {
+	bucket := nestedBucket(e.tx, bucketEventsDeliveries, delivery.EventId().Hex())
+	if bucket == nil {
+		return nil
+	}
+
+	v := bucket.Get(deliveryKey(delivery))
+	if v == nil {
+		return nil
+	}
+
+	var transport deliveryTransport
+	if err := json.Unmarshal(v, &transport); err != nil {
+		return errors.Wrap(err, "error unmarshaling the delivery")
+	}
+
+	now := time.Now()
+	transport.SentAt = &now
+
+	if err := putJSON(bucket, deliveryKey(delivery), transport); err != nil {
+		return errors.Wrap(err, "error saving the delivery")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) DeleteDelivery(delivery notifications.Delivery) error {
+	bucket := nestedBucket(e.tx, bucketEventsDeliveries, delivery.EventId().Hex())
+	if bucket == nil {
+		return nil
+	}
+
+	if err := bucket.Delete(deliveryKey(delivery)); err != nil {
+		return errors.Wrap(err, "error deleting the delivery")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) DeliveryExists(ctx context.Context, delivery notifications.Delivery) (bool, error) {
+	bucket := nestedBucket(e.tx, bucketEventsDeliveries, delivery.EventId().Hex())
+	if bucket == nil {
+		return false, nil
+	}
+	return bucket.Get(deliveryKey(delivery)) != nil, nil
+}
+
+// DeleteByPublicKey deletes all events and associated notifications,
+// deliveries and tags for a given public key.
+func (e *EventRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	byPublicKey := nestedBucket(e.tx, bucketEventsByPublicKey, publicKey.Hex())
+	if byPublicKey == nil {
+		return nil
+	}
+
+	var eventIds []domain.EventId
+	if err := byPublicKey.ForEach(func(k, v []byte) error {
+		eventId, err := domain.NewEventId(string(k))
+		if err != nil {
+			return errors.Wrap(err, "error creating the event id")
+		}
+		eventIds = append(eventIds, eventId)
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error iterating over events")
+	}
+
+	for _, eventId := range eventIds {
+		if err := e.delete(ctx, eventId); err != nil {
+			return errors.Wrapf(err, "error deleting event '%s'", eventId.Hex())
+		}
+	}
+
+	if err := deleteNestedBucket(e.tx, bucketEventsByPublicKey, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the public key bucket")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) delete(ctx context.Context, id domain.EventId) error {
+	event, err := e.Get(ctx, id)
+	if err != nil {
+		return errors.Wrap(err, "error getting the event")
+	}
+
+	if err := deleteTags(e.tx, event); err != nil {
+		return errors.Wrap(err, "error deleting tags")
+	}
+
+	if err := deleteNestedBucket(e.tx, bucketEventsNotifications, id.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting notifications")
+	}
+
+	if err := deleteNestedBucket(e.tx, bucketEventsDeliveries, id.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting deliveries")
+	}
+
+	if err := e.tx.Bucket(bucketEvents).Delete([]byte(id.Hex())); err != nil {
+		return errors.Wrap(err, "error deleting the event")
+	}
+
+	return nil
+}
+
+// GetEvents loads all events before returning so that the transaction isn't
+// accessed concurrently.
+func (e *EventRepository) GetEvents(ctx context.Context, filters domain.Filters) <-chan app.EventOrError {
+	ch := make(chan app.EventOrError)
+
+	events, err := e.loadEventsForFilters(ctx, filters)
+	if err != nil {
+		go sendErr(ctx, ch, err)
+		return ch
+	}
+
+	go sendEvents(ctx, ch, events)
+	return ch
+}
+
+func (e *EventRepository) loadEventsForFilters(ctx context.Context, filters domain.Filters) ([]domain.Event, error) {
+	events := make(map[domain.EventId]domain.Event)
+
+	for _, filter := range filters.Filters() {
+		filterEvents, err := e.loadEventsForFilter(ctx, filter)
+		if err != nil {
+			return nil, errors.Wrap(err, "error loading events")
+		}
+
+		for _, event := range filterEvents {
+			events[event.Id()] = event
+		}
+	}
+
+	var result []domain.Event
+	for _, event := range events {
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+func (e *EventRepository) loadEventsForFilter(ctx context.Context, filter domain.Filter) ([]domain.Event, error) {
+	candidates, err := e.candidateEventIds(filter)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting candidate event ids")
+	}
+
+	var result []domain.Event
+	for _, id := range candidates {
+		raw := e.tx.Bucket(bucketEvents).Get([]byte(id.Hex()))
+		if raw == nil {
+			continue
+		}
+
+		event, err := domain.NewEventFromRaw(raw)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the event")
+		}
+
+		if filter.Matches(event) {
+			result = append(result, event)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt().After(result[j].CreatedAt())
+	})
+
+	if limit := filter.Limit(); limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+
+	return result, nil
+}
+
+// candidateEventIds uses the most selective index available for the filter.
+// The returned events still have to be matched against the filter.
+func (e *EventRepository) candidateEventIds(filter domain.Filter) ([]domain.EventId, error) {
+	if ids := filter.Ids(); len(ids) > 0 {
+		return ids, nil
+	}
+
+	if authors := filter.Authors(); len(authors) > 0 {
+		var result []domain.EventId
+		for _, author := range authors {
+			ids, err := e.getEventIdsForPublicKey(author)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting events for public key")
+			}
+			result = append(result, ids...)
+		}
+		return result, nil
+	}
+
+	for name, values := range filter.Tags() {
+		if len(values) == 0 {
+			continue
+		}
+
+		var result []domain.EventId
+		for _, value := range values {
+			ids, err := getEventIdsForTag(e.tx, name, value)
+			if err != nil {
+				return nil, errors.Wrap(err, "error getting events for tag")
+			}
+			result = append(result, ids...)
+		}
+		return result, nil
+	}
+
+	var result []domain.EventId
+	if err := e.tx.Bucket(bucketEvents).ForEach(func(k, v []byte) error {
+		eventId, err := domain.NewEventId(string(k))
+		if err != nil {
+			return errors.Wrap(err, "error creating the event id")
+		}
+		result = append(result, eventId)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over events")
+	}
+	return result, nil
+}
+
+func (e *EventRepository) getEventIdsForPublicKey(publicKey domain.PublicKey) ([]domain.EventId, error) {
+	bucket := nestedBucket(e.tx, bucketEventsByPublicKey, publicKey.Hex())
+	if bucket == nil {
+		return nil, nil
+	}
+
+	var result []domain.EventId
+	if err := bucket.ForEach(func(k, v []byte) error {
+		eventId, err := domain.NewEventId(string(k))
+		if err != nil {
+			return errors.Wrap(err, "error creating the event id")
+		}
+		result = append(result, eventId)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over events")
+	}
+	return result, nil
+}
+
+type notificationTransport struct {
+	Platform  string    `json:"platform"`
+	Token     string    `json:"token"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// SentAt is nil for claimed deliveries and for deliveries saved before
+// deliveries were claimed.
+type deliveryTransport struct {
+	CreatedAt time.Time  `json:"createdAt"`
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+}
+
+func deliveryKey(delivery notifications.Delivery) []byte {
+	return []byte(delivery.Mention().Hex() + keySeparator + string(pushTokenKey(delivery.PushToken())))
+}
+
+func sendEvents(ctx context.Context, ch chan<- app.EventOrError, events []domain.Event) {
+	defer close(ch)
+
+	for _, event := range events {
+		select {
+		case ch <- app.NewEventOrErrorWithEvent(event):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func sendErr(ctx context.Context, ch chan<- app.EventOrError, err error) {
+	defer close(ch)
+
+	select {
+	case ch <- app.NewEventOrErrorWithError(err):
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,50 @@
+package bolt
+
+import (
+	"context"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"go.etcd.io/bbolt"
+)
+
+type MuteListRepository struct {
+	tx *bbolt.Tx
+}
+
+func NewMuteListRepository(tx *bbolt.Tx) *MuteListRepository {
+	return &MuteListRepository{tx: tx}
+}
+
+func (r *MuteListRepository) Save(muteList domain.MuteList) error {
+	if err := r.tx.Bucket(bucketMuteLists).Put([]byte(muteList.Author().Hex()), muteList.Event().Raw()); err != nil {
+		return errors.Wrap(err, "error saving the mute list")
+	}
+	return nil
+}
+
+func (r *MuteListRepository) Get(ctx context.Context, publicKey domain.PublicKey) (domain.MuteList, bool, error) {
+	raw := r.tx.Bucket(bucketMuteLists).Get([]byte(publicKey.Hex()))
+	if raw == nil {
+		return domain.MuteList{}, false, nil
+	}
+
+	event, err := domain.NewEventFromRaw(raw)
+	if err != nil {
+		return domain.MuteList{}, false, errors.Wrap(err, "error creating the event")
+	}
+
+	muteList, err := domain.NewMuteList(event)
+	if err != nil {
+		return domain.MuteList{}, false, errors.Wrap(err, "error creating the mute list")
+	}
+
+	return muteList, true, nil
+}
+
+func (r *MuteListRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	if err := r.tx.Bucket(bucketMuteLists).Delete([]byte(publicKey.Hex())); err != nil {
+		return errors.Wrap(err, "error deleting the mute list")
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"go.etcd.io/bbolt"
+)
+
+type PublicKeyRepository struct {
+	tx *bbolt.Tx
+}
+
+func NewPublicKeyRepository(tx *bbolt.Tx) *PublicKeyRepository {
+	return &PublicKeyRepository{tx: tx}
+}
+
+func (r *PublicKeyRepository) Save(registration domain.Registration) error {
+	tokens, err := createNestedBucket(r.tx, bucketPublicKeysPushTokens, registration.PublicKey().Hex())
+	if err != nil {
+		return errors.Wrap(err, "error creating the tokens bucket")
+	}
+
+	preferences, err := json.Marshal(registration.Preferences())
+	if err != nil {
+		return errors.Wrap(err, "error marshaling preferences")
+	}
+
+	token := registration.PushToken()
+	value := pushTokenTransport{
+		Platform:         token.Platform().String(),
+		Token:            token.Token(),
+		NotificationMode: registration.NotificationMode().String(),
+		Preferences:      preferences,
+		UpdatedTimestamp: time.Now(),
+	}
+
+	if err := putJSON(tokens, pushTokenKey(token), value); err != nil {
+		return errors.Wrap(err, "error saving the token")
+	}
+
+	return nil
+}
+
+// DeleteByPublicKey deletes a public key and all its associated push tokens.
+func (r *PublicKeyRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	if err := deleteNestedBucket(r.tx, bucketPublicKeysPushTokens, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the tokens")
+	}
+	return nil
+}
+
+func (r *PublicKeyRepository) DeletePushToken(ctx context.Context, publicKey domain.PublicKey, token domain.PushToken) error {
+	tokens := nestedBucket(r.tx, bucketPublicKeysPushTokens, publicKey.Hex())
+	if tokens == nil {
+		return nil
+	}
+
+	if err := tokens.Delete(pushTokenKey(token)); err != nil {
+		return errors.Wrap(err, "error deleting the token")
+	}
+
+	return nil
+}
+
+func (r *PublicKeyRepository) GetPushTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.RegisteredPushToken, error) {
+	tokens := nestedBucket(r.tx, bucketPublicKeysPushTokens, publicKey.Hex())
+	if tokens == nil {
+		return nil, nil
+	}
+
+	var result []domain.RegisteredPushToken
+
+	if err := tokens.ForEach(func(k, v []byte) error {
+		var transport pushTokenTransport
+		if err := json.Unmarshal(v, &transport); err != nil {
+			return errors.Wrap(err, "error unmarshaling")
+		}
+
+		if !transport.UpdatedTimestamp.After(savedAfter) {
+			return nil
+		}
+
+		token, err := readPushToken(transport.Platform, transport.Token)
+		if err != nil {
+			return errors.Wrap(err, "error reading the token")
+		}
+
+		notificationMode, err := domain.NewNotificationMode(transport.NotificationMode)
+		if err != nil {
+			return errors.Wrap(err, "error reading the notification mode")
+		}
+
+		var preferences domain.Preferences
+		if len(transport.Preferences) > 0 {
+			if err := json.Unmarshal(transport.Preferences, &preferences); err != nil {
+				return errors.Wrap(err, "error unmarshaling preferences")
+			}
+		}
+
+		registeredPushToken, err := domain.NewRegisteredPushToken(token, notificationMode, preferences)
+		if err != nil {
+			return errors.Wrap(err, "error creating a registered push token")
+		}
+
+		result = append(result, registeredPushToken)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over tokens")
+	}
+
+	return result, nil
+}
+
+type pushTokenTransport struct {
+	Platform         string          `json:"platform"`
+	Token            string          `json:"token"`
+	NotificationMode string          `json:"notificationMode"`
+	Preferences      json.RawMessage `json:"preferences"`
+	UpdatedTimestamp time.Time       `json:"updatedTimestamp"`
+}
+
+func pushTokenKey(token domain.PushToken) []byte {
+	return []byte(token.Platform().String() + keySeparator + token.Token())
+}
+
+func readPushToken(platform, token string) (domain.PushToken, error) {
+	p, err := domain.NewPushTokenPlatform(platform)
+	if err != nil {
+		return domain.PushToken{}, errors.Wrap(err, "error creating the platform")
+	}
+	return domain.NewPushToken(p, token)
+}
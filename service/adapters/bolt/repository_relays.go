@@ -0,0 +1,432 @@
+package bolt
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"go.etcd.io/bbolt"
+)
+
+type RelayRepository struct {
+	tx *bbolt.Tx
+}
+
+func NewRelayRepository(tx *bbolt.Tx) *RelayRepository {
+	return &RelayRepository{tx: tx}
+}
+
+func (r *RelayRepository) Save(registration domain.Registration) error {
+	now := time.Now()
+
+	for _, relayAddress := range registration.Relays() {
+		if err := r.updateRelay(relayAddress, func(v *relayTransport) {
+			v.UpdatedTimestamp = now
+			v.Registered = boolPointer(true)
+		}); err != nil {
+			return errors.Wrap(err, "error saving the relay")
+		}
+
+		if err := r.updatePublicKey(relayAddress, registration.PublicKey(), func(v *relayPublicKeyTransport) {
+			v.UpdatedTimestamp = now
+			v.Registered = boolPointer(true)
+		}); err != nil {
+			return errors.Wrap(err, "error saving the public key")
+		}
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) GetRelays(ctx context.Context, updatedAfter time.Time) ([]app.StoredRelay, error) {
+	var result []app.StoredRelay
+
+	if err := r.tx.Bucket(bucketRelays).ForEach(func(k, v []byte) error {
+		transport, err := readRelay(v)
+		if err != nil {
+			return errors.Wrap(err, "error reading the relay")
+		}
+
+		if !transport.UpdatedTimestamp.After(updatedAfter) {
+			return nil
+		}
+
+		relayAddress, err := domain.NewRelayAddress(string(k))
+		if err != nil {
+			return errors.Wrapf(err, "error creating a relay address from key '%s'", string(k))
+		}
+
+		if !relayAddress.ShouldBeSkipped() {
+			result = append(result, app.NewStoredRelay(relayAddress, relaySources(transport.registered(), transport.InRelayList)))
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over relays")
+	}
+
+	return result, nil
+}
+
+func (r *RelayRepository) GetPublicKeys(ctx context.Context, address domain.RelayAddress, updatedAfter time.Time) ([]domain.PublicKey, error) {
+	publicKeys := nestedBucket(r.tx, bucketRelaysPublicKeys, address.String())
+	if publicKeys == nil {
+		return nil, nil
+	}
+
+	var result []domain.PublicKey
+
+	if err := publicKeys.ForEach(func(k, v []byte) error {
+		transport, err := readRelayPublicKey(v)
+		if err != nil {
+			return errors.Wrap(err, "error reading the public key")
+		}
+
+		if !transport.UpdatedTimestamp.After(updatedAfter) {
+			return nil
+		}
+
+		publicKey, err := domain.NewPublicKeyFromHex(string(k))
+		if err != nil {
+			return errors.Wrap(err, "error creating a public key")
+		}
+
+		result = append(result, publicKey)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over public keys")
+	}
+
+	return result, nil
+}
+
+// DeletePublicKeyFromRelays keeps the public key under the relays which are
+// also listed in the relay list of the public key.
+func (r *RelayRepository) DeletePublicKeyFromRelays(ctx context.Context, publicKey domain.PublicKey, addresses []domain.RelayAddress) error {
+	for _, address := range addresses {
+		if err := r.deletePublicKey(address, publicKey); err != nil {
+			return errors.Wrapf(err, "error deleting the public key from relay '%s'", address.String())
+		}
+	}
+	return nil
+}
+
+func (r *RelayRepository) deletePublicKey(address domain.RelayAddress, publicKey domain.PublicKey) error {
+	publicKeys := nestedBucket(r.tx, bucketRelaysPublicKeys, address.String())
+	if publicKeys == nil {
+		return nil
+	}
+
+	key := []byte(publicKey.Hex())
+
+	transport, err := readRelayPublicKey(publicKeys.Get(key))
+	if err != nil {
+		return errors.Wrap(err, "error reading the public key")
+	}
+
+	if transport.InRelayList {
+		transport.Registered = boolPointer(false)
+		if err := putJSON(publicKeys, key, transport); err != nil {
+			return errors.Wrap(err, "error saving the public key")
+		}
+		return nil
+	}
+
+	if err := publicKeys.Delete(key); err != nil {
+		return errors.Wrap(err, "error deleting the public key")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) DeletePublicKeyFromAllRelays(ctx context.Context, publicKey domain.PublicKey) error {
+	if err := r.tx.Bucket(bucketRelaysPublicKeys).ForEachBucket(func(k []byte) error {
+		if err := r.tx.Bucket(bucketRelaysPublicKeys).Bucket(k).Delete([]byte(publicKey.Hex())); err != nil {
+			return errors.Wrapf(err, "error deleting the public key from relay '%s'", string(k))
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "error iterating over relays")
+	}
+
+	if err := r.tx.Bucket(bucketRelayLists).Delete([]byte(publicKey.Hex())); err != nil {
+		return errors.Wrap(err, "error deleting the relay list")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) GetCursors(ctx context.Context, address domain.RelayAddress) (map[domain.PublicKey]time.Time, error) {
+	result := make(map[domain.PublicKey]time.Time)
+
+	publicKeys := nestedBucket(r.tx, bucketRelaysPublicKeys, address.String())
+	if publicKeys == nil {
+		return result, nil
+	}
+
+	if err := publicKeys.ForEach(func(k, v []byte) error {
+		transport, err := readRelayPublicKey(v)
+		if err != nil {
+			return errors.Wrap(err, "error reading the public key")
+		}
+
+		if transport.Cursor == nil {
+			return nil
+		}
+
+		publicKey, err := domain.NewPublicKeyFromHex(string(k))
+		if err != nil {
+			return errors.Wrap(err, "error creating a public key")
+		}
+
+		result[publicKey] = *transport.Cursor
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over public keys")
+	}
+
+	return result, nil
+}
+
+func (r *RelayRepository) SaveCursors(ctx context.Context, address domain.RelayAddress, cursors map[domain.PublicKey]time.Time) error {
+	publicKeys := nestedBucket(r.tx, bucketRelaysPublicKeys, address.String())
+	if publicKeys == nil {
+		return nil
+	}
+
+	for publicKey, cursor := range cursors {
+		key := []byte(publicKey.Hex())
+
+		v := publicKeys.Get(key)
+		if v == nil {
+			continue
+		}
+
+		transport, err := readRelayPublicKey(v)
+		if err != nil {
+			return errors.Wrap(err, "error reading the public key")
+		}
+
+		if transport.Cursor != nil && !transport.Cursor.Before(cursor) {
+			continue
+		}
+		transport.Cursor = &cursor
+
+		if err := putJSON(publicKeys, key, transport); err != nil {
+			return errors.Wrap(err, "error saving the public key")
+		}
+	}
+
+	return nil
+}
+
+// SaveRelayList remembers the relays of the previous relay list so that the
+// public key can be removed from them without going through all relays.
+func (r *RelayRepository) SaveRelayList(ctx context.Context, relayList domain.RelayList) error {
+	key := []byte(relayList.Author().Hex())
+
+	previous, err := readRelayList(r.tx.Bucket(bucketRelayLists).Get(key))
+	if err != nil {
+		return errors.Wrap(err, "error reading the relay list")
+	}
+
+	if relayList.Event().CreatedAt().Before(previous.CreatedAt) {
+		return nil
+	}
+
+	now := time.Now()
+	current := internal.NewEmptySet[string]()
+
+	for _, relayAddress := range relayList.ReadRelays() {
+		current.Put(relayAddress.String())
+
+		if err := r.updateRelay(relayAddress, func(v *relayTransport) {
+			v.UpdatedTimestamp = now
+			v.InRelayList = true
+			if v.Registered == nil {
+				v.Registered = boolPointer(false)
+			}
+		}); err != nil {
+			return errors.Wrap(err, "error saving the relay")
+		}
+
+		if err := r.updatePublicKey(relayAddress, relayList.Author(), func(v *relayPublicKeyTransport) {
+			v.UpdatedTimestamp = now
+			v.InRelayList = true
+			if v.Registered == nil {
+				v.Registered = boolPointer(false)
+			}
+		}); err != nil {
+			return errors.Wrap(err, "error saving the public key")
+		}
+	}
+
+	for _, address := range previous.Relays {
+		if current.Contains(address) {
+			continue
+		}
+
+		if err := r.removeFromRelayList(address, relayList.Author()); err != nil {
+			return errors.Wrapf(err, "error removing the public key from relay '%s'", address)
+		}
+	}
+
+	if err := putJSON(r.tx.Bucket(bucketRelayLists), key, relayListTransport{
+		CreatedAt: relayList.Event().CreatedAt(),
+		Relays:    current.List(),
+	}); err != nil {
+		return errors.Wrap(err, "error saving the relay list")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) removeFromRelayList(address string, publicKey domain.PublicKey) error {
+	publicKeys := nestedBucket(r.tx, bucketRelaysPublicKeys, address)
+	if publicKeys == nil {
+		return nil
+	}
+
+	key := []byte(publicKey.Hex())
+
+	v := publicKeys.Get(key)
+	if v == nil {
+		return nil
+	}
+
+	transport, err := readRelayPublicKey(v)
+	if err != nil {
+		return errors.Wrap(err, "error reading the public key")
+	}
+
+	if !transport.registered() {
+		if err := publicKeys.Delete(key); err != nil {
+			return errors.Wrap(err, "error deleting the public key")
+		}
+		return nil
+	}
+
+	transport.InRelayList = false
+	if err := putJSON(publicKeys, key, transport); err != nil {
+		return errors.Wrap(err, "error saving the public key")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) updateRelay(address domain.RelayAddress, fn func(v *relayTransport)) error {
+	bucket := r.tx.Bucket(bucketRelays)
+	key := []byte(address.String())
+
+	transport, err := readRelay(bucket.Get(key))
+	if err != nil {
+		return errors.Wrap(err, "error reading the relay")
+	}
+
+	fn(&transport)
+
+	return putJSON(bucket, key, transport)
+}
+
+func (r *RelayRepository) updatePublicKey(address domain.RelayAddress, publicKey domain.PublicKey, fn func(v *relayPublicKeyTransport)) error {
+	publicKeys, err := createNestedBucket(r.tx, bucketRelaysPublicKeys, address.String())
+	if err != nil {
+		return errors.Wrap(err, "error creating the public keys bucket")
+	}
+
+	key := []byte(publicKey.Hex())
+
+	transport, err := readRelayPublicKey(publicKeys.Get(key))
+	if err != nil {
+		return errors.Wrap(err, "error reading the public key")
+	}
+
+	fn(&transport)
+
+	return putJSON(publicKeys, key, transport)
+}
+
+func relaySources(registered, inRelayList bool) []domain.RelaySource {
+	var sources []domain.RelaySource
+	if registered {
+		sources = append(sources, domain.RelaySourceRegistration)
+	}
+	if inRelayList {
+		sources = append(sources, domain.RelaySourceRelayList)
+	}
+	return sources
+}
+
+// Registered is nil for values saved before relay lists were introduced when
+// all values came from registrations.
+type relayTransport struct {
+	UpdatedTimestamp time.Time `json:"updatedTimestamp"`
+	Registered       *bool     `json:"registered,omitempty"`
+	InRelayList      bool      `json:"inRelayList,omitempty"`
+}
+
+func (t relayTransport) registered() bool {
+	return t.Registered == nil || *t.Registered
+}
+
+// readRelay returns a zero value if the relay wasn't saved yet.
+func readRelay(v []byte) (relayTransport, error) {
+	var transport relayTransport
+	if v == nil {
+		return transport, nil
+	}
+	if err := json.Unmarshal(v, &transport); err != nil {
+		return relayTransport{}, errors.Wrap(err, "error unmarshaling")
+	}
+	return transport, nil
+}
+
+// Registered is nil for values saved before relay lists were introduced when
+// all values came from registrations.
+type relayPublicKeyTransport struct {
+	UpdatedTimestamp time.Time  `json:"updatedTimestamp"`
+	Registered       *bool      `json:"registered,omitempty"`
+	InRelayList      bool       `json:"inRelayList,omitempty"`
+	Cursor           *time.Time `json:"cursor,omitempty"`
+}
+
+func (t relayPublicKeyTransport) registered() bool {
+	return t.Registered == nil || *t.Registered
+}
+
+// readRelayPublicKey returns a zero value if the public key wasn't saved yet.
+func readRelayPublicKey(v []byte) (relayPublicKeyTransport, error) {
+	var transport relayPublicKeyTransport
+	if v == nil {
+		return transport, nil
+	}
+	if err := json.Unmarshal(v, &transport); err != nil {
+		return relayPublicKeyTransport{}, errors.Wrap(err, "error unmarshaling")
+	}
+	return transport, nil
+}
+
+type relayListTransport struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Relays    []string  `json:"relays"`
+}
+
+// readRelayList returns a zero value if the relay list wasn't saved yet.
+func readRelayList(v []byte) (relayListTransport, error) {
+	var transport relayListTransport
+	if v == nil {
+		return transport, nil
+	}
+	if err := json.Unmarshal(v, &transport); err != nil {
+		return relayListTransport{}, errors.Wrap(err, "error unmarshaling")
+	}
+	return transport, nil
+}
+
+func boolPointer(v bool) *bool {
+	return &v
+}
@@ -0,0 +1,81 @@
+package bolt
+
+import (
+	"encoding/binary"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"go.etcd.io/bbolt"
+)
+
+type TagRepository struct {
+	tx *bbolt.Tx
+}
+
+func NewTagRepository(tx *bbolt.Tx) *TagRepository {
+	return &TagRepository{tx: tx}
+}
+
+func (r *TagRepository) Save(event domain.Event, tags []domain.EventTag) error {
+	for _, tag := range tags {
+		bucket, err := createNestedBucket(r.tx, bucketTags, tagBucketKey(tag.Name(), tag.FirstValue()))
+		if err != nil {
+			return errors.Wrap(err, "error creating the tag bucket")
+		}
+
+		if err := bucket.Put(tagKey(event), nil); err != nil {
+			return errors.Wrap(err, "error saving the tag")
+		}
+	}
+	return nil
+}
+
+// deleteTags removes the event from the tag index.
+func deleteTags(tx *bbolt.Tx, event domain.Event) error {
+	for _, tag := range event.Tags() {
+		bucket := nestedBucket(tx, bucketTags, tagBucketKey(tag.Name(), tag.FirstValue()))
+		if bucket == nil {
+			continue
+		}
+
+		if err := bucket.Delete(tagKey(event)); err != nil {
+			return errors.Wrap(err, "error deleting the tag")
+		}
+	}
+	return nil
+}
+
+// getEventIdsForTag returns ids of events with the given tag.
+func getEventIdsForTag(tx *bbolt.Tx, name domain.EventTagName, value string) ([]domain.EventId, error) {
+	bucket := nestedBucket(tx, bucketTags, tagBucketKey(name, value))
+	if bucket == nil {
+		return nil, nil
+	}
+
+	var result []domain.EventId
+	if err := bucket.ForEach(func(k, v []byte) error {
+		eventId, err := domain.NewEventId(string(k[createdAtLength:]))
+		if err != nil {
+			return errors.Wrap(err, "error creating the event id")
+		}
+		result = append(result, eventId)
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error iterating over the tag")
+	}
+
+	return result, nil
+}
+
+const createdAtLength = 8
+
+func tagBucketKey(name domain.EventTagName, value string) string {
+	return name.String() + keySeparator + value
+}
+
+// tagKey sorts the events chronologically.
+func tagKey(event domain.Event) []byte {
+	key := make([]byte, createdAtLength)
+	binary.BigEndian.PutUint64(key, uint64(event.CreatedAt().Unix()))
+	return append(key, []byte(event.Id().Hex())...)
+}
@@ -0,0 +1,245 @@
+package bolt
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"go.etcd.io/bbolt"
+)
+
+const (
+	subscriberBatchSize  = 100
+	subscriberRetryDelay = 1 * time.Second
+	subscriberNackDelay  = 10 * time.Second
+)
+
+// Subscriber delivers messages stored by the publisher. Messages which are
+// being processed are tracked in memory as only one process can use the
+// database at a time. Messages which weren't acked before the process was
+// stopped are redelivered after a restart.
+type Subscriber struct {
+	db     *bbolt.DB
+	logger logging.Logger
+
+	lock     sync.Mutex
+	inFlight map[string]map[uint64]struct{}
+	wakeUp   map[string][]chan struct{}
+}
+
+func NewSubscriber(db *bbolt.DB, logger logging.Logger) *Subscriber {
+	return &Subscriber{
+		db:       db,
+		logger:   logger.New("boltSubscriber"),
+		inFlight: make(map[string]map[uint64]struct{}),
+		wakeUp:   make(map[string][]chan struct{}),
+	}
+}
+
+func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	wakeUp := make(chan struct{}, 1)
+
+	s.lock.Lock()
+	s.wakeUp[topic] = append(s.wakeUp[topic], wakeUp)
+	s.lock.Unlock()
+
+	ch := make(chan *message.Message)
+	go s.run(ctx, topic, ch, wakeUp)
+	return ch, nil
+}
+
+func (s *Subscriber) Close() error {
+	return nil
+}
+
+func (s *Subscriber) QueueLength(topic string) (int, error) {
+	var n int
+	if err := s.db.View(func(tx *bbolt.Tx) error {
+		if bucket := nestedBucket(tx, bucketPubSub, topic); bucket != nil {
+			n = bucket.Stats().KeyN
+		}
+		return nil
+	}); err != nil {
+		return 0, errors.Wrap(err, "error counting messages")
+	}
+	return n, nil
+}
+
+func (s *Subscriber) run(ctx context.Context, topic string, ch chan<- *message.Message, wakeUp <-chan struct{}) {
+	defer close(ch)
+	defer s.unsubscribe(topic, wakeUp)
+
+	for {
+		n, err := s.deliverBatch(ctx, topic, ch)
+		if err != nil {
+			s.logger.Error().WithError(err).WithField("topic", topic).Message("error delivering messages")
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			select {
+			case <-time.After(subscriberRetryDelay):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if n == 0 {
+			select {
+			case <-wakeUp:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (s *Subscriber) deliverBatch(ctx context.Context, topic string, ch chan<- *message.Message) (int, error) {
+	messages, err := s.claim(topic)
+	if err != nil {
+		return 0, errors.Wrap(err, "error claiming messages")
+	}
+
+	for i, claimed := range messages {
+		msg := message.NewMessage(claimed.uuid, claimed.payload)
+
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			for _, unsent := range messages[i:] {
+				s.release(topic, unsent.seq)
+			}
+			return 0, ctx.Err()
+		}
+
+		go s.waitForAck(ctx, topic, claimed.seq, msg)
+	}
+
+	return len(messages), nil
+}
+
+func (s *Subscriber) claim(topic string) ([]claimedMessage, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var result []claimedMessage
+	if err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := nestedBucket(tx, bucketPubSub, topic)
+		if bucket == nil {
+			return nil
+		}
+
+		c := bucket.Cursor()
+		for k, v := c.First(); k != nil && len(result) < subscriberBatchSize; k, v = c.Next() {
+			seq := binary.BigEndian.Uint64(k)
+			if _, ok := s.inFlight[topic][seq]; ok {
+				continue
+			}
+
+			var transport messageTransport
+			if err := json.Unmarshal(v, &transport); err != nil {
+				return errors.Wrap(err, "error unmarshaling")
+			}
+
+			result = append(result, claimedMessage{
+				seq:     seq,
+				uuid:    transport.UUID,
+				payload: transport.Payload,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "error reading messages")
+	}
+
+	if _, ok := s.inFlight[topic]; !ok {
+		s.inFlight[topic] = make(map[uint64]struct{})
+	}
+	for _, claimed := range result {
+		s.inFlight[topic][claimed.seq] = struct{}{}
+	}
+
+	return result, nil
+}
+
+func (s *Subscriber) waitForAck(ctx context.Context, topic string, seq uint64, msg *message.Message) {
+	select {
+	case <-msg.Acked():
+		if err := s.db.Update(func(tx *bbolt.Tx) error {
+			bucket := nestedBucket(tx, bucketPubSub, topic)
+			if bucket == nil {
+				return nil
+			}
+			return bucket.Delete(messageKey(seq))
+		}); err != nil {
+			s.logger.Error().WithError(err).Message("error deleting an acked message")
+		}
+		s.release(topic, seq)
+	case <-msg.Nacked():
+		time.AfterFunc(subscriberNackDelay, func() {
+			s.release(topic, seq)
+			s.notify(topic)
+		})
+	case <-ctx.Done():
+		s.release(topic, seq)
+	}
+}
+
+func (s *Subscriber) release(topic string, seq uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.inFlight[topic], seq)
+}
+
+func (s *Subscriber) notify(topic string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, wakeUp := range s.wakeUp[topic] {
+		select {
+		case wakeUp <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Subscriber) unsubscribe(topic string, wakeUp <-chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var remaining []chan struct{}
+	for _, v := range s.wakeUp[topic] {
+		if v != wakeUp {
+			remaining = append(remaining, v)
+		}
+	}
+	s.wakeUp[topic] = remaining
+}
+
+type messageTransport struct {
+	UUID    string `json:"uuid"`
+	Payload []byte `json:"payload"`
+}
+
+type claimedMessage struct {
+	seq     uint64
+	uuid    string
+	payload []byte
+}
+
+func messageKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
@@ -8,18 +8,17 @@ import (
 	"github.com/planetary-social/go-notification-service/service/domain"
 )
 
-const (
-	cacheSavedEventsFor = 60 * time.Minute
-	cleanupEvery        = 5 * time.Minute
-)
+const cleanupEvery = 5 * time.Minute
 
 type MemoryEventWasAlreadySavedCache struct {
+	ttl       time.Duration
 	cacheLock sync.Mutex
 	cache     map[domain.EventId]time.Time
 }
 
-func NewMemoryEventWasAlreadySavedCache() *MemoryEventWasAlreadySavedCache {
+func NewMemoryEventWasAlreadySavedCache(ttl time.Duration) *MemoryEventWasAlreadySavedCache {
 	return &MemoryEventWasAlreadySavedCache{
+		ttl:   ttl,
 		cache: make(map[domain.EventId]time.Time),
 	}
 }
@@ -42,23 +41,24 @@ func (m *MemoryEventWasAlreadySavedCache) cleanup() {
 	defer m.cacheLock.Unlock()
 
 	for id, timestamp := range m.cache {
-		if time.Since(timestamp) > cacheSavedEventsFor {
+		if time.Since(timestamp) > m.ttl {
 			delete(m.cache, id)
 		}
 	}
 }
 
-func (m *MemoryEventWasAlreadySavedCache) MarkEventAsAlreadySaved(id domain.EventId) {
+func (m *MemoryEventWasAlreadySavedCache) MarkEventAsAlreadySaved(ctx context.Context, id domain.EventId) error {
 	m.cacheLock.Lock()
 	defer m.cacheLock.Unlock()
 
 	m.cache[id] = time.Now()
+	return nil
 }
 
-func (m *MemoryEventWasAlreadySavedCache) EventWasAlreadySaved(id domain.EventId) bool {
+func (m *MemoryEventWasAlreadySavedCache) EventWasAlreadySaved(ctx context.Context, id domain.EventId) (bool, error) {
 	m.cacheLock.Lock()
 	defer m.cacheLock.Unlock()
 
 	_, ok := m.cache[id]
-	return ok
+	return ok, nil
 }
@@ -0,0 +1,57 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisEventWasAlreadySavedCache can be shared by multiple replicas of the
+// service so that each of them doesn't have to check the storage for events
+// which were already saved by the others.
+type RedisEventWasAlreadySavedCache struct {
+	client    *redis.Client
+	ttl       time.Duration
+	keyPrefix string
+}
+
+func NewRedisEventWasAlreadySavedCache(
+	client *redis.Client,
+	environment config.Environment,
+	ttl time.Duration,
+) *RedisEventWasAlreadySavedCache {
+	return &RedisEventWasAlreadySavedCache{
+		client:    client,
+		ttl:       ttl,
+		keyPrefix: "notification_service:saved_events:" + environment.String() + ":",
+	}
+}
+
+// Run doesn't do anything as Redis expires the keys on its own.
+func (c *RedisEventWasAlreadySavedCache) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (c *RedisEventWasAlreadySavedCache) MarkEventAsAlreadySaved(ctx context.Context, id domain.EventId) error {
+	if err := c.client.Set(ctx, c.key(id), 1, c.ttl).Err(); err != nil {
+		return errors.Wrap(err, "error setting the key")
+	}
+	return nil
+}
+
+func (c *RedisEventWasAlreadySavedCache) EventWasAlreadySaved(ctx context.Context, id domain.EventId) (bool, error) {
+	n, err := c.client.Exists(ctx, c.key(id)).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "error checking if the key exists")
+	}
+	return n > 0, nil
+}
+
+func (c *RedisEventWasAlreadySavedCache) key(id domain.EventId) string {
+	return c.keyPrefix + id.Hex()
+}
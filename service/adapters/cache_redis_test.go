@@ -0,0 +1,84 @@
+package adapters_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/adapters"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisEventWasAlreadySavedCache_EventsAreRememberedUntilTTLPasses(t *testing.T) {
+	ctx := fixtures.Context(t)
+	server := miniredis.RunT(t)
+	ttl := 10 * time.Minute
+
+	cache := newRedisEventWasAlreadySavedCache(t, server, ttl)
+
+	eventID := fixtures.SomeEventID()
+	otherEventID := fixtures.SomeEventID()
+
+	saved, err := cache.EventWasAlreadySaved(ctx, eventID)
+	require.NoError(t, err)
+	require.False(t, saved)
+
+	err = cache.MarkEventAsAlreadySaved(ctx, eventID)
+	require.NoError(t, err)
+
+	saved, err = cache.EventWasAlreadySaved(ctx, eventID)
+	require.NoError(t, err)
+	require.True(t, saved)
+
+	saved, err = cache.EventWasAlreadySaved(ctx, otherEventID)
+	require.NoError(t, err)
+	require.False(t, saved)
+
+	server.FastForward(ttl)
+
+	saved, err = cache.EventWasAlreadySaved(ctx, eventID)
+	require.NoError(t, err)
+	require.False(t, saved)
+}
+
+func TestRedisEventWasAlreadySavedCache_CacheIsSharedBetweenInstances(t *testing.T) {
+	ctx := fixtures.Context(t)
+	server := miniredis.RunT(t)
+
+	cache1 := newRedisEventWasAlreadySavedCache(t, server, time.Hour)
+	cache2 := newRedisEventWasAlreadySavedCache(t, server, time.Hour)
+
+	eventID := fixtures.SomeEventID()
+
+	err := cache1.MarkEventAsAlreadySaved(ctx, eventID)
+	require.NoError(t, err)
+
+	saved, err := cache2.EventWasAlreadySaved(ctx, eventID)
+	require.NoError(t, err)
+	require.True(t, saved)
+}
+
+func TestRedisEventWasAlreadySavedCache_ReturnsErrorsIfRedisIsUnavailable(t *testing.T) {
+	ctx := fixtures.Context(t)
+	server := miniredis.RunT(t)
+
+	cache := newRedisEventWasAlreadySavedCache(t, server, time.Hour)
+	server.Close()
+
+	err := cache.MarkEventAsAlreadySaved(ctx, fixtures.SomeEventID())
+	require.Error(t, err)
+
+	_, err = cache.EventWasAlreadySaved(ctx, fixtures.SomeEventID())
+	require.Error(t, err)
+}
+
+func newRedisEventWasAlreadySavedCache(tb testing.TB, server *miniredis.Miniredis, ttl time.Duration) *adapters.RedisEventWasAlreadySavedCache {
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	tb.Cleanup(func() {
+		_ = client.Close()
+	})
+	return adapters.NewRedisEventWasAlreadySavedCache(client, config.EnvironmentDevelopment, ttl)
+}
@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"io"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/internal/logging"
@@ -26,6 +29,32 @@ const (
 	envGooglePubsubEnabled             = "GOOGLE_PUBSUB_ENABLED"
 	envGooglePubsubProjectID           = "GOOGLE_PUBSUB_PROJECT_ID"
 	envGooglePubsubCredentialsJSONPath = "GOOGLE_PUBSUB_CREDENTIALS_JSON_PATH"
+	envFCMEnabled                      = "FCM_ENABLED"
+	envFCMProjectID                    = "FCM_PROJECT_ID"
+	envFCMCredentialsJSONPath          = "FCM_CREDENTIALS_JSON_PATH"
+	envWebPushEnabled                  = "WEBPUSH_ENABLED"
+	envWebPushVAPIDPublicKey           = "WEBPUSH_VAPID_PUBLIC_KEY"
+	envWebPushVAPIDPrivateKey          = "WEBPUSH_VAPID_PRIVATE_KEY"
+	envWebPushSubscriber               = "WEBPUSH_SUBSCRIBER"
+	envStorage                         = "STORAGE"
+	envPostgresURL                     = "POSTGRES_URL"
+	envBoltPath                        = "BOLT_PATH"
+	envMetadataRelays                  = "METADATA_RELAYS"
+	envRateLimitIP                     = "RATE_LIMIT_IP"
+	envRateLimitPublicKey              = "RATE_LIMIT_PUBLIC_KEY"
+	envRateLimitConnection             = "RATE_LIMIT_CONNECTION"
+	envTrustedProxies                  = "TRUSTED_PROXIES"
+	envMaxSubscriptionsPerConnection   = "MAX_SUBSCRIPTIONS_PER_CONNECTION"
+	envMaxFiltersPerSubscription       = "MAX_FILTERS_PER_SUBSCRIPTION"
+	envAdminListenAddress              = "ADMIN_LISTEN_ADDRESS"
+	envAdminToken                      = "ADMIN_TOKEN"
+	envEventCache                      = "EVENT_CACHE"
+	envEventCacheTTL                   = "EVENT_CACHE_TTL"
+	envRedisURL                        = "REDIS_URL"
+	envReplicaRegistry                 = "REPLICA_REGISTRY"
+	envDownloaderMaxLookback           = "DOWNLOADER_MAX_LOOKBACK"
+	envDownloaderMaxCursorAge          = "DOWNLOADER_MAX_CURSOR_AGE"
+	envDownloaderCursorOverlap         = "DOWNLOADER_CURSOR_OVERLAP"
 )
 
 type EnvironmentConfigLoader struct {
@@ -81,6 +110,91 @@ func (c *EnvironmentConfigLoader) Load() (config.Config, error) {
 		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envGooglePubsubEnabled)
 	}
 
+	var fcmCredentialsJSON []byte
+	if p := c.getenv(envFCMCredentialsJSONPath); p != "" {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			return config.Config{}, errors.Wrap(err, "error reading the fcm credentials file")
+		}
+
+		fcmCredentialsJSON = b
+	}
+
+	fcmEnabled, err := c.getenvbool(envFCMEnabled)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envFCMEnabled)
+	}
+
+	webPushEnabled, err := c.getenvbool(envWebPushEnabled)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envWebPushEnabled)
+	}
+
+	storageBackend, err := c.loadStorageBackend()
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading the storage backend setting")
+	}
+
+	ipRateLimit, err := c.loadRateLimit(envRateLimitIP)
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading the ip rate limit")
+	}
+
+	publicKeyRateLimit, err := c.loadRateLimit(envRateLimitPublicKey)
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading the public key rate limit")
+	}
+
+	connectionRateLimit, err := c.loadRateLimit(envRateLimitConnection)
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading the connection rate limit")
+	}
+
+	trustedProxies, err := c.loadTrustedProxies()
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading trusted proxies")
+	}
+
+	maxSubscriptionsPerConnection, err := c.getenvint(envMaxSubscriptionsPerConnection)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envMaxSubscriptionsPerConnection)
+	}
+
+	maxFiltersPerSubscription, err := c.getenvint(envMaxFiltersPerSubscription)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envMaxFiltersPerSubscription)
+	}
+
+	eventCacheBackend, err := c.loadEventCacheBackend()
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading the event cache backend setting")
+	}
+
+	eventCacheTTL, err := c.getenvduration(envEventCacheTTL)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envEventCacheTTL)
+	}
+
+	replicaRegistryBackend, err := c.loadReplicaRegistryBackend()
+	if err != nil {
+		return config.Config{}, errors.Wrap(err, "error loading the replica registry backend setting")
+	}
+
+	downloaderMaxLookback, err := c.getenvduration(envDownloaderMaxLookback)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envDownloaderMaxLookback)
+	}
+
+	downloaderMaxCursorAge, err := c.getenvduration(envDownloaderMaxCursorAge)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envDownloaderMaxCursorAge)
+	}
+
+	downloaderCursorOverlap, err := c.getenvduration(envDownloaderCursorOverlap)
+	if err != nil {
+		return config.Config{}, errors.Wrapf(err, "error loading variable '%s'", envDownloaderCursorOverlap)
+	}
+
 	return config.NewConfig(
 		c.getenv(envNostrListenAddress),
 		c.getenv(envMetricsListenAddress),
@@ -94,6 +208,32 @@ func (c *EnvironmentConfigLoader) Load() (config.Config, error) {
 		googlePubSubEnabled,
 		c.getenv(envGooglePubsubProjectID),
 		googlePubSubCredentialsJSON,
+		fcmEnabled,
+		c.getenv(envFCMProjectID),
+		fcmCredentialsJSON,
+		webPushEnabled,
+		c.getenv(envWebPushVAPIDPublicKey),
+		c.getenv(envWebPushVAPIDPrivateKey),
+		c.getenv(envWebPushSubscriber),
+		storageBackend,
+		c.getenv(envPostgresURL),
+		c.getenv(envBoltPath),
+		c.getenvlist(envMetadataRelays),
+		ipRateLimit,
+		publicKeyRateLimit,
+		connectionRateLimit,
+		trustedProxies,
+		maxSubscriptionsPerConnection,
+		maxFiltersPerSubscription,
+		c.getenv(envAdminListenAddress),
+		c.getenv(envAdminToken),
+		eventCacheBackend,
+		eventCacheTTL,
+		c.getenv(envRedisURL),
+		replicaRegistryBackend,
+		downloaderMaxLookback,
+		downloaderMaxCursorAge,
+		downloaderCursorOverlap,
 	)
 }
 
@@ -111,6 +251,50 @@ func (c *EnvironmentConfigLoader) loadEnvironment() (config.Environment, error)
 	}
 }
 
+func (c *EnvironmentConfigLoader) loadStorageBackend() (config.StorageBackend, error) {
+	v := strings.ToUpper(c.getenv(envStorage))
+	switch v {
+	case "FIRESTORE":
+		return config.StorageBackendFirestore, nil
+	case "POSTGRES":
+		return config.StorageBackendPostgres, nil
+	case "BOLT":
+		return config.StorageBackendBolt, nil
+	case "":
+		return config.StorageBackendFirestore, nil
+	default:
+		return config.StorageBackend{}, fmt.Errorf("invalid storage backend requested '%s'", v)
+	}
+}
+
+func (c *EnvironmentConfigLoader) loadEventCacheBackend() (config.EventCacheBackend, error) {
+	v := strings.ToUpper(c.getenv(envEventCache))
+	switch v {
+	case "MEMORY":
+		return config.EventCacheBackendMemory, nil
+	case "REDIS":
+		return config.EventCacheBackendRedis, nil
+	case "":
+		return config.EventCacheBackendMemory, nil
+	default:
+		return config.EventCacheBackend{}, fmt.Errorf("invalid event cache backend requested '%s'", v)
+	}
+}
+
+func (c *EnvironmentConfigLoader) loadReplicaRegistryBackend() (config.ReplicaRegistryBackend, error) {
+	v := strings.ToUpper(c.getenv(envReplicaRegistry))
+	switch v {
+	case "MEMORY":
+		return config.ReplicaRegistryBackendMemory, nil
+	case "REDIS":
+		return config.ReplicaRegistryBackendRedis, nil
+	case "":
+		return config.ReplicaRegistryBackendMemory, nil
+	default:
+		return config.ReplicaRegistryBackend{}, fmt.Errorf("invalid replica registry backend requested '%s'", v)
+	}
+}
+
 func (c *EnvironmentConfigLoader) loadLogLevel() (logging.Level, error) {
 	v := strings.ToUpper(c.getenv(envLogLevel))
 	switch v {
@@ -129,10 +313,85 @@ func (c *EnvironmentConfigLoader) loadLogLevel() (logging.Level, error) {
 	}
 }
 
+// loadRateLimit reads rate limits in the format "<per second>/<burst>" e.g.
+// "10/50".
+func (c *EnvironmentConfigLoader) loadRateLimit(key string) (config.RateLimit, error) {
+	v := c.getenv(key)
+	if v == "" {
+		return config.RateLimit{}, nil
+	}
+
+	perSecondString, burstString, ok := strings.Cut(v, "/")
+	if !ok {
+		return config.RateLimit{}, fmt.Errorf("invalid rate limit '%s'", v)
+	}
+
+	perSecond, err := strconv.ParseFloat(strings.TrimSpace(perSecondString), 64)
+	if err != nil {
+		return config.RateLimit{}, errors.Wrap(err, "error parsing the rate")
+	}
+
+	burst, err := strconv.Atoi(strings.TrimSpace(burstString))
+	if err != nil {
+		return config.RateLimit{}, errors.Wrap(err, "error parsing the burst")
+	}
+
+	return config.NewRateLimit(perSecond, burst)
+}
+
+// loadTrustedProxies reads a list of addresses or prefixes e.g.
+// "10.0.0.1,35.191.0.0/16".
+func (c *EnvironmentConfigLoader) loadTrustedProxies() ([]netip.Prefix, error) {
+	var result []netip.Prefix
+	for _, v := range c.getenvlist(envTrustedProxies) {
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error parsing address '%s'", v)
+			}
+			result = append(result, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error parsing prefix '%s'", v)
+		}
+		result = append(result, prefix.Masked())
+	}
+	return result, nil
+}
+
 func (c *EnvironmentConfigLoader) getenv(key string) string {
 	return os.Getenv(fmt.Sprintf("%s_%s", envPrefix, key))
 }
 
+func (c *EnvironmentConfigLoader) getenvlist(key string) []string {
+	var result []string
+	for _, v := range strings.Split(c.getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+func (c *EnvironmentConfigLoader) getenvint(key string) (int, error) {
+	v := c.getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(v)
+}
+
+func (c *EnvironmentConfigLoader) getenvduration(key string) (time.Duration, error) {
+	v := c.getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(v)
+}
+
 func (c *EnvironmentConfigLoader) getenvbool(key string) (bool, error) {
 	switch v := strings.ToUpper(c.getenv(key)); v {
 	case "":
@@ -0,0 +1,334 @@
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	DefaultEndpoint = "https://fcm.googleapis.com"
+
+	scope   = "https://www.googleapis.com/auth/firebase.messaging"
+	timeout = 30 * time.Second
+
+	priorityNormal = "normal"
+	priorityHigh   = "high"
+
+	// See https://firebase.google.com/docs/reference/fcm/rest/v1/ErrorCode
+	errorDetailTypeFCMError   = "type.googleapis.com/google.firebase.fcm.v1.FcmError"
+	errorDetailTypeBadRequest = "type.googleapis.com/google.rpc.BadRequest"
+	errorCodeUnregistered     = "UNREGISTERED"
+	errorCodeSenderIDMismatch = "SENDER_ID_MISMATCH"
+	statusNotFound            = "NOT_FOUND"
+	fieldToken                = "message.token"
+)
+
+type Metrics interface {
+	ReportCallToFCM(statusCode int, err error)
+}
+
+// FCM sends notifications using the Firebase Cloud Messaging HTTP v1 API.
+type FCM struct {
+	client    *http.Client
+	endpoint  string
+	projectID string
+	metrics   Metrics
+	logger    logging.Logger
+}
+
+func NewFCM(ctx context.Context, cfg config.Config, metrics Metrics, logger logging.Logger) (*FCM, error) {
+	credentials, err := google.CredentialsFromJSON(ctx, cfg.FCMCredentialsJSON(), scope)
+	if err != nil {
+		return nil, errors.Wrap(err, "error loading credentials")
+	}
+
+	client := oauth2.NewClient(ctx, credentials.TokenSource)
+	client.Timeout = timeout
+
+	return NewFCMWithClient(client, DefaultEndpoint, cfg.FCMProjectID(), metrics, logger), nil
+}
+
+// NewFCMWithClient is useful when sending notifications to something else than
+// the real FCM server e.g. ServerMock.
+func NewFCMWithClient(client *http.Client, endpoint string, projectID string, metrics Metrics, logger logging.Logger) *FCM {
+	return &FCM{
+		client:    client,
+		endpoint:  strings.TrimRight(endpoint, "/"),
+		projectID: projectID,
+		metrics:   metrics,
+		logger:    logger.New("fcm"),
+	}
+}
+
+func (f *FCM) SendNotification(notification notifications.Notification) error {
+	var data map[string]string
+	if err := json.Unmarshal(notification.Payload(), &data); err != nil {
+		return errors.Wrap(err, "payload isn't an fcm payload")
+	}
+
+	msg := Message{
+		Token: notification.PushToken().Token(),
+		Data:  data,
+		Android: &AndroidConfig{
+			Priority: priorityNormal,
+		},
+	}
+
+	if notification.Priority() == notifications.PriorityHigh {
+		msg.Android.Priority = priorityHigh
+	}
+
+	if alert := notification.Alert(); !alert.IsZero() {
+		msg.Android.Notification = &AndroidNotification{
+			BodyLocKey:  alert.LocKey(),
+			BodyLocArgs: alert.LocArgs(),
+			Tag:         alert.ThreadID(),
+		}
+	}
+
+	if err := f.send(notification.PushToken(), msg); err != nil {
+		return errors.Wrap(err, "error sending the notification")
+	}
+
+	f.logger.Debug().
+		WithField("uuid", notification.UUID().String()).
+		Message("sent a notification")
+
+	return nil
+}
+
+func (f *FCM) SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	msg, err := FollowChangeMessage(followChange, token)
+	if err != nil {
+		return errors.Wrap(err, "error creating a message")
+	}
+
+	if err := f.send(token, msg); err != nil {
+		return errors.Wrap(err, "error sending the follow change notification")
+	}
+
+	f.logger.Debug().Message("sent a follow change notification")
+	return nil
+}
+
+func (f *FCM) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	msg, err := SilentFollowChangeMessage(followChange, token)
+	if err != nil {
+		return errors.Wrap(err, "error creating a message")
+	}
+
+	if err := f.send(token, msg); err != nil {
+		return errors.Wrap(err, "error sending the silent follow change notification")
+	}
+
+	f.logger.Debug().Message("sent a silent follow change notification")
+	return nil
+}
+
+func (f *FCM) send(token domain.PushToken, msg Message) error {
+	if token.Platform() != domain.PushTokenPlatformFCM {
+		return fmt.Errorf("this is a token for platform '%s'", token.Platform().String())
+	}
+
+	statusCode, err := f.post(msg)
+	f.metrics.ReportCallToFCM(statusCode, err)
+	return err
+}
+
+func (f *FCM) post(msg Message) (int, error) {
+	body, err := json.Marshal(SendRequest{Message: msg})
+	if err != nil {
+		return 0, errors.Wrap(err, "error marshaling the request")
+	}
+
+	url := fmt.Sprintf("%s/v1/projects/%s/messages:send", f.endpoint, f.projectID)
+
+	resp, err := f.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "error performing the request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return resp.StatusCode, errors.Wrap(err, "error reading the error response")
+		}
+		if err := invalidTokenError(resp.StatusCode, b); err != nil {
+			return resp.StatusCode, err
+		}
+		return resp.StatusCode, fmt.Errorf("fcm returned status code '%d': %s", resp.StatusCode, string(b))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// invalidTokenError returns an error if FCM reported that the token will never
+// be valid again. Other failures are left to the caller.
+func invalidTokenError(statusCode int, body []byte) error {
+	var resp ErrorResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil
+	}
+
+	for _, detail := range resp.Error.Details {
+		switch detail.Type {
+		case errorDetailTypeFCMError:
+			if detail.ErrorCode == errorCodeUnregistered || detail.ErrorCode == errorCodeSenderIDMismatch {
+				return app.NewInvalidPushTokenError(detail.ErrorCode)
+			}
+		case errorDetailTypeBadRequest:
+			for _, violation := range detail.FieldViolations {
+				if violation.Field == fieldToken {
+					return app.NewInvalidPushTokenError(violation.Description)
+				}
+			}
+		}
+	}
+
+	if statusCode == http.StatusNotFound && resp.Error.Status == statusNotFound {
+		return app.NewInvalidPushTokenError(resp.Error.Message)
+	}
+
+	return nil
+}
+
+func FollowChangeMessage(followChange domain.FollowChangeBatch, token domain.PushToken) (Message, error) {
+	data, err := followChangeData(followChange)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "error creating data")
+	}
+
+	followeeNpub, err := nip19.EncodePublicKey(followChange.Followee.Hex())
+	if err != nil {
+		return Message{}, errors.Wrap(err, "error encoding followee npub")
+	}
+
+	notification := &AndroidNotification{
+		Tag: followeeNpub,
+	}
+
+	if len(followChange.Follows) == 1 {
+		if strings.HasPrefix(followChange.FriendlyFollower, "npub") {
+			notification.BodyLocKey = "newFollower"
+		} else {
+			notification.BodyLocKey = "namedNewFollower"
+			notification.BodyLocArgs = []string{followChange.FriendlyFollower}
+		}
+	} else {
+		notification.BodyLocKey = "xNewFollowers"
+		notification.BodyLocArgs = []string{fmt.Sprint(len(followChange.Follows))}
+	}
+
+	return Message{
+		Token: token.Token(),
+		Data:  data,
+		Android: &AndroidConfig{
+			Priority:     priorityHigh,
+			Notification: notification,
+		},
+	}, nil
+}
+
+func SilentFollowChangeMessage(followChange domain.FollowChangeBatch, token domain.PushToken) (Message, error) {
+	data, err := followChangeData(followChange)
+	if err != nil {
+		return Message{}, errors.Wrap(err, "error creating data")
+	}
+
+	return Message{
+		Token: token.Token(),
+		Data:  data,
+		Android: &AndroidConfig{
+			Priority: priorityNormal,
+		},
+	}, nil
+}
+
+// Values of the data field can only be strings so lists are encoded as JSON.
+func followChangeData(followChange domain.FollowChangeBatch) (map[string]string, error) {
+	var npubFollows []string
+	for _, follow := range followChange.Follows {
+		npub, err := nip19.EncodePublicKey(follow.Hex())
+		if err != nil {
+			return nil, errors.Wrap(err, "error encoding a public key")
+		}
+		npubFollows = append(npubFollows, npub)
+	}
+
+	follows, err := json.Marshal(npubFollows)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling follows")
+	}
+
+	data := map[string]string{
+		"follows": string(follows),
+	}
+
+	if len(followChange.Follows) == 1 {
+		data["friendlyFollower"] = followChange.FriendlyFollower
+	}
+
+	return data, nil
+}
+
+// See https://firebase.google.com/docs/reference/fcm/rest/v1/projects.messages
+type SendRequest struct {
+	Message Message `json:"message"`
+}
+
+type Message struct {
+	Token   string            `json:"token"`
+	Data    map[string]string `json:"data,omitempty"`
+	Android *AndroidConfig    `json:"android,omitempty"`
+}
+
+type AndroidConfig struct {
+	Priority     string               `json:"priority,omitempty"`
+	Notification *AndroidNotification `json:"notification,omitempty"`
+}
+
+type AndroidNotification struct {
+	BodyLocKey  string   `json:"body_loc_key,omitempty"`
+	BodyLocArgs []string `json:"body_loc_args,omitempty"`
+	Tag         string   `json:"tag,omitempty"`
+}
+
+// See https://cloud.google.com/apis/design/errors#http_mapping
+type ErrorResponse struct {
+	Error ErrorStatus `json:"error"`
+}
+
+type ErrorStatus struct {
+	Code    int           `json:"code"`
+	Message string        `json:"message,omitempty"`
+	Status  string        `json:"status"`
+	Details []ErrorDetail `json:"details,omitempty"`
+}
+
+type ErrorDetail struct {
+	Type            string           `json:"@type"`
+	ErrorCode       string           `json:"errorCode,omitempty"`
+	FieldViolations []FieldViolation `json:"fieldViolations,omitempty"`
+}
+
+type FieldViolation struct {
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
@@ -0,0 +1,118 @@
+package fcm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/planetary-social/go-notification-service/internal"
+)
+
+// ServerMock is a local stand-in for the FCM HTTP v1 API which makes it
+// possible to test sending notifications without network access.
+type ServerMock struct {
+	server *httptest.Server
+
+	lock               sync.Mutex
+	receivedMessages   []Message
+	unregisteredTokens *internal.Set[string]
+	invalidTokens      *internal.Set[string]
+}
+
+func NewServerMock() *ServerMock {
+	s := &ServerMock{
+		unregisteredTokens: internal.NewEmptySet[string](),
+		invalidTokens:      internal.NewEmptySet[string](),
+	}
+	s.server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *ServerMock) URL() string {
+	return s.server.URL
+}
+
+func (s *ServerMock) Client() *http.Client {
+	return s.server.Client()
+}
+
+func (s *ServerMock) Close() {
+	s.server.Close()
+}
+
+// Unregister makes the server respond to messages sent to this token the same
+// way FCM responds to messages sent to tokens which are no longer valid.
+func (s *ServerMock) Unregister(token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.unregisteredTokens.Put(token)
+}
+
+// Invalidate makes the server respond to messages sent to this token the same
+// way FCM responds to messages sent to malformed tokens.
+func (s *ServerMock) Invalidate(token string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.invalidTokens.Put(token)
+}
+
+func (s *ServerMock) ReceivedMessages() []Message {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return internal.CopySlice(s.receivedMessages)
+}
+
+func (s *ServerMock) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.URL.Path, "/v1/projects/") || !strings.HasSuffix(r.URL.Path, "/messages:send") {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req SendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		s.writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT")
+		return
+	}
+
+	if req.Message.Token == "" {
+		s.writeError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "INVALID_ARGUMENT")
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.unregisteredTokens.Contains(req.Message.Token) {
+		s.writeError(w, http.StatusNotFound, "NOT_FOUND", "UNREGISTERED")
+		return
+	}
+
+	if s.invalidTokens.Contains(req.Message.Token) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w,
+			`{"error":{"code":400,"message":"The registration token is not a valid FCM registration token","status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"INVALID_ARGUMENT"},{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"message.token","description":"Invalid registration token"}]}]}}`,
+		)
+		return
+	}
+
+	s.receivedMessages = append(s.receivedMessages, req.Message)
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"name":"%s/%d"}`, strings.TrimSuffix(r.URL.Path, ":send"), len(s.receivedMessages))
+}
+
+func (s *ServerMock) writeError(w http.ResponseWriter, statusCode int, status string, errorCode string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w,
+		`{"error":{"code":%d,"status":"%s","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"%s"}]}}`,
+		statusCode, status, errorCode,
+	)
+}
@@ -0,0 +1,262 @@
+package fcm_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters/fcm"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFCM_SendNotificationDeliversDataMessage(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	token := fixtures.SomeFCMPushToken()
+	notification := someNotification(t, token, `{"eventId":"someEventId"}`)
+
+	err := adapter.SendNotification(notification)
+	require.NoError(t, err)
+
+	require.Equal(t,
+		[]fcm.Message{
+			{
+				Token: token.Token(),
+				Data: map[string]string{
+					"eventId": "someEventId",
+				},
+				Android: &fcm.AndroidConfig{
+					Priority: "normal",
+				},
+			},
+		},
+		server.ReceivedMessages(),
+	)
+}
+
+func TestFCM_SendNotificationUsesHighPriorityForHighPriorityNotifications(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	token := fixtures.SomeFCMPushToken()
+	notification := someNotificationWithPriority(t, token, `{"eventId":"someEventId"}`, notifications.PriorityHigh)
+
+	err := adapter.SendNotification(notification)
+	require.NoError(t, err)
+
+	messages := server.ReceivedMessages()
+	require.Len(t, messages, 1)
+	require.Equal(t, &fcm.AndroidConfig{Priority: "high"}, messages[0].Android)
+}
+
+func TestFCM_SendNotificationDisplaysAlerts(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	alert, err := notifications.NewAlert("namedNewMention", []string{"Some Name", "hello"}, "someThreadId")
+	require.NoError(t, err)
+
+	uuid, err := notifications.NewNotificationUUID()
+	require.NoError(t, err)
+
+	token := fixtures.SomeFCMPushToken()
+	notification, err := notifications.NewNotification(domain.Event{}, uuid, token, []byte(`{"eventId":"someEventId"}`), notifications.PriorityNormal, alert, time.Now())
+	require.NoError(t, err)
+
+	err = adapter.SendNotification(notification)
+	require.NoError(t, err)
+
+	messages := server.ReceivedMessages()
+	require.Len(t, messages, 1)
+	require.Equal(t,
+		&fcm.AndroidConfig{
+			Priority: "normal",
+			Notification: &fcm.AndroidNotification{
+				BodyLocKey:  "namedNewMention",
+				BodyLocArgs: []string{"Some Name", "hello"},
+				Tag:         "someThreadId",
+			},
+		},
+		messages[0].Android,
+	)
+}
+
+func TestFCM_SendNotificationReturnsErrorForUnregisteredTokens(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	token := fixtures.SomeFCMPushToken()
+	server.Unregister(token.Token())
+
+	err := adapter.SendNotification(someNotification(t, token, `{}`))
+	var invalidPushTokenErr *app.InvalidPushTokenError
+	require.True(t, errors.As(err, &invalidPushTokenErr))
+	require.Equal(t, "UNREGISTERED", invalidPushTokenErr.Reason())
+	require.Empty(t, server.ReceivedMessages())
+}
+
+func TestFCM_SendNotificationReturnsErrorForInvalidTokens(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	token := fixtures.SomeFCMPushToken()
+	server.Invalidate(token.Token())
+
+	err := adapter.SendNotification(someNotification(t, token, `{}`))
+	var invalidPushTokenErr *app.InvalidPushTokenError
+	require.True(t, errors.As(err, &invalidPushTokenErr))
+	require.Empty(t, server.ReceivedMessages())
+}
+
+func TestFCM_SendFollowChangeNotificationReturnsErrorForUnregisteredTokens(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	pk1, _ := fixtures.PublicKeyAndNpub()
+	pk2, _ := fixtures.PublicKeyAndNpub()
+	token := fixtures.SomeFCMPushToken()
+	server.Unregister(token.Token())
+
+	batch := domain.FollowChangeBatch{
+		Followee: pk1,
+		Follows:  []domain.PublicKey{pk2},
+	}
+
+	err := adapter.SendFollowChangeNotification(batch, token)
+	var invalidPushTokenErr *app.InvalidPushTokenError
+	require.True(t, errors.As(err, &invalidPushTokenErr))
+}
+
+func TestFCM_RefusesTokensForOtherPlatforms(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	err := adapter.SendNotification(someNotification(t, fixtures.SomeAPNSPushToken(), `{}`))
+	require.EqualError(t, err, "error sending the notification: this is a token for platform 'apns'")
+	require.Empty(t, server.ReceivedMessages())
+}
+
+func TestFCM_SendFollowChangeNotification(t *testing.T) {
+	server := fcm.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter := newFCM(server)
+
+	pk1, pk1Npub := fixtures.PublicKeyAndNpub()
+	pk2, pk2Npub := fixtures.PublicKeyAndNpub()
+	token := fixtures.SomeFCMPushToken()
+
+	batch := domain.FollowChangeBatch{
+		Followee:         pk1,
+		FriendlyFollower: "John Doe",
+		Follows:          []domain.PublicKey{pk2},
+	}
+
+	err := adapter.SendFollowChangeNotification(batch, token)
+	require.NoError(t, err)
+
+	err = adapter.SendSilentFollowChangeNotification(batch, token)
+	require.NoError(t, err)
+
+	follows, err := json.Marshal([]string{pk2Npub})
+	require.NoError(t, err)
+
+	expectedData := map[string]string{
+		"follows":          string(follows),
+		"friendlyFollower": "John Doe",
+	}
+
+	require.Equal(t,
+		[]fcm.Message{
+			{
+				Token: token.Token(),
+				Data:  expectedData,
+				Android: &fcm.AndroidConfig{
+					Priority: "high",
+					Notification: &fcm.AndroidNotification{
+						BodyLocKey:  "namedNewFollower",
+						BodyLocArgs: []string{"John Doe"},
+						Tag:         pk1Npub,
+					},
+				},
+			},
+			{
+				Token: token.Token(),
+				Data:  expectedData,
+				Android: &fcm.AndroidConfig{
+					Priority: "normal",
+				},
+			},
+		},
+		server.ReceivedMessages(),
+	)
+}
+
+func TestFollowChangeMessage_MultipleFollows(t *testing.T) {
+	pk1, _ := fixtures.PublicKeyAndNpub()
+	pk2, _ := fixtures.PublicKeyAndNpub()
+	pk3, _ := fixtures.PublicKeyAndNpub()
+
+	batch := domain.FollowChangeBatch{
+		Followee: pk1,
+		Follows:  []domain.PublicKey{pk2, pk3},
+	}
+
+	msg, err := fcm.FollowChangeMessage(batch, fixtures.SomeFCMPushToken())
+	require.NoError(t, err)
+
+	require.Equal(t, "xNewFollowers", msg.Android.Notification.BodyLocKey)
+	require.Equal(t, []string{"2"}, msg.Android.Notification.BodyLocArgs)
+	require.NotContains(t, msg.Data, "friendlyFollower")
+}
+
+func newFCM(server *fcm.ServerMock) *fcm.FCM {
+	return fcm.NewFCMWithClient(
+		server.Client(),
+		server.URL(),
+		"some-project-id",
+		mockMetrics{},
+		logging.NewDevNullLogger(),
+	)
+}
+
+func someNotification(t *testing.T, token domain.PushToken, payload string) notifications.Notification {
+	return someNotificationWithPriority(t, token, payload, notifications.PriorityNormal)
+}
+
+func someNotificationWithPriority(t *testing.T, token domain.PushToken, payload string, priority notifications.Priority) notifications.Notification {
+	uuid, err := notifications.NewNotificationUUID()
+	require.NoError(t, err)
+
+	notification, err := notifications.NewNotification(domain.Event{}, uuid, token, []byte(payload), priority, notifications.Alert{}, time.Now())
+	require.NoError(t, err)
+
+	return notification
+}
+
+type mockMetrics struct {
+}
+
+func (m mockMetrics) ReportCallToFCM(statusCode int, err error) {
+}
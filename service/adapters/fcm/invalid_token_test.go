@@ -0,0 +1,73 @@
+package fcm
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidTokenError(t *testing.T) {
+	testCases := []struct {
+		Name       string
+		StatusCode int
+		Body       string
+
+		ExpectedInvalidToken bool
+	}{
+		{
+			Name:                 "unregistered",
+			StatusCode:           http.StatusNotFound,
+			Body:                 `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`,
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:                 "not_found_without_details",
+			StatusCode:           http.StatusNotFound,
+			Body:                 `{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND"}}`,
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:                 "sender_id_mismatch",
+			StatusCode:           http.StatusForbidden,
+			Body:                 `{"error":{"code":403,"status":"PERMISSION_DENIED","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"SENDER_ID_MISMATCH"}]}}`,
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:                 "invalid_token",
+			StatusCode:           http.StatusBadRequest,
+			Body:                 `{"error":{"code":400,"status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"INVALID_ARGUMENT"},{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"message.token","description":"Invalid registration token"}]}]}}`,
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:       "other_invalid_argument",
+			StatusCode: http.StatusBadRequest,
+			Body:       `{"error":{"code":400,"status":"INVALID_ARGUMENT","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"INVALID_ARGUMENT"},{"@type":"type.googleapis.com/google.rpc.BadRequest","fieldViolations":[{"field":"message.data","description":"Invalid data"}]}]}}`,
+		},
+		{
+			Name:       "quota_exceeded",
+			StatusCode: http.StatusTooManyRequests,
+			Body:       `{"error":{"code":429,"status":"RESOURCE_EXHAUSTED","details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"QUOTA_EXCEEDED"}]}}`,
+		},
+		{
+			Name:       "not_json",
+			StatusCode: http.StatusBadGateway,
+			Body:       `bad gateway`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := invalidTokenError(testCase.StatusCode, []byte(testCase.Body))
+			if !testCase.ExpectedInvalidToken {
+				require.NoError(t, err)
+				return
+			}
+
+			var invalidPushTokenErr *app.InvalidPushTokenError
+			require.True(t, errors.As(err, &invalidPushTokenErr))
+		})
+	}
+}
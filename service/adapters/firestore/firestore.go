@@ -35,6 +35,14 @@ func NewTransactionProvider(client *firestore.Client, fn AdaptersFactoryFn) *Tra
 }
 
 func (t *TransactionProvider) Transact(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	return t.transact(ctx, f)
+}
+
+func (t *TransactionProvider) TransactReadOnly(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	return t.transact(ctx, f, firestore.ReadOnly)
+}
+
+func (t *TransactionProvider) transact(ctx context.Context, f func(context.Context, app.Adapters) error, opts ...firestore.TransactionOption) error {
 	if err := t.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
 		adapters, err := t.fn(t.client, tx)
 		if err != nil {
@@ -46,7 +54,7 @@ func (t *TransactionProvider) Transact(ctx context.Context, f func(context.Conte
 		}
 
 		return nil
-	}); err != nil {
+	}, opts...); err != nil {
 		return errors.Wrap(err, "transaction returned an error")
 	}
 
@@ -9,11 +9,10 @@ import (
 	watermillfirestore "github.com/ThreeDotsLabs/watermill-firestore/pkg/firestore"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/adapters/pubsub"
 	"github.com/planetary-social/go-notification-service/service/domain"
 )
 
-const PubsubTopicEventSaved = "event_saved"
-
 type Publisher struct {
 	publisher *watermillfirestore.Publisher
 	tx        *firestore.Transaction
@@ -30,7 +29,7 @@ func NewPublisher(
 }
 
 func (p Publisher) PublishEventSaved(ctx context.Context, id domain.EventId) error {
-	payload := EventSavedPayload{EventId: id.Hex()}
+	payload := pubsub.EventSavedPayload{EventId: id.Hex()}
 
 	payloadJSON, err := json.Marshal(payload)
 	if err != nil {
@@ -38,9 +37,5 @@ func (p Publisher) PublishEventSaved(ctx context.Context, id domain.EventId) err
 	}
 
 	msg := message.NewMessage(watermill.NewULID(), payloadJSON)
-	return p.publisher.PublishInTransaction(PubsubTopicEventSaved, p.tx, msg)
-}
-
-type EventSavedPayload struct {
-	EventId string `json:"eventId"`
+	return p.publisher.PublishInTransaction(pubsub.TopicEventSaved, p.tx, msg)
 }
@@ -17,6 +17,7 @@ import (
 const (
 	collectionEvents              = "events"
 	collectionEventsNotifications = "notifications"
+	collectionEventsDeliveries    = "deliveries"
 
 	eventFieldId        = "id"
 	eventFieldPublicKey = "publicKey"
@@ -26,8 +27,15 @@ const (
 
 	eventNotificationUUID      = "uuid"
 	eventNotificationToken     = "token"
+	eventNotificationPlatform  = "platform"
 	eventNotificationPayload   = "payload"
 	eventNotificationCreatedAt = "createdAt"
+
+	eventDeliveryMention   = "mention"
+	eventDeliveryToken     = "token"
+	eventDeliveryPlatform  = "platform"
+	eventDeliveryCreatedAt = "createdAt"
+	eventDeliverySentAt    = "sentAt"
 )
 
 type EventRepository struct {
@@ -98,7 +106,8 @@ func (e *EventRepository) SaveNotificationForEvent(notification notifications.No
 
 	notificationDocData := map[string]any{
 		eventNotificationUUID:      ensureType[string](notification.UUID().String()),
-		eventNotificationToken:     ensureType[string](notification.APNSToken().Hex()),
+		eventNotificationToken:     ensureType[string](notification.PushToken().Token()),
+		eventNotificationPlatform:  ensureType[string](notification.PushToken().Platform().String()),
 		eventNotificationPayload:   ensureType[[]byte](notification.Payload()),
 		eventNotificationCreatedAt: ensureType[time.Time](*createdAt),
 	}
@@ -110,6 +119,61 @@ func (e *EventRepository) SaveNotificationForEvent(notification notifications.No
 	return nil
 }
 
+func (e *EventRepository) ClaimDelivery(delivery notifications.Delivery) error {
+	deliveryDocData := map[string]any{
+		eventDeliveryMention:   ensureType[string](delivery.Mention().Hex()),
+		eventDeliveryToken:     ensureType[string](delivery.PushToken().Token()),
+		eventDeliveryPlatform:  ensureType[string](delivery.PushToken().Platform().String()),
+		eventDeliveryCreatedAt: ensureType[time.Time](time.Now()),
+	}
+
+	if err := e.tx.Set(e.deliveryDocPath(delivery), deliveryDocData); err != nil {
+		return errors.Wrap(err, "error creating the delivery doc")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) MarkDeliverySent(delivery notifications.Delivery) error {
+	if err := e.tx.Update(e.deliveryDocPath(delivery), []firestore.Update{
+		{
+			Path:  eventDeliverySentAt,
+			Value: ensureType[time.Time](time.Now()),
+		},
+	}); err != nil {
+		return errors.Wrap(err, "error updating the delivery doc")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) DeleteDelivery(delivery notifications.Delivery) error {
+	if err := e.tx.Delete(e.deliveryDocPath(delivery)); err != nil {
+		return errors.Wrap(err, "error deleting the delivery doc")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) DeliveryExists(ctx context.Context, delivery notifications.Delivery) (bool, error) {
+	_, err := e.tx.Get(e.deliveryDocPath(delivery))
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "error checking if document exists")
+	}
+	return true, nil
+}
+
+func (e *EventRepository) deliveryDocPath(delivery notifications.Delivery) *firestore.DocumentRef {
+	return e.client.
+		Collection(collectionEvents).
+		Doc(delivery.EventId().Hex()).
+		Collection(collectionEventsDeliveries).
+		Doc(delivery.Mention().Hex() + "_" + delivery.PushToken().Platform().String() + ":" + pushTokenDocID(delivery.PushToken()))
+}
+
 func (e *EventRepository) saveUnderEvents(event domain.Event) error {
 	eventDocPath := e.client.Collection(collectionEvents).Doc(event.Id().Hex())
 	eventDocData := map[string]any{
@@ -126,9 +190,10 @@ func (e *EventRepository) saveUnderEvents(event domain.Event) error {
 	return nil
 }
 
-// DeleteByPublicKey deletes all events and associated notifications for a given public key.
+// DeleteByPublicKey deletes all events and associated notifications and
+// deliveries for a given public key.
 func (e *EventRepository) DeleteByPublicKey(ctx context.Context, pubkey domain.PublicKey) error {
-	notificationsToDelete := make(map[*firestore.DocumentRef][]*firestore.DocumentRef)
+	docsToDelete := make(map[*firestore.DocumentRef][]*firestore.DocumentRef)
 
 	eventsQuery := e.client.Collection(collectionEvents).Where(eventFieldPublicKey, "==", pubkey.Hex())
 	eventsIter := eventsQuery.Documents(ctx)
@@ -145,28 +210,22 @@ func (e *EventRepository) DeleteByPublicKey(ctx context.Context, pubkey domain.P
 			return errors.Wrap(err, "error fetching event document")
 		}
 
-		notificationsCollection := eventDoc.Ref.Collection(collectionEventsNotifications)
-		notificationsIter := notificationsCollection.Documents(ctx)
-
-		var notificationRefs []*firestore.DocumentRef
-		for {
-			notificationDoc, err := notificationsIter.Next()
-			if err == iterator.Done {
-				break
-			}
+		var refs []*firestore.DocumentRef
+		for _, collection := range []string{collectionEventsNotifications, collectionEventsDeliveries} {
+			tmp, err := e.listDocuments(ctx, eventDoc.Ref.Collection(collection))
 			if err != nil {
-				return errors.Wrap(err, "error fetching notification document")
+				return errors.Wrapf(err, "error listing documents in collection '%s'", collection)
 			}
-			notificationRefs = append(notificationRefs, notificationDoc.Ref)
+			refs = append(refs, tmp...)
 		}
 
-		notificationsToDelete[eventDoc.Ref] = notificationRefs
+		docsToDelete[eventDoc.Ref] = refs
 	}
 
-	for eventRef, notificationRefs := range notificationsToDelete {
-		for _, notificationRef := range notificationRefs {
-			if err := e.tx.Delete(notificationRef); err != nil {
-				return errors.Wrap(err, "error deleting notification document")
+	for eventRef, refs := range docsToDelete {
+		for _, ref := range refs {
+			if err := e.tx.Delete(ref); err != nil {
+				return errors.Wrap(err, "error deleting a document associated with the event")
 			}
 		}
 
@@ -178,6 +237,23 @@ func (e *EventRepository) DeleteByPublicKey(ctx context.Context, pubkey domain.P
 	return nil
 }
 
+func (e *EventRepository) listDocuments(ctx context.Context, collection *firestore.CollectionRef) ([]*firestore.DocumentRef, error) {
+	iter := collection.Documents(ctx)
+
+	var result []*firestore.DocumentRef
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "error fetching document")
+		}
+		result = append(result, doc.Ref)
+	}
+	return result, nil
+}
+
 func (e *EventRepository) GetEvents(ctx context.Context, filters domain.Filters) <-chan app.EventOrError {
 	ch := make(chan app.EventOrError)
 	go e.getEvents(ctx, filters, ch)
@@ -228,7 +304,7 @@ func (e *EventRepository) GetNotifications(ctx context.Context, id domain.EventI
 			return nil, errors.Wrap(err, "error creating an uuid")
 		}
 
-		token, err := domain.NewAPNSTokenFromHex(data[eventNotificationToken].(string))
+		token, err := readPushToken(data, eventNotificationToken, eventNotificationPlatform)
 		if err != nil {
 			return nil, errors.Wrap(err, "error creating a token")
 		}
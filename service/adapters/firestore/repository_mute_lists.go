@@ -0,0 +1,80 @@
+package firestore
+
+import (
+	"context"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	collectionMuteLists               = "muteLists"
+	collectionMuteListsFieldPublicKey = "publicKey"
+	collectionMuteListsFieldCreatedAt = "createdAt"
+	collectionMuteListsFieldRaw       = "raw"
+)
+
+type MuteListRepository struct {
+	client *firestore.Client
+	tx     *firestore.Transaction
+}
+
+func NewMuteListRepository(client *firestore.Client, tx *firestore.Transaction) *MuteListRepository {
+	return &MuteListRepository{client: client, tx: tx}
+}
+
+func (r *MuteListRepository) Save(muteList domain.MuteList) error {
+	docPath := r.client.Collection(collectionMuteLists).Doc(muteList.Author().Hex())
+	docData := map[string]any{
+		collectionMuteListsFieldPublicKey: ensureType[string](muteList.Author().Hex()),
+		collectionMuteListsFieldCreatedAt: ensureType[time.Time](muteList.Event().CreatedAt()),
+		collectionMuteListsFieldRaw:       ensureType[[]byte](muteList.Event().Raw()),
+	}
+	if err := r.tx.Set(docPath, docData); err != nil {
+		return errors.Wrap(err, "error setting the mute list doc")
+	}
+	return nil
+}
+
+func (r *MuteListRepository) Get(ctx context.Context, publicKey domain.PublicKey) (domain.MuteList, bool, error) {
+	doc, err := r.tx.Get(r.client.Collection(collectionMuteLists).Doc(publicKey.Hex()))
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return domain.MuteList{}, false, nil
+		}
+		return domain.MuteList{}, false, errors.Wrap(err, "error getting the mute list doc")
+	}
+
+	data := make(map[string]any)
+	if err := doc.DataTo(&data); err != nil {
+		return domain.MuteList{}, false, errors.Wrap(err, "error reading document data")
+	}
+
+	raw, ok := data[collectionMuteListsFieldRaw].([]byte)
+	if !ok {
+		return domain.MuteList{}, false, errors.New("raw event is missing")
+	}
+
+	event, err := domain.NewEventFromRaw(raw)
+	if err != nil {
+		return domain.MuteList{}, false, errors.Wrap(err, "error creating the event")
+	}
+
+	muteList, err := domain.NewMuteList(event)
+	if err != nil {
+		return domain.MuteList{}, false, errors.Wrap(err, "error creating the mute list")
+	}
+
+	return muteList, true, nil
+}
+
+func (r *MuteListRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	if err := r.tx.Delete(r.client.Collection(collectionMuteLists).Doc(publicKey.Hex())); err != nil {
+		return errors.Wrap(err, "error deleting the mute list doc")
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package firestore
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	"cloud.google.com/go/firestore"
@@ -16,6 +19,9 @@ const (
 
 	collectionPublicKeysAPNSTokens                      = "apnsTokens"
 	collectionPublicKeysAPNSTokensFieldToken            = "token"
+	collectionPublicKeysAPNSTokensFieldPlatform         = "platform"
+	collectionPublicKeysAPNSTokensFieldNotificationMode = "notificationMode"
+	collectionPublicKeysAPNSTokensFieldPreferences      = "preferences"
 	collectionPublicKeysAPNSTokensFieldUpdatedTimestamp = "updatedTimestamp"
 )
 
@@ -37,9 +43,17 @@ func (r *PublicKeyRepository) Save(registration domain.Registration) error {
 		return errors.Wrap(err, "error creating the public key doc")
 	}
 
-	tokenDocPath := r.client.Collection(collectionPublicKeys).Doc(registration.PublicKey().Hex()).Collection(collectionPublicKeysAPNSTokens).Doc(registration.APNSToken().Hex())
+	preferences, err := json.Marshal(registration.Preferences())
+	if err != nil {
+		return errors.Wrap(err, "error marshaling preferences")
+	}
+
+	tokenDocPath := r.client.Collection(collectionPublicKeys).Doc(registration.PublicKey().Hex()).Collection(collectionPublicKeysAPNSTokens).Doc(pushTokenDocID(registration.PushToken()))
 	tokenDocData := map[string]any{
-		collectionPublicKeysAPNSTokensFieldToken:            ensureType[string](registration.APNSToken().Hex()),
+		collectionPublicKeysAPNSTokensFieldToken:            ensureType[string](registration.PushToken().Token()),
+		collectionPublicKeysAPNSTokensFieldPlatform:         ensureType[string](registration.PushToken().Platform().String()),
+		collectionPublicKeysAPNSTokensFieldNotificationMode: ensureType[string](registration.NotificationMode().String()),
+		collectionPublicKeysAPNSTokensFieldPreferences:      ensureType[string](string(preferences)),
 		collectionPublicKeysAPNSTokensFieldUpdatedTimestamp: ensureType[time.Time](time.Now()),
 	}
 	if err := r.tx.Set(tokenDocPath, tokenDocData, firestore.MergeAll); err != nil {
@@ -76,7 +90,19 @@ func (r *PublicKeyRepository) DeleteByPublicKey(ctx context.Context, publicKey d
 	return nil
 }
 
-func (r *PublicKeyRepository) GetAPNSTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.APNSToken, error) {
+func (r *PublicKeyRepository) DeletePushToken(ctx context.Context, publicKey domain.PublicKey, token domain.PushToken) error {
+	tokenDocRef := r.client.
+		Collection(collectionPublicKeys).
+		Doc(publicKey.Hex()).
+		Collection(collectionPublicKeysAPNSTokens).
+		Doc(pushTokenDocID(token))
+	if err := r.tx.Delete(tokenDocRef); err != nil {
+		return errors.Wrap(err, "error deleting the token doc")
+	}
+	return nil
+}
+
+func (r *PublicKeyRepository) GetPushTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.RegisteredPushToken, error) {
 	docs := r.tx.Documents(
 		r.client.
 			Collection(collectionPublicKeys).
@@ -85,7 +111,7 @@ func (r *PublicKeyRepository) GetAPNSTokens(ctx context.Context, publicKey domai
 			Where(collectionPublicKeysAPNSTokensFieldUpdatedTimestamp, ">", savedAfter),
 	)
 
-	var result []domain.APNSToken
+	var result []domain.RegisteredPushToken
 
 	for {
 		doc, err := docs.Next()
@@ -102,13 +128,79 @@ func (r *PublicKeyRepository) GetAPNSTokens(ctx context.Context, publicKey domai
 			return nil, errors.Wrap(err, "error reading document data")
 		}
 
-		apnsToken, err := domain.NewAPNSTokenFromHex(data["token"].(string))
+		token, err := readPushToken(data, collectionPublicKeysAPNSTokensFieldToken, collectionPublicKeysAPNSTokensFieldPlatform)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading the token")
+		}
+
+		notificationMode, err := readNotificationMode(data, collectionPublicKeysAPNSTokensFieldNotificationMode)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading the notification mode")
+		}
+
+		preferences, err := readPreferences(data, collectionPublicKeysAPNSTokensFieldPreferences)
 		if err != nil {
-			return nil, errors.Wrap(err, "error creating a token from hex")
+			return nil, errors.Wrap(err, "error reading preferences")
 		}
 
-		result = append(result, apnsToken)
+		registeredPushToken, err := domain.NewRegisteredPushToken(token, notificationMode, preferences)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a registered push token")
+		}
+
+		result = append(result, registeredPushToken)
 	}
 
 	return result, nil
 }
+
+// pushTokenDocID returns the raw token if possible so that the ids of tokens
+// saved before other platforms were supported don't change. Web Push
+// subscriptions contain characters which can't be used in document ids so they
+// are hashed.
+func pushTokenDocID(token domain.PushToken) string {
+	if token.Platform() == domain.PushTokenPlatformWebPush {
+		sum := sha256.Sum256([]byte(token.Token()))
+		return hex.EncodeToString(sum[:])
+	}
+	return token.Token()
+}
+
+// readPushToken assumes that the token is an APNs token if the platform is
+// missing as tokens saved before other platforms were supported don't have it.
+func readPushToken(data map[string]any, tokenField, platformField string) (domain.PushToken, error) {
+	token, ok := data[tokenField].(string)
+	if !ok {
+		return domain.PushToken{}, errors.New("token is missing")
+	}
+
+	platform := domain.PushTokenPlatformAPNS
+	if s, ok := data[platformField].(string); ok {
+		tmp, err := domain.NewPushTokenPlatform(s)
+		if err != nil {
+			return domain.PushToken{}, errors.Wrap(err, "error creating the platform")
+		}
+		platform = tmp
+	}
+
+	return domain.NewPushToken(platform, token)
+}
+
+// readNotificationMode assumes that the mode is silent if it is missing as
+// tokens saved before the mode could be selected don't have it.
+func readNotificationMode(data map[string]any, field string) (domain.NotificationMode, error) {
+	s, _ := data[field].(string)
+	return domain.NewNotificationMode(s)
+}
+
+// readPreferences returns the default preferences if they are missing as tokens
+// saved before the preferences could be set don't have them.
+func readPreferences(data map[string]any, field string) (domain.Preferences, error) {
+	var preferences domain.Preferences
+	if s, ok := data[field].(string); ok {
+		if err := json.Unmarshal([]byte(s), &preferences); err != nil {
+			return domain.Preferences{}, errors.Wrap(err, "error unmarshaling")
+		}
+	}
+	return preferences, nil
+}
@@ -3,31 +3,35 @@ package firestore
 import (
 	"context"
 	"encoding/hex"
-	"strings"
 	"time"
 
 	"cloud.google.com/go/firestore"
 	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/domain"
 	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
-var relaySuffixesToSkip = []string{
-	"127.0.0.1",
-	"localhost",
-	"nostr.band",
-	"nostrja-kari-nip50.heguro.com",
-	"nostr.sebastix.social",
-}
-
 const (
 	collectionRelays                      = "relays"
 	collectionRelaysFieldAddress          = "address"
 	collectionRelaysFieldUpdatedTimestamp = "updatedTimestamp"
+	collectionRelaysFieldRegistered       = "registered"
+	collectionRelaysFieldInRelayList      = "inRelayList"
 
 	collectionRelaysPublicKeys                      = "publicKeys"
 	collectionRelaysPublicKeysFieldPublicKey        = "publicKey"
 	collectionRelaysPublicKeysFieldUpdatedTimestamp = "updatedTimestamp"
+	collectionRelaysPublicKeysFieldCursor           = "cursor"
+	collectionRelaysPublicKeysFieldRegistered       = "registered"
+	collectionRelaysPublicKeysFieldInRelayList      = "inRelayList"
+
+	collectionRelayLists               = "relayLists"
+	collectionRelayListsFieldCreatedAt = "createdAt"
+	collectionRelayListsFieldRelays    = "relays"
 )
 
 type RelayRepository struct {
@@ -45,6 +49,7 @@ func (r *RelayRepository) Save(registration domain.Registration) error {
 		relayDocData := map[string]any{
 			collectionRelaysFieldAddress:          ensureType[string](relayAddress.String()),
 			collectionRelaysFieldUpdatedTimestamp: ensureType[time.Time](time.Now()),
+			collectionRelaysFieldRegistered:       ensureType[bool](true),
 		}
 		if err := r.tx.Set(relayDocPath, relayDocData, firestore.MergeAll); err != nil {
 			return errors.Wrap(err, "error creating the relay doc")
@@ -54,6 +59,7 @@ func (r *RelayRepository) Save(registration domain.Registration) error {
 		pubKeyDocData := map[string]any{
 			collectionRelaysPublicKeysFieldPublicKey:        ensureType[string](registration.PublicKey().Hex()),
 			collectionRelaysPublicKeysFieldUpdatedTimestamp: ensureType[time.Time](time.Now()),
+			collectionRelaysPublicKeysFieldRegistered:       ensureType[bool](true),
 		}
 		if err := r.tx.Set(pubKeyDocPath, pubKeyDocData, firestore.MergeAll); err != nil {
 			return errors.Wrap(err, "error creating the public key doc")
@@ -63,14 +69,14 @@ func (r *RelayRepository) Save(registration domain.Registration) error {
 	return nil
 }
 
-func (r *RelayRepository) GetRelays(ctx context.Context, updatedAfter time.Time) ([]domain.RelayAddress, error) {
+func (r *RelayRepository) GetRelays(ctx context.Context, updatedAfter time.Time) ([]app.StoredRelay, error) {
 	iter := r.tx.Documents(
 		r.client.
 			Collection(collectionRelays).
 			Where(collectionRelaysFieldUpdatedTimestamp, ">", updatedAfter),
 	)
 
-	var result []domain.RelayAddress
+	var result []app.StoredRelay
 	for {
 		docRef, err := iter.Next()
 		if err != nil {
@@ -85,25 +91,18 @@ func (r *RelayRepository) GetRelays(ctx context.Context, updatedAfter time.Time)
 			return nil, errors.Wrapf(err, "error creating a relay address from key '%s'", docRef.Ref.ID)
 		}
 
-		if !endsWithAny(relayAddress.HostWithoutPort(), relaySuffixesToSkip) {
-			result = append(result, relayAddress)
+		if !relayAddress.ShouldBeSkipped() {
+			sources := relaySources(
+				isRegistered(docRef.Data(), collectionRelaysFieldRegistered),
+				isInRelayList(docRef.Data(), collectionRelaysFieldInRelayList),
+			)
+			result = append(result, app.NewStoredRelay(relayAddress, sources))
 		}
 	}
 
 	return result, nil
 }
 
-// endsWithAny checks if the given string ends with any of the strings in the
-// list.
-func endsWithAny(s string, list []string) bool {
-	for _, suffix := range list {
-		if strings.HasSuffix(s, suffix) {
-			return true
-		}
-	}
-	return false
-}
-
 func (r *RelayRepository) GetPublicKeys(ctx context.Context, address domain.RelayAddress, updatedAfter time.Time) ([]domain.PublicKey, error) {
 	iter := r.tx.Documents(
 		r.client.
@@ -133,6 +132,303 @@ func (r *RelayRepository) GetPublicKeys(ctx context.Context, address domain.Rela
 	return result, nil
 }
 
+// DeletePublicKeyFromRelays keeps the public key docs of relays which are also
+// listed in the relay list of the public key. All docs are read before any of
+// them are modified as firestore transactions require all reads to happen
+// before writes.
+func (r *RelayRepository) DeletePublicKeyFromRelays(ctx context.Context, publicKey domain.PublicKey, addresses []domain.RelayAddress) error {
+	var pubKeyDocRefs []*firestore.DocumentRef
+	for _, address := range addresses {
+		pubKeyDocRefs = append(pubKeyDocRefs, r.pubKeyDocRef(address, publicKey))
+	}
+
+	docs, err := r.tx.GetAll(pubKeyDocRefs)
+	if err != nil {
+		return errors.Wrap(err, "error getting the public key docs")
+	}
+
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+
+		if isInRelayList(doc.Data(), collectionRelaysPublicKeysFieldInRelayList) {
+			if err := r.tx.Update(doc.Ref, []firestore.Update{
+				{
+					Path:  collectionRelaysPublicKeysFieldRegistered,
+					Value: ensureType[bool](false),
+				},
+			}); err != nil {
+				return errors.Wrap(err, "error updating the public key doc")
+			}
+			continue
+		}
+
+		if err := r.tx.Delete(doc.Ref); err != nil {
+			return errors.Wrap(err, "error deleting the public key doc")
+		}
+	}
+
+	return nil
+}
+
+// DeletePublicKeyFromAllRelays finds the relays which the public key was saved
+// under using a collection group query so that the number of reads and writes
+// doesn't depend on the total number of relays. The query requires the single
+// field index of the public key field to have the collection group scope
+// enabled. Collection groups include all collections with the same id so the
+// top level public keys collection has to be skipped.
+func (r *RelayRepository) DeletePublicKeyFromAllRelays(ctx context.Context, publicKey domain.PublicKey) error {
+	docs, err := r.tx.Documents(
+		r.client.
+			CollectionGroup(collectionRelaysPublicKeys).
+			Where(collectionRelaysPublicKeysFieldPublicKey, "==", publicKey.Hex()),
+	).GetAll()
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return errors.Wrapf(err, "error getting the public key docs, the single field index of '%s' in collection group '%s' most likely doesn't have the collection group scope enabled", collectionRelaysPublicKeysFieldPublicKey, collectionRelaysPublicKeys)
+		}
+		return errors.Wrap(err, "error getting the public key docs")
+	}
+
+	for _, doc := range docs {
+		if !r.isRelayPublicKeyDoc(doc.Ref) {
+			continue
+		}
+		if err := r.tx.Delete(doc.Ref); err != nil {
+			return errors.Wrap(err, "error deleting the public key doc")
+		}
+	}
+
+	if err := r.tx.Delete(r.relayListDocRef(publicKey)); err != nil {
+		return errors.Wrap(err, "error deleting the relay list doc")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) GetCursors(ctx context.Context, address domain.RelayAddress) (map[domain.PublicKey]time.Time, error) {
+	iter := r.tx.Documents(
+		r.client.
+			Collection(collectionRelays).
+			Doc(r.relayAddressAsKey(address)).
+			Collection(collectionRelaysPublicKeys).
+			Where(collectionRelaysPublicKeysFieldCursor, ">", time.Time{}),
+	)
+
+	result := make(map[domain.PublicKey]time.Time)
+	for {
+		doc, err := iter.Next()
+		if err != nil {
+			if err == iterator.Done {
+				break
+			}
+			return nil, errors.Wrap(err, "error calling iter next")
+		}
+
+		publicKey, err := domain.NewPublicKeyFromHex(doc.Ref.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a public key")
+		}
+
+		cursor, ok := doc.Data()[collectionRelaysPublicKeysFieldCursor].(time.Time)
+		if !ok {
+			return nil, errors.New("invalid cursor")
+		}
+		result[publicKey] = cursor
+	}
+
+	return result, nil
+}
+
+// SaveCursors reads the public key docs first as firestore transactions
+// require all reads to happen before writes.
+func (r *RelayRepository) SaveCursors(ctx context.Context, address domain.RelayAddress, cursors map[domain.PublicKey]time.Time) error {
+	var pubKeyDocRefs []*firestore.DocumentRef
+	for publicKey := range cursors {
+		pubKeyDocRefs = append(pubKeyDocRefs, r.client.
+			Collection(collectionRelays).
+			Doc(r.relayAddressAsKey(address)).
+			Collection(collectionRelaysPublicKeys).
+			Doc(publicKey.Hex()),
+		)
+	}
+
+	docs, err := r.tx.GetAll(pubKeyDocRefs)
+	if err != nil {
+		return errors.Wrap(err, "error getting the public key docs")
+	}
+
+	for _, doc := range docs {
+		if !doc.Exists() {
+			continue
+		}
+
+		publicKey, err := domain.NewPublicKeyFromHex(doc.Ref.ID)
+		if err != nil {
+			return errors.Wrap(err, "error creating a public key")
+		}
+
+		cursor := cursors[publicKey]
+		if previous, ok := doc.Data()[collectionRelaysPublicKeysFieldCursor].(time.Time); ok && !previous.Before(cursor) {
+			continue
+		}
+
+		if err := r.tx.Update(doc.Ref, []firestore.Update{
+			{
+				Path:  collectionRelaysPublicKeysFieldCursor,
+				Value: ensureType[time.Time](cursor),
+			},
+		}); err != nil {
+			return errors.Wrap(err, "error updating the public key doc")
+		}
+	}
+
+	return nil
+}
+
+// SaveRelayList reads the relay list doc and the docs of both the previous
+// and the new relays first as firestore transactions require all reads to
+// happen before writes.
+func (r *RelayRepository) SaveRelayList(ctx context.Context, relayList domain.RelayList) error {
+	relayListDoc, err := r.tx.Get(r.relayListDocRef(relayList.Author()))
+	if err != nil && status.Code(err) != codes.NotFound {
+		return errors.Wrap(err, "error getting the relay list doc")
+	}
+
+	var previousRelays []domain.RelayAddress
+	if relayListDoc.Exists() {
+		data := relayListDoc.Data()
+
+		if createdAt, ok := data[collectionRelayListsFieldCreatedAt].(time.Time); ok && relayList.Event().CreatedAt().Before(createdAt) {
+			return nil
+		}
+
+		relays, _ := data[collectionRelayListsFieldRelays].([]any)
+		for _, v := range relays {
+			s, ok := v.(string)
+			if !ok {
+				return errors.New("invalid relay")
+			}
+
+			relayAddress, err := domain.NewRelayAddress(s)
+			if err != nil {
+				return errors.Wrapf(err, "error creating a relay address from '%s'", s)
+			}
+
+			previousRelays = append(previousRelays, relayAddress)
+		}
+	}
+
+	currentRelays := relayList.ReadRelays()
+	isCurrent := internal.NewSet(currentRelays)
+
+	var removedRelays []domain.RelayAddress
+	for _, relayAddress := range previousRelays {
+		if !isCurrent.Contains(relayAddress) {
+			removedRelays = append(removedRelays, relayAddress)
+		}
+	}
+
+	var docRefs []*firestore.DocumentRef
+	for _, relayAddress := range removedRelays {
+		docRefs = append(docRefs, r.pubKeyDocRef(relayAddress, relayList.Author()))
+	}
+	for _, relayAddress := range currentRelays {
+		docRefs = append(docRefs, r.relayDocRef(relayAddress), r.pubKeyDocRef(relayAddress, relayList.Author()))
+	}
+
+	docs, err := r.tx.GetAll(docRefs)
+	if err != nil {
+		return errors.Wrap(err, "error getting the docs")
+	}
+
+	for _, doc := range docs[:len(removedRelays)] {
+		if !doc.Exists() {
+			continue
+		}
+
+		if !isRegistered(doc.Data(), collectionRelaysPublicKeysFieldRegistered) {
+			if err := r.tx.Delete(doc.Ref); err != nil {
+				return errors.Wrap(err, "error deleting the public key doc")
+			}
+			continue
+		}
+
+		if err := r.tx.Update(doc.Ref, []firestore.Update{
+			{
+				Path:  collectionRelaysPublicKeysFieldInRelayList,
+				Value: ensureType[bool](false),
+			},
+		}); err != nil {
+			return errors.Wrap(err, "error updating the public key doc")
+		}
+	}
+
+	now := time.Now()
+	docs = docs[len(removedRelays):]
+	for i, relayAddress := range currentRelays {
+		relayDoc := docs[2*i]
+		pubKeyDoc := docs[2*i+1]
+
+		relayDocData := map[string]any{
+			collectionRelaysFieldAddress:          ensureType[string](relayAddress.String()),
+			collectionRelaysFieldUpdatedTimestamp: ensureType[time.Time](now),
+			collectionRelaysFieldInRelayList:      ensureType[bool](true),
+		}
+		if !relayDoc.Exists() {
+			relayDocData[collectionRelaysFieldRegistered] = ensureType[bool](false)
+		}
+		if err := r.tx.Set(relayDoc.Ref, relayDocData, firestore.MergeAll); err != nil {
+			return errors.Wrap(err, "error creating the relay doc")
+		}
+
+		pubKeyDocData := map[string]any{
+			collectionRelaysPublicKeysFieldPublicKey:        ensureType[string](relayList.Author().Hex()),
+			collectionRelaysPublicKeysFieldUpdatedTimestamp: ensureType[time.Time](now),
+			collectionRelaysPublicKeysFieldInRelayList:      ensureType[bool](true),
+		}
+		if !pubKeyDoc.Exists() {
+			pubKeyDocData[collectionRelaysPublicKeysFieldRegistered] = ensureType[bool](false)
+		}
+		if err := r.tx.Set(pubKeyDoc.Ref, pubKeyDocData, firestore.MergeAll); err != nil {
+			return errors.Wrap(err, "error creating the public key doc")
+		}
+	}
+
+	var relays []string
+	for _, relayAddress := range currentRelays {
+		relays = append(relays, relayAddress.String())
+	}
+
+	relayListDocData := map[string]any{
+		collectionRelayListsFieldCreatedAt: ensureType[time.Time](relayList.Event().CreatedAt()),
+		collectionRelayListsFieldRelays:    ensureType[[]string](relays),
+	}
+	if err := r.tx.Set(r.relayListDocRef(relayList.Author()), relayListDocData); err != nil {
+		return errors.Wrap(err, "error saving the relay list doc")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) relayDocRef(address domain.RelayAddress) *firestore.DocumentRef {
+	return r.client.Collection(collectionRelays).Doc(r.relayAddressAsKey(address))
+}
+
+func (r *RelayRepository) pubKeyDocRef(address domain.RelayAddress, publicKey domain.PublicKey) *firestore.DocumentRef {
+	return r.relayDocRef(address).Collection(collectionRelaysPublicKeys).Doc(publicKey.Hex())
+}
+
+func (r *RelayRepository) relayListDocRef(publicKey domain.PublicKey) *firestore.DocumentRef {
+	return r.client.Collection(collectionRelayLists).Doc(publicKey.Hex())
+}
+
+func (r *RelayRepository) isRelayPublicKeyDoc(ref *firestore.DocumentRef) bool {
+	relayDocRef := ref.Parent.Parent
+	return relayDocRef != nil && relayDocRef.Parent.ID == collectionRelays
+}
+
 func (r *RelayRepository) relayAddressAsKey(v domain.RelayAddress) string {
 	return hex.EncodeToString([]byte(v.String()))
 }
@@ -150,3 +446,26 @@ func (r *RelayRepository) relayAddressFromKey(v string) (domain.RelayAddress, er
 
 	return addr, nil
 }
+
+// isRegistered treats docs without the field as registered as all docs were
+// created from registrations before relay lists were introduced.
+func isRegistered(data map[string]any, field string) bool {
+	registered, ok := data[field].(bool)
+	return !ok || registered
+}
+
+func isInRelayList(data map[string]any, field string) bool {
+	inRelayList, ok := data[field].(bool)
+	return ok && inRelayList
+}
+
+func relaySources(registered, inRelayList bool) []domain.RelaySource {
+	var sources []domain.RelaySource
+	if registered {
+		sources = append(sources, domain.RelaySourceRegistration)
+	}
+	if inRelayList {
+		sources = append(sources, domain.RelaySourceRelayList)
+	}
+	return sources
+}
@@ -0,0 +1,161 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	metadataQueryTimeout  = 5 * time.Second
+	cacheMetadataFor      = 60 * time.Minute
+	metadataCacheMaxItems = 10000
+)
+
+// RelayMetadataProvider looks up metadata using a fixed set of relays. The
+// results are cached as the same people tend to mention others repeatedly.
+type RelayMetadataProvider struct {
+	relays []string
+	logger logging.Logger
+
+	cacheLock sync.Mutex
+	cache     map[domain.PublicKey]cachedMetadata
+}
+
+func NewRelayMetadataProvider(cfg config.Config, logger logging.Logger) *RelayMetadataProvider {
+	return &RelayMetadataProvider{
+		relays: cfg.MetadataRelays(),
+		logger: logger.New("relayMetadataProvider"),
+		cache:  make(map[domain.PublicKey]cachedMetadata),
+	}
+}
+
+func (p *RelayMetadataProvider) GetMetadata(ctx context.Context, publicKey domain.PublicKey) (domain.Metadata, bool, error) {
+	if cached, ok := p.getCached(publicKey); ok {
+		return cached.metadata, cached.found, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, metadataQueryTimeout)
+	defer cancel()
+
+	var (
+		newest   *domain.Event
+		anyRelay bool
+	)
+
+	for _, relay := range p.relays {
+		event, ok, err := p.query(ctx, relay, publicKey)
+		if err != nil {
+			p.logger.Debug().
+				WithError(err).
+				WithField("relay", relay).
+				Message("error querying the relay for metadata")
+			continue
+		}
+
+		anyRelay = true
+
+		if ok && (newest == nil || event.CreatedAt().After(newest.CreatedAt())) {
+			newest = &event
+		}
+	}
+
+	if !anyRelay {
+		return domain.Metadata{}, false, errors.New("all relays failed")
+	}
+
+	if newest == nil {
+		p.setCached(publicKey, cachedMetadata{found: false})
+		return domain.Metadata{}, false, nil
+	}
+
+	metadata, err := domain.NewMetadata(*newest)
+	if err != nil {
+		return domain.Metadata{}, false, errors.Wrap(err, "error reading the metadata")
+	}
+
+	p.setCached(publicKey, cachedMetadata{metadata: metadata, found: true})
+	return metadata, true, nil
+}
+
+func (p *RelayMetadataProvider) query(ctx context.Context, address string, publicKey domain.PublicKey) (domain.Event, bool, error) {
+	relay, err := nostr.RelayConnect(ctx, address)
+	if err != nil {
+		return domain.Event{}, false, errors.Wrap(err, "error connecting")
+	}
+	defer relay.Close()
+
+	libevents, err := relay.QuerySync(ctx, nostr.Filter{
+		Kinds:   []int{domain.EventKindMetadata.Int()},
+		Authors: []string{publicKey.Hex()},
+		Limit:   1,
+	})
+	if err != nil {
+		return domain.Event{}, false, errors.Wrap(err, "error querying")
+	}
+
+	var newest *domain.Event
+	for _, libevent := range libevents {
+		event, err := domain.NewEvent(*libevent)
+		if err != nil {
+			return domain.Event{}, false, errors.Wrap(err, "error creating an event")
+		}
+
+		if event.PubKey() != publicKey || event.Kind() != domain.EventKindMetadata {
+			return domain.Event{}, false, errors.New("relay returned an event which doesn't match the filter")
+		}
+
+		if newest == nil || event.CreatedAt().After(newest.CreatedAt()) {
+			newest = &event
+		}
+	}
+
+	if newest == nil {
+		return domain.Event{}, false, nil
+	}
+
+	return *newest, true, nil
+}
+
+func (p *RelayMetadataProvider) getCached(publicKey domain.PublicKey) (cachedMetadata, bool) {
+	p.cacheLock.Lock()
+	defer p.cacheLock.Unlock()
+
+	cached, ok := p.cache[publicKey]
+	if !ok || time.Since(cached.timestamp) > cacheMetadataFor {
+		return cachedMetadata{}, false
+	}
+	return cached, true
+}
+
+func (p *RelayMetadataProvider) setCached(publicKey domain.PublicKey, cached cachedMetadata) {
+	p.cacheLock.Lock()
+	defer p.cacheLock.Unlock()
+
+	if len(p.cache) >= metadataCacheMaxItems {
+		for key, value := range p.cache {
+			if time.Since(value.timestamp) > cacheMetadataFor {
+				delete(p.cache, key)
+			}
+		}
+	}
+
+	if len(p.cache) >= metadataCacheMaxItems {
+		p.cache = make(map[domain.PublicKey]cachedMetadata)
+	}
+
+	cached.timestamp = time.Now()
+	p.cache[publicKey] = cached
+}
+
+type cachedMetadata struct {
+	metadata  domain.Metadata
+	found     bool
+	timestamp time.Time
+}
@@ -0,0 +1,105 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	nip11Timeout           = 10 * time.Second
+	nip11MaxDocumentLength = 1024 * 1024
+)
+
+// NIP11RelayInformationProvider retrieves relay information documents as
+// described in NIP-11.
+type NIP11RelayInformationProvider struct {
+	client *http.Client
+}
+
+func NewNIP11RelayInformationProvider() *NIP11RelayInformationProvider {
+	return &NIP11RelayInformationProvider{
+		client: &http.Client{
+			Timeout: nip11Timeout,
+		},
+	}
+}
+
+func (p *NIP11RelayInformationProvider) GetRelayInformation(ctx context.Context, address domain.RelayAddress) (domain.RelayInformation, error) {
+	document, err := p.getDocument(ctx, address)
+	if err != nil {
+		return domain.RelayInformation{}, errors.Wrap(err, "error getting the relay information document")
+	}
+
+	limitations, err := domain.NewRelayLimitations(
+		document.Limitation.MaxMessageLength,
+		document.Limitation.MaxSubscriptions,
+		document.Limitation.MaxFilters,
+		document.Limitation.AuthRequired,
+		document.Limitation.PaymentRequired,
+	)
+	if err != nil {
+		return domain.RelayInformation{}, errors.Wrap(err, "error creating limitations")
+	}
+
+	return domain.NewRelayInformation(document.SupportedNIPs, limitations), nil
+}
+
+func (p *NIP11RelayInformationProvider) getDocument(ctx context.Context, address domain.RelayAddress) (nip11Document, error) {
+	u, err := url.Parse(address.String())
+	if err != nil {
+		return nip11Document{}, errors.Wrap(err, "error parsing the address")
+	}
+
+	switch u.Scheme {
+	case "ws":
+		u.Scheme = "http"
+	case "wss":
+		u.Scheme = "https"
+	default:
+		return nip11Document{}, fmt.Errorf("unknown scheme '%s'", u.Scheme)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nip11Document{}, errors.Wrap(err, "error creating the request")
+	}
+	req.Header.Set("Accept", "application/nostr+json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nip11Document{}, errors.Wrap(err, "error performing the request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nip11Document{}, fmt.Errorf("unexpected status code '%d'", resp.StatusCode)
+	}
+
+	var document nip11Document
+	if err := json.NewDecoder(io.LimitReader(resp.Body, nip11MaxDocumentLength)).Decode(&document); err != nil {
+		return nip11Document{}, errors.Wrap(err, "error decoding the document")
+	}
+
+	return document, nil
+}
+
+type nip11Document struct {
+	SupportedNIPs []int           `json:"supported_nips"`
+	Limitation    nip11Limitation `json:"limitation"`
+}
+
+type nip11Limitation struct {
+	MaxMessageLength int  `json:"max_message_length"`
+	MaxSubscriptions int  `json:"max_subscriptions"`
+	MaxFilters       int  `json:"max_filters"`
+	AuthRequired     bool `json:"auth_required"`
+	PaymentRequired  bool `json:"payment_required"`
+}
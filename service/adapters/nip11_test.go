@@ -0,0 +1,63 @@
+package adapters_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/adapters"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNIP11RelayInformationProvider_GetRelayInformation(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/nostr+json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/nostr+json")
+		_, _ = w.Write([]byte(`{
+			"name": "some relay",
+			"supported_nips": [1, 11],
+			"limitation": {
+				"max_message_length": 16384,
+				"max_subscriptions": 20,
+				"max_filters": 5,
+				"auth_required": true,
+				"payment_required": false
+			}
+		}`))
+	}))
+	t.Cleanup(server.Close)
+
+	address, err := domain.NewRelayAddress(strings.Replace(server.URL, "http://", "ws://", 1))
+	require.NoError(t, err)
+
+	provider := adapters.NewNIP11RelayInformationProvider()
+	information, err := provider.GetRelayInformation(ctx, address)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 11}, information.SupportedNIPs())
+	require.True(t, information.ShouldBeSkipped())
+
+	limitations := information.Limitations()
+	require.True(t, limitations.AuthRequired())
+	require.False(t, limitations.PaymentRequired())
+
+	maxMessageLength, ok := limitations.MaxMessageLength()
+	require.True(t, ok)
+	require.Equal(t, 16384, maxMessageLength)
+
+	maxSubscriptions, ok := limitations.MaxSubscriptions()
+	require.True(t, ok)
+	require.Equal(t, 20, maxSubscriptions)
+
+	maxFilters, ok := limitations.MaxFilters()
+	require.True(t, ok)
+	require.Equal(t, 5, maxFilters)
+}
@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"io/fs"
+	"sort"
+
+	"github.com/boreq/errors"
+)
+
+// Arbitrary number used to make sure that only one instance of the service
+// applies migrations at the same time.
+const migrationsAdvisoryLockID = 4237190815
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migrate applies migrations which weren't applied yet. Migrations are applied
+// in lexicographical order of their file names.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrationsFS, "migrations/*.sql")
+	if err != nil {
+		return errors.Wrap(err, "error listing migrations")
+	}
+	sort.Strings(names)
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "error starting the transaction")
+	}
+
+	if err := migrate(ctx, tx, names); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			return errors.Wrapf(err, "error rolling back the transaction (%s)", rollbackErr)
+		}
+		return errors.Wrap(err, "error migrating")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+
+	return nil
+}
+
+func migrate(ctx context.Context, tx *sql.Tx, names []string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, migrationsAdvisoryLockID); err != nil {
+		return errors.Wrap(err, "error acquiring the lock")
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL
+		)`,
+	); err != nil {
+		return errors.Wrap(err, "error creating the migrations table")
+	}
+
+	for _, name := range names {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE name = $1)`, name).Scan(&exists); err != nil {
+			return errors.Wrapf(err, "error checking if migration '%s' was applied", name)
+		}
+
+		if exists {
+			continue
+		}
+
+		b, err := migrationsFS.ReadFile(name)
+		if err != nil {
+			return errors.Wrapf(err, "error reading migration '%s'", name)
+		}
+
+		if _, err := tx.ExecContext(ctx, string(b)); err != nil {
+			return errors.Wrapf(err, "error applying migration '%s'", name)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (name, applied_at) VALUES ($1, now())`, name); err != nil {
+			return errors.Wrapf(err, "error recording migration '%s'", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+CREATE TABLE relays (
+    address           TEXT PRIMARY KEY,
+    updated_timestamp TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX relays_updated_timestamp_idx ON relays (updated_timestamp);
+
+CREATE TABLE relays_public_keys (
+    address           TEXT NOT NULL REFERENCES relays (address) ON DELETE CASCADE,
+    public_key        TEXT NOT NULL,
+    updated_timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (address, public_key)
+);
+
+CREATE TABLE public_keys (
+    public_key TEXT PRIMARY KEY
+);
+
+CREATE TABLE public_keys_push_tokens (
+    public_key        TEXT NOT NULL REFERENCES public_keys (public_key) ON DELETE CASCADE,
+    platform          TEXT NOT NULL,
+    token             TEXT NOT NULL,
+    updated_timestamp TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (public_key, platform, token)
+);
+
+CREATE TABLE events (
+    id         TEXT PRIMARY KEY,
+    public_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    kind       INTEGER NOT NULL,
+    raw        BYTEA NOT NULL
+);
+
+CREATE INDEX events_public_key_idx ON events (public_key);
+CREATE INDEX events_kind_idx ON events (kind);
+CREATE INDEX events_created_at_idx ON events (created_at);
+
+CREATE TABLE events_notifications (
+    uuid       TEXT PRIMARY KEY,
+    event_id   TEXT NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    platform   TEXT NOT NULL,
+    token      TEXT NOT NULL,
+    payload    BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX events_notifications_event_id_idx ON events_notifications (event_id);
+
+CREATE TABLE events_deliveries (
+    event_id   TEXT NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    mention    TEXT NOT NULL,
+    platform   TEXT NOT NULL,
+    token      TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (event_id, mention, platform, token)
+);
+
+CREATE TABLE tags (
+    name        TEXT NOT NULL,
+    first_value TEXT NOT NULL,
+    event_id    TEXT NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (name, first_value, event_id)
+);
+
+CREATE INDEX tags_event_id_idx ON tags (event_id);
+
+-- Outbox used for passing messages between transactions and subscribers.
+-- Messages are inserted in the same transaction as the changes which they
+-- describe and removed once they are acknowledged.
+CREATE TABLE pubsub_messages (
+    id            BIGSERIAL PRIMARY KEY,
+    topic         TEXT NOT NULL,
+    uuid          TEXT NOT NULL,
+    payload       BYTEA NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL,
+    visible_after TIMESTAMPTZ NOT NULL,
+    attempts      INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX pubsub_messages_topic_visible_after_idx ON pubsub_messages (topic, visible_after);
@@ -0,0 +1 @@
+ALTER TABLE public_keys_push_tokens ADD COLUMN notification_mode TEXT NOT NULL DEFAULT 'silent';
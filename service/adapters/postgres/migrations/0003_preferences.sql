@@ -0,0 +1 @@
+ALTER TABLE public_keys_push_tokens ADD COLUMN preferences TEXT NOT NULL DEFAULT '{}';
@@ -0,0 +1,5 @@
+CREATE TABLE mute_lists (
+    public_key TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    raw        BYTEA NOT NULL
+);
@@ -0,0 +1 @@
+ALTER TABLE relays_public_keys ADD COLUMN cursor_timestamp TIMESTAMPTZ;
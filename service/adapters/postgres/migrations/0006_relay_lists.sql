@@ -0,0 +1,12 @@
+ALTER TABLE relays
+    ADD COLUMN registered    BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN in_relay_list BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE relays_public_keys
+    ADD COLUMN registered    BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN in_relay_list BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE relay_lists (
+    public_key TEXT PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,3 @@
+ALTER TABLE events_deliveries ADD COLUMN sent_at TIMESTAMPTZ;
+
+UPDATE events_deliveries SET sent_at = created_at;
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/boreq/errors"
+	"github.com/jackc/pgx/v5/pgconn"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+)
+
+const (
+	driverName = "pgx"
+
+	// Transactions are serializable so that they behave like Firestore
+	// transactions. Postgres aborts them if they conflict with other
+	// transactions in which case they have to be retried.
+	maxTransactionAttempts = 5
+
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+func NewDB(ctx context.Context, config config.Config) (*sql.DB, error) {
+	db, err := sql.Open(driverName, config.PostgresURL())
+	if err != nil {
+		return nil, errors.Wrap(err, "error opening the database")
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		return nil, errors.Wrap(err, "error pinging the database")
+	}
+
+	if err := Migrate(ctx, db); err != nil {
+		return nil, errors.Wrap(err, "error running migrations")
+	}
+
+	return db, nil
+}
+
+type AdaptersFactoryFn func(*sql.Tx) (app.Adapters, error)
+
+type TransactionProvider struct {
+	fn     AdaptersFactoryFn
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewTransactionProvider(db *sql.DB, fn AdaptersFactoryFn, logger logging.Logger) *TransactionProvider {
+	return &TransactionProvider{
+		fn:     fn,
+		db:     db,
+		logger: logger.New("postgresTransactionProvider"),
+	}
+}
+
+func (t *TransactionProvider) Transact(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	return t.transactWithRetries(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}, f)
+}
+
+func (t *TransactionProvider) TransactReadOnly(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	return t.transactWithRetries(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable, ReadOnly: true}, f)
+}
+
+func (t *TransactionProvider) transactWithRetries(ctx context.Context, opts *sql.TxOptions, f func(context.Context, app.Adapters) error) error {
+	var err error
+	for i := 0; i < maxTransactionAttempts; i++ {
+		err = t.transact(ctx, opts, f)
+		if err == nil {
+			return nil
+		}
+
+		if !isRetryable(err) {
+			break
+		}
+
+		t.logger.Debug().WithError(err).WithField("attempt", i).Message("retrying the transaction")
+	}
+	return errors.Wrap(err, "transaction returned an error")
+}
+
+func (t *TransactionProvider) transact(ctx context.Context, opts *sql.TxOptions, f func(context.Context, app.Adapters) error) error {
+	tx, err := t.db.BeginTx(ctx, opts)
+	if err != nil {
+		return errors.Wrap(err, "error starting the transaction")
+	}
+
+	if err := t.runInTransaction(ctx, tx, f); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			t.logger.Error().WithError(rollbackErr).Message("error rolling back the transaction")
+		}
+		return errors.Wrap(err, "error running the transaction")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "error committing the transaction")
+	}
+
+	return nil
+}
+
+func (t *TransactionProvider) runInTransaction(ctx context.Context, tx *sql.Tx, f func(context.Context, app.Adapters) error) error {
+	adapters, err := t.fn(tx)
+	if err != nil {
+		return errors.Wrap(err, "error building the adapters")
+	}
+
+	if err := f(ctx, adapters); err != nil {
+		return errors.Wrap(err, "error calling the provided function")
+	}
+
+	return nil
+}
+
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == codeSerializationFailure || pgErr.Code == codeDeadlockDetected
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/adapters/pubsub"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type Publisher struct {
+	tx *sql.Tx
+}
+
+func NewPublisher(tx *sql.Tx) *Publisher {
+	return &Publisher{tx: tx}
+}
+
+func (p *Publisher) PublishEventSaved(ctx context.Context, id domain.EventId) error {
+	payload := pubsub.EventSavedPayload{EventId: id.Hex()}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the payload")
+	}
+
+	return publishInTransaction(ctx, p.tx, pubsub.TopicEventSaved, payloadJSON)
+}
+
+func publishInTransaction(ctx context.Context, tx *sql.Tx, topic string, payload []byte) error {
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO pubsub_messages (topic, uuid, payload, created_at, visible_after)
+		VALUES ($1, $2, $3, now(), now())`,
+		topic,
+		watermill.NewULID(),
+		payload,
+	); err != nil {
+		return errors.Wrap(err, "error inserting the message")
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+)
+
+// query builds queries with numbered placeholders.
+type query struct {
+	base    string
+	where   []string
+	orderBy string
+	limit   int
+	args    []any
+}
+
+func newQuery(base string) *query {
+	return &query{base: base}
+}
+
+// Where adds a condition. Each %s in the condition is replaced with a
+// placeholder for the corresponding argument.
+func (q *query) Where(condition string, args ...any) {
+	var placeholders []any
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		placeholders = append(placeholders, fmt.Sprintf("$%d", len(q.args)))
+	}
+	q.where = append(q.where, fmt.Sprintf(condition, placeholders...))
+}
+
+// WhereIn adds a condition checking if the column is equal to any of the
+// values.
+func (q *query) WhereIn(column string, values []any) {
+	q.Where(column+" IN ("+placeholders(len(values))+")", values...)
+}
+
+func (q *query) OrderBy(orderBy string) {
+	q.orderBy = orderBy
+}
+
+func (q *query) Limit(limit int) {
+	q.limit = limit
+}
+
+func (q *query) Build() (string, []any) {
+	s := q.base
+	if len(q.where) > 0 {
+		s += " WHERE " + strings.Join(q.where, " AND ")
+	}
+	if q.orderBy != "" {
+		s += " ORDER BY " + q.orderBy
+	}
+	if q.limit > 0 {
+		s += fmt.Sprintf(" LIMIT %d", q.limit)
+	}
+	return s, q.args
+}
+
+// placeholders returns n comma separated verbs for use with Where.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("%s, ", n), ", ")
+}
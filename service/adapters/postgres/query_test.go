@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Build(t *testing.T) {
+	q := newQuery("SELECT raw FROM events")
+	q.WhereIn("kind", []any{1, 3})
+	q.Where("created_at >= %s", "since")
+	q.Where("id IN (SELECT event_id FROM tags WHERE name = %s AND first_value IN ("+placeholders(2)+"))", "p", "a", "b")
+	q.OrderBy("created_at DESC")
+	q.Limit(10)
+
+	s, args := q.Build()
+	require.Equal(t,
+		"SELECT raw FROM events WHERE kind IN ($1, $2) AND created_at >= $3 AND id IN (SELECT event_id FROM tags WHERE name = $4 AND first_value IN ($5, $6)) ORDER BY created_at DESC LIMIT 10",
+		s,
+	)
+	require.Equal(t, []any{1, 3, "since", "p", "a", "b"}, args)
+}
+
+func TestQuery_BuildWithoutConditions(t *testing.T) {
+	s, args := newQuery("SELECT raw FROM events").Build()
+	require.Equal(t, "SELECT raw FROM events", s)
+	require.Empty(t, args)
+}
+
+func TestEventsQueryForFilter(t *testing.T) {
+	author, _ := fixtures.SomeKeyPair()
+	since := time.Unix(1000, 0)
+	sinceTimestamp := nostr.Timestamp(since.Unix())
+
+	filter, err := domain.NewFilter(nostr.Filter{
+		Kinds:   []int{1},
+		Authors: []string{author.Hex()},
+		Tags: nostr.TagMap{
+			"p": []string{"pvalue"},
+			"e": []string{"evalue1", "evalue2"},
+		},
+		Since: &sinceTimestamp,
+		Limit: 5,
+	})
+	require.NoError(t, err)
+
+	s, args := eventsQueryForFilter(filter).Build()
+	require.Equal(t,
+		"SELECT raw FROM events WHERE kind IN ($1) AND public_key IN ($2)"+
+			" AND id IN (SELECT event_id FROM tags WHERE name = $3 AND first_value IN ($4, $5))"+
+			" AND id IN (SELECT event_id FROM tags WHERE name = $6 AND first_value IN ($7))"+
+			" AND created_at >= $8 ORDER BY created_at DESC LIMIT 5",
+		s,
+	)
+	require.Equal(t, []any{1, author.Hex(), "e", "evalue1", "evalue2", "p", "pvalue", since}, args)
+}
@@ -0,0 +1,354 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+)
+
+type EventRepository struct {
+	tx *sql.Tx
+}
+
+func NewEventRepository(tx *sql.Tx) *EventRepository {
+	return &EventRepository{tx: tx}
+}
+
+func (e *EventRepository) Save(event domain.Event) error {
+	if _, err := e.tx.Exec(`
+		INSERT INTO events (id, public_key, created_at, kind, raw)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING`,
+		event.Id().Hex(),
+		event.PubKey().Hex(),
+		event.CreatedAt(),
+		event.Kind().Int(),
+		event.Raw(),
+	); err != nil {
+		return errors.Wrap(err, "error inserting the event")
+	}
+	return nil
+}
+
+func (e *EventRepository) Exists(ctx context.Context, id domain.EventId) (bool, error) {
+	var exists bool
+	if err := e.tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM events WHERE id = $1)`, id.Hex()).Scan(&exists); err != nil {
+		return false, errors.Wrap(err, "error checking if the event exists")
+	}
+	return exists, nil
+}
+
+func (e *EventRepository) Get(ctx context.Context, id domain.EventId) (domain.Event, error) {
+	var raw []byte
+	if err := e.tx.QueryRowContext(ctx, `SELECT raw FROM events WHERE id = $1`, id.Hex()).Scan(&raw); err != nil {
+		return domain.Event{}, errors.Wrap(err, "error getting the event")
+	}
+
+	event, err := domain.NewEventFromRaw(raw)
+	if err != nil {
+		return domain.Event{}, errors.Wrap(err, "error creating the event")
+	}
+
+	return event, nil
+}
+
+func (e *EventRepository) SaveNotificationForEvent(notification notifications.Notification) error {
+	createdAt := notification.CreatedAt()
+	if createdAt == nil {
+		return errors.New("new notifications should always have the createdAt value populated")
+	}
+
+	if _, err := e.tx.Exec(`
+		INSERT INTO events_notifications (uuid, event_id, platform, token, payload, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (uuid) DO NOTHING`,
+		notification.UUID().String(),
+		notification.Event().Id().Hex(),
+		notification.PushToken().Platform().String(),
+		notification.PushToken().Token(),
+		notification.Payload(),
+		*createdAt,
+	); err != nil {
+		return errors.Wrap(err, "error inserting the notification")
+	}
+
+	return nil
+}
+
+func (e *EventRepository) GetNotifications(ctx context.Context, id domain.EventId) ([]notifications.Notification, error) {
+	event, err := e.Get(ctx, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting the event")
+	}
+
+	rows, err := e.tx.QueryContext(ctx, `
+		SELECT uuid, platform, token, payload, created_at
+		FROM events_notifications
+		WHERE event_id = $1
+		ORDER BY created_at`,
+		id.Hex(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	var result []notifications.Notification
+	for rows.Next() {
+		var (
+			uuidString string
+			platform   string
+			token      string
+			payload    []byte
+			createdAt  time.Time
+		)
+
+		if err := rows.Scan(&uuidString, &platform, &token, &payload, &createdAt); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+
+		uuid, err := notifications.NewNotificationUUIDFromString(uuidString)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating an uuid")
+		}
+
+		pushToken, err := readPushToken(platform, token)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a token")
+		}
+
+		notification, err := notifications.NewNotificationFromHistory(event, uuid, pushToken, payload, &createdAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a notification")
+		}
+
+		result = append(result, notification)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
+
+func (e *EventRepository) ClaimDelivery(delivery notifications.Delivery) error {
+	if _, err := e.tx.Exec(`
+		INSERT INTO events_deliveries (event_id, mention, platform, token, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT DO NOTHING`,
+		delivery.EventId().Hex(),
+		delivery.Mention().Hex(),
+		delivery.PushToken().Platform().String(),
+		delivery.PushToken().Token(),
+		time.Now(),
+	); err != nil {
+		return errors.Wrap(err, "error inserting the delivery")
+	}
+	return nil
+}
+
+func (e *EventRepository) MarkDeliverySent(delivery notifications.Delivery) error {
+	if _, err := e.tx.Exec(`
+		UPDATE events_deliveries
+		SET sent_at = $5
+		WHERE event_id = $1 AND mention = $2 AND platform = $3 AND token = $4`,
+		delivery.EventId().Hex(),
+		delivery.Mention().Hex(),
+		delivery.PushToken().Platform().String(),
+		delivery.PushToken().Token(),
+		time.Now(),
+	); err != nil {
+		return errors.Wrap(err, "error updating the delivery")
+	}
+	return nil
+}
+
+func (e *EventRepository) DeleteDelivery(delivery notifications.Delivery) error {
+	if _, err := e.tx.Exec(`
+		DELETE FROM events_deliveries
+		WHERE event_id = $1 AND mention = $2 AND platform = $3 AND token = $4`,
+		delivery.EventId().Hex(),
+		delivery.Mention().Hex(),
+		delivery.PushToken().Platform().String(),
+		delivery.PushToken().Token(),
+	); err != nil {
+		return errors.Wrap(err, "error deleting the delivery")
+	}
+	return nil
+}
+
+func (e *EventRepository) DeliveryExists(ctx context.Context, delivery notifications.Delivery) (bool, error) {
+	var exists bool
+	if err := e.tx.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1
+			FROM events_deliveries
+			WHERE event_id = $1 AND mention = $2 AND platform = $3 AND token = $4
+		)`,
+		delivery.EventId().Hex(),
+		delivery.Mention().Hex(),
+		delivery.PushToken().Platform().String(),
+		delivery.PushToken().Token(),
+	).Scan(&exists); err != nil {
+		return false, errors.Wrap(err, "error checking if the delivery exists")
+	}
+	return exists, nil
+}
+
+// DeleteByPublicKey deletes all events and associated notifications,
+// deliveries and tags for a given public key.
+func (e *EventRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	if _, err := e.tx.ExecContext(ctx, `DELETE FROM events WHERE public_key = $1`, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the events")
+	}
+	return nil
+}
+
+func (e *EventRepository) GetEvents(ctx context.Context, filters domain.Filters) <-chan app.EventOrError {
+	ch := make(chan app.EventOrError)
+	go e.getEvents(ctx, filters, ch)
+	return ch
+}
+
+func (e *EventRepository) getEvents(ctx context.Context, filters domain.Filters, ch chan<- app.EventOrError) {
+	defer close(ch)
+
+	events, err := e.loadEventsForFilters(ctx, filters)
+	if err != nil {
+		sendErr(ctx, ch, err)
+		return
+	}
+
+	for _, event := range events {
+		select {
+		case ch <- app.NewEventOrErrorWithEvent(event):
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (e *EventRepository) loadEventsForFilters(ctx context.Context, filters domain.Filters) ([]domain.Event, error) {
+	events := make(map[domain.EventId]domain.Event)
+
+	for _, filter := range filters.Filters() {
+		query, args := eventsQueryForFilter(filter).Build()
+		if err := e.loadEvents(ctx, query, args, events); err != nil {
+			return nil, errors.Wrap(err, "error loading events")
+		}
+	}
+
+	// it is in my opinion unclear how to apply the limit field with multiple filters
+	var result []domain.Event
+	for _, event := range events {
+		if filters.Match(event) {
+			result = append(result, event)
+		}
+	}
+	return result, nil
+}
+
+func (e *EventRepository) loadEvents(ctx context.Context, query string, args []any, events map[domain.EventId]domain.Event) error {
+	rows, err := e.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var raw []byte
+		if err := rows.Scan(&raw); err != nil {
+			return errors.Wrap(err, "error scanning")
+		}
+
+		event, err := domain.NewEventFromRaw(raw)
+		if err != nil {
+			return errors.Wrap(err, "error creating the event")
+		}
+
+		events[event.Id()] = event
+	}
+
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "rows error")
+	}
+
+	return nil
+}
+
+func eventsQueryForFilter(filter domain.Filter) *query {
+	q := newQuery(`SELECT raw FROM events`)
+
+	if ids := filter.Ids(); len(ids) > 0 {
+		var values []any
+		for _, id := range ids {
+			values = append(values, id.Hex())
+		}
+		q.WhereIn("id", values)
+	}
+
+	if kinds := filter.Kinds(); len(kinds) > 0 {
+		var values []any
+		for _, kind := range kinds {
+			values = append(values, kind.Int())
+		}
+		q.WhereIn("kind", values)
+	}
+
+	if authors := filter.Authors(); len(authors) > 0 {
+		var values []any
+		for _, author := range authors {
+			values = append(values, author.Hex())
+		}
+		q.WhereIn("public_key", values)
+	}
+
+	tags := filter.Tags()
+	var tagNames []domain.EventTagName
+	for name := range tags {
+		tagNames = append(tagNames, name)
+	}
+	sort.Slice(tagNames, func(i, j int) bool {
+		return tagNames[i].String() < tagNames[j].String()
+	})
+
+	for _, name := range tagNames {
+		tagValues := tags[name]
+		if len(tagValues) == 0 {
+			continue
+		}
+
+		args := []any{name.String()}
+		for _, v := range tagValues {
+			args = append(args, v)
+		}
+
+		q.Where("id IN (SELECT event_id FROM tags WHERE name = %s AND first_value IN ("+placeholders(len(tagValues))+"))", args...)
+	}
+
+	if since := filter.Since(); since != nil {
+		q.Where("created_at >= %s", *since)
+	}
+
+	if until := filter.Until(); until != nil {
+		q.Where("created_at <= %s", *until)
+	}
+
+	q.OrderBy("created_at DESC")
+	q.Limit(filter.Limit())
+
+	return q
+}
+
+func sendErr(ctx context.Context, ch chan<- app.EventOrError, err error) {
+	select {
+	case ch <- app.NewEventOrErrorWithError(err):
+	case <-ctx.Done():
+	}
+}
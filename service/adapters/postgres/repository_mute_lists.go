@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type MuteListRepository struct {
+	tx *sql.Tx
+}
+
+func NewMuteListRepository(tx *sql.Tx) *MuteListRepository {
+	return &MuteListRepository{tx: tx}
+}
+
+func (r *MuteListRepository) Save(muteList domain.MuteList) error {
+	if _, err := r.tx.Exec(`
+		INSERT INTO mute_lists (public_key, created_at, raw)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (public_key) DO UPDATE SET
+			created_at = EXCLUDED.created_at,
+			raw = EXCLUDED.raw`,
+		muteList.Author().Hex(),
+		muteList.Event().CreatedAt(),
+		muteList.Event().Raw(),
+	); err != nil {
+		return errors.Wrap(err, "error upserting the mute list")
+	}
+	return nil
+}
+
+func (r *MuteListRepository) Get(ctx context.Context, publicKey domain.PublicKey) (domain.MuteList, bool, error) {
+	var raw []byte
+	if err := r.tx.QueryRowContext(ctx, `SELECT raw FROM mute_lists WHERE public_key = $1`, publicKey.Hex()).Scan(&raw); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.MuteList{}, false, nil
+		}
+		return domain.MuteList{}, false, errors.Wrap(err, "error getting the mute list")
+	}
+
+	muteList, err := readMuteList(raw)
+	if err != nil {
+		return domain.MuteList{}, false, errors.Wrap(err, "error reading the mute list")
+	}
+
+	return muteList, true, nil
+}
+
+func (r *MuteListRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	if _, err := r.tx.ExecContext(ctx, `DELETE FROM mute_lists WHERE public_key = $1`, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the mute list")
+	}
+	return nil
+}
+
+func readMuteList(raw []byte) (domain.MuteList, error) {
+	event, err := domain.NewEventFromRaw(raw)
+	if err != nil {
+		return domain.MuteList{}, errors.Wrap(err, "error creating the event")
+	}
+	return domain.NewMuteList(event)
+}
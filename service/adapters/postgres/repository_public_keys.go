@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type PublicKeyRepository struct {
+	tx *sql.Tx
+}
+
+func NewPublicKeyRepository(tx *sql.Tx) *PublicKeyRepository {
+	return &PublicKeyRepository{tx: tx}
+}
+
+func (r *PublicKeyRepository) Save(registration domain.Registration) error {
+	preferences, err := json.Marshal(registration.Preferences())
+	if err != nil {
+		return errors.Wrap(err, "error marshaling preferences")
+	}
+
+	if _, err := r.tx.Exec(`
+		INSERT INTO public_keys (public_key)
+		VALUES ($1)
+		ON CONFLICT DO NOTHING`,
+		registration.PublicKey().Hex(),
+	); err != nil {
+		return errors.Wrap(err, "error inserting the public key")
+	}
+
+	if _, err := r.tx.Exec(`
+		INSERT INTO public_keys_push_tokens (public_key, platform, token, notification_mode, preferences, updated_timestamp)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (public_key, platform, token) DO UPDATE SET
+			notification_mode = EXCLUDED.notification_mode,
+			preferences = EXCLUDED.preferences,
+			updated_timestamp = EXCLUDED.updated_timestamp`,
+		registration.PublicKey().Hex(),
+		registration.PushToken().Platform().String(),
+		registration.PushToken().Token(),
+		registration.NotificationMode().String(),
+		string(preferences),
+		time.Now(),
+	); err != nil {
+		return errors.Wrap(err, "error upserting the push token")
+	}
+
+	return nil
+}
+
+// DeleteByPublicKey deletes a public key and all its associated push tokens.
+func (r *PublicKeyRepository) DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error {
+	if _, err := r.tx.ExecContext(ctx, `DELETE FROM public_keys WHERE public_key = $1`, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the public key")
+	}
+	return nil
+}
+
+func (r *PublicKeyRepository) DeletePushToken(ctx context.Context, publicKey domain.PublicKey, token domain.PushToken) error {
+	if _, err := r.tx.ExecContext(ctx, `
+		DELETE FROM public_keys_push_tokens
+		WHERE public_key = $1 AND platform = $2 AND token = $3`,
+		publicKey.Hex(),
+		token.Platform().String(),
+		token.Token(),
+	); err != nil {
+		return errors.Wrap(err, "error deleting the push token")
+	}
+	return nil
+}
+
+func (r *PublicKeyRepository) GetPushTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.RegisteredPushToken, error) {
+	rows, err := r.tx.QueryContext(ctx, `
+		SELECT platform, token, notification_mode, preferences
+		FROM public_keys_push_tokens
+		WHERE public_key = $1 AND updated_timestamp > $2`,
+		publicKey.Hex(),
+		savedAfter,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	var result []domain.RegisteredPushToken
+	for rows.Next() {
+		var platform, token, notificationMode, preferences string
+		if err := rows.Scan(&platform, &token, &notificationMode, &preferences); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+
+		registeredPushToken, err := readRegisteredPushToken(platform, token, notificationMode, preferences)
+		if err != nil {
+			return nil, errors.Wrap(err, "error reading the token")
+		}
+
+		result = append(result, registeredPushToken)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
+
+func readRegisteredPushToken(platform, token, notificationMode, preferences string) (domain.RegisteredPushToken, error) {
+	pushToken, err := readPushToken(platform, token)
+	if err != nil {
+		return domain.RegisteredPushToken{}, errors.Wrap(err, "error reading the push token")
+	}
+
+	mode, err := domain.NewNotificationMode(notificationMode)
+	if err != nil {
+		return domain.RegisteredPushToken{}, errors.Wrap(err, "error creating the notification mode")
+	}
+
+	var p domain.Preferences
+	if err := json.Unmarshal([]byte(preferences), &p); err != nil {
+		return domain.RegisteredPushToken{}, errors.Wrap(err, "error unmarshaling preferences")
+	}
+
+	return domain.NewRegisteredPushToken(pushToken, mode, p)
+}
+
+func readPushToken(platform, token string) (domain.PushToken, error) {
+	p, err := domain.NewPushTokenPlatform(platform)
+	if err != nil {
+		return domain.PushToken{}, errors.Wrap(err, "error creating the platform")
+	}
+	return domain.NewPushToken(p, token)
+}
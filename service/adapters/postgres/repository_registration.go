@@ -0,0 +1,33 @@
+package postgres
+
+import (
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type RegistrationRepository struct {
+	relayRepository     *RelayRepository
+	publicKeyRepository *PublicKeyRepository
+}
+
+func NewRegistrationRepository(
+	relayRepository *RelayRepository,
+	publicKeyRepository *PublicKeyRepository,
+) *RegistrationRepository {
+	return &RegistrationRepository{
+		relayRepository:     relayRepository,
+		publicKeyRepository: publicKeyRepository,
+	}
+}
+
+func (r *RegistrationRepository) Save(registration domain.Registration) error {
+	if err := r.relayRepository.Save(registration); err != nil {
+		return errors.Wrap(err, "error saving under relays")
+	}
+
+	if err := r.publicKeyRepository.Save(registration); err != nil {
+		return errors.Wrap(err, "error saving under public keys")
+	}
+
+	return nil
+}
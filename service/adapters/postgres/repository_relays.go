@@ -0,0 +1,292 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type RelayRepository struct {
+	tx *sql.Tx
+}
+
+func NewRelayRepository(tx *sql.Tx) *RelayRepository {
+	return &RelayRepository{tx: tx}
+}
+
+func (r *RelayRepository) Save(registration domain.Registration) error {
+	now := time.Now()
+
+	for _, relayAddress := range registration.Relays() {
+		if _, err := r.tx.Exec(`
+			INSERT INTO relays (address, updated_timestamp, registered)
+			VALUES ($1, $2, TRUE)
+			ON CONFLICT (address) DO UPDATE SET
+				updated_timestamp = EXCLUDED.updated_timestamp,
+				registered = TRUE`,
+			relayAddress.String(),
+			now,
+		); err != nil {
+			return errors.Wrap(err, "error upserting the relay")
+		}
+
+		if _, err := r.tx.Exec(`
+			INSERT INTO relays_public_keys (address, public_key, updated_timestamp, registered)
+			VALUES ($1, $2, $3, TRUE)
+			ON CONFLICT (address, public_key) DO UPDATE SET
+				updated_timestamp = EXCLUDED.updated_timestamp,
+				registered = TRUE`,
+			relayAddress.String(),
+			registration.PublicKey().Hex(),
+			now,
+		); err != nil {
+			return errors.Wrap(err, "error upserting the public key")
+		}
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) GetRelays(ctx context.Context, updatedAfter time.Time) ([]app.StoredRelay, error) {
+	rows, err := r.tx.QueryContext(ctx, `
+		SELECT address, registered, in_relay_list
+		FROM relays
+		WHERE updated_timestamp > $1`,
+		updatedAfter,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	var result []app.StoredRelay
+	for rows.Next() {
+		var address string
+		var registered, inRelayList bool
+		if err := rows.Scan(&address, &registered, &inRelayList); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+
+		relayAddress, err := domain.NewRelayAddress(address)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error creating a relay address from '%s'", address)
+		}
+
+		if !relayAddress.ShouldBeSkipped() {
+			result = append(result, app.NewStoredRelay(relayAddress, relaySources(registered, inRelayList)))
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
+
+func (r *RelayRepository) GetPublicKeys(ctx context.Context, address domain.RelayAddress, updatedAfter time.Time) ([]domain.PublicKey, error) {
+	rows, err := r.tx.QueryContext(ctx, `
+		SELECT public_key
+		FROM relays_public_keys
+		WHERE address = $1 AND updated_timestamp > $2`,
+		address.String(),
+		updatedAfter,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	var result []domain.PublicKey
+	for rows.Next() {
+		var hex string
+		if err := rows.Scan(&hex); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+
+		publicKey, err := domain.NewPublicKeyFromHex(hex)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a public key")
+		}
+		result = append(result, publicKey)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
+
+// DeletePublicKeyFromRelays keeps the public key under the relays which are
+// also listed in the relay list of the public key.
+func (r *RelayRepository) DeletePublicKeyFromRelays(ctx context.Context, publicKey domain.PublicKey, addresses []domain.RelayAddress) error {
+	for _, address := range addresses {
+		if _, err := r.tx.ExecContext(ctx, `
+			DELETE FROM relays_public_keys
+			WHERE address = $1 AND public_key = $2 AND NOT in_relay_list`,
+			address.String(),
+			publicKey.Hex(),
+		); err != nil {
+			return errors.Wrapf(err, "error deleting the public key from relay '%s'", address.String())
+		}
+
+		if _, err := r.tx.ExecContext(ctx, `
+			UPDATE relays_public_keys
+			SET registered = FALSE
+			WHERE address = $1 AND public_key = $2`,
+			address.String(),
+			publicKey.Hex(),
+		); err != nil {
+			return errors.Wrapf(err, "error updating the public key under relay '%s'", address.String())
+		}
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) DeletePublicKeyFromAllRelays(ctx context.Context, publicKey domain.PublicKey) error {
+	if _, err := r.tx.ExecContext(ctx, `DELETE FROM relays_public_keys WHERE public_key = $1`, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the public key")
+	}
+
+	if _, err := r.tx.ExecContext(ctx, `DELETE FROM relay_lists WHERE public_key = $1`, publicKey.Hex()); err != nil {
+		return errors.Wrap(err, "error deleting the relay list")
+	}
+
+	return nil
+}
+
+func (r *RelayRepository) GetCursors(ctx context.Context, address domain.RelayAddress) (map[domain.PublicKey]time.Time, error) {
+	rows, err := r.tx.QueryContext(ctx, `
+		SELECT public_key, cursor_timestamp
+		FROM relays_public_keys
+		WHERE address = $1 AND cursor_timestamp IS NOT NULL`,
+		address.String(),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	result := make(map[domain.PublicKey]time.Time)
+	for rows.Next() {
+		var hex string
+		var cursor time.Time
+		if err := rows.Scan(&hex, &cursor); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+
+		publicKey, err := domain.NewPublicKeyFromHex(hex)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a public key")
+		}
+		result[publicKey] = cursor
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
+
+func (r *RelayRepository) SaveCursors(ctx context.Context, address domain.RelayAddress, cursors map[domain.PublicKey]time.Time) error {
+	for publicKey, cursor := range cursors {
+		if _, err := r.tx.ExecContext(ctx, `
+			UPDATE relays_public_keys
+			SET cursor_timestamp = $3
+			WHERE address = $1 AND public_key = $2 AND (cursor_timestamp IS NULL OR cursor_timestamp < $3)`,
+			address.String(),
+			publicKey.Hex(),
+			cursor,
+		); err != nil {
+			return errors.Wrap(err, "error updating the cursor")
+		}
+	}
+	return nil
+}
+
+func (r *RelayRepository) SaveRelayList(ctx context.Context, relayList domain.RelayList) error {
+	var createdAt time.Time
+	if err := r.tx.QueryRowContext(ctx, `SELECT created_at FROM relay_lists WHERE public_key = $1`, relayList.Author().Hex()).Scan(&createdAt); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return errors.Wrap(err, "error getting the relay list")
+		}
+	} else if relayList.Event().CreatedAt().Before(createdAt) {
+		return nil
+	}
+
+	if _, err := r.tx.ExecContext(ctx, `
+		INSERT INTO relay_lists (public_key, created_at)
+		VALUES ($1, $2)
+		ON CONFLICT (public_key) DO UPDATE SET created_at = EXCLUDED.created_at`,
+		relayList.Author().Hex(),
+		relayList.Event().CreatedAt(),
+	); err != nil {
+		return errors.Wrap(err, "error upserting the relay list")
+	}
+
+	if _, err := r.tx.ExecContext(ctx, `
+		UPDATE relays_public_keys
+		SET in_relay_list = FALSE
+		WHERE public_key = $1`,
+		relayList.Author().Hex(),
+	); err != nil {
+		return errors.Wrap(err, "error clearing the previous relay list")
+	}
+
+	now := time.Now()
+
+	for _, relayAddress := range relayList.ReadRelays() {
+		if _, err := r.tx.ExecContext(ctx, `
+			INSERT INTO relays (address, updated_timestamp, registered, in_relay_list)
+			VALUES ($1, $2, FALSE, TRUE)
+			ON CONFLICT (address) DO UPDATE SET
+				updated_timestamp = EXCLUDED.updated_timestamp,
+				in_relay_list = TRUE`,
+			relayAddress.String(),
+			now,
+		); err != nil {
+			return errors.Wrap(err, "error upserting the relay")
+		}
+
+		if _, err := r.tx.ExecContext(ctx, `
+			INSERT INTO relays_public_keys (address, public_key, updated_timestamp, registered, in_relay_list)
+			VALUES ($1, $2, $3, FALSE, TRUE)
+			ON CONFLICT (address, public_key) DO UPDATE SET
+				updated_timestamp = EXCLUDED.updated_timestamp,
+				in_relay_list = TRUE`,
+			relayAddress.String(),
+			relayList.Author().Hex(),
+			now,
+		); err != nil {
+			return errors.Wrap(err, "error upserting the public key")
+		}
+	}
+
+	if _, err := r.tx.ExecContext(ctx, `
+		DELETE FROM relays_public_keys
+		WHERE public_key = $1 AND NOT registered AND NOT in_relay_list`,
+		relayList.Author().Hex(),
+	); err != nil {
+		return errors.Wrap(err, "error deleting relays which are no longer listed")
+	}
+
+	return nil
+}
+
+func relaySources(registered, inRelayList bool) []domain.RelaySource {
+	var sources []domain.RelaySource
+	if registered {
+		sources = append(sources, domain.RelaySourceRegistration)
+	}
+	if inRelayList {
+		sources = append(sources, domain.RelaySourceRelayList)
+	}
+	return sources
+}
@@ -0,0 +1,77 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type TagRepository struct {
+	tx *sql.Tx
+}
+
+func NewTagRepository(tx *sql.Tx) *TagRepository {
+	return &TagRepository{tx: tx}
+}
+
+func (r *TagRepository) Save(event domain.Event, tags []domain.EventTag) error {
+	for _, tag := range tags {
+		if _, err := r.tx.Exec(`
+			INSERT INTO tags (name, first_value, event_id, created_at)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING`,
+			tag.Name().String(),
+			tag.FirstValue(),
+			event.Id().Hex(),
+			event.CreatedAt(),
+		); err != nil {
+			return errors.Wrap(err, "error inserting the tag")
+		}
+	}
+	return nil
+}
+
+func (r *TagRepository) GetEventIds(ctx context.Context, name domain.EventTagName, value string, since, until *time.Time, limit int) ([]domain.EventId, error) {
+	q := newQuery(`SELECT event_id FROM tags`)
+	q.Where("name = %s", name.String())
+	q.Where("first_value = %s", value)
+	if since != nil {
+		q.Where("created_at > %s", *since)
+	}
+	if until != nil {
+		q.Where("created_at < %s", *until)
+	}
+	q.OrderBy("created_at DESC")
+	q.Limit(limit)
+
+	query, args := q.Build()
+	rows, err := r.tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	var result []domain.EventId
+	for rows.Next() {
+		var hex string
+		if err := rows.Scan(&hex); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+
+		eventId, err := domain.NewEventId(hex)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the event id")
+		}
+
+		result = append(result, eventId)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
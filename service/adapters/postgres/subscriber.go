@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/ThreeDotsLabs/watermill/message"
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+)
+
+const (
+	subscriberPollInterval = 1 * time.Second
+	subscriberBatchSize    = 100
+
+	// Messages which weren't acked or nacked before this deadline are
+	// redelivered e.g. if the instance which received them crashed.
+	subscriberAckDeadline = 5 * time.Minute
+	subscriberNackDelay   = 10 * time.Second
+)
+
+// Subscriber delivers messages stored in the pubsub_messages table. Messages
+// are claimed using SKIP LOCKED so multiple instances of the service can
+// process the same topic concurrently.
+type Subscriber struct {
+	db     *sql.DB
+	logger logging.Logger
+}
+
+func NewSubscriber(db *sql.DB, logger logging.Logger) *Subscriber {
+	return &Subscriber{
+		db:     db,
+		logger: logger.New("postgresSubscriber"),
+	}
+}
+
+func (s *Subscriber) Subscribe(ctx context.Context, topic string) (<-chan *message.Message, error) {
+	ch := make(chan *message.Message)
+	go s.run(ctx, topic, ch)
+	return ch, nil
+}
+
+func (s *Subscriber) Close() error {
+	return nil
+}
+
+func (s *Subscriber) QueueLength(topic string) (int, error) {
+	var n int
+	if err := s.db.QueryRow(`SELECT count(*) FROM pubsub_messages WHERE topic = $1`, topic).Scan(&n); err != nil {
+		return 0, errors.Wrap(err, "error counting messages")
+	}
+	return n, nil
+}
+
+func (s *Subscriber) run(ctx context.Context, topic string, ch chan<- *message.Message) {
+	defer close(ch)
+
+	for {
+		n, err := s.deliverBatch(ctx, topic, ch)
+		if err != nil {
+			s.logger.Error().WithError(err).WithField("topic", topic).Message("error delivering messages")
+		}
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil || n == 0 {
+			select {
+			case <-time.After(subscriberPollInterval):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+func (s *Subscriber) deliverBatch(ctx context.Context, topic string, ch chan<- *message.Message) (int, error) {
+	messages, err := s.claim(ctx, topic)
+	if err != nil {
+		return 0, errors.Wrap(err, "error claiming messages")
+	}
+
+	for _, claimed := range messages {
+		msg := message.NewMessage(claimed.uuid, claimed.payload)
+
+		select {
+		case ch <- msg:
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+
+		go s.waitForAck(ctx, claimed.id, msg)
+	}
+
+	return len(messages), nil
+}
+
+func (s *Subscriber) claim(ctx context.Context, topic string) ([]claimedMessage, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE pubsub_messages
+		SET visible_after = now() + make_interval(secs => $2), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id
+			FROM pubsub_messages
+			WHERE topic = $1 AND visible_after <= now()
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, uuid, payload`,
+		topic,
+		int(subscriberAckDeadline.Seconds()),
+		subscriberBatchSize,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query error")
+	}
+	defer rows.Close()
+
+	var result []claimedMessage
+	for rows.Next() {
+		var v claimedMessage
+		if err := rows.Scan(&v.id, &v.uuid, &v.payload); err != nil {
+			return nil, errors.Wrap(err, "error scanning")
+		}
+		result = append(result, v)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "rows error")
+	}
+
+	return result, nil
+}
+
+func (s *Subscriber) waitForAck(ctx context.Context, id int64, msg *message.Message) {
+	select {
+	case <-msg.Acked():
+		if _, err := s.db.ExecContext(ctx, `DELETE FROM pubsub_messages WHERE id = $1`, id); err != nil {
+			s.logger.Error().WithError(err).Message("error deleting an acked message")
+		}
+	case <-msg.Nacked():
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE pubsub_messages
+			SET visible_after = now() + make_interval(secs => $2)
+			WHERE id = $1`,
+			id,
+			int(subscriberNackDelay.Seconds()),
+		); err != nil {
+			s.logger.Error().WithError(err).Message("error delaying a nacked message")
+		}
+	case <-ctx.Done():
+	}
+}
+
+type claimedMessage struct {
+	id      int64
+	uuid    string
+	payload []byte
+}
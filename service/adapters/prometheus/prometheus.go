@@ -8,6 +8,7 @@ import (
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/internal/logging"
 	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
 )
@@ -27,6 +28,10 @@ const (
 	labelResultInvalidPointerPassed = "invalidPointerPassed"
 
 	labelStatusCode = "statusCode"
+	labelPlatform   = "platform"
+	labelReason     = "reason"
+	labelLimit      = "limit"
+	labelRelay      = "relay"
 )
 
 type Prometheus struct {
@@ -36,6 +41,17 @@ type Prometheus struct {
 	relayFollowChangeGauge                  prometheus.Counter
 	subscriptionQueueLengthGauge            *prometheus.GaugeVec
 	apnsCallsCounter                        *prometheus.CounterVec
+	fcmCallsCounter                         *prometheus.CounterVec
+	webPushCallsCounter                     *prometheus.CounterVec
+	invalidPushTokensRemovedCounter         *prometheus.CounterVec
+	rateLimitHitsCounter                    *prometheus.CounterVec
+	invalidEventsCounter                    *prometheus.CounterVec
+	flaggedRelaysGauge                      *prometheus.GaugeVec
+	relayConnectLatencyGauge                *prometheus.GaugeVec
+	relayConsecutiveFailuresGauge           *prometheus.GaugeVec
+	relayEventsPerMinuteGauge               *prometheus.GaugeVec
+	relayLastEOSEGauge                      *prometheus.GaugeVec
+	relayQuarantinedGauge                   *prometheus.GaugeVec
 
 	registry *prometheus.Registry
 
@@ -91,6 +107,83 @@ func NewPrometheus(logger logging.Logger) (*Prometheus, error) {
 		},
 		[]string{labelStatusCode, labelResult},
 	)
+	fcmCallsCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "fcm_calls_total",
+			Help: "Total number of calls to FCM.",
+		},
+		[]string{labelStatusCode, labelResult},
+	)
+	webPushCallsCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "webpush_calls_total",
+			Help: "Total number of calls to Web Push services.",
+		},
+		[]string{labelStatusCode, labelResult},
+	)
+	invalidPushTokensRemovedCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "invalid_push_tokens_removed_total",
+			Help: "Total number of push tokens removed because they were rejected by the push service.",
+		},
+		[]string{labelPlatform, labelReason},
+	)
+	rateLimitHitsCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rate_limit_hits_total",
+			Help: "Total number of messages rejected because a websocket client hit a limit.",
+		},
+		[]string{labelLimit},
+	)
+	invalidEventsCounter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "invalid_events_total",
+			Help: "Total number of events received from relays which had an invalid id or signature.",
+		},
+		[]string{labelRelay, labelReason},
+	)
+	flaggedRelaysGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "flagged_relays",
+			Help: "Relays flagged for repeatedly sending invalid events.",
+		},
+		[]string{labelRelay},
+	)
+	relayConnectLatencyGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "relay_connect_latency_seconds",
+			Help: "Time it took to establish the last successful connection to a relay.",
+		},
+		[]string{labelRelay},
+	)
+	relayConsecutiveFailuresGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "relay_consecutive_failures",
+			Help: "Number of failed connection attempts since the last stable connection to a relay.",
+		},
+		[]string{labelRelay},
+	)
+	relayEventsPerMinuteGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "relay_events_per_minute",
+			Help: "Number of events received from a relay during the last full minute.",
+		},
+		[]string{labelRelay},
+	)
+	relayLastEOSEGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "relay_last_eose_timestamp_seconds",
+			Help: "Unix time of the last EOSE message received from a relay.",
+		},
+		[]string{labelRelay},
+	)
+	relayQuarantinedGauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "relay_quarantined",
+			Help: "Relays which we stopped connecting to because they were unreachable.",
+		},
+		[]string{labelRelay},
+	)
 
 	reg := prometheus.NewRegistry()
 	for _, v := range []prometheus.Collector{
@@ -101,6 +194,17 @@ func NewPrometheus(logger logging.Logger) (*Prometheus, error) {
 		subscriptionQueueLengthGauge,
 		versionGague,
 		apnsCallsCounter,
+		fcmCallsCounter,
+		webPushCallsCounter,
+		invalidPushTokensRemovedCounter,
+		rateLimitHitsCounter,
+		invalidEventsCounter,
+		flaggedRelaysGauge,
+		relayConnectLatencyGauge,
+		relayConsecutiveFailuresGauge,
+		relayEventsPerMinuteGauge,
+		relayLastEOSEGauge,
+		relayQuarantinedGauge,
 		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
 		collectors.NewGoCollector(),
 	} {
@@ -130,6 +234,17 @@ func NewPrometheus(logger logging.Logger) (*Prometheus, error) {
 		relayFollowChangeGauge:                  relayFollowChangeGauge,
 		subscriptionQueueLengthGauge:            subscriptionQueueLengthGauge,
 		apnsCallsCounter:                        apnsCallsCounter,
+		fcmCallsCounter:                         fcmCallsCounter,
+		webPushCallsCounter:                     webPushCallsCounter,
+		invalidPushTokensRemovedCounter:         invalidPushTokensRemovedCounter,
+		rateLimitHitsCounter:                    rateLimitHitsCounter,
+		invalidEventsCounter:                    invalidEventsCounter,
+		flaggedRelaysGauge:                      flaggedRelaysGauge,
+		relayConnectLatencyGauge:                relayConnectLatencyGauge,
+		relayConsecutiveFailuresGauge:           relayConsecutiveFailuresGauge,
+		relayEventsPerMinuteGauge:               relayEventsPerMinuteGauge,
+		relayLastEOSEGauge:                      relayLastEOSEGauge,
+		relayQuarantinedGauge:                   relayQuarantinedGauge,
 
 		registry: reg,
 
@@ -165,6 +280,75 @@ func (p *Prometheus) ReportCallToAPNS(statusCode int, err error) {
 	p.apnsCallsCounter.With(labels).Inc()
 }
 
+func (p *Prometheus) ReportCallToFCM(statusCode int, err error) {
+	labels := prometheus.Labels{
+		labelStatusCode: strconv.Itoa(statusCode),
+	}
+	if err == nil {
+		labels[labelResult] = labelResultSuccess
+	} else {
+		labels[labelResult] = labelResultError
+	}
+	p.fcmCallsCounter.With(labels).Inc()
+}
+
+func (p *Prometheus) ReportCallToWebPush(statusCode int, err error) {
+	labels := prometheus.Labels{
+		labelStatusCode: strconv.Itoa(statusCode),
+	}
+	if err == nil {
+		labels[labelResult] = labelResultSuccess
+	} else {
+		labels[labelResult] = labelResultError
+	}
+	p.webPushCallsCounter.With(labels).Inc()
+}
+
+func (p *Prometheus) ReportInvalidPushTokenRemoved(platform domain.PushTokenPlatform, reason string) {
+	p.invalidPushTokensRemovedCounter.With(prometheus.Labels{labelPlatform: platform.String(), labelReason: reason}).Inc()
+}
+
+func (p *Prometheus) ReportRateLimitHit(limit string) {
+	p.rateLimitHitsCounter.With(prometheus.Labels{labelLimit: limit}).Inc()
+}
+
+func (p *Prometheus) ReportInvalidEvent(relay domain.RelayAddress, reason domain.InvalidEventReason) {
+	p.invalidEventsCounter.With(prometheus.Labels{labelRelay: relay.String(), labelReason: reason.String()}).Inc()
+}
+
+func (p *Prometheus) MeasureFlaggedRelays(relays []domain.RelayAddress) {
+	p.flaggedRelaysGauge.Reset()
+	for _, relay := range relays {
+		p.flaggedRelaysGauge.With(prometheus.Labels{labelRelay: relay.String()}).Set(1)
+	}
+}
+
+func (p *Prometheus) MeasureRelayHealth(health []app.RelayHealth) {
+	p.relayConnectLatencyGauge.Reset()
+	p.relayConsecutiveFailuresGauge.Reset()
+	p.relayEventsPerMinuteGauge.Reset()
+	p.relayLastEOSEGauge.Reset()
+	p.relayQuarantinedGauge.Reset()
+
+	for _, h := range health {
+		labels := prometheus.Labels{labelRelay: h.Address().String()}
+
+		p.relayConnectLatencyGauge.With(labels).Set(h.ConnectLatency().Seconds())
+		p.relayConsecutiveFailuresGauge.With(labels).Set(float64(h.ConsecutiveFailures()))
+		p.relayEventsPerMinuteGauge.With(labels).Set(float64(h.EventsPerMinute()))
+
+		if lastEOSE, ok := h.LastEOSE(); ok {
+			p.relayLastEOSEGauge.With(labels).Set(float64(lastEOSE.Unix()))
+		}
+
+		if _, ok := h.QuarantinedUntil(); ok {
+			p.relayQuarantinedGauge.With(labels).Set(1)
+		} else {
+			p.relayQuarantinedGauge.With(labels).Set(0)
+		}
+	}
+}
+
 func (p *Prometheus) Registry() *prometheus.Registry {
 	return p.registry
 }
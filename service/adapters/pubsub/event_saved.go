@@ -0,0 +1,9 @@
+package pubsub
+
+// TopicEventSaved is used by all storage adapters to notify the
+// service that an event was saved.
+const TopicEventSaved = "event_saved"
+
+type EventSavedPayload struct {
+	EventId string `json:"eventId"`
+}
@@ -0,0 +1,53 @@
+package adapters
+
+import (
+	"fmt"
+
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+)
+
+// PushNotificationRouter sends each notification using the adapter which
+// supports the platform of the token that the notification is addressed to.
+type PushNotificationRouter struct {
+	senders map[domain.PushTokenPlatform]app.APNS
+}
+
+func NewPushNotificationRouter(senders map[domain.PushTokenPlatform]app.APNS) *PushNotificationRouter {
+	return &PushNotificationRouter{
+		senders: senders,
+	}
+}
+
+func (r *PushNotificationRouter) SendNotification(notification notifications.Notification) error {
+	sender, err := r.sender(notification.PushToken())
+	if err != nil {
+		return err
+	}
+	return sender.SendNotification(notification)
+}
+
+func (r *PushNotificationRouter) SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	sender, err := r.sender(token)
+	if err != nil {
+		return err
+	}
+	return sender.SendFollowChangeNotification(followChange, token)
+}
+
+func (r *PushNotificationRouter) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	sender, err := r.sender(token)
+	if err != nil {
+		return err
+	}
+	return sender.SendSilentFollowChangeNotification(followChange, token)
+}
+
+func (r *PushNotificationRouter) sender(token domain.PushToken) (app.APNS, error) {
+	sender, ok := r.senders[token.Platform()]
+	if !ok {
+		return nil, fmt.Errorf("sending notifications using platform '%s' is not enabled", token.Platform().String())
+	}
+	return sender, nil
+}
@@ -0,0 +1,60 @@
+package adapters
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/service/app"
+)
+
+// MemoryReplicaRegistry can only be used to coordinate replicas running in a
+// single process. It is used when only one replica of the service is running
+// and in tests.
+type MemoryReplicaRegistry struct {
+	leasesLock sync.Mutex
+	leases     map[app.ReplicaID]time.Time
+}
+
+func NewMemoryReplicaRegistry() *MemoryReplicaRegistry {
+	return &MemoryReplicaRegistry{
+		leases: make(map[app.ReplicaID]time.Time),
+	}
+}
+
+func (r *MemoryReplicaRegistry) Renew(ctx context.Context, replica app.ReplicaID, until time.Time) error {
+	r.leasesLock.Lock()
+	defer r.leasesLock.Unlock()
+
+	r.leases[replica] = until
+	return nil
+}
+
+func (r *MemoryReplicaRegistry) Release(ctx context.Context, replica app.ReplicaID) error {
+	r.leasesLock.Lock()
+	defer r.leasesLock.Unlock()
+
+	delete(r.leases, replica)
+	return nil
+}
+
+func (r *MemoryReplicaRegistry) List(ctx context.Context, now time.Time) ([]app.ReplicaID, error) {
+	r.leasesLock.Lock()
+	defer r.leasesLock.Unlock()
+
+	var result []app.ReplicaID
+	for replica, until := range r.leases {
+		if until.After(now) {
+			result = append(result, replica)
+		} else {
+			delete(r.leases, replica)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].String() < result[j].String()
+	})
+
+	return result, nil
+}
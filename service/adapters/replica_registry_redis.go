@@ -0,0 +1,71 @@
+package adapters
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisReplicaRegistry stores leases in a sorted set scored by their expiry
+// time. Expiry times are set by the replicas themselves so their clocks
+// shouldn't drift apart by more than a small fraction of the lease duration.
+type RedisReplicaRegistry struct {
+	client *redis.Client
+	key    string
+}
+
+func NewRedisReplicaRegistry(client *redis.Client, environment config.Environment) *RedisReplicaRegistry {
+	return &RedisReplicaRegistry{
+		client: client,
+		key:    "notification_service:replicas:" + environment.String(),
+	}
+}
+
+func (r *RedisReplicaRegistry) Renew(ctx context.Context, replica app.ReplicaID, until time.Time) error {
+	if err := r.client.ZAdd(ctx, r.key, redis.Z{
+		Score:  float64(until.UnixMilli()),
+		Member: replica.String(),
+	}).Err(); err != nil {
+		return errors.Wrap(err, "error adding the replica")
+	}
+	return nil
+}
+
+func (r *RedisReplicaRegistry) Release(ctx context.Context, replica app.ReplicaID) error {
+	if err := r.client.ZRem(ctx, r.key, replica.String()).Err(); err != nil {
+		return errors.Wrap(err, "error removing the replica")
+	}
+	return nil
+}
+
+func (r *RedisReplicaRegistry) List(ctx context.Context, now time.Time) ([]app.ReplicaID, error) {
+	nowString := strconv.FormatInt(now.UnixMilli(), 10)
+
+	if err := r.client.ZRemRangeByScore(ctx, r.key, "-inf", nowString).Err(); err != nil {
+		return nil, errors.Wrap(err, "error removing expired replicas")
+	}
+
+	members, err := r.client.ZRangeByScore(ctx, r.key, &redis.ZRangeBy{
+		Min: "(" + nowString,
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing replicas")
+	}
+
+	var result []app.ReplicaID
+	for _, member := range members {
+		replica, err := app.NewReplicaID(member)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating the replica id")
+		}
+		result = append(result, replica)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package adapters_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/adapters"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisReplicaRegistry(t *testing.T) {
+	ctx := fixtures.Context(t)
+	server := miniredis.RunT(t)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+	registry := adapters.NewRedisReplicaRegistry(client, config.EnvironmentDevelopment)
+
+	now := time.Now()
+	replica1 := app.NewRandomReplicaID("replica1")
+	replica2 := app.NewRandomReplicaID("replica2")
+
+	replicas, err := registry.List(ctx, now)
+	require.NoError(t, err)
+	require.Empty(t, replicas)
+
+	err = registry.Renew(ctx, replica1, now.Add(time.Minute))
+	require.NoError(t, err)
+
+	err = registry.Renew(ctx, replica2, now.Add(2*time.Minute))
+	require.NoError(t, err)
+
+	replicas, err = registry.List(ctx, now)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []app.ReplicaID{replica1, replica2}, replicas)
+
+	replicas, err = registry.List(ctx, now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Equal(t, []app.ReplicaID{replica2}, replicas, "expired leases shouldn't be returned")
+
+	err = registry.Release(ctx, replica2)
+	require.NoError(t, err)
+
+	replicas, err = registry.List(ctx, now)
+	require.NoError(t, err)
+	require.Empty(t, replicas)
+}
@@ -0,0 +1,60 @@
+package webpush
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidTokenError(t *testing.T) {
+	testCases := []struct {
+		Name       string
+		StatusCode int
+
+		ExpectedInvalidToken bool
+	}{
+		{
+			Name:                 "not_found",
+			StatusCode:           http.StatusNotFound,
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:                 "gone",
+			StatusCode:           http.StatusGone,
+			ExpectedInvalidToken: true,
+		},
+		{
+			Name:       "bad_request",
+			StatusCode: http.StatusBadRequest,
+		},
+		{
+			Name:       "unauthorized",
+			StatusCode: http.StatusUnauthorized,
+		},
+		{
+			Name:       "too_many_requests",
+			StatusCode: http.StatusTooManyRequests,
+		},
+		{
+			Name:       "internal_server_error",
+			StatusCode: http.StatusInternalServerError,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			err := invalidTokenError(testCase.StatusCode, []byte("some reason"))
+			if !testCase.ExpectedInvalidToken {
+				require.NoError(t, err)
+				return
+			}
+
+			var invalidPushTokenErr *app.InvalidPushTokenError
+			require.True(t, errors.As(err, &invalidPushTokenErr))
+			require.Equal(t, "some reason", invalidPushTokenErr.Reason())
+		})
+	}
+}
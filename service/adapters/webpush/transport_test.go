@@ -0,0 +1,47 @@
+package webpush
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefuseInternalAddresses(t *testing.T) {
+	testCases := []struct {
+		Address       string
+		ExpectedError bool
+	}{
+		{Address: "142.250.74.10:443"},
+		{Address: "[2a00:1450:4001:82a::200a]:443"},
+		{Address: "127.0.0.1:443", ExpectedError: true},
+		{Address: "10.0.0.1:443", ExpectedError: true},
+		{Address: "169.254.169.254:80", ExpectedError: true},
+		{Address: "[::1]:443", ExpectedError: true},
+		{Address: "[fe80::1]:443", ExpectedError: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Address, func(t *testing.T) {
+			err := refuseInternalAddresses("tcp", testCase.Address, nil)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNewTransport_RefusesToConnectToInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: newTransport()}
+
+	_, err := client.Post(server.URL, "text/plain", nil)
+	require.ErrorContains(t, err, "refusing to connect")
+}
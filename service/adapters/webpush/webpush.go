@@ -0,0 +1,260 @@
+package webpush
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"syscall"
+	"time"
+
+	webpushgo "github.com/SherClockHolmes/webpush-go"
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+)
+
+const (
+	timeout = 30 * time.Second
+	ttl     = 24 * time.Hour
+
+	payloadTypeFollowChange = "followChange"
+)
+
+type Metrics interface {
+	ReportCallToWebPush(statusCode int, err error)
+}
+
+// WebPush sends notifications to browsers using the Web Push protocol (RFC
+// 8030). Messages are encrypted using aes128gcm (RFC 8291) and signed using
+// VAPID (RFC 8292).
+type WebPush struct {
+	client          *http.Client
+	vapidPublicKey  string
+	vapidPrivateKey string
+	subscriber      string
+	metrics         Metrics
+	logger          logging.Logger
+}
+
+func NewWebPush(cfg config.Config, metrics Metrics, logger logging.Logger) *WebPush {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: newTransport(),
+	}
+
+	return NewWebPushWithClient(
+		client,
+		cfg.WebPushVAPIDPublicKey(),
+		cfg.WebPushVAPIDPrivateKey(),
+		cfg.WebPushSubscriber(),
+		metrics,
+		logger,
+	)
+}
+
+// NewWebPushWithClient is useful when sending notifications to a push service
+// which uses a self-signed certificate e.g. ServerMock.
+func NewWebPushWithClient(
+	client *http.Client,
+	vapidPublicKey string,
+	vapidPrivateKey string,
+	subscriber string,
+	metrics Metrics,
+	logger logging.Logger,
+) *WebPush {
+	return &WebPush{
+		client:          client,
+		vapidPublicKey:  vapidPublicKey,
+		vapidPrivateKey: vapidPrivateKey,
+		subscriber:      subscriber,
+		metrics:         metrics,
+		logger:          logger.New("webPush"),
+	}
+}
+
+// newTransport returns a transport which refuses to connect to internal
+// addresses. Endpoints are validated when subscriptions are created but their
+// host names can resolve to anything. Proxies aren't used as the addresses
+// wouldn't be checked then.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout: timeout,
+		Control: refuseInternalAddresses,
+	}).DialContext
+	return transport
+}
+
+func refuseInternalAddresses(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return errors.Wrap(err, "error parsing the address")
+	}
+
+	if !domain.WebPushAddressIsAllowed(addrPort.Addr()) {
+		return fmt.Errorf("refusing to connect to '%s'", address)
+	}
+
+	return nil
+}
+
+func (w *WebPush) SendNotification(notification notifications.Notification) error {
+	urgency := webpushgo.UrgencyNormal
+	if notification.Priority() == notifications.PriorityHigh {
+		urgency = webpushgo.UrgencyHigh
+	}
+
+	if err := w.send(notification.PushToken(), notification.Payload(), urgency); err != nil {
+		return errors.Wrap(err, "error sending the notification")
+	}
+
+	w.logger.Debug().
+		WithField("uuid", notification.UUID().String()).
+		Message("sent a notification")
+
+	return nil
+}
+
+func (w *WebPush) SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	payload, err := NewFollowChangePayload(followChange)
+	if err != nil {
+		return errors.Wrap(err, "error creating the payload")
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling the payload")
+	}
+
+	if err := w.send(token, payloadJSON, webpushgo.UrgencyHigh); err != nil {
+		return errors.Wrap(err, "error sending the follow change notification")
+	}
+
+	w.logger.Debug().Message("sent a follow change notification")
+	return nil
+}
+
+// SendSilentFollowChangeNotification doesn't do anything. Browsers require
+// service workers to display a notification every time they receive a push
+// message so silent notifications can't be delivered without spamming users.
+func (w *WebPush) SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error {
+	if token.Platform() != domain.PushTokenPlatformWebPush {
+		return fmt.Errorf("this is a token for platform '%s'", token.Platform().String())
+	}
+	return nil
+}
+
+func (w *WebPush) send(token domain.PushToken, payload []byte, urgency webpushgo.Urgency) error {
+	subscription, err := token.WebPushSubscription()
+	if err != nil {
+		return errors.Wrap(err, "error getting the subscription")
+	}
+
+	statusCode, err := w.post(subscription, payload, urgency)
+	w.metrics.ReportCallToWebPush(statusCode, err)
+	return err
+}
+
+func (w *WebPush) post(subscription domain.WebPushSubscription, payload []byte, urgency webpushgo.Urgency) (int, error) {
+	resp, err := webpushgo.SendNotificationWithContext(
+		context.Background(),
+		payload,
+		&webpushgo.Subscription{
+			Endpoint: subscription.Endpoint(),
+			Keys: webpushgo.Keys{
+				Auth:   subscription.Auth(),
+				P256dh: subscription.P256dh(),
+			},
+		},
+		&webpushgo.Options{
+			HTTPClient:      w.client,
+			Subscriber:      w.subscriber,
+			TTL:             int(ttl.Seconds()),
+			Urgency:         urgency,
+			VAPIDPublicKey:  w.vapidPublicKey,
+			VAPIDPrivateKey: w.vapidPrivateKey,
+		},
+	)
+	if err != nil {
+		return 0, errors.Wrap(err, "error performing the request")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return resp.StatusCode, errors.Wrap(err, "error reading the error response")
+		}
+		if err := invalidTokenError(resp.StatusCode, b); err != nil {
+			return resp.StatusCode, err
+		}
+		return resp.StatusCode, fmt.Errorf("push service returned status code '%d': %s", resp.StatusCode, string(b))
+	}
+
+	return resp.StatusCode, nil
+}
+
+// invalidTokenError returns an error if the push service reported that the
+// subscription expired or was removed. See RFC 8030 section 7.3.
+func invalidTokenError(statusCode int, body []byte) error {
+	if statusCode == http.StatusNotFound || statusCode == http.StatusGone {
+		return app.NewInvalidPushTokenError(strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// FollowChangePayload is passed to the service worker which uses the
+// localization key and its arguments to display the notification.
+type FollowChangePayload struct {
+	Type             string   `json:"type"`
+	Followee         string   `json:"followee"`
+	Follows          []string `json:"follows"`
+	FriendlyFollower string   `json:"friendlyFollower,omitempty"`
+	LocKey           string   `json:"locKey"`
+	LocArgs          []string `json:"locArgs,omitempty"`
+}
+
+func NewFollowChangePayload(followChange domain.FollowChangeBatch) (FollowChangePayload, error) {
+	followeeNpub, err := nip19.EncodePublicKey(followChange.Followee.Hex())
+	if err != nil {
+		return FollowChangePayload{}, errors.Wrap(err, "error encoding followee npub")
+	}
+
+	payload := FollowChangePayload{
+		Type:     payloadTypeFollowChange,
+		Followee: followeeNpub,
+	}
+
+	for _, follow := range followChange.Follows {
+		npub, err := nip19.EncodePublicKey(follow.Hex())
+		if err != nil {
+			return FollowChangePayload{}, errors.Wrap(err, "error encoding a public key")
+		}
+		payload.Follows = append(payload.Follows, npub)
+	}
+
+	if len(followChange.Follows) == 1 {
+		payload.FriendlyFollower = followChange.FriendlyFollower
+		if strings.HasPrefix(followChange.FriendlyFollower, "npub") {
+			payload.LocKey = "newFollower"
+		} else {
+			payload.LocKey = "namedNewFollower"
+			payload.LocArgs = []string{followChange.FriendlyFollower}
+		}
+	} else {
+		payload.LocKey = "xNewFollowers"
+		payload.LocArgs = []string{fmt.Sprint(len(followChange.Follows))}
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,377 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	serverMockPathPrefix = "/push/"
+	serverMockHost       = "push.example.com"
+
+	authSecretLength = 16
+	saltLength       = 16
+	ecPublicKeyLen   = 65
+)
+
+// ServerMock is a local stand-in for a push service. It verifies the VAPID
+// signature of received messages and decrypts them the same way a browser
+// would which makes it possible to test sending notifications without network
+// access.
+type ServerMock struct {
+	server *httptest.Server
+	url    string
+
+	lock             sync.Mutex
+	subscriptions    map[string]serverMockSubscription
+	unsubscribed     *internal.Set[string]
+	receivedMessages []ReceivedMessage
+}
+
+type serverMockSubscription struct {
+	subscription domain.WebPushSubscription
+	privateKey   *ecdh.PrivateKey
+	authSecret   []byte
+}
+
+type ReceivedMessage struct {
+	Subscription   domain.WebPushSubscription
+	Payload        []byte
+	Urgency        string
+	TTL            string
+	VAPIDPublicKey string
+	VAPIDSubject   string
+}
+
+func NewServerMock() *ServerMock {
+	s := &ServerMock{
+		subscriptions: make(map[string]serverMockSubscription),
+		unsubscribed:  internal.NewEmptySet[string](),
+	}
+	s.server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	s.url = fmt.Sprintf("https://%s:%d", serverMockHost, s.server.Listener.Addr().(*net.TCPAddr).Port)
+	return s
+}
+
+// Client returns a client which trusts the certificate of the server and
+// connects to it regardless of the requested address. Endpoints of
+// subscriptions use a public host name as subscriptions pointing to local
+// addresses are rejected.
+func (s *ServerMock) Client() *http.Client {
+	client := s.server.Client()
+	transport := client.Transport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, network, s.server.Listener.Addr().String())
+	}
+	client.Transport = transport
+	return client
+}
+
+func (s *ServerMock) Close() {
+	s.server.Close()
+}
+
+// NewSubscription creates a subscription the same way a browser would when
+// subscribing to push messages.
+func (s *ServerMock) NewSubscription() (domain.WebPushSubscription, error) {
+	privateKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return domain.WebPushSubscription{}, errors.Wrap(err, "error generating the key")
+	}
+
+	authSecret := make([]byte, authSecretLength)
+	if _, err := rand.Read(authSecret); err != nil {
+		return domain.WebPushSubscription{}, errors.Wrap(err, "error generating the auth secret")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return domain.WebPushSubscription{}, errors.Wrap(err, "error generating the id")
+	}
+
+	path := serverMockPathPrefix + base64.RawURLEncoding.EncodeToString(id)
+
+	subscription, err := domain.NewWebPushSubscription(
+		s.url+path,
+		base64.RawURLEncoding.EncodeToString(privateKey.PublicKey().Bytes()),
+		base64.RawURLEncoding.EncodeToString(authSecret),
+	)
+	if err != nil {
+		return domain.WebPushSubscription{}, errors.Wrap(err, "error creating the subscription")
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.subscriptions[path] = serverMockSubscription{
+		subscription: subscription,
+		privateKey:   privateKey,
+		authSecret:   authSecret,
+	}
+
+	return subscription, nil
+}
+
+// Unsubscribe makes the server respond to messages sent to this subscription
+// the same way push services respond to messages sent to expired
+// subscriptions.
+func (s *ServerMock) Unsubscribe(subscription domain.WebPushSubscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.unsubscribed.Put(subscription.Endpoint())
+}
+
+// Forget makes the server respond to messages sent to this subscription the
+// same way push services respond to messages sent to subscriptions which they
+// don't know about.
+func (s *ServerMock) Forget(subscription domain.WebPushSubscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for path, sub := range s.subscriptions {
+		if sub.subscription.Endpoint() == subscription.Endpoint() {
+			delete(s.subscriptions, path)
+		}
+	}
+}
+
+func (s *ServerMock) ReceivedMessages() []ReceivedMessage {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return internal.CopySlice(s.receivedMessages)
+}
+
+func (s *ServerMock) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	sub, ok := s.subscriptions[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if s.unsubscribed.Contains(sub.subscription.Endpoint()) {
+		http.Error(w, "push subscription has unsubscribed or expired", http.StatusGone)
+		return
+	}
+
+	vapidPublicKey, vapidSubject, err := s.verifyVAPID(r.Header.Get("Authorization"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid vapid authorization: %s", err), http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("Content-Encoding") != "aes128gcm" {
+		http.Error(w, "unsupported content encoding", http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	payload, err := decrypt(sub, body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error decrypting: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	s.receivedMessages = append(s.receivedMessages, ReceivedMessage{
+		Subscription:   sub.subscription,
+		Payload:        payload,
+		Urgency:        r.Header.Get("Urgency"),
+		TTL:            r.Header.Get("TTL"),
+		VAPIDPublicKey: vapidPublicKey,
+		VAPIDSubject:   vapidSubject,
+	})
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// verifyVAPID checks the header described in RFC 8292 and returns the public
+// key of the application server and the subject of the token.
+func (s *ServerMock) verifyVAPID(header string) (string, string, error) {
+	var token, key string
+	for _, part := range strings.Split(strings.TrimPrefix(header, "vapid "), ",") {
+		part = strings.TrimSpace(part)
+		switch {
+		case strings.HasPrefix(part, "t="):
+			token = strings.TrimPrefix(part, "t=")
+		case strings.HasPrefix(part, "k="):
+			key = strings.TrimPrefix(part, "k=")
+		}
+	}
+
+	if token == "" || key == "" {
+		return "", "", errors.New("malformed header")
+	}
+
+	keyBytes, err := base64.RawURLEncoding.DecodeString(key)
+	if err != nil {
+		return "", "", errors.Wrap(err, "error decoding the key")
+	}
+
+	if _, err := ecdh.P256().NewPublicKey(keyBytes); err != nil {
+		return "", "", errors.Wrap(err, "invalid key")
+	}
+
+	publicKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(keyBytes[1:33]),
+		Y:     new(big.Int).SetBytes(keyBytes[33:65]),
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", "", errors.New("malformed token")
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return "", "", errors.New("malformed signature")
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	sig := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(publicKey, hash[:], r, sig) {
+		return "", "", errors.New("invalid signature")
+	}
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", "", errors.Wrap(err, "error decoding the claims")
+	}
+
+	var claims struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}
+	if err := json.Unmarshal(claimsJSON, &claims); err != nil {
+		return "", "", errors.Wrap(err, "error unmarshaling the claims")
+	}
+
+	if claims.Aud != s.url {
+		return "", "", fmt.Errorf("invalid audience '%s'", claims.Aud)
+	}
+
+	if time.Unix(claims.Exp, 0).Before(time.Now()) {
+		return "", "", errors.New("token expired")
+	}
+
+	return key, claims.Sub, nil
+}
+
+// decrypt reverses the encryption described in RFC 8291.
+func decrypt(sub serverMockSubscription, body []byte) ([]byte, error) {
+	headerLen := saltLength + 4 + 1 + ecPublicKeyLen
+	if len(body) < headerLen {
+		return nil, errors.New("body too short")
+	}
+
+	salt := body[:saltLength]
+	recordSize := binary.BigEndian.Uint32(body[saltLength : saltLength+4])
+	if idLen := int(body[saltLength+4]); idLen != ecPublicKeyLen {
+		return nil, fmt.Errorf("invalid key id length '%d'", idLen)
+	}
+	senderPublicKeyBytes := body[saltLength+5 : headerLen]
+	ciphertext := body[headerLen:]
+
+	if len(ciphertext) > int(recordSize) {
+		return nil, errors.New("multiple records are not supported")
+	}
+
+	senderPublicKey, err := ecdh.P256().NewPublicKey(senderPublicKeyBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid sender public key")
+	}
+
+	sharedSecret, err := sub.privateKey.ECDH(senderPublicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "ecdh error")
+	}
+
+	keyInfo := []byte("WebPush: info\x00")
+	keyInfo = append(keyInfo, sub.privateKey.PublicKey().Bytes()...)
+	keyInfo = append(keyInfo, senderPublicKeyBytes...)
+
+	ikm, err := readHKDF(sharedSecret, sub.authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving ikm")
+	}
+
+	cek, err := readHKDF(ikm, salt, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving the content encryption key")
+	}
+
+	nonce, err := readHKDF(ikm, salt, []byte("Content-Encoding: nonce\x00"), 12)
+	if err != nil {
+		return nil, errors.Wrap(err, "error deriving the nonce")
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the cipher")
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating gcm")
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "error decrypting")
+	}
+
+	plaintext = []byte(strings.TrimRight(string(plaintext), "\x00"))
+	if len(plaintext) == 0 || plaintext[len(plaintext)-1] != 0x02 {
+		return nil, errors.New("missing the last record delimiter")
+	}
+
+	return plaintext[:len(plaintext)-1], nil
+}
+
+func readHKDF(secret, salt, info []byte, length int) ([]byte, error) {
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
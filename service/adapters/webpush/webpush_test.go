@@ -0,0 +1,222 @@
+package webpush_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	webpushgo "github.com/SherClockHolmes/webpush-go"
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters/webpush"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebPush_SendNotificationDeliversEncryptedPayload(t *testing.T) {
+	server := webpush.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter, vapidPublicKey := newWebPush(t, server)
+
+	subscription, err := server.NewSubscription()
+	require.NoError(t, err)
+
+	token, err := domain.NewWebPushPushToken(subscription)
+	require.NoError(t, err)
+
+	payload := `{"eventId":"someEventId"}`
+
+	err = adapter.SendNotification(someNotification(t, token, payload))
+	require.NoError(t, err)
+
+	require.Equal(t,
+		[]webpush.ReceivedMessage{
+			{
+				Subscription:   subscription,
+				Payload:        []byte(payload),
+				Urgency:        "normal",
+				TTL:            "86400",
+				VAPIDPublicKey: vapidPublicKey,
+				VAPIDSubject:   "mailto:admin@example.com",
+			},
+		},
+		server.ReceivedMessages(),
+	)
+}
+
+func TestWebPush_SendNotificationUsesHighUrgencyForHighPriorityNotifications(t *testing.T) {
+	server := webpush.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter, _ := newWebPush(t, server)
+
+	subscription, err := server.NewSubscription()
+	require.NoError(t, err)
+
+	token, err := domain.NewWebPushPushToken(subscription)
+	require.NoError(t, err)
+
+	err = adapter.SendNotification(someNotificationWithPriority(t, token, `{}`, notifications.PriorityHigh))
+	require.NoError(t, err)
+
+	messages := server.ReceivedMessages()
+	require.Len(t, messages, 1)
+	require.Equal(t, "high", messages[0].Urgency)
+}
+
+func TestWebPush_SendNotificationReturnsErrorForExpiredSubscriptions(t *testing.T) {
+	server := webpush.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter, _ := newWebPush(t, server)
+
+	subscription, err := server.NewSubscription()
+	require.NoError(t, err)
+
+	token, err := domain.NewWebPushPushToken(subscription)
+	require.NoError(t, err)
+
+	server.Unsubscribe(subscription)
+
+	err = adapter.SendNotification(someNotification(t, token, `{}`))
+	var invalidPushTokenErr *app.InvalidPushTokenError
+	require.True(t, errors.As(err, &invalidPushTokenErr))
+	require.Empty(t, server.ReceivedMessages())
+}
+
+func TestWebPush_SendNotificationReturnsErrorForUnknownSubscriptions(t *testing.T) {
+	server := webpush.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter, _ := newWebPush(t, server)
+
+	subscription, err := server.NewSubscription()
+	require.NoError(t, err)
+
+	token, err := domain.NewWebPushPushToken(subscription)
+	require.NoError(t, err)
+
+	server.Forget(subscription)
+
+	err = adapter.SendNotification(someNotification(t, token, `{}`))
+	var invalidPushTokenErr *app.InvalidPushTokenError
+	require.True(t, errors.As(err, &invalidPushTokenErr))
+	require.Empty(t, server.ReceivedMessages())
+}
+
+func TestWebPush_RefusesTokensForOtherPlatforms(t *testing.T) {
+	server := webpush.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter, _ := newWebPush(t, server)
+
+	err := adapter.SendNotification(someNotification(t, fixtures.SomeAPNSPushToken(), `{}`))
+	require.EqualError(t, err, "error sending the notification: error getting the subscription: this is a token for platform 'apns'")
+	require.Empty(t, server.ReceivedMessages())
+}
+
+func TestWebPush_SendFollowChangeNotification(t *testing.T) {
+	server := webpush.NewServerMock()
+	t.Cleanup(server.Close)
+
+	adapter, _ := newWebPush(t, server)
+
+	subscription, err := server.NewSubscription()
+	require.NoError(t, err)
+
+	token, err := domain.NewWebPushPushToken(subscription)
+	require.NoError(t, err)
+
+	pk1, pk1Npub := fixtures.PublicKeyAndNpub()
+	pk2, pk2Npub := fixtures.PublicKeyAndNpub()
+
+	batch := domain.FollowChangeBatch{
+		Followee:         pk1,
+		FriendlyFollower: "John Doe",
+		Follows:          []domain.PublicKey{pk2},
+	}
+
+	err = adapter.SendFollowChangeNotification(batch, token)
+	require.NoError(t, err)
+
+	err = adapter.SendSilentFollowChangeNotification(batch, token)
+	require.NoError(t, err)
+
+	messages := server.ReceivedMessages()
+	require.Len(t, messages, 1, "silent notifications shouldn't be sent")
+	require.Equal(t, "high", messages[0].Urgency)
+
+	var payload webpush.FollowChangePayload
+	err = json.Unmarshal(messages[0].Payload, &payload)
+	require.NoError(t, err)
+
+	require.Equal(t,
+		webpush.FollowChangePayload{
+			Type:             "followChange",
+			Followee:         pk1Npub,
+			Follows:          []string{pk2Npub},
+			FriendlyFollower: "John Doe",
+			LocKey:           "namedNewFollower",
+			LocArgs:          []string{"John Doe"},
+		},
+		payload,
+	)
+}
+
+func TestNewFollowChangePayload_MultipleFollows(t *testing.T) {
+	pk1, _ := fixtures.PublicKeyAndNpub()
+	pk2, _ := fixtures.PublicKeyAndNpub()
+	pk3, _ := fixtures.PublicKeyAndNpub()
+
+	batch := domain.FollowChangeBatch{
+		Followee:         pk1,
+		FriendlyFollower: "John Doe",
+		Follows:          []domain.PublicKey{pk2, pk3},
+	}
+
+	payload, err := webpush.NewFollowChangePayload(batch)
+	require.NoError(t, err)
+
+	require.Equal(t, "xNewFollowers", payload.LocKey)
+	require.Equal(t, []string{"2"}, payload.LocArgs)
+	require.Empty(t, payload.FriendlyFollower)
+}
+
+func newWebPush(t *testing.T, server *webpush.ServerMock) (*webpush.WebPush, string) {
+	privateKey, publicKey, err := webpushgo.GenerateVAPIDKeys()
+	require.NoError(t, err)
+
+	adapter := webpush.NewWebPushWithClient(
+		server.Client(),
+		publicKey,
+		privateKey,
+		"admin@example.com",
+		mockMetrics{},
+		logging.NewDevNullLogger(),
+	)
+	return adapter, publicKey
+}
+
+func someNotification(t *testing.T, token domain.PushToken, payload string) notifications.Notification {
+	return someNotificationWithPriority(t, token, payload, notifications.PriorityNormal)
+}
+
+func someNotificationWithPriority(t *testing.T, token domain.PushToken, payload string, priority notifications.Priority) notifications.Notification {
+	uuid, err := notifications.NewNotificationUUID()
+	require.NoError(t, err)
+
+	notification, err := notifications.NewNotification(domain.Event{}, uuid, token, []byte(payload), priority, notifications.Alert{}, time.Now())
+	require.NoError(t, err)
+
+	return notification
+}
+
+type mockMetrics struct {
+}
+
+func (m mockMetrics) ReportCallToWebPush(statusCode int, err error) {
+}
@@ -2,14 +2,24 @@ package app
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
 	"github.com/planetary-social/go-notification-service/service/domain"
 	"github.com/planetary-social/go-notification-service/service/domain/notifications"
 )
 
+var ErrEventNotFound = errors.New("event not found")
+
 type TransactionProvider interface {
 	Transact(context.Context, func(context.Context, Adapters) error) error
+
+	// TransactReadOnly runs the function in a transaction in which adapters
+	// can't be used to modify anything. Read-only transactions don't block
+	// other transactions in storage which allows only one writer at a time.
+	TransactReadOnly(context.Context, func(context.Context, Adapters) error) error
 }
 
 type Adapters struct {
@@ -18,6 +28,7 @@ type Adapters struct {
 	PublicKeys    PublicKeyRepository
 	Events        EventRepository
 	Tags          TagRepository
+	MuteLists     MuteListRepository
 
 	Publisher Publisher
 }
@@ -27,13 +38,53 @@ type RegistrationRepository interface {
 }
 
 type RelayRepository interface {
-	GetRelays(ctx context.Context, updatedAfter time.Time) ([]domain.RelayAddress, error)
+	GetRelays(ctx context.Context, updatedAfter time.Time) ([]StoredRelay, error)
 	GetPublicKeys(ctx context.Context, address domain.RelayAddress, updatedAfter time.Time) ([]domain.PublicKey, error)
+	DeletePublicKeyFromRelays(ctx context.Context, publicKey domain.PublicKey, addresses []domain.RelayAddress) error
+	DeletePublicKeyFromAllRelays(ctx context.Context, publicKey domain.PublicKey) error
+
+	// GetCursors returns the creation time of the newest event downloaded
+	// from the relay for each public key. Public keys for which nothing was
+	// downloaded yet are omitted.
+	GetCursors(ctx context.Context, address domain.RelayAddress) (map[domain.PublicKey]time.Time, error)
+
+	// SaveCursors moves the cursors forward. Cursors are never moved back and
+	// cursors of public keys which aren't saved under the relay are ignored.
+	SaveCursors(ctx context.Context, address domain.RelayAddress, cursors map[domain.PublicKey]time.Time) error
+
+	// SaveRelayList saves the public key under the read relays of the relay
+	// list and removes it from relays which it was saved under using the
+	// previous relay list unless they were also registered. Relay lists older
+	// than the saved one are ignored.
+	SaveRelayList(ctx context.Context, relayList domain.RelayList) error
+}
+
+// StoredRelay is a relay which events are downloaded from together with the
+// ways in which it was discovered.
+type StoredRelay struct {
+	address domain.RelayAddress
+	sources []domain.RelaySource
+}
+
+func NewStoredRelay(address domain.RelayAddress, sources []domain.RelaySource) StoredRelay {
+	return StoredRelay{
+		address: address,
+		sources: internal.CopySlice(sources),
+	}
+}
+
+func (r StoredRelay) Address() domain.RelayAddress {
+	return r.address
+}
+
+func (r StoredRelay) Sources() []domain.RelaySource {
+	return internal.CopySlice(r.sources)
 }
 
 type PublicKeyRepository interface {
 	DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error
-	GetAPNSTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.APNSToken, error)
+	DeletePushToken(ctx context.Context, publicKey domain.PublicKey, token domain.PushToken) error
+	GetPushTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.RegisteredPushToken, error)
 }
 
 type EventRepository interface {
@@ -44,12 +95,38 @@ type EventRepository interface {
 	GetEvents(ctx context.Context, filters domain.Filters) <-chan EventOrError
 	SaveNotificationForEvent(notification notifications.Notification) error
 	GetNotifications(ctx context.Context, id domain.EventId) ([]notifications.Notification, error)
+
+	// ClaimDelivery records that notifications for the given mention are
+	// about to be sent to the given token. It should be called before sending
+	// the notifications outside of the transaction.
+	ClaimDelivery(delivery notifications.Delivery) error
+
+	// MarkDeliverySent records that the notifications of a claimed delivery
+	// were sent. It should be called in the same transaction as
+	// SaveNotificationForEvent.
+	MarkDeliverySent(delivery notifications.Delivery) error
+
+	// DeleteDelivery releases a claimed delivery if sending failed.
+	DeleteDelivery(delivery notifications.Delivery) error
+
+	// DeliveryExists returns true both for claimed and sent deliveries.
+	DeliveryExists(ctx context.Context, delivery notifications.Delivery) (bool, error)
 }
 
 type TagRepository interface {
 	Save(event domain.Event, tags []domain.EventTag) error
 }
 
+type MuteListRepository interface {
+	// Save replaces the mute list previously saved for the same author.
+	Save(muteList domain.MuteList) error
+
+	// Get returns false if no mute list was saved for the given public key.
+	Get(ctx context.Context, publicKey domain.PublicKey) (domain.MuteList, bool, error)
+
+	DeleteByPublicKey(ctx context.Context, publicKey domain.PublicKey) error
+}
+
 type Publisher interface {
 	PublishEventSaved(ctx context.Context, id domain.EventId) error
 }
@@ -68,22 +145,49 @@ type Application struct {
 }
 
 type Commands struct {
-	SaveReceivedEvent *SaveReceivedEventHandler
-	SaveRegistration  *SaveRegistrationHandler
+	SaveReceivedEvent      *SaveReceivedEventHandler
+	SaveRegistration       *SaveRegistrationHandler
+	RemoveRegistration     *RemoveRegistrationHandler
+	RemoveInvalidPushToken *RemoveInvalidPushTokenHandler
+	DeletePublicKey        *DeletePublicKeyHandler
+	ReprocessEvent         *ReprocessEventHandler
+	ReplayEvents           *ReplayEventsHandler
 }
 
 type Queries struct {
-	GetRelays        *GetRelaysHandler
-	GetPublicKeys    *GetPublicKeysHandler
-	GetTokens        *GetTokensHandler
-	GetEvents        *GetEventsHandler
-	GetNotifications *GetNotificationsHandler
+	GetRelays              *GetRelaysHandler
+	GetPublicKeys          *GetPublicKeysHandler
+	GetTokens              *GetTokensHandler
+	GetEvents              *GetEventsHandler
+	GetNotifications       *GetNotificationsHandler
+	GetRelayHealth         *GetRelayHealthHandler
+	GetRecentNotifications *GetRecentNotificationsHandler
 }
 
 type APNS interface {
 	SendNotification(notification notifications.Notification) error
-	SendFollowChangeNotification(followChange domain.FollowChangeBatch, apnsToken domain.APNSToken) error
-	SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, apnsToken domain.APNSToken) error
+	SendFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error
+	SendSilentFollowChangeNotification(followChange domain.FollowChangeBatch, token domain.PushToken) error
+}
+
+// InvalidPushTokenError is returned by push services if they reported that
+// the token will never be valid again e.g. because the app was uninstalled.
+// Such tokens should be removed.
+type InvalidPushTokenError struct {
+	reason string
+}
+
+func NewInvalidPushTokenError(reason string) *InvalidPushTokenError {
+	return &InvalidPushTokenError{reason: reason}
+}
+
+// Reason is the explanation returned by the push service.
+func (e *InvalidPushTokenError) Reason() string {
+	return e.reason
+}
+
+func (e *InvalidPushTokenError) Error() string {
+	return fmt.Sprintf("invalid push token: %s", e.reason)
 }
 
 type EventOrError struct {
@@ -132,6 +236,10 @@ type Metrics interface {
 	StartApplicationCall(handlerName string) ApplicationCall
 	MeasureRelayDownloadersState(n int, state RelayDownloaderState)
 	MeasureFollowChange(n int)
+	ReportInvalidPushTokenRemoved(platform domain.PushTokenPlatform, reason string)
+	ReportInvalidEvent(relay domain.RelayAddress, reason domain.InvalidEventReason)
+	MeasureFlaggedRelays(relays []domain.RelayAddress)
+	MeasureRelayHealth(health []RelayHealth)
 }
 
 type ApplicationCall interface {
@@ -140,7 +248,22 @@ type ApplicationCall interface {
 	End(err *error)
 }
 
+// MetadataProvider looks up the metadata of users whose events aren't
+// necessarily downloaded by the service.
+type MetadataProvider interface {
+	GetMetadata(ctx context.Context, publicKey domain.PublicKey) (domain.Metadata, bool, error)
+}
+
+// RelayInformationProvider retrieves information which relays advertise about
+// themselves using NIP-11.
+type RelayInformationProvider interface {
+	GetRelayInformation(ctx context.Context, address domain.RelayAddress) (domain.RelayInformation, error)
+}
+
+// EventWasAlreadySavedCache remembers which events were saved recently so that
+// we don't have to check the storage every time the same event is received
+// from a different relay.
 type EventWasAlreadySavedCache interface {
-	MarkEventAsAlreadySaved(id domain.EventId)
-	EventWasAlreadySaved(id domain.EventId) bool
+	MarkEventAsAlreadySaved(ctx context.Context, id domain.EventId) error
+	EventWasAlreadySaved(ctx context.Context, id domain.EventId) (bool, error)
 }
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"sync"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const saveCursorsEvery = 1 * time.Minute
+
+// downloadCursors tracks the creation time of the newest event received for
+// each subscription. Once EOSE is received for a subscription the relay has
+// sent all stored events so the cursor can be saved for all public keys of
+// that subscription, no matter if any events mentioning them were received.
+// Afterwards the cursor follows new events as they arrive. Public keys which
+// are also a part of subscriptions still waiting for EOSE are skipped as not
+// all of their events were received yet.
+type downloadCursors struct {
+	lock          sync.Mutex
+	subscriptions map[string]*subscriptionCursors
+	pending       map[domain.PublicKey]time.Time
+}
+
+func newDownloadCursors() *downloadCursors {
+	return &downloadCursors{
+		subscriptions: make(map[string]*subscriptionCursors),
+		pending:       make(map[domain.PublicKey]time.Time),
+	}
+}
+
+// Subscribed should be called when a REQ is sent. It replaces the previous
+// subscription with the same id.
+func (c *downloadCursors) Subscribed(subscriptionID string, publicKeys []domain.PublicKey) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.subscriptions[subscriptionID] = &subscriptionCursors{
+		publicKeys: internal.NewSet(publicKeys),
+	}
+}
+
+func (c *downloadCursors) Closed(subscriptionID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.subscriptions, subscriptionID)
+}
+
+// EventReceived moves the cursor of the subscription forward. Events created
+// in the future according to our clock can't move the cursor past now as
+// that would skip events created in the meantime.
+func (c *downloadCursors) EventReceived(subscriptionID string, createdAt time.Time, now time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	subscription, ok := c.subscriptions[subscriptionID]
+	if !ok {
+		return
+	}
+
+	if createdAt.After(now) {
+		createdAt = now
+	}
+
+	if createdAt.After(subscription.cursor) {
+		subscription.cursor = createdAt
+		subscription.changed = true
+	}
+}
+
+func (c *downloadCursors) EOSEReceived(subscriptionID string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	subscription, ok := c.subscriptions[subscriptionID]
+	if !ok {
+		return
+	}
+
+	subscription.eoseReceived = true
+}
+
+// TakePending returns cursors which have to be saved. Cursors which couldn't
+// be saved should be returned using ReturnPending.
+func (c *downloadCursors) TakePending() map[domain.PublicKey]time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	waitingForEOSE := internal.NewEmptySet[domain.PublicKey]()
+	for _, subscription := range c.subscriptions {
+		if !subscription.eoseReceived {
+			for _, publicKey := range subscription.publicKeys.List() {
+				waitingForEOSE.Put(publicKey)
+			}
+		}
+	}
+
+	for _, subscription := range c.subscriptions {
+		if !subscription.eoseReceived || !subscription.changed {
+			continue
+		}
+
+		allTaken := true
+		for _, publicKey := range subscription.publicKeys.List() {
+			if waitingForEOSE.Contains(publicKey) {
+				allTaken = false
+				continue
+			}
+			putNewer(c.pending, publicKey, subscription.cursor)
+		}
+		subscription.changed = !allTaken
+	}
+
+	pending := c.pending
+	c.pending = make(map[domain.PublicKey]time.Time)
+	return pending
+}
+
+func (c *downloadCursors) ReturnPending(cursors map[domain.PublicKey]time.Time) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for publicKey, cursor := range cursors {
+		putNewer(c.pending, publicKey, cursor)
+	}
+}
+
+type subscriptionCursors struct {
+	publicKeys   *internal.Set[domain.PublicKey]
+	eoseReceived bool
+	cursor       time.Time
+	changed      bool
+}
+
+func putNewer(cursors map[domain.PublicKey]time.Time, publicKey domain.PublicKey, cursor time.Time) {
+	if previous, ok := cursors[publicKey]; !ok || previous.Before(cursor) {
+		cursors[publicKey] = cursor
+	}
+}
+
+// resumeDownloadingFrom returns the time from which events for the given
+// public keys should be requested. The public keys share a single
+// subscription so the oldest cursor has to be used. Downloads are resumed a
+// bit before the cursors in case relays received some events late or the
+// clocks of the clients are skewed. Cursors older than max cursor age are
+// ignored so that coming back after a long time doesn't flood relays with
+// requests for events which are too old to notify anyone about anyway. Public
+// keys without a cursor were never downloaded from this relay before so for
+// them events are requested starting at max lookback.
+func resumeDownloadingFrom(
+	publicKeys []domain.PublicKey,
+	cursors map[domain.PublicKey]time.Time,
+	config DownloaderConfig,
+	now time.Time,
+) time.Time {
+	since := now
+	for _, publicKey := range publicKeys {
+		v := now.Add(-config.MaxLookback())
+		if cursor, ok := cursors[publicKey]; ok {
+			v = cursor.Add(-config.CursorOverlap())
+			if oldest := now.Add(-config.MaxCursorAge()); v.Before(oldest) {
+				v = oldest
+			}
+		}
+
+		if v.Before(since) {
+			since = v
+		}
+	}
+	return since
+}
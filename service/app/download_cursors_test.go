@@ -0,0 +1,204 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownloadCursors_CursorsOfAllPublicKeysAreSavedAfterEOSE(t *testing.T) {
+	now := time.Now()
+
+	publicKey1, _ := fixtures.SomeKeyPair()
+	publicKey2, _ := fixtures.SomeKeyPair()
+
+	cursors := newDownloadCursors()
+	cursors.Subscribed("sub", []domain.PublicKey{publicKey1, publicKey2})
+	cursors.EventReceived("sub", now.Add(-2*time.Hour), now)
+	cursors.EventReceived("sub", now.Add(-1*time.Hour), now)
+	cursors.EventReceived("sub", now.Add(-3*time.Hour), now)
+	require.Empty(t, cursors.TakePending())
+
+	cursors.EOSEReceived("sub")
+	cursors.EOSEReceived("other")
+	require.Equal(t,
+		map[domain.PublicKey]time.Time{
+			publicKey1: now.Add(-1 * time.Hour),
+			publicKey2: now.Add(-1 * time.Hour),
+		},
+		cursors.TakePending(),
+	)
+	require.Empty(t, cursors.TakePending())
+
+	cursors.EventReceived("sub", now.Add(-time.Minute), now)
+	require.Equal(t,
+		map[domain.PublicKey]time.Time{
+			publicKey1: now.Add(-time.Minute),
+			publicKey2: now.Add(-time.Minute),
+		},
+		cursors.TakePending(),
+	)
+
+	cursors.Closed("sub")
+	cursors.EventReceived("sub", now, now)
+	require.Empty(t, cursors.TakePending())
+}
+
+func TestDownloadCursors_SubscriptionsWithoutEventsHaveNoCursors(t *testing.T) {
+	publicKey, _ := fixtures.SomeKeyPair()
+
+	cursors := newDownloadCursors()
+	cursors.Subscribed("sub", []domain.PublicKey{publicKey})
+	cursors.EOSEReceived("sub")
+	require.Empty(t, cursors.TakePending())
+}
+
+func TestDownloadCursors_EventsFromTheFutureDoNotMoveCursorsPastNow(t *testing.T) {
+	now := time.Now()
+
+	publicKey, _ := fixtures.SomeKeyPair()
+
+	cursors := newDownloadCursors()
+	cursors.Subscribed("sub", []domain.PublicKey{publicKey})
+	cursors.EOSEReceived("sub")
+	cursors.EventReceived("sub", now.Add(24*time.Hour), now)
+	require.Equal(t,
+		map[domain.PublicKey]time.Time{
+			publicKey: now,
+		},
+		cursors.TakePending(),
+	)
+}
+
+func TestDownloadCursors_PublicKeysWaitForEOSEOfAllSubscriptions(t *testing.T) {
+	now := time.Now()
+
+	publicKey1, _ := fixtures.SomeKeyPair()
+	publicKey2, _ := fixtures.SomeKeyPair()
+
+	cursors := newDownloadCursors()
+	cursors.Subscribed("sub1", []domain.PublicKey{publicKey1, publicKey2})
+	cursors.Subscribed("sub2", []domain.PublicKey{publicKey2})
+
+	cursors.EventReceived("sub1", now, now)
+	cursors.EOSEReceived("sub1")
+	require.Equal(t,
+		map[domain.PublicKey]time.Time{
+			publicKey1: now,
+		},
+		cursors.TakePending(),
+	)
+
+	cursors.EventReceived("sub2", now.Add(-time.Second), now)
+	cursors.EOSEReceived("sub2")
+	require.Equal(t,
+		map[domain.PublicKey]time.Time{
+			publicKey1: now,
+			publicKey2: now,
+		},
+		cursors.TakePending(),
+	)
+}
+
+func TestDownloadCursors_ReturnedCursorsAreMerged(t *testing.T) {
+	now := time.Now()
+
+	publicKey, _ := fixtures.SomeKeyPair()
+
+	cursors := newDownloadCursors()
+	cursors.Subscribed("sub", []domain.PublicKey{publicKey})
+	cursors.EventReceived("sub", now.Add(-2*time.Hour), now)
+	cursors.EOSEReceived("sub")
+
+	pending := cursors.TakePending()
+
+	cursors.Subscribed("sub", []domain.PublicKey{publicKey})
+	cursors.EventReceived("sub", now.Add(-1*time.Hour), now)
+	cursors.EOSEReceived("sub")
+	cursors.ReturnPending(pending)
+
+	require.Equal(t,
+		map[domain.PublicKey]time.Time{
+			publicKey: now.Add(-1 * time.Hour),
+		},
+		cursors.TakePending(),
+	)
+}
+
+func TestResumeDownloadingFrom(t *testing.T) {
+	now := time.Now()
+	maxLookback := 24 * time.Hour
+	maxCursorAge := 7 * 24 * time.Hour
+	cursorOverlap := 10 * time.Minute
+
+	config, err := NewDownloaderConfig(maxLookback, maxCursorAge, cursorOverlap)
+	require.NoError(t, err)
+
+	publicKey1, _ := fixtures.SomeKeyPair()
+	publicKey2, _ := fixtures.SomeKeyPair()
+
+	testCases := []struct {
+		Name       string
+		PublicKeys []domain.PublicKey
+		Cursors    map[domain.PublicKey]time.Time
+
+		ExpectedSince time.Time
+	}{
+		{
+			Name:          "no_cursors",
+			PublicKeys:    []domain.PublicKey{publicKey1},
+			ExpectedSince: now.Add(-maxLookback),
+		},
+		{
+			Name:       "cursor",
+			PublicKeys: []domain.PublicKey{publicKey1},
+			Cursors: map[domain.PublicKey]time.Time{
+				publicKey1: now.Add(-1 * time.Hour),
+			},
+			ExpectedSince: now.Add(-1*time.Hour - cursorOverlap),
+		},
+		{
+			Name:       "oldest_cursor_is_used",
+			PublicKeys: []domain.PublicKey{publicKey1, publicKey2},
+			Cursors: map[domain.PublicKey]time.Time{
+				publicKey1: now.Add(-1 * time.Hour),
+				publicKey2: now.Add(-2 * time.Hour),
+			},
+			ExpectedSince: now.Add(-2*time.Hour - cursorOverlap),
+		},
+		{
+			Name:       "public_key_without_cursor_uses_max_lookback",
+			PublicKeys: []domain.PublicKey{publicKey1, publicKey2},
+			Cursors: map[domain.PublicKey]time.Time{
+				publicKey1: now.Add(-1 * time.Hour),
+			},
+			ExpectedSince: now.Add(-maxLookback),
+		},
+		{
+			Name:       "cursor_older_than_max_lookback",
+			PublicKeys: []domain.PublicKey{publicKey1},
+			Cursors: map[domain.PublicKey]time.Time{
+				publicKey1: now.Add(-3 * 24 * time.Hour),
+			},
+			ExpectedSince: now.Add(-3*24*time.Hour - cursorOverlap),
+		},
+		{
+			Name:       "very_old_cursor",
+			PublicKeys: []domain.PublicKey{publicKey1},
+			Cursors: map[domain.PublicKey]time.Time{
+				publicKey1: now.Add(-30 * 24 * time.Hour),
+			},
+			ExpectedSince: now.Add(-maxCursorAge),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			since := resumeDownloadingFrom(testCase.PublicKeys, testCase.Cursors, config, now)
+			require.Equal(t, testCase.ExpectedSince, since)
+		})
+	}
+}
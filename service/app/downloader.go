@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,24 +18,67 @@ import (
 const (
 	getRelaysYoungerThan  = 6 * 30 * 24 * time.Hour
 	recheckRelayListEvery = 5 * time.Minute
+	rebalanceRelaysEvery  = 15 * time.Second
 
-	reconnectEvery           = 5 * time.Minute
 	getPublicKeysYoungerThan = 6 * 30 * 24 * time.Hour
 	manageSubscriptionsEvery = 5 * time.Minute
 
-	howFarIntoThePastToLook = 24 * time.Hour
-
 	storeMetricsEvery = 10 * time.Second
+
+	flagRelayAfterInvalidEvents = 10
+	countInvalidEventsOver      = 1 * time.Hour
 )
 
 type ReceivedEventPublisher interface {
 	Publish(relay domain.RelayAddress, event domain.Event)
 }
 
+type DownloaderConfig struct {
+	maxLookback   time.Duration
+	maxCursorAge  time.Duration
+	cursorOverlap time.Duration
+}
+
+// NewDownloaderConfig creates a config. Events for public keys which events
+// weren't downloaded for yet are downloaded starting maxLookback ago. Other
+// downloads resume cursorOverlap before the newest received event but not
+// earlier than maxCursorAge ago.
+func NewDownloaderConfig(maxLookback, maxCursorAge, cursorOverlap time.Duration) (DownloaderConfig, error) {
+	if maxLookback <= 0 {
+		return DownloaderConfig{}, errors.New("max lookback must be positive")
+	}
+	if maxCursorAge <= 0 {
+		return DownloaderConfig{}, errors.New("max cursor age must be positive")
+	}
+	if cursorOverlap < 0 {
+		return DownloaderConfig{}, errors.New("cursor overlap can't be negative")
+	}
+	return DownloaderConfig{
+		maxLookback:   maxLookback,
+		maxCursorAge:  maxCursorAge,
+		cursorOverlap: cursorOverlap,
+	}, nil
+}
+
+func (c DownloaderConfig) MaxLookback() time.Duration {
+	return c.maxLookback
+}
+
+func (c DownloaderConfig) MaxCursorAge() time.Duration {
+	return c.maxCursorAge
+}
+
+func (c DownloaderConfig) CursorOverlap() time.Duration {
+	return c.cursorOverlap
+}
+
 type Downloader struct {
+	config                    DownloaderConfig
 	eventWasAlreadySavedCache EventWasAlreadySavedCache
 	transactionProvider       TransactionProvider
 	receivedEventPublisher    ReceivedEventPublisher
+	relayInformationCache     *RelayInformationCache
+	shards                    *RelayShards
 	logger                    logging.Logger
 	metrics                   Metrics
 
@@ -43,16 +87,22 @@ type Downloader struct {
 }
 
 func NewDownloader(
+	config DownloaderConfig,
 	eventWasAlreadySavedCache EventWasAlreadySavedCache,
 	transaction TransactionProvider,
 	receivedEventPublisher ReceivedEventPublisher,
+	relayInformationCache *RelayInformationCache,
+	shards *RelayShards,
 	logger logging.Logger,
 	metrics Metrics,
 ) *Downloader {
 	return &Downloader{
+		config:                    config,
 		eventWasAlreadySavedCache: eventWasAlreadySavedCache,
 		transactionProvider:       transaction,
 		receivedEventPublisher:    receivedEventPublisher,
+		relayInformationCache:     relayInformationCache,
+		shards:                    shards,
 		logger:                    logger.New("downloader"),
 		metrics:                   metrics,
 
@@ -62,16 +112,36 @@ func NewDownloader(
 
 func (d *Downloader) Run(ctx context.Context) error {
 	go d.storeMetricsLoop(ctx)
+	defer d.shards.Release()
+
+	var relays *internal.Set[domain.RelayAddress]
+	var relaysCheckedAt time.Time
 
 	for {
-		if err := d.updateRelays(ctx); err != nil {
+		if relays == nil || time.Since(relaysCheckedAt) >= recheckRelayListEvery {
+			v, err := d.getRelays(ctx)
+			if err != nil {
+				d.logger.Error().
+					WithError(err).
+					Message("error getting relays")
+			} else {
+				relays = v
+				relaysCheckedAt = time.Now()
+			}
+		}
+
+		if err := d.shards.Refresh(ctx, time.Now()); err != nil {
 			d.logger.Error().
 				WithError(err).
-				Message("error updating relays")
+				Message("error refreshing relay shards")
+		}
+
+		if relays != nil {
+			d.updateRelays(ctx, internal.NewSet(d.shards.Owned(relays.List())))
 		}
 
 		select {
-		case <-time.After(recheckRelayListEvery):
+		case <-time.After(rebalanceRelaysEvery):
 		case <-ctx.Done():
 			return ctx.Err()
 		}
@@ -95,23 +165,49 @@ func (d *Downloader) storeMetrics() {
 	defer d.relayDownloadersLock.Unlock()
 
 	v := make(map[RelayDownloaderState]int)
+	var flagged []domain.RelayAddress
+	var health []RelayHealth
+
+	for address, downloader := range d.relayDownloaders {
+		health = append(health, downloader.GetHealth())
 
-	for _, downloader := range d.relayDownloaders {
 		s := downloader.GetState()
 		v[s] = v[s] + 1
+
+		if downloader.Flagged() {
+			flagged = append(flagged, address)
+		}
 	}
 
 	for state, n := range v {
 		d.metrics.MeasureRelayDownloadersState(n, state)
 	}
+
+	d.metrics.MeasureFlaggedRelays(flagged)
+	d.metrics.MeasureRelayHealth(health)
 }
 
-func (d *Downloader) updateRelays(ctx context.Context) error {
-	relayAddresses, err := d.getRelays(ctx)
-	if err != nil {
-		return errors.Wrap(err, "error getting relays")
+// GetRelayHealth returns the health of all relays which we are currently
+// downloading events from sorted by their addresses.
+func (d *Downloader) GetRelayHealth() []RelayHealth {
+	d.relayDownloadersLock.Lock()
+	defer d.relayDownloadersLock.Unlock()
+
+	var result []RelayHealth
+	for _, downloader := range d.relayDownloaders {
+		result = append(result, downloader.GetHealth())
 	}
 
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Address().String() < result[j].Address().String()
+	})
+
+	return result
+}
+
+// updateRelays starts downloaders for the given relays and stops all other
+// downloaders.
+func (d *Downloader) updateRelays(ctx context.Context, relayAddresses *internal.Set[domain.RelayAddress]) {
 	d.relayDownloadersLock.Lock()
 	defer d.relayDownloadersLock.Unlock()
 
@@ -132,28 +228,31 @@ func (d *Downloader) updateRelays(ctx context.Context) error {
 				Message("creating a relay downloader")
 			relayDownloader := NewRelayDownloader(
 				ctx,
+				d.config,
 				d.eventWasAlreadySavedCache,
 				d.transactionProvider,
 				d.receivedEventPublisher,
+				d.relayInformationCache,
 				d.logger,
+				d.metrics,
 				relayAddress,
 			)
 			d.relayDownloaders[relayAddress] = relayDownloader
 		}
 	}
-
-	return nil
 }
 
 func (d *Downloader) getRelays(ctx context.Context) (*internal.Set[domain.RelayAddress], error) {
 	var relays []domain.RelayAddress
 
-	if err := d.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	if err := d.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		tmp, err := adapters.Relays.GetRelays(ctx, time.Now().Add(-getRelaysYoungerThan))
 		if err != nil {
 			return errors.Wrap(err, "error getting relays")
 		}
-		relays = tmp
+		for _, relay := range tmp {
+			relays = append(relays, relay.Address())
+		}
 		return nil
 	}); err != nil {
 		return nil, errors.Wrap(err, "transaction error")
@@ -174,37 +273,55 @@ var (
 	RelayDownloaderStateInitializing = RelayDownloaderState{"initializing"}
 	RelayDownloaderStateConnected    = RelayDownloaderState{"connected"}
 	RelayDownloaderStateDisconnected = RelayDownloaderState{"disconnected"}
+	RelayDownloaderStateQuarantined  = RelayDownloaderState{"quarantined"}
+	RelayDownloaderStateSkipped      = RelayDownloaderState{"skipped"}
 )
 
 type RelayDownloader struct {
+	config                    DownloaderConfig
 	eventWasAlreadySavedCache EventWasAlreadySavedCache
 	transactionProvider       TransactionProvider
 	receivedEventPublisher    ReceivedEventPublisher
+	relayInformationCache     *RelayInformationCache
 	logger                    logging.Logger
+	metrics                   Metrics
 
 	state      RelayDownloaderState
 	stateMutex sync.Mutex
 
+	invalidEvents            int
+	invalidEventsWindowStart time.Time
+	flagged                  bool
+
+	health *relayHealthTracker
+
 	address domain.RelayAddress
 	cancel  context.CancelFunc
 }
 
 func NewRelayDownloader(
 	ctx context.Context,
+	config DownloaderConfig,
 	eventWasAlreadySavedCache EventWasAlreadySavedCache,
 	transactionProvider TransactionProvider,
 	receivedEventPublisher ReceivedEventPublisher,
+	relayInformationCache *RelayInformationCache,
 	logger logging.Logger,
+	metrics Metrics,
 	address domain.RelayAddress,
 ) *RelayDownloader {
 	ctx, cancel := context.WithCancel(ctx)
 	v := &RelayDownloader{
+		config:                    config,
 		eventWasAlreadySavedCache: eventWasAlreadySavedCache,
 		transactionProvider:       transactionProvider,
 		receivedEventPublisher:    receivedEventPublisher,
+		relayInformationCache:     relayInformationCache,
 		logger:                    logger.New(fmt.Sprintf("relayDownloader(%s)", address)),
+		metrics:                   metrics,
 
-		state: RelayDownloaderStateInitializing,
+		state:  RelayDownloaderStateInitializing,
+		health: newRelayHealthTracker(),
 
 		cancel:  cancel,
 		address: address,
@@ -215,16 +332,42 @@ func NewRelayDownloader(
 
 func (d *RelayDownloader) run(ctx context.Context) {
 	for {
+		if information, ok := d.relayInformationCache.Get(ctx, d.address); ok && information.ShouldBeSkipped() {
+			d.logger.Debug().
+				WithField("authRequired", information.Limitations().AuthRequired()).
+				WithField("paymentRequired", information.Limitations().PaymentRequired()).
+				Message("skipping the relay")
+
+			d.setState(RelayDownloaderStateSkipped)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retryRelayInformationAfter):
+				continue
+			}
+		}
+
 		if err := d.connectAndDownload(ctx); err != nil {
 			d.logger.Error().
 				WithError(err).
 				Message("error connecting and downloading")
 		}
 
+		if d.health.Quarantined(time.Now()) {
+			d.setState(RelayDownloaderStateQuarantined)
+		}
+
+		wait := d.health.WaitBeforeReconnecting(time.Now())
+
+		d.logger.Trace().
+			WithField("wait", wait).
+			Message("waiting before reconnecting")
+
 		select {
 		case <-ctx.Done():
 			return
-		case <-time.After(reconnectEvery):
+		case <-time.After(wait):
 			continue
 		}
 	}
@@ -238,11 +381,18 @@ func (d *RelayDownloader) connectAndDownload(ctx context.Context) error {
 
 	d.logger.Trace().Message("connecting")
 
+	start := time.Now()
 	conn, _, err := websocket.DefaultDialer.DialContext(ctx, d.address.String(), nil)
 	if err != nil {
+		d.health.ConnectionFailed(time.Now())
 		return errors.Wrap(err, "error dialing the relay")
 	}
 
+	d.health.Connected(time.Now(), time.Since(start))
+	defer func() {
+		d.health.Disconnected(time.Now())
+	}()
+
 	d.setState(RelayDownloaderStateConnected)
 
 	go func() {
@@ -250,27 +400,31 @@ func (d *RelayDownloader) connectAndDownload(ctx context.Context) error {
 		conn.Close()
 	}()
 
+	cursors := newDownloadCursors()
+
 	go func() {
-		if err := d.manageSubs(ctx, conn); err != nil {
+		if err := d.manageSubs(ctx, conn, cursors); err != nil {
 			d.logger.Error().
 				WithError(err).
 				Message("error managing subs")
 		}
 	}()
 
+	go d.saveCursorsLoop(ctx, cursors)
+
 	for {
 		_, messageBytes, err := conn.ReadMessage()
 		if err != nil {
 			return errors.Wrap(err, "error reading a message")
 		}
 
-		if err := d.handleMessage(messageBytes); err != nil {
+		if err := d.handleMessage(ctx, cursors, messageBytes); err != nil {
 			return errors.Wrap(err, "error handling message")
 		}
 	}
 }
 
-func (d *RelayDownloader) handleMessage(messageBytes []byte) error {
+func (d *RelayDownloader) handleMessage(ctx context.Context, cursors *downloadCursors, messageBytes []byte) error {
 	envelope := nostr.ParseMessage(messageBytes)
 	if envelope == nil {
 		return errors.New("error parsing message, we are never going to find out what error unfortunately due to the design of this library")
@@ -278,15 +432,27 @@ func (d *RelayDownloader) handleMessage(messageBytes []byte) error {
 
 	switch v := envelope.(type) {
 	case *nostr.EOSEEnvelope:
+		d.health.EOSEReceived(time.Now())
 		d.logger.Trace().
 			WithField("subscription", string(*v)).
 			Message("received EOSE")
+		cursors.EOSEReceived(string(*v))
+		d.saveCursors(ctx, cursors)
 	case *nostr.EventEnvelope:
 		event, err := domain.NewEvent(v.Event)
 		if err != nil {
+			var invalidEventErr *domain.InvalidEventError
+			if errors.As(err, &invalidEventErr) {
+				d.reportInvalidEvent(invalidEventErr, time.Now())
+				return nil
+			}
 			return errors.Wrap(err, "error creating an event")
 		}
-		if !d.eventWasAlreadySavedCache.EventWasAlreadySaved(event.Id()) {
+		d.health.EventReceived(time.Now())
+		if v.SubscriptionID != nil {
+			cursors.EventReceived(*v.SubscriptionID, event.CreatedAt(), time.Now())
+		}
+		if !d.eventWasAlreadySaved(ctx, event) {
 			d.receivedEventPublisher.Publish(d.address, event)
 		}
 	default:
@@ -299,6 +465,83 @@ func (d *RelayDownloader) handleMessage(messageBytes []byte) error {
 	return nil
 }
 
+func (d *RelayDownloader) saveCursorsLoop(ctx context.Context, cursors *downloadCursors) {
+	for {
+		select {
+		case <-time.After(saveCursorsEvery):
+			d.saveCursors(ctx, cursors)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// saveCursors keeps the cursors which couldn't be saved so that saving them
+// can be retried later.
+func (d *RelayDownloader) saveCursors(ctx context.Context, cursors *downloadCursors) {
+	pending := cursors.TakePending()
+	if len(pending) == 0 {
+		return
+	}
+
+	if err := d.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		return adapters.Relays.SaveCursors(ctx, d.address, pending)
+	}); err != nil {
+		cursors.ReturnPending(pending)
+		d.logger.Error().
+			WithError(err).
+			Message("error saving cursors")
+	}
+}
+
+// eventWasAlreadySaved returns false if the cache can't be reached as the
+// event will be checked against the storage when saving it anyway.
+func (d *RelayDownloader) eventWasAlreadySaved(ctx context.Context, event domain.Event) bool {
+	saved, err := d.eventWasAlreadySavedCache.EventWasAlreadySaved(ctx, event.Id())
+	if err != nil {
+		d.logger.Error().
+			WithError(err).
+			WithField("event.id", event.Id().Hex()).
+			Message("error checking the event was already saved cache")
+		return false
+	}
+	return saved
+}
+
+// reportInvalidEvent skips events which failed verification. Relays which keep
+// sending them are flagged as they are either broken or malicious.
+func (d *RelayDownloader) reportInvalidEvent(err *domain.InvalidEventError, now time.Time) {
+	d.metrics.ReportInvalidEvent(d.address, err.Reason())
+
+	d.logger.Debug().
+		WithError(err).
+		Message("received an invalid event")
+
+	d.stateMutex.Lock()
+	defer d.stateMutex.Unlock()
+
+	if now.Sub(d.invalidEventsWindowStart) > countInvalidEventsOver {
+		d.invalidEventsWindowStart = now
+		d.invalidEvents = 0
+	}
+	d.invalidEvents++
+
+	if !d.flagged && d.invalidEvents >= flagRelayAfterInvalidEvents {
+		d.flagged = true
+		d.logger.Error().
+			WithField("invalidEvents", d.invalidEvents).
+			Message("flagging the relay for repeatedly sending invalid events")
+	}
+}
+
+// Flagged returns true if the relay repeatedly sent events with invalid ids or
+// signatures.
+func (d *RelayDownloader) Flagged() bool {
+	d.stateMutex.Lock()
+	defer d.stateMutex.Unlock()
+	return d.flagged
+}
+
 func (d *RelayDownloader) setState(state RelayDownloaderState) {
 	d.stateMutex.Lock()
 	defer d.stateMutex.Unlock()
@@ -311,21 +554,27 @@ func (d *RelayDownloader) GetState() RelayDownloaderState {
 	return d.state
 }
 
+func (d *RelayDownloader) GetHealth() RelayHealth {
+	return d.health.Health(time.Now(), d.address, d.GetState())
+}
+
 func (d *RelayDownloader) manageSubs(
 	ctx context.Context,
 	conn *websocket.Conn,
+	cursors *downloadCursors,
 ) error {
 	defer conn.Close()
 
-	activeSubscriptions := internal.NewEmptySet[domain.PublicKey]()
+	plan := newSubscriptionPlan(d.getLimitations(ctx), len(createFilters(nil, time.Time{})))
+	batches := newSubscriptionBatches()
 
 	for {
-		publicKeys, err := d.getPublicKeys(ctx)
+		publicKeys, savedCursors, err := d.getPublicKeysAndCursors(ctx)
 		if err != nil {
 			return errors.Wrap(err, "error getting public keys")
 		}
 
-		if err := d.updateSubs(conn, activeSubscriptions, publicKeys); err != nil {
+		if err := d.updateSubs(conn, plan, batches, publicKeys, savedCursors, cursors); err != nil {
 			return errors.Wrap(err, "error updating subscriptions")
 		}
 
@@ -338,20 +587,46 @@ func (d *RelayDownloader) manageSubs(
 	}
 }
 
-// For each pubkey for which we don't have an active nostr REQ, create a new REQ
-// For each pubkey for which we do have and active nostr REQ but it's not in the list, close it
+// getLimitations returns zero limitations if they aren't known so that we
+// fall back to the default limits.
+func (d *RelayDownloader) getLimitations(ctx context.Context) domain.RelayLimitations {
+	information, ok := d.relayInformationCache.Get(ctx, d.address)
+	if !ok {
+		return domain.RelayLimitations{}
+	}
+	return information.Limitations()
+}
+
+// updateSubs packs public keys into batches and sends REQs only for batches
+// which changed. Sending a REQ with the same subscription id replaces the
+// previous subscription. Each batch resumes downloading from the oldest cursor
+// of its public keys.
 func (d *RelayDownloader) updateSubs(
 	conn *websocket.Conn,
-	activeSubscriptions *internal.Set[domain.PublicKey],
+	plan subscriptionPlan,
+	batches *subscriptionBatches,
 	publicKeys *internal.Set[domain.PublicKey],
+	savedCursors map[domain.PublicKey]time.Time,
+	cursors *downloadCursors,
 ) error {
-	for _, publicKey := range activeSubscriptions.List() {
-		if !publicKeys.Contains(publicKey) {
-			d.logger.Trace().
-				WithField("publicKey", publicKey).
-				Message("closing subscription")
+	update := batches.Update(publicKeys, plan)
+
+	if update.skipped > 0 {
+		d.logger.Error().
+			WithField("skipped", update.skipped).
+			WithField("publicKeysPerBatch", plan.publicKeysPerBatch).
+			WithField("maxBatches", plan.maxBatches).
+			Message("relay limitations don't allow subscribing to all public keys")
+	}
 
-			envelope := nostr.CloseEnvelope(publicKey.Hex())
+	for _, id := range update.closed {
+		d.logger.Trace().
+			WithField("batch", id).
+			Message("closing subscriptions")
+
+		for part := 0; part < plan.requestsPerBatch; part++ {
+			cursors.Closed(subscriptionID(id, part))
+			envelope := nostr.CloseEnvelope(subscriptionID(id, part))
 
 			envelopeJSON, err := envelope.MarshalJSON()
 			if err != nil {
@@ -361,18 +636,19 @@ func (d *RelayDownloader) updateSubs(
 			if err := conn.WriteMessage(websocket.TextMessage, envelopeJSON); err != nil {
 				return errors.Wrap(err, "writing close envelope error")
 			}
-
-			activeSubscriptions.Delete(publicKey)
 		}
 	}
 
-	for _, publicKey := range publicKeys.List() {
-		if ok := activeSubscriptions.Contains(publicKey); !ok {
-			d.logger.Trace().
-				WithField("publicKey", publicKey).
-				Message("opening subscription")
+	for _, batch := range update.changed {
+		d.logger.Trace().
+			WithField("batch", batch.id).
+			WithField("publicKeys", len(batch.publicKeys)).
+			Message("opening subscriptions")
+
+		since := resumeDownloadingFrom(batch.publicKeys, savedCursors, d.config, time.Now())
 
-			envelope := d.createRequest(publicKey)
+		for _, envelope := range createRequests(plan, batch, since) {
+			cursors.Subscribed(envelope.SubscriptionID, batch.publicKeys)
 
 			envelopeJSON, err := envelope.MarshalJSON()
 			if err != nil {
@@ -382,51 +658,106 @@ func (d *RelayDownloader) updateSubs(
 			if err := conn.WriteMessage(websocket.TextMessage, envelopeJSON); err != nil {
 				return errors.Wrap(err, "writing req envelope error")
 			}
-
-			activeSubscriptions.Put(publicKey)
 		}
 	}
 
 	return nil
 }
 
-func (d *RelayDownloader) createRequest(publicKey domain.PublicKey) nostr.ReqEnvelope {
-	t := nostr.Timestamp(time.Now().Add(-howFarIntoThePastToLook).Unix())
+func createRequests(plan subscriptionPlan, batch subscriptionBatch, since time.Time) []nostr.ReqEnvelope {
+	filters := createFilters(batch.publicKeys, since)
+
+	var envelopes []nostr.ReqEnvelope
+	for part := 0; part < plan.requestsPerBatch; part++ {
+		start := part * plan.filtersPerRequest
+		end := start + plan.filtersPerRequest
+		if end > len(filters) {
+			end = len(filters)
+		}
+
+		envelopes = append(envelopes, nostr.ReqEnvelope{
+			SubscriptionID: subscriptionID(batch.id, part),
+			Filters:        filters[start:end],
+		})
+	}
+	return envelopes
+}
+
+func createFilters(publicKeys []domain.PublicKey, since time.Time) nostr.Filters {
+	var hexes []string
+	for _, publicKey := range publicKeys {
+		hexes = append(hexes, publicKey.Hex())
+	}
 
-	var eventKindsToDownload []int
+	// kinds which can be backdated need to be requested using separate
+	// filters which look further into the past
+	kindsByBackdating := make(map[time.Duration][]int)
 	for _, eventKind := range domain.EventKindsToDownload() {
-		eventKindsToDownload = append(eventKindsToDownload, eventKind.Int())
+		backdating := domain.MaxBackdating(eventKind)
+		kindsByBackdating[backdating] = append(kindsByBackdating[backdating], eventKind.Int())
 	}
 
-	envelope := nostr.ReqEnvelope{
-		SubscriptionID: publicKey.Hex(),
-		Filters: nostr.Filters{nostr.Filter{
-			Kinds: eventKindsToDownload,
+	var backdatings []time.Duration
+	for backdating := range kindsByBackdating {
+		backdatings = append(backdatings, backdating)
+	}
+	sort.Slice(backdatings, func(i, j int) bool {
+		return backdatings[i] < backdatings[j]
+	})
+
+	var filters nostr.Filters
+	for _, backdating := range backdatings {
+		kinds := kindsByBackdating[backdating]
+		sort.Ints(kinds)
+
+		t := nostr.Timestamp(since.Add(-backdating).Unix())
+		filters = append(filters, nostr.Filter{
+			Kinds: kinds,
 			Tags: map[string][]string{
-				"p": {publicKey.Hex()},
+				"p": hexes,
 			},
 			Since: &t,
-		}},
+		})
 	}
 
-	return envelope
+	// mute lists and relay lists are replaceable so only the latest one of
+	// each author is needed no matter how old it is
+	replaceableKinds := []int{domain.EventKindMuteList.Int(), domain.EventKindRelayList.Int()}
+	filters = append(filters, nostr.Filter{
+		Kinds:   replaceableKinds,
+		Authors: hexes,
+		Limit:   len(replaceableKinds) * len(hexes),
+	})
+
+	return filters
 }
 
-func (d *RelayDownloader) getPublicKeys(ctx context.Context) (*internal.Set[domain.PublicKey], error) {
+func subscriptionID(batch, part int) string {
+	return fmt.Sprintf("batch%d.%d", batch, part)
+}
+
+func (d *RelayDownloader) getPublicKeysAndCursors(ctx context.Context) (*internal.Set[domain.PublicKey], map[domain.PublicKey]time.Time, error) {
 	var publicKeys []domain.PublicKey
+	var cursors map[domain.PublicKey]time.Time
 
-	if err := d.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	if err := d.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		tmp, err := adapters.Relays.GetPublicKeys(ctx, d.address, time.Now().Add(-getPublicKeysYoungerThan))
 		if err != nil {
 			return errors.Wrap(err, "error getting public keys")
 		}
 		publicKeys = tmp
+
+		cursors, err = adapters.Relays.GetCursors(ctx, d.address)
+		if err != nil {
+			return errors.Wrap(err, "error getting cursors")
+		}
+
 		return nil
 	}); err != nil {
-		return nil, errors.Wrap(err, "transaction error")
+		return nil, nil, errors.Wrap(err, "transaction error")
 	}
 
-	return internal.NewSet(publicKeys), nil
+	return internal.NewSet(publicKeys), cursors, nil
 }
 
 func (d *RelayDownloader) Stop() {
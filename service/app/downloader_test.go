@@ -0,0 +1,502 @@
+package app_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayDownloader_InvalidEventsAreSkippedAndRelayIsFlagged(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	tamperedFixtures := []func(tb testing.TB) nostr.Event{
+		fixtures.LibeventWithTamperedID,
+		fixtures.LibeventWithTamperedContent,
+		fixtures.LibeventWithTamperedSignature,
+	}
+
+	var libevents []nostr.Event
+	for i := 0; i < 10; i++ {
+		libevents = append(libevents, tamperedFixtures[i%len(tamperedFixtures)](t))
+	}
+	validLibevent := fixtures.SomeLibevent(t)
+	libevents = append(libevents, validLibevent)
+
+	address := newFakeRelay(t, libevents)
+	publisher := newFakeReceivedEventPublisher()
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		newFakeStorage(),
+		publisher,
+		newRelayInformationCache(newFakeRelayInformationProvider()),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		address,
+	)
+	defer downloader.Stop()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		assert.True(t, downloader.Flagged())
+
+		published := publisher.PublishedEvents()
+		if assert.Len(t, published, 1) {
+			assert.Equal(t, validLibevent.ID, published[0].Id().Hex())
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayDownloader_RelayIsNotFlaggedForOccasionalInvalidEvents(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	validLibevent := fixtures.SomeLibevent(t)
+	libevents := []nostr.Event{
+		fixtures.LibeventWithTamperedID(t),
+		validLibevent,
+	}
+
+	address := newFakeRelay(t, libevents)
+	publisher := newFakeReceivedEventPublisher()
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		newFakeStorage(),
+		publisher,
+		newRelayInformationCache(newFakeRelayInformationProvider()),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		address,
+	)
+	defer downloader.Stop()
+
+	require.Eventually(t, func() bool {
+		return len(publisher.PublishedEvents()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, downloader.Flagged())
+}
+
+func TestRelayDownloader_HealthIsTracked(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	address := newFakeRelay(t, []nostr.Event{fixtures.SomeLibevent(t)})
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		newFakeStorage(),
+		newFakeReceivedEventPublisher(),
+		newRelayInformationCache(newFakeRelayInformationProvider()),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		address,
+	)
+	defer downloader.Stop()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		health := downloader.GetHealth()
+		assert.Equal(t, address, health.Address())
+		assert.Equal(t, app.RelayDownloaderStateConnected, health.State())
+		assert.Positive(t, health.ConnectLatency())
+		assert.Equal(t, 0, health.ConsecutiveFailures())
+
+		_, ok := health.LastEOSE()
+		assert.True(t, ok)
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayDownloader_FailedConnectionsAreTracked(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	address := unreachableRelayAddress(t)
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		newFakeStorage(),
+		newFakeReceivedEventPublisher(),
+		newRelayInformationCache(newFakeRelayInformationProvider()),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		address,
+	)
+	defer downloader.Stop()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		health := downloader.GetHealth()
+		assert.Equal(t, app.RelayDownloaderStateDisconnected, health.State())
+		assert.Equal(t, 1, health.ConsecutiveFailures())
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayDownloader_RelaysWhichRequireAuthOrPaymentAreSkipped(t *testing.T) {
+	testCases := []struct {
+		Name            string
+		AuthRequired    bool
+		PaymentRequired bool
+	}{
+		{
+			Name:         "auth",
+			AuthRequired: true,
+		},
+		{
+			Name:            "payment",
+			PaymentRequired: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			ctx := fixtures.Context(t)
+
+			relay := newRecordingFakeRelay(t, []nostr.Event{fixtures.SomeLibevent(t)})
+			publisher := newFakeReceivedEventPublisher()
+
+			storage := newFakeStorage()
+			publicKey, _ := fixtures.SomeKeyPair()
+			storage.state.relays[relay.Address()] = []domain.PublicKey{publicKey}
+
+			limitations, err := domain.NewRelayLimitations(0, 0, 0, testCase.AuthRequired, testCase.PaymentRequired)
+			require.NoError(t, err)
+
+			relayInformationProvider := newFakeRelayInformationProvider()
+			relayInformationProvider.SetInformation(domain.NewRelayInformation([]int{1, 11, 42}, limitations))
+
+			downloader := app.NewRelayDownloader(
+				ctx,
+				newDownloaderConfig(t),
+				adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+				storage,
+				publisher,
+				newRelayInformationCache(relayInformationProvider),
+				logging.NewDevNullLogger(),
+				fakeMetrics{},
+				relay.Address(),
+			)
+			defer downloader.Stop()
+
+			require.Eventually(t, func() bool {
+				return downloader.GetState() == app.RelayDownloaderStateSkipped
+			}, 5*time.Second, 10*time.Millisecond)
+
+			<-time.After(100 * time.Millisecond)
+			require.Empty(t, relay.Messages())
+			require.Empty(t, publisher.PublishedEvents())
+		})
+	}
+}
+
+func newRelayInformationCache(provider app.RelayInformationProvider) *app.RelayInformationCache {
+	return app.NewRelayInformationCache(provider, logging.NewDevNullLogger())
+}
+
+// newFakeRelay starts a relay which sends the provided events followed by EOSE
+// to every client right after it connects.
+func newFakeRelay(tb testing.TB, libevents []nostr.Event) domain.RelayAddress {
+	return newRecordingFakeRelay(tb, libevents).Address()
+}
+
+type recordingFakeRelay struct {
+	address domain.RelayAddress
+
+	lock     sync.Mutex
+	messages [][]byte
+}
+
+// newRecordingFakeRelay starts a fake relay which also records all messages
+// sent by the clients.
+func newRecordingFakeRelay(tb testing.TB, libevents []nostr.Event) *recordingFakeRelay {
+	relay := &recordingFakeRelay{}
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for _, libevent := range libevents {
+			envelope := nostr.EventEnvelope{Event: libevent}
+			if err := conn.WriteJSON(envelope); err != nil {
+				return
+			}
+		}
+
+		if err := conn.WriteJSON(nostr.EOSEEnvelope(fixtures.SomeString())); err != nil {
+			return
+		}
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			relay.lock.Lock()
+			relay.messages = append(relay.messages, message)
+			relay.lock.Unlock()
+		}
+	}))
+	tb.Cleanup(server.Close)
+
+	address, err := domain.NewRelayAddress(strings.Replace(server.URL, "http://", "ws://", 1))
+	require.NoError(tb, err)
+	relay.address = address
+	return relay
+}
+
+func (r *recordingFakeRelay) Address() domain.RelayAddress {
+	return r.address
+}
+
+func (r *recordingFakeRelay) Messages() [][]byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return internal.CopySlice(r.messages)
+}
+
+func TestRelayDownloader_PublicKeysAreBatchedAccordingToRelayLimitations(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	const (
+		maxMessageLength = 8192
+		maxSubscriptions = 4
+		maxFilters       = 2
+	)
+
+	relay := newRecordingFakeRelay(t, nil)
+
+	storage := newFakeStorage()
+	var publicKeys []string
+	for i := 0; i < 100; i++ {
+		publicKey, _ := fixtures.SomeKeyPair()
+		storage.state.relays[relay.Address()] = append(storage.state.relays[relay.Address()], publicKey)
+		publicKeys = append(publicKeys, publicKey.Hex())
+	}
+
+	limitations, err := domain.NewRelayLimitations(maxMessageLength, maxSubscriptions, maxFilters, false, false)
+	require.NoError(t, err)
+
+	relayInformationProvider := newFakeRelayInformationProvider()
+	relayInformationProvider.SetInformation(domain.NewRelayInformation(nil, limitations))
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		storage,
+		newFakeReceivedEventPublisher(),
+		newRelayInformationCache(relayInformationProvider),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		relay.Address(),
+	)
+	defer downloader.Stop()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		subscriptions := make(map[string]struct{})
+		mentioned := make(map[string]struct{})
+		authors := make(map[string]struct{})
+
+		for _, message := range relay.Messages() {
+			assert.LessOrEqual(t, len(message), maxMessageLength)
+
+			envelope, ok := nostr.ParseMessage(message).(*nostr.ReqEnvelope)
+			if !assert.True(t, ok) {
+				return
+			}
+
+			assert.LessOrEqual(t, len(envelope.Filters), maxFilters)
+			subscriptions[envelope.SubscriptionID] = struct{}{}
+
+			for _, filter := range envelope.Filters {
+				for _, publicKey := range filter.Tags["p"] {
+					mentioned[publicKey] = struct{}{}
+				}
+				for _, publicKey := range filter.Authors {
+					authors[publicKey] = struct{}{}
+				}
+			}
+		}
+
+		assert.LessOrEqual(t, len(subscriptions), maxSubscriptions)
+		assert.Greater(t, len(subscriptions), 1)
+		for _, publicKey := range publicKeys {
+			assert.Contains(t, mentioned, publicKey)
+			assert.Contains(t, authors, publicKey)
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayDownloader_DownloadsAreResumedFromCursors(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	relay := newRecordingFakeRelay(t, nil)
+
+	publicKey, _ := fixtures.SomeKeyPair()
+	cursor := time.Now().Add(-1 * time.Hour).Truncate(time.Second)
+
+	storage := newFakeStorage()
+	storage.state.relays[relay.Address()] = []domain.PublicKey{publicKey}
+	storage.state.cursors[relay.Address()] = map[domain.PublicKey]time.Time{publicKey: cursor}
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		storage,
+		newFakeReceivedEventPublisher(),
+		newRelayInformationCache(newFakeRelayInformationProvider()),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		relay.Address(),
+	)
+	defer downloader.Stop()
+
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		messages := relay.Messages()
+		if !assert.NotEmpty(t, messages) {
+			return
+		}
+
+		envelope, ok := nostr.ParseMessage(messages[0]).(*nostr.ReqEnvelope)
+		if !assert.True(t, ok) {
+			return
+		}
+
+		filter := envelope.Filters[0]
+		if assert.NotNil(t, filter.Since) {
+			since := filter.Since.Time()
+			assert.True(t, since.Before(cursor))
+			assert.True(t, since.After(cursor.Add(-1*time.Hour)))
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRelayDownloader_CursorsAreSavedAfterEOSE(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	mentionedPublicKey, _ := fixtures.SomeKeyPair()
+	quietPublicKey, _ := fixtures.SomeKeyPair()
+	_, secretKeyHex := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Timestamp(time.Now().Add(-30 * time.Minute).Unix()),
+		Kind:      domain.EventKindNote.Int(),
+		Tags:      nostr.Tags{{"p", mentionedPublicKey.Hex()}},
+		Content:   fixtures.SomeString(),
+	}
+	err := libevent.Sign(secretKeyHex)
+	require.NoError(t, err)
+
+	address := newReplyingFakeRelay(t, []nostr.Event{libevent})
+
+	storage := newFakeStorage()
+	storage.state.relays[address] = []domain.PublicKey{mentionedPublicKey, quietPublicKey}
+
+	downloader := app.NewRelayDownloader(
+		ctx,
+		newDownloaderConfig(t),
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		storage,
+		newFakeReceivedEventPublisher(),
+		newRelayInformationCache(newFakeRelayInformationProvider()),
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+		address,
+	)
+	defer downloader.Stop()
+
+	// public keys which weren't mentioned in any events also have their
+	// cursors moved forward as all of their events were received
+	require.EventuallyWithT(t, func(t *assert.CollectT) {
+		cursors := storage.Cursors(address)
+		for _, publicKey := range []domain.PublicKey{mentionedPublicKey, quietPublicKey} {
+			if assert.Contains(t, cursors, publicKey) {
+				assert.True(t, libevent.CreatedAt.Time().Equal(cursors[publicKey]))
+			}
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// newReplyingFakeRelay starts a fake relay which replies to every REQ with the
+// provided events followed by EOSE.
+func newReplyingFakeRelay(tb testing.TB, libevents []nostr.Event) domain.RelayAddress {
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			_, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+
+			envelope, ok := nostr.ParseMessage(message).(*nostr.ReqEnvelope)
+			if !ok {
+				continue
+			}
+
+			for _, libevent := range libevents {
+				subscriptionID := envelope.SubscriptionID
+				if err := conn.WriteJSON(nostr.EventEnvelope{SubscriptionID: &subscriptionID, Event: libevent}); err != nil {
+					return
+				}
+			}
+
+			if err := conn.WriteJSON(nostr.EOSEEnvelope(envelope.SubscriptionID)); err != nil {
+				return
+			}
+		}
+	}))
+	tb.Cleanup(server.Close)
+
+	address, err := domain.NewRelayAddress(strings.Replace(server.URL, "http://", "ws://", 1))
+	require.NoError(tb, err)
+	return address
+}
+
+func newDownloaderConfig(tb testing.TB) app.DownloaderConfig {
+	config, err := app.NewDownloaderConfig(24*time.Hour, 7*24*time.Hour, 10*time.Minute)
+	require.NoError(tb, err)
+	return config
+}
+
+func unreachableRelayAddress(tb testing.TB) domain.RelayAddress {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(tb, err)
+
+	addr := listener.Addr().String()
+	require.NoError(tb, listener.Close())
+
+	address, err := domain.NewRelayAddress("ws://" + addr)
+	require.NoError(tb, err)
+	return address
+}
@@ -0,0 +1,538 @@
+package app_test
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+)
+
+// fakeStorage keeps its state in memory. Changes made during a transaction
+// are discarded if the transaction returns an error which makes it possible to
+// test what happens when handlers fail halfway through. If retryTransactions
+// is set every transaction function is called twice and only the changes made
+// during the second call are kept the same way as when real storage retries a
+// transaction after a conflict.
+type fakeStorage struct {
+	lock              sync.Mutex
+	state             fakeStorageState
+	retryTransactions bool
+}
+
+func newFakeStorage() *fakeStorage {
+	return &fakeStorage{
+		state: newFakeStorageState(),
+	}
+}
+
+func (s *fakeStorage) Transact(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.retryTransactions {
+		state := s.state.copy()
+		if err := f(ctx, s.adapters(&state)); err != nil {
+			return errors.Wrap(err, "error calling the provided function")
+		}
+	}
+
+	state := s.state.copy()
+
+	if err := f(ctx, s.adapters(&state)); err != nil {
+		return errors.Wrap(err, "error calling the provided function")
+	}
+
+	s.state = state
+	return nil
+}
+
+// TransactReadOnly discards all changes.
+func (s *fakeStorage) TransactReadOnly(ctx context.Context, f func(context.Context, app.Adapters) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	state := s.state.copy()
+
+	if err := f(ctx, s.adapters(&state)); err != nil {
+		return errors.Wrap(err, "error calling the provided function")
+	}
+
+	return nil
+}
+
+func (s *fakeStorage) adapters(state *fakeStorageState) app.Adapters {
+	return app.Adapters{
+		Events:     &fakeEventRepository{state: state},
+		PublicKeys: &fakePublicKeyRepository{state: state},
+		Relays:     &fakeRelayRepository{state: state},
+		Tags:       &fakeTagRepository{},
+		MuteLists:  &fakeMuteListRepository{state: state},
+		Publisher:  &fakePublisher{state: state},
+	}
+}
+
+func (s *fakeStorage) Notifications() []notifications.Notification {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return internal.CopySlice(s.state.notifications)
+}
+
+func (s *fakeStorage) PublishedEventSaved() []domain.EventId {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return internal.CopySlice(s.state.published)
+}
+
+func (s *fakeStorage) Cursors(address domain.RelayAddress) map[domain.PublicKey]time.Time {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	result := make(map[domain.PublicKey]time.Time)
+	for publicKey, cursor := range s.state.cursors[address] {
+		result[publicKey] = cursor
+	}
+	return result
+}
+
+func (s *fakeStorage) RelayList(publicKey domain.PublicKey) (domain.RelayList, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	relayList, ok := s.state.relayLists[publicKey]
+	return relayList, ok
+}
+
+type fakeStorageState struct {
+	events        map[domain.EventId]domain.Event
+	tokens        map[domain.PublicKey][]domain.RegisteredPushToken
+	notifications []notifications.Notification
+	deliveries    map[notifications.Delivery]bool
+	muteLists     map[domain.PublicKey]domain.MuteList
+	relays        map[domain.RelayAddress][]domain.PublicKey
+	cursors       map[domain.RelayAddress]map[domain.PublicKey]time.Time
+	relayLists    map[domain.PublicKey]domain.RelayList
+	published     []domain.EventId
+}
+
+func newFakeStorageState() fakeStorageState {
+	return fakeStorageState{
+		events:     make(map[domain.EventId]domain.Event),
+		tokens:     make(map[domain.PublicKey][]domain.RegisteredPushToken),
+		deliveries: make(map[notifications.Delivery]bool),
+		muteLists:  make(map[domain.PublicKey]domain.MuteList),
+		relays:     make(map[domain.RelayAddress][]domain.PublicKey),
+		cursors:    make(map[domain.RelayAddress]map[domain.PublicKey]time.Time),
+		relayLists: make(map[domain.PublicKey]domain.RelayList),
+	}
+}
+
+func (s fakeStorageState) copy() fakeStorageState {
+	v := newFakeStorageState()
+	for k, e := range s.events {
+		v.events[k] = e
+	}
+	for k, t := range s.tokens {
+		v.tokens[k] = internal.CopySlice(t)
+	}
+	v.notifications = internal.CopySlice(s.notifications)
+	for k, sent := range s.deliveries {
+		v.deliveries[k] = sent
+	}
+	for k, m := range s.muteLists {
+		v.muteLists[k] = m
+	}
+	for k, p := range s.relays {
+		v.relays[k] = internal.CopySlice(p)
+	}
+	for k, c := range s.cursors {
+		v.cursors[k] = make(map[domain.PublicKey]time.Time)
+		for publicKey, cursor := range c {
+			v.cursors[k][publicKey] = cursor
+		}
+	}
+	for k, l := range s.relayLists {
+		v.relayLists[k] = l
+	}
+	v.published = internal.CopySlice(s.published)
+	return v
+}
+
+type fakeEventRepository struct {
+	app.EventRepository
+
+	state *fakeStorageState
+}
+
+func (r *fakeEventRepository) Save(event domain.Event) error {
+	r.state.events[event.Id()] = event
+	return nil
+}
+
+func (r *fakeEventRepository) Get(ctx context.Context, id domain.EventId) (domain.Event, error) {
+	event, ok := r.state.events[id]
+	if !ok {
+		return domain.Event{}, errors.New("event not found")
+	}
+	return event, nil
+}
+
+func (r *fakeEventRepository) Exists(ctx context.Context, id domain.EventId) (bool, error) {
+	_, ok := r.state.events[id]
+	return ok, nil
+}
+
+func (r *fakeEventRepository) GetEvents(ctx context.Context, filters domain.Filters) <-chan app.EventOrError {
+	var events []domain.Event
+	for _, filter := range filters.Filters() {
+		var matching []domain.Event
+		for _, event := range r.state.events {
+			if filter.Matches(event) {
+				matching = append(matching, event)
+			}
+		}
+
+		sort.Slice(matching, func(i, j int) bool {
+			return matching[i].CreatedAt().After(matching[j].CreatedAt())
+		})
+
+		if filter.Limit() > 0 && len(matching) > filter.Limit() {
+			matching = matching[:filter.Limit()]
+		}
+
+		for _, event := range matching {
+			if !containsEvent(events, event) {
+				events = append(events, event)
+			}
+		}
+	}
+
+	ch := make(chan app.EventOrError, len(events))
+	for _, event := range events {
+		ch <- app.NewEventOrErrorWithEvent(event)
+	}
+	close(ch)
+	return ch
+}
+
+func containsEvent(events []domain.Event, event domain.Event) bool {
+	for _, v := range events {
+		if v.Id() == event.Id() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeEventRepository) SaveNotificationForEvent(notification notifications.Notification) error {
+	r.state.notifications = append(r.state.notifications, notification)
+	return nil
+}
+
+func (r *fakeEventRepository) ClaimDelivery(delivery notifications.Delivery) error {
+	r.state.deliveries[delivery] = false
+	return nil
+}
+
+func (r *fakeEventRepository) MarkDeliverySent(delivery notifications.Delivery) error {
+	if _, ok := r.state.deliveries[delivery]; ok {
+		r.state.deliveries[delivery] = true
+	}
+	return nil
+}
+
+func (r *fakeEventRepository) DeleteDelivery(delivery notifications.Delivery) error {
+	delete(r.state.deliveries, delivery)
+	return nil
+}
+
+func (r *fakeEventRepository) DeliveryExists(ctx context.Context, delivery notifications.Delivery) (bool, error) {
+	_, ok := r.state.deliveries[delivery]
+	return ok, nil
+}
+
+type fakePublicKeyRepository struct {
+	app.PublicKeyRepository
+
+	state *fakeStorageState
+}
+
+func (r *fakePublicKeyRepository) GetPushTokens(ctx context.Context, publicKey domain.PublicKey, savedAfter time.Time) ([]domain.RegisteredPushToken, error) {
+	return internal.CopySlice(r.state.tokens[publicKey]), nil
+}
+
+func (r *fakePublicKeyRepository) DeletePushToken(ctx context.Context, publicKey domain.PublicKey, token domain.PushToken) error {
+	var remaining []domain.RegisteredPushToken
+	for _, v := range r.state.tokens[publicKey] {
+		if v.PushToken() != token {
+			remaining = append(remaining, v)
+		}
+	}
+	r.state.tokens[publicKey] = remaining
+	return nil
+}
+
+type fakeRelayRepository struct {
+	app.RelayRepository
+
+	state *fakeStorageState
+}
+
+func (r *fakeRelayRepository) GetPublicKeys(ctx context.Context, address domain.RelayAddress, updatedAfter time.Time) ([]domain.PublicKey, error) {
+	return internal.CopySlice(r.state.relays[address]), nil
+}
+
+func (r *fakeRelayRepository) DeletePublicKeyFromRelays(ctx context.Context, publicKey domain.PublicKey, addresses []domain.RelayAddress) error {
+	for _, address := range addresses {
+		var remaining []domain.PublicKey
+		for _, v := range r.state.relays[address] {
+			if v != publicKey {
+				remaining = append(remaining, v)
+			}
+		}
+		r.state.relays[address] = remaining
+		delete(r.state.cursors[address], publicKey)
+	}
+	return nil
+}
+
+func (r *fakeRelayRepository) DeletePublicKeyFromAllRelays(ctx context.Context, publicKey domain.PublicKey) error {
+	var addresses []domain.RelayAddress
+	for address := range r.state.relays {
+		addresses = append(addresses, address)
+	}
+	return r.DeletePublicKeyFromRelays(ctx, publicKey, addresses)
+}
+
+func (r *fakeRelayRepository) GetCursors(ctx context.Context, address domain.RelayAddress) (map[domain.PublicKey]time.Time, error) {
+	result := make(map[domain.PublicKey]time.Time)
+	for publicKey, cursor := range r.state.cursors[address] {
+		result[publicKey] = cursor
+	}
+	return result, nil
+}
+
+func (r *fakeRelayRepository) SaveCursors(ctx context.Context, address domain.RelayAddress, cursors map[domain.PublicKey]time.Time) error {
+	for _, publicKey := range r.state.relays[address] {
+		cursor, ok := cursors[publicKey]
+		if !ok {
+			continue
+		}
+
+		if r.state.cursors[address] == nil {
+			r.state.cursors[address] = make(map[domain.PublicKey]time.Time)
+		}
+
+		if previous, ok := r.state.cursors[address][publicKey]; !ok || previous.Before(cursor) {
+			r.state.cursors[address][publicKey] = cursor
+		}
+	}
+	return nil
+}
+
+func (r *fakeRelayRepository) SaveRelayList(ctx context.Context, relayList domain.RelayList) error {
+	if previous, ok := r.state.relayLists[relayList.Author()]; ok && relayList.Event().CreatedAt().Before(previous.Event().CreatedAt()) {
+		return nil
+	}
+	r.state.relayLists[relayList.Author()] = relayList
+	return nil
+}
+
+type fakeMuteListRepository struct {
+	app.MuteListRepository
+
+	state *fakeStorageState
+}
+
+func (r *fakeMuteListRepository) Save(muteList domain.MuteList) error {
+	r.state.muteLists[muteList.Author()] = muteList
+	return nil
+}
+
+func (r *fakeMuteListRepository) Get(ctx context.Context, publicKey domain.PublicKey) (domain.MuteList, bool, error) {
+	muteList, ok := r.state.muteLists[publicKey]
+	return muteList, ok, nil
+}
+
+type fakeTagRepository struct {
+}
+
+func (r *fakeTagRepository) Save(event domain.Event, tags []domain.EventTag) error {
+	return nil
+}
+
+// fakeAPNS fails the nth call to SendNotification if failOnCall is set and
+// rejects tokens listed in invalidTokens.
+type fakeAPNS struct {
+	app.APNS
+
+	lock          sync.Mutex
+	calls         int
+	failOnCall    int
+	invalidTokens []domain.PushToken
+	sent          []notifications.Notification
+}
+
+func (a *fakeAPNS) SendNotification(notification notifications.Notification) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	a.calls++
+	if a.calls == a.failOnCall {
+		return errors.New("apns is down")
+	}
+
+	for _, token := range a.invalidTokens {
+		if token == notification.PushToken() {
+			return errors.Wrap(app.NewInvalidPushTokenError("Unregistered"), "error pushing the notification")
+		}
+	}
+
+	a.sent = append(a.sent, notification)
+	return nil
+}
+
+func (a *fakeAPNS) SentNotifications() []notifications.Notification {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return internal.CopySlice(a.sent)
+}
+
+type fakeMetadataProvider struct {
+	lock     sync.Mutex
+	metadata map[domain.PublicKey]domain.Metadata
+	calls    int
+}
+
+func newFakeMetadataProvider() *fakeMetadataProvider {
+	return &fakeMetadataProvider{
+		metadata: make(map[domain.PublicKey]domain.Metadata),
+	}
+}
+
+func (p *fakeMetadataProvider) GetMetadata(ctx context.Context, publicKey domain.PublicKey) (domain.Metadata, bool, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.calls++
+	metadata, ok := p.metadata[publicKey]
+	return metadata, ok, nil
+}
+
+func (p *fakeMetadataProvider) Calls() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return p.calls
+}
+
+type fakePublisher struct {
+	state *fakeStorageState
+}
+
+func (p *fakePublisher) PublishEventSaved(ctx context.Context, id domain.EventId) error {
+	p.state.published = append(p.state.published, id)
+	return nil
+}
+
+type fakeReceivedEventPublisher struct {
+	lock      sync.Mutex
+	published []domain.Event
+}
+
+func newFakeReceivedEventPublisher() *fakeReceivedEventPublisher {
+	return &fakeReceivedEventPublisher{}
+}
+
+func (p *fakeReceivedEventPublisher) Publish(relay domain.RelayAddress, event domain.Event) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.published = append(p.published, event)
+}
+
+func (p *fakeReceivedEventPublisher) PublishedEvents() []domain.Event {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return internal.CopySlice(p.published)
+}
+
+type fakeRelayInformationProvider struct {
+	lock        sync.Mutex
+	information domain.RelayInformation
+	err         error
+	calls       int
+}
+
+func newFakeRelayInformationProvider() *fakeRelayInformationProvider {
+	return &fakeRelayInformationProvider{}
+}
+
+func (f *fakeRelayInformationProvider) GetRelayInformation(ctx context.Context, address domain.RelayAddress) (domain.RelayInformation, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.calls++
+	return f.information, f.err
+}
+
+func (f *fakeRelayInformationProvider) SetInformation(information domain.RelayInformation) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.information = information
+}
+
+func (f *fakeRelayInformationProvider) SetError(err error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.err = err
+}
+
+func (f *fakeRelayInformationProvider) Calls() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.calls
+}
+
+type fakeMetrics struct {
+}
+
+func (f fakeMetrics) StartApplicationCall(handlerName string) app.ApplicationCall {
+	return fakeApplicationCall{}
+}
+
+func (f fakeMetrics) MeasureRelayDownloadersState(n int, state app.RelayDownloaderState) {
+}
+
+func (f fakeMetrics) MeasureFollowChange(n int) {
+}
+
+func (f fakeMetrics) ReportInvalidPushTokenRemoved(platform domain.PushTokenPlatform, reason string) {
+}
+
+func (f fakeMetrics) ReportInvalidEvent(relay domain.RelayAddress, reason domain.InvalidEventReason) {
+}
+
+func (f fakeMetrics) MeasureFlaggedRelays(relays []domain.RelayAddress) {
+}
+
+func (f fakeMetrics) MeasureRelayHealth(health []app.RelayHealth) {
+}
+
+type fakeApplicationCall struct {
+}
+
+func (f fakeApplicationCall) End(err *error) {
+}
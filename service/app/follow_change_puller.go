@@ -6,6 +6,7 @@ import (
 
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
 )
 
 // Reads from the follow-change puller, creates FollowChangeBatch types from
@@ -15,6 +16,7 @@ type FollowChangePuller struct {
 	externalFollowChangeSubscriber ExternalFollowChangeSubscriber
 	apns                           APNS
 	queries                        Queries
+	removeInvalidPushToken         *RemoveInvalidPushTokenHandler
 	logger                         logging.Logger
 	metrics                        Metrics
 	counter                        int
@@ -24,6 +26,7 @@ func NewFollowChangePuller(
 	externalFollowChangeSubscriber ExternalFollowChangeSubscriber,
 	apns APNS,
 	queries Queries,
+	removeInvalidPushToken *RemoveInvalidPushTokenHandler,
 	logger logging.Logger,
 	metrics Metrics,
 ) *FollowChangePuller {
@@ -31,6 +34,7 @@ func NewFollowChangePuller(
 		externalFollowChangeSubscriber: externalFollowChangeSubscriber,
 		apns:                           apns,
 		queries:                        queries,
+		removeInvalidPushToken:         removeInvalidPushToken,
 		logger:                         logger.New("followChangePuller"),
 		metrics:                        metrics,
 		counter:                        0,
@@ -71,18 +75,29 @@ func (f *FollowChangePuller) Run(ctx context.Context) error {
 			f.logger.Debug().Message(followChangeAggregate.String())
 
 			for _, token := range tokens {
-				if err := f.apns.SendFollowChangeNotification(*followChangeAggregate, token); err != nil {
+				followChange, ok := token.Preferences().FilterFollowChange(*followChangeAggregate, time.Now())
+				if !ok {
+					continue
+				}
+
+				if err := f.apns.SendFollowChangeNotification(followChange, token.PushToken()); err != nil {
+					if f.removeIfInvalid(ctx, followChangeAggregate.Followee, token.PushToken(), err) {
+						continue
+					}
 					f.logger.Error().
-						WithField("token", token.Hex()).
+						WithField("token", token.PushToken().String()).
 						WithField("followee", followChangeAggregate.Followee.Hex()).
 						WithError(err).
 						Message("error sending follow change notification")
 					continue
 				}
 
-				if err := f.apns.SendSilentFollowChangeNotification(*followChangeAggregate, token); err != nil {
+				if err := f.apns.SendSilentFollowChangeNotification(followChange, token.PushToken()); err != nil {
+					if f.removeIfInvalid(ctx, followChangeAggregate.Followee, token.PushToken(), err) {
+						continue
+					}
 					f.logger.Error().
-						WithField("token", token.Hex()).
+						WithField("token", token.PushToken().String()).
 						WithField("followee", followChangeAggregate.Followee.Hex()).
 						WithError(err).
 						Message("error sending silent follow change notification")
@@ -98,6 +113,25 @@ func (f *FollowChangePuller) Run(ctx context.Context) error {
 	}
 }
 
+// removeIfInvalid removes the token and returns true if the error indicates
+// that the push service will never accept the token again.
+func (f *FollowChangePuller) removeIfInvalid(ctx context.Context, followee domain.PublicKey, token domain.PushToken, err error) bool {
+	var invalidPushTokenErr *InvalidPushTokenError
+	if !errors.As(err, &invalidPushTokenErr) {
+		return false
+	}
+
+	cmd := NewRemoveInvalidPushToken(followee, token, invalidPushTokenErr.Reason())
+	if err := f.removeInvalidPushToken.Handle(ctx, cmd); err != nil {
+		f.logger.Error().
+			WithField("token", token.String()).
+			WithField("followee", followee.Hex()).
+			WithError(err).
+			Message("error removing invalid token")
+	}
+	return true
+}
+
 func (f *FollowChangePuller) storeMetricsLoop(ctx context.Context) {
 	ticker := time.NewTicker(storeMetricsEvery)
 	defer ticker.Stop()
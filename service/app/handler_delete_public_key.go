@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type DeletePublicKey struct {
+	publicKey domain.PublicKey
+}
+
+func NewDeletePublicKey(publicKey domain.PublicKey) DeletePublicKey {
+	return DeletePublicKey{publicKey: publicKey}
+}
+
+// DeletePublicKeyHandler deletes the public key together with its tokens, mute
+// list, events and notifications. It is used to process vanish requests and by
+// operators.
+type DeletePublicKeyHandler struct {
+	transactionProvider TransactionProvider
+	logger              logging.Logger
+	metrics             Metrics
+}
+
+func NewDeletePublicKeyHandler(
+	transactionProvider TransactionProvider,
+	logger logging.Logger,
+	metrics Metrics,
+) *DeletePublicKeyHandler {
+	return &DeletePublicKeyHandler{
+		transactionProvider: transactionProvider,
+		logger:              logger.New("deletePublicKeyHandler"),
+		metrics:             metrics,
+	}
+}
+
+func (h *DeletePublicKeyHandler) Handle(ctx context.Context, cmd DeletePublicKey) (err error) {
+	defer h.metrics.StartApplicationCall("deletePublicKey").End(&err)
+
+	logger := h.logger.WithField("publicKey", cmd.publicKey.Hex())
+
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		if err := adapters.PublicKeys.DeleteByPublicKey(ctx, cmd.publicKey); err != nil {
+			return errors.Wrap(err, "error deleting the public key")
+		}
+
+		if err := adapters.MuteLists.DeleteByPublicKey(ctx, cmd.publicKey); err != nil {
+			return errors.Wrap(err, "error deleting the mute list")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "transaction error")
+	}
+
+	logger.Debug().Message("deleted public key and associated tokens")
+
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		if err := adapters.Events.DeleteByPublicKey(ctx, cmd.publicKey); err != nil {
+			return errors.Wrap(err, "error deleting events and notifications")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "transaction error")
+	}
+
+	logger.Debug().Message("deleted events and notifications for public key")
+
+	return nil
+}
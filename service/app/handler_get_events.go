@@ -68,7 +68,7 @@ func (h *GetEventsHandler) send(ctx context.Context, filters domain.Filters, ch
 
 	receivedEvents := h.receivedEventSubscriber.Subscribe(ctx)
 
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		for eventOrErr := range adapters.Events.GetEvents(ctx, filters) {
 			if err := eventOrErr.Err(); err != nil {
 				return errors.Wrap(err, "repository returned an error")
@@ -26,7 +26,7 @@ func NewGetNotificationsHandler(
 func (h *GetNotificationsHandler) Handle(ctx context.Context, id domain.EventId) (result []notifications.Notification, err error) {
 	defer h.metrics.StartApplicationCall("getNotifications").End(&err)
 
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		tmp, err := adapters.Events.GetNotifications(ctx, id)
 		if err != nil {
 			return errors.Wrap(err, "error getting notifications")
@@ -27,7 +27,7 @@ func (h *GetPublicKeysHandler) Handle(ctx context.Context, relay domain.RelayAdd
 	defer h.metrics.StartApplicationCall("getPublicKeys").End(&err)
 
 	var result []domain.PublicKey
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		tmp, err := adapters.Relays.GetPublicKeys(ctx, relay, time.Now().Add(-getPublicKeysYoungerThan))
 		if err != nil {
 			return errors.Wrap(err, "error getting relays")
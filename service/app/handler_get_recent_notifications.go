@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+)
+
+const getRecentNotificationsForEvents = 100
+
+type EventWithNotifications struct {
+	event         domain.Event
+	notifications []notifications.Notification
+}
+
+func (e EventWithNotifications) Event() domain.Event {
+	return e.event
+}
+
+func (e EventWithNotifications) Notifications() []notifications.Notification {
+	return internal.CopySlice(e.notifications)
+}
+
+// GetRecentNotificationsHandler returns the latest events which tag the given
+// public key together with the notifications generated for them.
+type GetRecentNotificationsHandler struct {
+	transactionProvider TransactionProvider
+	metrics             Metrics
+}
+
+func NewGetRecentNotificationsHandler(
+	transactionProvider TransactionProvider,
+	metrics Metrics,
+) *GetRecentNotificationsHandler {
+	return &GetRecentNotificationsHandler{
+		transactionProvider: transactionProvider,
+		metrics:             metrics,
+	}
+}
+
+func (h *GetRecentNotificationsHandler) Handle(ctx context.Context, publicKey domain.PublicKey) (result []EventWithNotifications, err error) {
+	defer h.metrics.StartApplicationCall("getRecentNotifications").End(&err)
+
+	filters, err := domain.NewFilters(nostr.Filters{
+		{
+			Tags: map[string][]string{
+				"p": {publicKey.Hex()},
+			},
+			Limit: getRecentNotificationsForEvents,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating filters")
+	}
+
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
+		var events []domain.Event
+		for eventOrErr := range adapters.Events.GetEvents(ctx, filters) {
+			if err := eventOrErr.Err(); err != nil {
+				return errors.Wrap(err, "repository returned an error")
+			}
+			events = append(events, eventOrErr.Event())
+		}
+
+		result = nil
+		for _, event := range events {
+			notifications, err := adapters.Events.GetNotifications(ctx, event.Id())
+			if err != nil {
+				return errors.Wrapf(err, "error getting notifications for event '%s'", event.Id().Hex())
+			}
+
+			result = append(result, EventWithNotifications{
+				event:         event,
+				notifications: notifications,
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "transaction error")
+	}
+
+	return result, nil
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"context"
+)
+
+type RelayHealthProvider interface {
+	GetRelayHealth() []RelayHealth
+}
+
+type GetRelayHealthHandler struct {
+	relayHealthProvider RelayHealthProvider
+	metrics             Metrics
+}
+
+func NewGetRelayHealthHandler(
+	relayHealthProvider RelayHealthProvider,
+	metrics Metrics,
+) *GetRelayHealthHandler {
+	return &GetRelayHealthHandler{
+		relayHealthProvider: relayHealthProvider,
+		metrics:             metrics,
+	}
+}
+
+func (h *GetRelayHealthHandler) Handle(ctx context.Context) (health []RelayHealth, err error) {
+	defer h.metrics.StartApplicationCall("getRelayHealth").End(&err)
+
+	return h.relayHealthProvider.GetRelayHealth(), nil
+}
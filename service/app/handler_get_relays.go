@@ -5,37 +5,77 @@ import (
 	"time"
 
 	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
 	"github.com/planetary-social/go-notification-service/service/domain"
 )
 
+type Relay struct {
+	address        domain.RelayAddress
+	sources        []domain.RelaySource
+	information    domain.RelayInformation
+	hasInformation bool
+}
+
+func (r Relay) Address() domain.RelayAddress {
+	return r.address
+}
+
+// Sources returns the ways in which the relay was discovered e.g. it was
+// listed in a registration or in a NIP-65 relay list of a registered public
+// key.
+func (r Relay) Sources() []domain.RelaySource {
+	return internal.CopySlice(r.sources)
+}
+
+// Information returns false if the relay information document wasn't
+// retrieved e.g. because this replica doesn't download events from the relay
+// or the relay doesn't serve the document.
+func (r Relay) Information() (domain.RelayInformation, bool) {
+	return r.information, r.hasInformation
+}
+
 type GetRelaysHandler struct {
-	transactionProvider TransactionProvider
-	metrics             Metrics
+	transactionProvider   TransactionProvider
+	relayInformationCache *RelayInformationCache
+	metrics               Metrics
 }
 
 func NewGetRelaysHandler(
 	transactionProvider TransactionProvider,
+	relayInformationCache *RelayInformationCache,
 	metrics Metrics,
 ) *GetRelaysHandler {
 	return &GetRelaysHandler{
-		transactionProvider: transactionProvider,
-		metrics:             metrics,
+		transactionProvider:   transactionProvider,
+		relayInformationCache: relayInformationCache,
+		metrics:               metrics,
 	}
 }
 
-func (h *GetRelaysHandler) Handle(ctx context.Context) (addresses []domain.RelayAddress, err error) {
+func (h *GetRelaysHandler) Handle(ctx context.Context) (relays []Relay, err error) {
 	defer h.metrics.StartApplicationCall("getRelays").End(&err)
 
-	var result []domain.RelayAddress
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	var storedRelays []StoredRelay
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		tmp, err := adapters.Relays.GetRelays(ctx, time.Now().Add(-getRelaysYoungerThan))
 		if err != nil {
 			return errors.Wrap(err, "error getting relays")
 		}
-		result = tmp
+		storedRelays = tmp
 		return nil
 	}); err != nil {
 		return nil, errors.Wrap(err, "transaction error")
 	}
+
+	var result []Relay
+	for _, storedRelay := range storedRelays {
+		information, ok := h.relayInformationCache.GetCached(storedRelay.Address())
+		result = append(result, Relay{
+			address:        storedRelay.Address(),
+			sources:        storedRelay.Sources(),
+			information:    information,
+			hasInformation: ok,
+		})
+	}
 	return result, nil
 }
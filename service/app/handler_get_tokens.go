@@ -23,14 +23,14 @@ func NewGetTokensHandler(
 	}
 }
 
-func (h *GetTokensHandler) Handle(ctx context.Context, publicKey domain.PublicKey) (tokens []domain.APNSToken, err error) {
+func (h *GetTokensHandler) Handle(ctx context.Context, publicKey domain.PublicKey) (tokens []domain.RegisteredPushToken, err error) {
 	defer h.metrics.StartApplicationCall("getTokens").End(&err)
 
-	var result []domain.APNSToken
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
-		tmp, err := adapters.PublicKeys.GetAPNSTokens(ctx, publicKey, time.Now().Add(-sendNotificationsToTokensYoungerThan))
+	var result []domain.RegisteredPushToken
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
+		tmp, err := adapters.PublicKeys.GetPushTokens(ctx, publicKey, time.Now().Add(-sendNotificationsToTokensYoungerThan))
 		if err != nil {
-			return errors.Wrap(err, "error getting apns tokens")
+			return errors.Wrap(err, "error getting push tokens")
 		}
 		result = tmp
 		return nil
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/boreq/errors"
+	"github.com/hashicorp/go-multierror"
 	"github.com/planetary-social/go-notification-service/internal"
 	"github.com/planetary-social/go-notification-service/internal/logging"
 	"github.com/planetary-social/go-notification-service/service/domain"
@@ -13,7 +14,6 @@ import (
 
 const (
 	tagBatchSize                       = 150
-	apnsTokenBatchSize                 = 500
 	onlySaveEventForEventsWithMoreTags = 500
 
 	sendNotificationsToTokensYoungerThan = 6 * 30 * 24 * time.Hour
@@ -31,26 +31,32 @@ type ProcessSavedEventHandler struct {
 	transactionProvider    TransactionProvider
 	generator              *notifications.Generator
 	apns                   APNS
+	metadataProvider       MetadataProvider
 	logger                 logging.Logger
 	metrics                Metrics
 	externalEventPublisher ExternalEventPublisher
+	removeInvalidPushToken *RemoveInvalidPushTokenHandler
 }
 
 func NewProcessSavedEventHandler(
 	transactionProvider TransactionProvider,
 	generator *notifications.Generator,
 	apns APNS,
+	metadataProvider MetadataProvider,
 	logger logging.Logger,
 	metrics Metrics,
 	externalEventPublisher ExternalEventPublisher,
+	removeInvalidPushToken *RemoveInvalidPushTokenHandler,
 ) *ProcessSavedEventHandler {
 	return &ProcessSavedEventHandler{
 		transactionProvider:    transactionProvider,
 		generator:              generator,
 		apns:                   apns,
+		metadataProvider:       metadataProvider,
 		logger:                 logger.New("processSavedEventHandler"),
 		metrics:                metrics,
 		externalEventPublisher: externalEventPublisher,
+		removeInvalidPushToken: removeInvalidPushToken,
 	}
 }
 
@@ -85,7 +91,7 @@ func (h *ProcessSavedEventHandler) Handle(ctx context.Context, cmd ProcessSavedE
 
 func (h *ProcessSavedEventHandler) loadEvent(ctx context.Context, cmd ProcessSavedEvent) (domain.Event, error) {
 	var event domain.Event
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
 		tmp, err := adapters.Events.Get(ctx, cmd.eventId)
 		if err != nil {
 			return errors.Wrap(err, "error getting the event from the database")
@@ -126,68 +132,274 @@ func (h *ProcessSavedEventHandler) saveTags(ctx context.Context, event domain.Ev
 	return nil
 }
 
-func (h *ProcessSavedEventHandler) generateSendAndSaveNotifications(ctx context.Context, event domain.Event, logger logging.Logger) error {
-	// todo this shouldn't send multiple notifications if the event is retried
+// Preview returns notifications which would be sent if the event was processed
+// again without sending them or saving anything. Deliveries which already
+// happened are skipped the same way as during processing.
+func (h *ProcessSavedEventHandler) Preview(ctx context.Context, event domain.Event) (result []notifications.Notification, err error) {
+	defer h.metrics.StartApplicationCall("previewSavedEvent").End(&err)
 
-	mentions, err := domain.GetMentionsFromTags(event.Tags())
+	if len(event.Tags()) > onlySaveEventForEventsWithMoreTags {
+		return nil, nil
+	}
+
+	logger := h.logger.WithField("event.id", event.Id().Hex())
+
+	mentionToTokens, mentionToMuteList, err := h.getRecipients(ctx, event)
 	if err != nil {
-		return errors.Wrap(err, "error getting mentions for this event")
+		return nil, errors.Wrap(err, "error getting recipients")
 	}
 
-	var mentionToTokens map[domain.PublicKey][]domain.APNSToken
-	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
-		mentionToTokens = make(map[domain.PublicKey][]domain.APNSToken) // transactions can run multiple times
+	authorName := h.getAuthorNameIfNeeded(ctx, event, mentionToTokens, logger)
 
-		for _, mention := range mentions {
-			tmp, err := adapters.PublicKeys.GetAPNSTokens(ctx, mention, time.Now().Add(-sendNotificationsToTokensYoungerThan))
-			if err != nil {
-				return errors.Wrap(err, "error getting the token")
-			}
-			if len(tmp) > 0 {
-				mentionToTokens[mention] = append(mentionToTokens[mention], tmp...)
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
+		// transactions can run multiple times
+		result = nil
+
+		for mention, tokens := range mentionToTokens {
+			for _, token := range tokens {
+				delivery := notifications.NewDelivery(event.Id(), mention, token.PushToken())
+
+				exists, err := adapters.Events.DeliveryExists(ctx, delivery)
+				if err != nil {
+					return errors.Wrap(err, "error checking if delivery exists")
+				}
+
+				if exists {
+					continue
+				}
+
+				notifications, err := h.generator.Generate(mention, token, mentionToMuteList[mention], event, authorName)
+				if err != nil {
+					return errors.Wrap(err, "error generating notifications")
+				}
+
+				result = append(result, notifications...)
 			}
 		}
 
 		return nil
 	}); err != nil {
-		return errors.Wrap(err, "token transaction error")
+		return nil, errors.Wrap(err, "transaction error")
+	}
+
+	return result, nil
+}
+
+func (h *ProcessSavedEventHandler) generateSendAndSaveNotifications(ctx context.Context, event domain.Event, logger logging.Logger) error {
+	mentionToTokens, mentionToMuteList, err := h.getRecipients(ctx, event)
+	if err != nil {
+		return errors.Wrap(err, "error getting recipients")
 	}
 
+	authorName := h.getAuthorNameIfNeeded(ctx, event, mentionToTokens, logger)
+
+	// a failure to deliver notifications to one token shouldn't prevent
+	// sending them to other tokens, deliveries which succeeded are skipped
+	// when the event is retried
+	var result error
 	for mention, tokens := range mentionToTokens {
 		logger.Debug().
 			WithField("mention", mention.Hex()).
 			WithField("numberOfTokens", len(tokens)).
 			Message("sending notifications")
 
-		for _, batch := range internal.BatchesFromSlice(tokens, apnsTokenBatchSize) {
-			if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
-				for _, token := range batch {
-					notifications, err := h.generator.Generate(mention, token, event)
-					if err != nil {
-						return errors.Wrap(err, "error generating notifications")
+		for _, token := range tokens {
+			delivery := notifications.NewDelivery(event.Id(), mention, token.PushToken())
+			if err := h.sendAndSaveNotifications(ctx, event, delivery, token, mentionToMuteList[mention], authorName, logger); err != nil {
+				var invalidPushTokenErr *InvalidPushTokenError
+				if errors.As(err, &invalidPushTokenErr) {
+					cmd := NewRemoveInvalidPushToken(mention, token.PushToken(), invalidPushTokenErr.Reason())
+					if err := h.removeInvalidPushToken.Handle(ctx, cmd); err != nil {
+						result = multierror.Append(result, errors.Wrapf(err, "error removing invalid token '%s'", token.PushToken().String()))
 					}
+					continue
+				}
+				result = multierror.Append(result, errors.Wrapf(err, "error sending notifications to token '%s'", token.PushToken().String()))
+			}
+		}
+	}
 
-					for _, notification := range notifications {
-						if err := h.apns.SendNotification(notification); err != nil {
-							return errors.Wrap(err, "error sending a notification")
-						}
+	return result
+}
 
-						if err := adapters.Events.SaveNotificationForEvent(notification); err != nil {
-							return errors.Wrap(err, "error saving notification")
-						}
-					}
-				}
+// getRecipients returns tokens and mute lists of registered users mentioned in
+// the event.
+func (h *ProcessSavedEventHandler) getRecipients(ctx context.Context, event domain.Event) (map[domain.PublicKey][]domain.RegisteredPushToken, map[domain.PublicKey]domain.MuteList, error) {
+	mentions, err := domain.GetMentionsFromTags(event.Tags())
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "error getting mentions for this event")
+	}
+
+	var (
+		mentionToTokens   map[domain.PublicKey][]domain.RegisteredPushToken
+		mentionToMuteList map[domain.PublicKey]domain.MuteList
+	)
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
+		// transactions can run multiple times
+		mentionToTokens = make(map[domain.PublicKey][]domain.RegisteredPushToken)
+		mentionToMuteList = make(map[domain.PublicKey]domain.MuteList)
+
+		for _, mention := range mentions {
+			tmp, err := adapters.PublicKeys.GetPushTokens(ctx, mention, time.Now().Add(-sendNotificationsToTokensYoungerThan))
+			if err != nil {
+				return errors.Wrap(err, "error getting the token")
+			}
+			if len(tmp) == 0 {
+				continue
+			}
+			mentionToTokens[mention] = append(mentionToTokens[mention], tmp...)
 
-				return nil
-			}); err != nil {
-				return errors.Wrap(err, "transaction error")
+			muteList, ok, err := adapters.MuteLists.Get(ctx, mention)
+			if err != nil {
+				return errors.Wrap(err, "error getting the mute list")
+			}
+			if ok {
+				mentionToMuteList[mention] = muteList
 			}
 		}
+
+		return nil
+	}); err != nil {
+		return nil, nil, errors.Wrap(err, "token transaction error")
+	}
+
+	return mentionToTokens, mentionToMuteList, nil
+}
+
+// getAuthorNameIfNeeded returns an empty string if the name isn't needed or
+// can't be found as notifications can be displayed without it.
+func (h *ProcessSavedEventHandler) getAuthorNameIfNeeded(ctx context.Context, event domain.Event, mentionToTokens map[domain.PublicKey][]domain.RegisteredPushToken, logger logging.Logger) string {
+	if !h.authorNameIsNeeded(event, mentionToTokens) {
+		return ""
+	}
+
+	metadata, ok, err := h.metadataProvider.GetMetadata(ctx, event.PubKey())
+	if err != nil {
+		logger.Error().WithError(err).Message("error getting the metadata of the author")
+		return ""
+	}
+
+	if !ok {
+		return ""
+	}
+
+	return metadata.DisplayName()
+}
+
+func (h *ProcessSavedEventHandler) authorNameIsNeeded(event domain.Event, mentionToTokens map[domain.PublicKey][]domain.RegisteredPushToken) bool {
+	for _, tokens := range mentionToTokens {
+		for _, token := range tokens {
+			if h.generator.UsesAuthorName(token, event) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Each delivery is claimed in a separate transaction before sending the
+// notifications and marked as sent afterwards. Notifications aren't sent
+// inside of a transaction as transactions can be retried which would send them
+// again. If processing fails halfway through the deliveries which were already
+// claimed are skipped when the event is retried. A claim is released if
+// sending failed so that sending can be retried but not if the process stopped
+// while sending as it isn't known whether the notifications were delivered.
+// Releasing a claim after some of its notifications were sent means that they
+// will be sent again which is better than never sending the remaining ones.
+func (h *ProcessSavedEventHandler) sendAndSaveNotifications(ctx context.Context, event domain.Event, delivery notifications.Delivery, token domain.RegisteredPushToken, muteList domain.MuteList, authorName string, logger logging.Logger) error {
+	notifications, err := h.claimDelivery(ctx, event, delivery, token, muteList, authorName, logger)
+	if err != nil {
+		return errors.Wrap(err, "error claiming the delivery")
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	for _, notification := range notifications {
+		if err := h.apns.SendNotification(notification); err != nil {
+			sendErr := errors.Wrap(err, "error sending a notification")
+			if err := h.releaseDelivery(ctx, delivery); err != nil {
+				return errors.Wrapf(sendErr, "releasing the delivery also failed: %s", err)
+			}
+			return sendErr
+		}
+	}
+
+	if err := h.markDeliveryAsSent(ctx, delivery, notifications); err != nil {
+		return errors.Wrap(err, "error marking the delivery as sent")
 	}
 
 	return nil
 }
 
+// claimDelivery returns no notifications if the delivery was already claimed
+// or there is nothing to send.
+func (h *ProcessSavedEventHandler) claimDelivery(ctx context.Context, event domain.Event, delivery notifications.Delivery, token domain.RegisteredPushToken, muteList domain.MuteList, authorName string, logger logging.Logger) ([]notifications.Notification, error) {
+	var result []notifications.Notification
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		// transactions can run multiple times
+		result = nil
+
+		exists, err := adapters.Events.DeliveryExists(ctx, delivery)
+		if err != nil {
+			return errors.Wrap(err, "error checking if delivery exists")
+		}
+
+		if exists {
+			logger.Debug().
+				WithField("mention", delivery.Mention().Hex()).
+				Message("notifications were already delivered to this token")
+			return nil
+		}
+
+		notifications, err := h.generator.Generate(delivery.Mention(), token, muteList, event, authorName)
+		if err != nil {
+			return errors.Wrap(err, "error generating notifications")
+		}
+
+		if len(notifications) == 0 {
+			return nil
+		}
+
+		if err := adapters.Events.ClaimDelivery(delivery); err != nil {
+			return errors.Wrap(err, "error claiming delivery")
+		}
+
+		result = notifications
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "transaction error")
+	}
+
+	return result, nil
+}
+
+func (h *ProcessSavedEventHandler) releaseDelivery(ctx context.Context, delivery notifications.Delivery) error {
+	return h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		if err := adapters.Events.DeleteDelivery(delivery); err != nil {
+			return errors.Wrap(err, "error deleting delivery")
+		}
+		return nil
+	})
+}
+
+func (h *ProcessSavedEventHandler) markDeliveryAsSent(ctx context.Context, delivery notifications.Delivery, sent []notifications.Notification) error {
+	return h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		for _, notification := range sent {
+			if err := adapters.Events.SaveNotificationForEvent(notification); err != nil {
+				return errors.Wrap(err, "error saving notification")
+			}
+		}
+
+		if err := adapters.Events.MarkDeliverySent(delivery); err != nil {
+			return errors.Wrap(err, "error marking delivery as sent")
+		}
+
+		return nil
+	})
+}
+
 // Since Firestore actually converts all paths to `slash/separated/strings` it
 // doesn't understand the situation where things `accidently/end/with/a/slash/`
 // as the last element is an empty string. Therefore, we can't save tags that
@@ -0,0 +1,283 @@
+package app_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters/mocks"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessSavedEventHandler_RetriedEventsDoNotSendNotificationsTwice(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{failOnCall: 3}
+	handler := newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider())
+
+	mention, _ := fixtures.SomeKeyPair()
+	tokens := []domain.PushToken{
+		fixtures.SomeAPNSPushToken(),
+		fixtures.SomeAPNSPushToken(),
+		fixtures.SomeAPNSPushToken(),
+		fixtures.SomeAPNSPushToken(),
+	}
+	event := someEventMentioning(t, mention)
+	storage.state.events[event.Id()] = event
+	for _, token := range tokens {
+		storage.state.tokens[mention] = append(storage.state.tokens[mention], domain.MustNewRegisteredPushToken(token, domain.NotificationModeSilent, domain.Preferences{}))
+	}
+
+	err := handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.Error(t, err)
+	require.Len(t, apns.SentNotifications(), 3)
+	require.Len(t, storage.Notifications(), 3)
+
+	err = handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+	require.ElementsMatch(t, tokens, sentTokens(apns.SentNotifications()))
+	require.ElementsMatch(t, tokens, sentTokens(storage.Notifications()))
+
+	err = handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+	require.ElementsMatch(t, tokens, sentTokens(apns.SentNotifications()))
+	require.ElementsMatch(t, tokens, sentTokens(storage.Notifications()))
+}
+
+func TestProcessSavedEventHandler_FailedSendsAreRetriedWithoutAffectingOtherRecipients(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{failOnCall: 2}
+	handler := newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider())
+
+	mention1, _ := fixtures.SomeKeyPair()
+	mention2, _ := fixtures.SomeKeyPair()
+	token1 := fixtures.SomeAPNSPushToken()
+	token2 := fixtures.SomeAPNSPushToken()
+	token3 := fixtures.SomeAPNSPushToken()
+	event := someEventMentioning(t, mention1, mention2)
+	storage.state.events[event.Id()] = event
+	storage.state.tokens[mention1] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(token1, domain.NotificationModeSilent, domain.Preferences{}),
+		domain.MustNewRegisteredPushToken(token2, domain.NotificationModeSilent, domain.Preferences{}),
+	}
+	storage.state.tokens[mention2] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(token3, domain.NotificationModeSilent, domain.Preferences{}),
+	}
+
+	err := handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.Error(t, err)
+	require.Len(t, apns.SentNotifications(), 2, "the second send failed but the remaining recipients should be notified")
+	require.Len(t, storage.Notifications(), 2)
+
+	var unsent []notifications.Delivery
+	for delivery, sent := range storage.state.deliveries {
+		if !sent {
+			unsent = append(unsent, delivery)
+		}
+	}
+	require.Empty(t, unsent, "failed deliveries should be released instead of being marked as sent")
+	require.Len(t, storage.state.deliveries, 2)
+
+	err = handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+	require.ElementsMatch(t, []domain.PushToken{token1, token2, token3}, sentTokens(apns.SentNotifications()))
+	require.ElementsMatch(t, []domain.PushToken{token1, token2, token3}, sentTokens(storage.Notifications()))
+}
+
+func TestProcessSavedEventHandler_RetriedTransactionsDoNotSendNotificationsTwice(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	storage.retryTransactions = true
+	apns := &fakeAPNS{}
+	handler := newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider())
+
+	mention, _ := fixtures.SomeKeyPair()
+	token := fixtures.SomeAPNSPushToken()
+	event := someEventMentioning(t, mention)
+	storage.state.events[event.Id()] = event
+	storage.state.tokens[mention] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(token, domain.NotificationModeSilent, domain.Preferences{}),
+	}
+
+	err := handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+	require.Equal(t, []domain.PushToken{token}, sentTokens(apns.SentNotifications()))
+	require.Equal(t, []domain.PushToken{token}, sentTokens(storage.Notifications()))
+	require.Equal(t,
+		map[notifications.Delivery]bool{
+			notifications.NewDelivery(event.Id(), mention, token): true,
+		},
+		storage.state.deliveries,
+	)
+}
+
+func TestProcessSavedEventHandler_MetadataIsOnlyLookedUpForVisibleNotifications(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{}
+	metadataProvider := newFakeMetadataProvider()
+	handler := newProcessSavedEventHandler(storage, apns, metadataProvider)
+
+	silentMention, _ := fixtures.SomeKeyPair()
+	visibleMention, _ := fixtures.SomeKeyPair()
+
+	silentEvent := someEventMentioning(t, silentMention)
+	storage.state.events[silentEvent.Id()] = silentEvent
+	storage.state.tokens[silentMention] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeSilent, domain.Preferences{}),
+	}
+
+	err := handler.Handle(ctx, app.NewProcessSavedEvent(silentEvent.Id()))
+	require.NoError(t, err)
+	require.Equal(t, 0, metadataProvider.Calls())
+	require.Len(t, apns.SentNotifications(), 1)
+	require.True(t, apns.SentNotifications()[0].Alert().IsZero())
+
+	visibleEvent := someEventMentioning(t, visibleMention)
+	metadataProvider.metadata[visibleEvent.PubKey()] = someMetadata(t, "Some Name")
+	storage.state.events[visibleEvent.Id()] = visibleEvent
+	storage.state.tokens[visibleMention] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeVisible, domain.Preferences{}),
+	}
+
+	err = handler.Handle(ctx, app.NewProcessSavedEvent(visibleEvent.Id()))
+	require.NoError(t, err)
+	require.Equal(t, 1, metadataProvider.Calls())
+	require.Len(t, apns.SentNotifications(), 2)
+
+	alert := apns.SentNotifications()[1].Alert()
+	require.Equal(t, "namedNewMention", alert.LocKey())
+	require.Equal(t, []string{"Some Name", "some content"}, alert.LocArgs())
+}
+
+func TestProcessSavedEventHandler_MutedEventsDoNotSendNotifications(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{}
+	handler := newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider())
+
+	mention, mentionSecretKey := fixtures.SomeKeyPair()
+	event := someEventMentioning(t, mention)
+	storage.state.events[event.Id()] = event
+	storage.state.tokens[mention] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeSilent, domain.Preferences{}),
+	}
+
+	muteList, err := domain.NewMuteList(fixtures.MuteListEvent(t, mentionSecretKey, time.Now(), nostr.Tags{
+		{"p", event.PubKey().Hex()},
+	}))
+	require.NoError(t, err)
+	storage.state.muteLists[mention] = muteList
+
+	err = handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+	require.Empty(t, apns.SentNotifications())
+	require.Empty(t, storage.Notifications())
+}
+
+func TestProcessSavedEventHandler_InvalidTokensAreRemoved(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	invalidToken := fixtures.SomeAPNSPushToken()
+	validToken := fixtures.SomeAPNSPushToken()
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{invalidTokens: []domain.PushToken{invalidToken}}
+	handler := newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider())
+
+	mention, _ := fixtures.SomeKeyPair()
+	event := someEventMentioning(t, mention)
+	storage.state.events[event.Id()] = event
+	storage.state.tokens[mention] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(invalidToken, domain.NotificationModeSilent, domain.Preferences{}),
+		domain.MustNewRegisteredPushToken(validToken, domain.NotificationModeSilent, domain.Preferences{}),
+	}
+
+	err := handler.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+	require.Equal(t, []domain.PushToken{validToken}, sentTokens(apns.SentNotifications()))
+	require.Equal(t, []domain.PushToken{validToken}, sentTokens(storage.Notifications()))
+
+	require.Len(t, storage.state.tokens[mention], 1)
+	require.Equal(t, validToken, storage.state.tokens[mention][0].PushToken())
+}
+
+func newProcessSavedEventHandler(storage *fakeStorage, apns *fakeAPNS, metadataProvider *fakeMetadataProvider) *app.ProcessSavedEventHandler {
+	logger := logging.NewDevNullLogger()
+	return app.NewProcessSavedEventHandler(
+		storage,
+		notifications.NewGenerator(logger),
+		apns,
+		metadataProvider,
+		logger,
+		fakeMetrics{},
+		mocks.NewMockExternalEventPublisher(),
+		app.NewRemoveInvalidPushTokenHandler(storage, logger, fakeMetrics{}),
+	)
+}
+
+func someEventMentioning(t *testing.T, mentions ...domain.PublicKey) domain.Event {
+	_, sk := fixtures.SomeKeyPair()
+
+	var tags nostr.Tags
+	for _, mention := range mentions {
+		tags = append(tags, nostr.Tag{"p", mention.Hex()})
+	}
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags:      tags,
+		Content:   "some content",
+	}
+
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	return event
+}
+
+func someMetadata(t *testing.T, name string) domain.Metadata {
+	_, sk := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindMetadata.Int(),
+		Tags:      nostr.Tags{},
+		Content:   fmt.Sprintf(`{"name": "%s"}`, name),
+	}
+
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	metadata, err := domain.NewMetadata(event)
+	require.NoError(t, err)
+
+	return metadata
+}
+
+func sentTokens(notifications []notifications.Notification) []domain.PushToken {
+	var result []domain.PushToken
+	for _, notification := range notifications {
+		result = append(result, notification.PushToken())
+	}
+	return result
+}
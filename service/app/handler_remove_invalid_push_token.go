@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type RemoveInvalidPushToken struct {
+	publicKey domain.PublicKey
+	token     domain.PushToken
+	reason    string
+}
+
+func NewRemoveInvalidPushToken(publicKey domain.PublicKey, token domain.PushToken, reason string) RemoveInvalidPushToken {
+	return RemoveInvalidPushToken{publicKey: publicKey, token: token, reason: reason}
+}
+
+// RemoveInvalidPushTokenHandler removes tokens which were rejected by the push
+// service. Unlike RemoveRegistrationHandler it leaves the relays alone as the
+// user may still have other tokens or register a new one soon.
+type RemoveInvalidPushTokenHandler struct {
+	transactionProvider TransactionProvider
+	logger              logging.Logger
+	auditLogger         logging.Logger
+	metrics             Metrics
+}
+
+func NewRemoveInvalidPushTokenHandler(
+	transactionProvider TransactionProvider,
+	logger logging.Logger,
+	metrics Metrics,
+) *RemoveInvalidPushTokenHandler {
+	return &RemoveInvalidPushTokenHandler{
+		transactionProvider: transactionProvider,
+		logger:              logger.New("removeInvalidPushTokenHandler"),
+		auditLogger:         logger.New("audit"),
+		metrics:             metrics,
+	}
+}
+
+func (h *RemoveInvalidPushTokenHandler) Handle(ctx context.Context, cmd RemoveInvalidPushToken) (err error) {
+	defer h.metrics.StartApplicationCall("removeInvalidPushToken").End(&err)
+
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		if err := adapters.PublicKeys.DeletePushToken(ctx, cmd.publicKey, cmd.token); err != nil {
+			return errors.Wrap(err, "error deleting the push token")
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "transaction error")
+	}
+
+	h.metrics.ReportInvalidPushTokenRemoved(cmd.token.Platform(), cmd.reason)
+
+	h.auditLogger.Debug().
+		WithField("action", "removeInvalidPushToken").
+		WithField("publicKey", cmd.publicKey.Hex()).
+		WithField("pushToken", cmd.token.String()).
+		WithField("platform", cmd.token.Platform().String()).
+		WithField("reason", cmd.reason).
+		Message("removed a push token rejected by the push service")
+
+	return nil
+}
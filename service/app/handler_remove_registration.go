@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type RemoveRegistration struct {
+	unregistration domain.Unregistration
+}
+
+func NewRemoveRegistration(unregistration domain.Unregistration) RemoveRegistration {
+	return RemoveRegistration{unregistration: unregistration}
+}
+
+type RemoveRegistrationHandler struct {
+	transactionProvider TransactionProvider
+	logger              logging.Logger
+	metrics             Metrics
+}
+
+func NewRemoveRegistrationHandler(
+	transactionProvider TransactionProvider,
+	logger logging.Logger,
+	metrics Metrics,
+) *RemoveRegistrationHandler {
+	return &RemoveRegistrationHandler{
+		transactionProvider: transactionProvider,
+		logger:              logger.New("removeRegistrationHandler"),
+		metrics:             metrics,
+	}
+}
+
+func (h *RemoveRegistrationHandler) Handle(ctx context.Context, cmd RemoveRegistration) (err error) {
+	defer h.metrics.StartApplicationCall("removeRegistration").End(&err)
+
+	unregistration := cmd.unregistration
+
+	h.logger.Debug().
+		WithField("pushToken", unregistration.PushToken().String()).
+		WithField("publicKey", unregistration.PublicKey().Hex()).
+		WithField("relays", unregistration.Relays()).
+		Message("removing registration")
+
+	// Firestore requires all reads to happen before any writes in a
+	// transaction which is why the deletions are ordered like this.
+	return h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		tokens, err := adapters.PublicKeys.GetPushTokens(ctx, unregistration.PublicKey(), time.Time{})
+		if err != nil {
+			return errors.Wrap(err, "error getting push tokens")
+		}
+
+		// relays are saved per public key and not per token so relays which
+		// weren't listed may still be used by other devices registered using
+		// the same public key
+		if !otherTokensRemain(tokens, unregistration.PushToken()) {
+			if err := adapters.Relays.DeletePublicKeyFromAllRelays(ctx, unregistration.PublicKey()); err != nil {
+				return errors.Wrap(err, "error deleting the public key from all relays")
+			}
+		} else if relays := unregistration.Relays(); len(relays) > 0 {
+			if err := adapters.Relays.DeletePublicKeyFromRelays(ctx, unregistration.PublicKey(), relays); err != nil {
+				return errors.Wrap(err, "error deleting the public key from relays")
+			}
+		}
+
+		if err := adapters.PublicKeys.DeletePushToken(ctx, unregistration.PublicKey(), unregistration.PushToken()); err != nil {
+			return errors.Wrap(err, "error deleting the push token")
+		}
+
+		return nil
+	})
+}
+
+func otherTokensRemain(tokens []domain.RegisteredPushToken, removed domain.PushToken) bool {
+	for _, token := range tokens {
+		if token.PushToken() != removed {
+			return true
+		}
+	}
+	return false
+}
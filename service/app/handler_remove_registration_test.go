@@ -0,0 +1,136 @@
+package app_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveRegistrationHandler(t *testing.T) {
+	relay1 := fixtures.SomeRelayAddress()
+	relay2 := fixtures.SomeRelayAddress()
+
+	testCases := []struct {
+		Name string
+
+		OtherTokenRemains bool
+		Relays            []domain.RelayAddress
+
+		ExpectedRelay1 bool
+		ExpectedRelay2 bool
+	}{
+		{
+			Name:              "specific_relays_when_other_tokens_remain",
+			OtherTokenRemains: true,
+			Relays:            []domain.RelayAddress{relay1},
+			ExpectedRelay1:    false,
+			ExpectedRelay2:    true,
+		},
+		{
+			Name:              "all_relays_when_no_tokens_remain_and_specific_relays_are_given",
+			OtherTokenRemains: false,
+			Relays:            []domain.RelayAddress{relay1},
+			ExpectedRelay1:    false,
+			ExpectedRelay2:    false,
+		},
+		{
+			Name:              "all_relays_when_no_tokens_remain",
+			OtherTokenRemains: false,
+			ExpectedRelay1:    false,
+			ExpectedRelay2:    false,
+		},
+		{
+			Name:              "relays_are_kept_for_other_tokens",
+			OtherTokenRemains: true,
+			ExpectedRelay1:    true,
+			ExpectedRelay2:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			ctx := fixtures.Context(t)
+
+			storage := newFakeStorage()
+			handler := app.NewRemoveRegistrationHandler(storage, logging.NewDevNullLogger(), fakeMetrics{})
+
+			publicKey, secretKey := fixtures.SomeKeyPair()
+			otherPublicKey, _ := fixtures.SomeKeyPair()
+			removedToken := fixtures.SomeAPNSPushToken()
+			otherToken := fixtures.SomeAPNSPushToken()
+
+			storage.state.tokens[publicKey] = []domain.RegisteredPushToken{
+				domain.MustNewRegisteredPushToken(removedToken, domain.NotificationModeSilent, domain.Preferences{}),
+			}
+			if testCase.OtherTokenRemains {
+				storage.state.tokens[publicKey] = append(storage.state.tokens[publicKey], domain.MustNewRegisteredPushToken(otherToken, domain.NotificationModeSilent, domain.Preferences{}))
+			}
+			storage.state.relays[relay1] = []domain.PublicKey{publicKey, otherPublicKey}
+			storage.state.relays[relay2] = []domain.PublicKey{publicKey, otherPublicKey}
+
+			unregistration := someUnregistration(t, publicKey, secretKey, removedToken, testCase.Relays)
+
+			err := handler.Handle(ctx, app.NewRemoveRegistration(unregistration))
+			require.NoError(t, err)
+
+			for _, token := range storage.state.tokens[publicKey] {
+				require.NotEqual(t, removedToken, token.PushToken())
+			}
+			if testCase.OtherTokenRemains {
+				require.Len(t, storage.state.tokens[publicKey], 1)
+			} else {
+				require.Empty(t, storage.state.tokens[publicKey])
+			}
+
+			require.Equal(t, testCase.ExpectedRelay1, containsPublicKey(storage.state.relays[relay1], publicKey))
+			require.Equal(t, testCase.ExpectedRelay2, containsPublicKey(storage.state.relays[relay2], publicKey))
+			require.True(t, containsPublicKey(storage.state.relays[relay1], otherPublicKey))
+			require.True(t, containsPublicKey(storage.state.relays[relay2], otherPublicKey))
+		})
+	}
+}
+
+func someUnregistration(t *testing.T, publicKey domain.PublicKey, secretKey string, token domain.PushToken, relays []domain.RelayAddress) domain.Unregistration {
+	var relayTransports []string
+	for _, relay := range relays {
+		relayTransports = append(relayTransports, fmt.Sprintf(`{"address": "%s"}`, relay.String()))
+	}
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindUnregistration.Int(),
+		Content: fmt.Sprintf(
+			`{"publicKey": "%s", "apnsToken": "%s", "relays": [%s]}`,
+			publicKey.Hex(),
+			token.Token(),
+			strings.Join(relayTransports, ","),
+		),
+	}
+
+	err := libevent.Sign(secretKey)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	unregistration, err := domain.NewUnregistrationFromEvent(event)
+	require.NoError(t, err)
+
+	return unregistration
+}
+
+func containsPublicKey(publicKeys []domain.PublicKey, publicKey domain.PublicKey) bool {
+	for _, v := range publicKeys {
+		if v == publicKey {
+			return true
+		}
+	}
+	return false
+}
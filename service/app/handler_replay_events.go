@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/planetary-social/go-notification-service/service/domain/notifications"
+)
+
+const replayEventsPageSize = 100
+
+type ReplayEvents struct {
+	filters domain.Filters
+	dryRun  bool
+}
+
+// NewReplayEvents selects stored events using the filters. In the dry run mode
+// events are not processed, instead notifications which would be generated
+// for them are returned.
+func NewReplayEvents(filters domain.Filters, dryRun bool) ReplayEvents {
+	return ReplayEvents{filters: filters, dryRun: dryRun}
+}
+
+type ReplayedEvent struct {
+	event         domain.Event
+	notifications []notifications.Notification
+}
+
+func (r ReplayedEvent) Event() domain.Event {
+	return r.event
+}
+
+// Notifications are only returned in the dry run mode.
+func (r ReplayedEvent) Notifications() []notifications.Notification {
+	return internal.CopySlice(r.notifications)
+}
+
+// ReplayEventsHandler processes stored events again e.g. to send notifications
+// which weren't sent because of a bug or because the user registered late.
+// Notifications which were already delivered are not sent again.
+type ReplayEventsHandler struct {
+	transactionProvider TransactionProvider
+	processSavedEvent   *ProcessSavedEventHandler
+	reprocessEvent      *ReprocessEventHandler
+	logger              logging.Logger
+	metrics             Metrics
+}
+
+func NewReplayEventsHandler(
+	transactionProvider TransactionProvider,
+	processSavedEvent *ProcessSavedEventHandler,
+	reprocessEvent *ReprocessEventHandler,
+	logger logging.Logger,
+	metrics Metrics,
+) *ReplayEventsHandler {
+	return &ReplayEventsHandler{
+		transactionProvider: transactionProvider,
+		processSavedEvent:   processSavedEvent,
+		reprocessEvent:      reprocessEvent,
+		logger:              logger.New("replayEventsHandler"),
+		metrics:             metrics,
+	}
+}
+
+func (h *ReplayEventsHandler) Handle(ctx context.Context, cmd ReplayEvents) (result []ReplayedEvent, err error) {
+	defer h.metrics.StartApplicationCall("replayEvents").End(&err)
+
+	h.logger.Debug().
+		WithField("numberOfFilters", len(cmd.filters.Filters())).
+		WithField("dryRun", cmd.dryRun).
+		Message("replaying events")
+
+	replayed := internal.NewEmptySet[domain.EventId]()
+	for _, filter := range cmd.filters.Filters() {
+		filterResult, err := h.replayFilter(ctx, filter, cmd.dryRun, replayed)
+		if err != nil {
+			return nil, errors.Wrap(err, "error replaying events matching a filter")
+		}
+		result = append(result, filterResult...)
+	}
+
+	return result, nil
+}
+
+// replayFilter pages through events matching the filter from the newest to
+// the oldest, each page is loaded in a separate transaction. Pages overlap by
+// one second as events which were created at the same time can be split
+// between them, events which were already replayed are skipped.
+func (h *ReplayEventsHandler) replayFilter(ctx context.Context, filter domain.Filter, dryRun bool, replayed *internal.Set[domain.EventId]) ([]ReplayedEvent, error) {
+	var result []ReplayedEvent
+
+	until := filter.Until()
+	pageSize := replayEventsPageSize
+	matched := 0
+
+	for {
+		page, err := filter.WithUntilAndLimit(until, pageSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "error creating a page filter")
+		}
+
+		events, err := h.getEvents(ctx, domain.NewFiltersFromFilters(page))
+		if err != nil {
+			return nil, errors.Wrap(err, "error getting events")
+		}
+
+		for _, event := range events {
+			if replayed.Contains(event.Id()) {
+				continue
+			}
+			replayed.Put(event.Id())
+
+			replayedEvent, err := h.replay(ctx, event, dryRun)
+			if err != nil {
+				return nil, errors.Wrapf(err, "error replaying event '%s'", event.Id().Hex())
+			}
+			result = append(result, replayedEvent)
+
+			matched++
+			if filter.Limit() > 0 && matched >= filter.Limit() {
+				return result, nil
+			}
+		}
+
+		if len(events) < pageSize {
+			return result, nil
+		}
+
+		oldest := oldestCreatedAt(events)
+		if until != nil && !oldest.Before(until.Truncate(time.Second)) {
+			// all events in this page were created during the same second
+			pageSize *= 2
+			continue
+		}
+
+		until = &oldest
+		pageSize = replayEventsPageSize
+	}
+}
+
+func (h *ReplayEventsHandler) replay(ctx context.Context, event domain.Event, dryRun bool) (ReplayedEvent, error) {
+	if dryRun {
+		notifications, err := h.processSavedEvent.Preview(ctx, event)
+		if err != nil {
+			return ReplayedEvent{}, errors.Wrap(err, "error previewing event")
+		}
+		return ReplayedEvent{event: event, notifications: notifications}, nil
+	}
+
+	if err := h.reprocessEvent.Handle(ctx, NewReprocessEvent(event.Id())); err != nil {
+		return ReplayedEvent{}, errors.Wrap(err, "error reprocessing event")
+	}
+	return ReplayedEvent{event: event}, nil
+}
+
+func (h *ReplayEventsHandler) getEvents(ctx context.Context, filters domain.Filters) ([]domain.Event, error) {
+	var events []domain.Event
+	if err := h.transactionProvider.TransactReadOnly(ctx, func(ctx context.Context, adapters Adapters) error {
+		// transactions can run multiple times
+		events = nil
+
+		for eventOrErr := range adapters.Events.GetEvents(ctx, filters) {
+			if err := eventOrErr.Err(); err != nil {
+				return errors.Wrap(err, "repository returned an error")
+			}
+			events = append(events, eventOrErr.Event())
+		}
+		return nil
+	}); err != nil {
+		return nil, errors.Wrap(err, "transaction error")
+	}
+
+	return events, nil
+}
+
+func oldestCreatedAt(events []domain.Event) time.Time {
+	oldest := events[0].CreatedAt()
+	for _, event := range events[1:] {
+		if event.CreatedAt().Before(oldest) {
+			oldest = event.CreatedAt()
+		}
+	}
+	return oldest
+}
@@ -0,0 +1,176 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplayEventsHandler_DryRunOnlyReturnsNotifications(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{}
+	processSavedEvent := newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider())
+	handler := newReplayEventsHandler(storage, processSavedEvent)
+
+	mention, _ := fixtures.SomeKeyPair()
+	token := fixtures.SomeAPNSPushToken()
+	event := someEventMentioning(t, mention)
+	storage.state.events[event.Id()] = event
+	storage.state.tokens[mention] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(token, domain.NotificationModeSilent, domain.Preferences{}),
+	}
+
+	replayed, err := handler.Handle(ctx, app.NewReplayEvents(filtersMentioning(t, mention), true))
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+	require.Equal(t, event.Id(), replayed[0].Event().Id())
+	require.Equal(t, []domain.PushToken{token}, sentTokens(replayed[0].Notifications()))
+
+	require.Empty(t, apns.SentNotifications())
+	require.Empty(t, storage.Notifications())
+	require.Empty(t, storage.PublishedEventSaved())
+
+	err = processSavedEvent.Handle(ctx, app.NewProcessSavedEvent(event.Id()))
+	require.NoError(t, err)
+
+	replayed, err = handler.Handle(ctx, app.NewReplayEvents(filtersMentioning(t, mention), true))
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+	require.Empty(t, replayed[0].Notifications(), "delivered notifications shouldn't be sent again")
+}
+
+func TestReplayEventsHandler_PublishesMatchingEvents(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	apns := &fakeAPNS{}
+	handler := newReplayEventsHandler(storage, newProcessSavedEventHandler(storage, apns, newFakeMetadataProvider()))
+
+	mention, _ := fixtures.SomeKeyPair()
+	otherMention, _ := fixtures.SomeKeyPair()
+	event := someEventMentioning(t, mention)
+	otherEvent := someEventMentioning(t, otherMention)
+	storage.state.events[event.Id()] = event
+	storage.state.events[otherEvent.Id()] = otherEvent
+
+	replayed, err := handler.Handle(ctx, app.NewReplayEvents(filtersMentioning(t, mention), false))
+	require.NoError(t, err)
+	require.Len(t, replayed, 1)
+	require.Empty(t, replayed[0].Notifications())
+
+	require.Equal(t, []domain.EventId{event.Id()}, storage.PublishedEventSaved())
+	require.Empty(t, apns.SentNotifications())
+}
+
+func TestReplayEventsHandler_PagesThroughAllMatchingEvents(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := newReplayEventsHandler(storage, newProcessSavedEventHandler(storage, &fakeAPNS{}, newFakeMetadataProvider()))
+
+	mention, _ := fixtures.SomeKeyPair()
+	now := time.Now()
+
+	// more events created during the same second than fit in a single page
+	var expected []domain.EventId
+	for i := 0; i < 150; i++ {
+		event := someEventMentioningCreatedAt(t, mention, now)
+		storage.state.events[event.Id()] = event
+		expected = append(expected, event.Id())
+	}
+	for i := 1; i <= 200; i++ {
+		event := someEventMentioningCreatedAt(t, mention, now.Add(-time.Duration(i)*time.Second))
+		storage.state.events[event.Id()] = event
+		expected = append(expected, event.Id())
+	}
+
+	replayed, err := handler.Handle(ctx, app.NewReplayEvents(filtersMentioning(t, mention), false))
+	require.NoError(t, err)
+	require.Len(t, replayed, len(expected))
+	require.ElementsMatch(t, expected, storage.PublishedEventSaved())
+}
+
+func TestReplayEventsHandler_LimitIsRespectedWhenPaging(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := newReplayEventsHandler(storage, newProcessSavedEventHandler(storage, &fakeAPNS{}, newFakeMetadataProvider()))
+
+	mention, _ := fixtures.SomeKeyPair()
+	now := time.Now()
+
+	for i := 0; i < 300; i++ {
+		event := someEventMentioningCreatedAt(t, mention, now.Add(-time.Duration(i)*time.Second))
+		storage.state.events[event.Id()] = event
+	}
+
+	filters, err := domain.NewFilters(nostr.Filters{
+		{
+			Tags: map[string][]string{
+				"p": {mention.Hex()},
+			},
+			Limit: 150,
+		},
+	})
+	require.NoError(t, err)
+
+	replayed, err := handler.Handle(ctx, app.NewReplayEvents(filters, true))
+	require.NoError(t, err)
+	require.Len(t, replayed, 150)
+
+	for i := 1; i < len(replayed); i++ {
+		require.False(t, replayed[i].Event().CreatedAt().After(replayed[i-1].Event().CreatedAt()))
+	}
+}
+
+func newReplayEventsHandler(storage *fakeStorage, processSavedEvent *app.ProcessSavedEventHandler) *app.ReplayEventsHandler {
+	logger := logging.NewDevNullLogger()
+	return app.NewReplayEventsHandler(
+		storage,
+		processSavedEvent,
+		app.NewReprocessEventHandler(storage, logger, fakeMetrics{}),
+		logger,
+		fakeMetrics{},
+	)
+}
+
+func someEventMentioningCreatedAt(t *testing.T, mention domain.PublicKey, createdAt time.Time) domain.Event {
+	_, sk := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Timestamp(createdAt.Unix()),
+		Kind:      domain.EventKindNote.Int(),
+		Tags: nostr.Tags{
+			nostr.Tag{"p", mention.Hex()},
+		},
+		Content: "some content",
+	}
+
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	return event
+}
+
+func filtersMentioning(t *testing.T, publicKey domain.PublicKey) domain.Filters {
+	filters, err := domain.NewFilters(nostr.Filters{
+		{
+			Tags: map[string][]string{
+				"p": {publicKey.Hex()},
+			},
+		},
+	})
+	require.NoError(t, err)
+	return filters
+}
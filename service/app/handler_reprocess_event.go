@@ -0,0 +1,67 @@
+package app
+
+import (
+	"context"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+type ReprocessEvent struct {
+	eventId domain.EventId
+}
+
+func NewReprocessEvent(eventId domain.EventId) ReprocessEvent {
+	return ReprocessEvent{eventId: eventId}
+}
+
+// ReprocessEventHandler publishes the event saved message again so that the
+// event goes through the same pipeline as a newly saved event. Notifications
+// which were already delivered are not sent again.
+type ReprocessEventHandler struct {
+	transactionProvider TransactionProvider
+	logger              logging.Logger
+	metrics             Metrics
+}
+
+func NewReprocessEventHandler(
+	transactionProvider TransactionProvider,
+	logger logging.Logger,
+	metrics Metrics,
+) *ReprocessEventHandler {
+	return &ReprocessEventHandler{
+		transactionProvider: transactionProvider,
+		logger:              logger.New("reprocessEventHandler"),
+		metrics:             metrics,
+	}
+}
+
+func (h *ReprocessEventHandler) Handle(ctx context.Context, cmd ReprocessEvent) (err error) {
+	defer h.metrics.StartApplicationCall("reprocessEvent").End(&err)
+
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		exists, err := adapters.Events.Exists(ctx, cmd.eventId)
+		if err != nil {
+			return errors.Wrap(err, "error checking if the event exists")
+		}
+
+		if !exists {
+			return ErrEventNotFound
+		}
+
+		if err := adapters.Publisher.PublishEventSaved(ctx, cmd.eventId); err != nil {
+			return errors.Wrap(err, "error publishing the event saved message")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "transaction error")
+	}
+
+	h.logger.Debug().
+		WithField("event.id", cmd.eventId.Hex()).
+		Message("scheduled the event for reprocessing")
+
+	return nil
+}
@@ -0,0 +1,45 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReprocessEventHandler_PublishesSavedEvents(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := app.NewReprocessEventHandler(storage, logging.NewDevNullLogger(), fakeMetrics{})
+
+	event, err := domain.NewEvent(fixtures.SomeLibevent(t))
+	require.NoError(t, err)
+
+	err = storage.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		return adapters.Events.Save(event)
+	})
+	require.NoError(t, err)
+
+	err = handler.Handle(ctx, app.NewReprocessEvent(event.Id()))
+	require.NoError(t, err)
+
+	require.Equal(t, []domain.EventId{event.Id()}, storage.PublishedEventSaved())
+}
+
+func TestReprocessEventHandler_ReturnsErrorForUnknownEvents(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := app.NewReprocessEventHandler(storage, logging.NewDevNullLogger(), fakeMetrics{})
+
+	err := handler.Handle(ctx, app.NewReprocessEvent(fixtures.SomeEventID()))
+	require.True(t, errors.Is(err, app.ErrEventNotFound))
+
+	require.Empty(t, storage.PublishedEventSaved())
+}
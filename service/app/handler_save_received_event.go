@@ -3,6 +3,7 @@ package app
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/internal/logging"
@@ -46,7 +47,15 @@ func (h *SaveReceivedEventHandler) Handle(ctx context.Context, cmd SaveReceivedE
 		return fmt.Errorf("event '%s' shouldn't have been downloaded", cmd.event.String())
 	}
 
-	if h.eventWasAlreadySavedCache.EventWasAlreadySaved(cmd.event.Id()) {
+	saved, err := h.eventWasAlreadySavedCache.EventWasAlreadySaved(ctx, cmd.event.Id())
+	if err != nil {
+		h.logger.Error().
+			WithError(err).
+			WithField("event.id", cmd.event.Id().Hex()).
+			Message("error checking the event was already saved cache")
+	}
+
+	if saved {
 		return nil
 	}
 
@@ -58,6 +67,20 @@ func (h *SaveReceivedEventHandler) Handle(ctx context.Context, cmd SaveReceivedE
 		WithField("number_of_tags", len(cmd.event.Tags())).
 		Message("saving received event")
 
+	if cmd.event.Kind() == domain.EventKindMuteList {
+		if err := h.saveMuteList(ctx, cmd.event); err != nil {
+			return errors.Wrap(err, "error saving the mute list")
+		}
+		return nil
+	}
+
+	if cmd.event.Kind() == domain.EventKindRelayList {
+		if err := h.saveRelayList(ctx, cmd.event); err != nil {
+			return errors.Wrap(err, "error saving the relay list")
+		}
+		return nil
+	}
+
 	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
 		exists, err := adapters.Events.Exists(ctx, cmd.event.Id())
 		if err != nil {
@@ -65,7 +88,6 @@ func (h *SaveReceivedEventHandler) Handle(ctx context.Context, cmd SaveReceivedE
 		}
 
 		if exists {
-			h.eventWasAlreadySavedCache.MarkEventAsAlreadySaved(cmd.event.Id())
 			return nil
 		}
 
@@ -82,5 +104,93 @@ func (h *SaveReceivedEventHandler) Handle(ctx context.Context, cmd SaveReceivedE
 		return errors.Wrap(err, "transaction error")
 	}
 
+	h.markEventAsAlreadySaved(ctx, cmd.event)
+	return nil
+}
+
+// markEventAsAlreadySaved only logs errors as the cache is an optimisation and
+// the event was already saved at this point.
+func (h *SaveReceivedEventHandler) markEventAsAlreadySaved(ctx context.Context, event domain.Event) {
+	if err := h.eventWasAlreadySavedCache.MarkEventAsAlreadySaved(ctx, event.Id()); err != nil {
+		h.logger.Error().
+			WithError(err).
+			WithField("event.id", event.Id().Hex()).
+			Message("error marking the event as already saved")
+	}
+}
+
+// Mute lists aren't saved as events as they aren't processed like other events
+// e.g. the muted public keys shouldn't receive notifications about them. Only
+// the latest mute list of each author is kept, see NIP-01 replaceable events.
+// Mute lists of public keys which don't have any push tokens are ignored as
+// they wouldn't receive any notifications anyway.
+func (h *SaveReceivedEventHandler) saveMuteList(ctx context.Context, event domain.Event) error {
+	muteList, err := domain.NewMuteList(event)
+	if err != nil {
+		return errors.Wrap(err, "error creating the mute list")
+	}
+
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		tokens, err := adapters.PublicKeys.GetPushTokens(ctx, muteList.Author(), time.Now().Add(-sendNotificationsToTokensYoungerThan))
+		if err != nil {
+			return errors.Wrap(err, "error getting push tokens")
+		}
+
+		if len(tokens) == 0 {
+			return nil
+		}
+
+		current, ok, err := adapters.MuteLists.Get(ctx, muteList.Author())
+		if err != nil {
+			return errors.Wrap(err, "error getting the current mute list")
+		}
+
+		if ok && !muteList.Event().CreatedAt().After(current.Event().CreatedAt()) {
+			return nil
+		}
+
+		if err := adapters.MuteLists.Save(muteList); err != nil {
+			return errors.Wrap(err, "error saving the mute list")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "transaction error")
+	}
+
+	h.markEventAsAlreadySaved(ctx, event)
+	return nil
+}
+
+// Relay lists aren't saved as events either. Their read relays are used as
+// additional relays to download events mentioning their authors from, see
+// NIP-65. Relay lists of public keys which no longer have any push tokens are
+// ignored so that we don't start downloading events for them again.
+func (h *SaveReceivedEventHandler) saveRelayList(ctx context.Context, event domain.Event) error {
+	relayList, err := domain.NewRelayList(event)
+	if err != nil {
+		return errors.Wrap(err, "error creating the relay list")
+	}
+
+	if err := h.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
+		tokens, err := adapters.PublicKeys.GetPushTokens(ctx, relayList.Author(), time.Now().Add(-sendNotificationsToTokensYoungerThan))
+		if err != nil {
+			return errors.Wrap(err, "error getting push tokens")
+		}
+
+		if len(tokens) == 0 {
+			return nil
+		}
+
+		if err := adapters.Relays.SaveRelayList(ctx, relayList); err != nil {
+			return errors.Wrap(err, "error saving the relay list")
+		}
+
+		return nil
+	}); err != nil {
+		return errors.Wrap(err, "transaction error")
+	}
+
+	h.markEventAsAlreadySaved(ctx, event)
 	return nil
 }
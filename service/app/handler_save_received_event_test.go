@@ -0,0 +1,135 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveReceivedEventHandler_OnlyTheLatestMuteListIsKept(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := app.NewSaveReceivedEventHandler(
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		storage,
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+	)
+
+	owner, ownerSecretKey := fixtures.SomeKeyPair()
+	relay := fixtures.SomeRelayAddress()
+
+	storage.state.tokens[owner] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeVisible, domain.Preferences{}),
+	}
+
+	older := fixtures.MuteListEvent(t, ownerSecretKey, time.Unix(1000, 0), nostr.Tags{})
+	newer := fixtures.MuteListEvent(t, ownerSecretKey, time.Unix(2000, 0), nostr.Tags{})
+
+	err := handler.Handle(ctx, app.NewSaveReceivedEvent(relay, newer))
+	require.NoError(t, err)
+
+	err = handler.Handle(ctx, app.NewSaveReceivedEvent(relay, older))
+	require.NoError(t, err)
+
+	err = storage.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		muteList, ok, err := adapters.MuteLists.Get(ctx, owner)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, newer.Id(), muteList.Event().Id())
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Empty(t, storage.state.events, "mute lists shouldn't be processed like other events")
+}
+
+func TestSaveReceivedEventHandler_MuteListsAreOnlySavedForPublicKeysWithTokens(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := app.NewSaveReceivedEventHandler(
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		storage,
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+	)
+
+	registered, registeredSecretKey := fixtures.SomeKeyPair()
+	unregistered, unregisteredSecretKey := fixtures.SomeKeyPair()
+	relay := fixtures.SomeRelayAddress()
+
+	storage.state.tokens[registered] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeVisible, domain.Preferences{}),
+	}
+
+	registeredMuteList := fixtures.MuteListEvent(t, registeredSecretKey, time.Unix(1000, 0), nostr.Tags{})
+	unregisteredMuteList := fixtures.MuteListEvent(t, unregisteredSecretKey, time.Unix(1000, 0), nostr.Tags{})
+
+	err := handler.Handle(ctx, app.NewSaveReceivedEvent(relay, registeredMuteList))
+	require.NoError(t, err)
+
+	err = handler.Handle(ctx, app.NewSaveReceivedEvent(relay, unregisteredMuteList))
+	require.NoError(t, err)
+
+	err = storage.Transact(ctx, func(ctx context.Context, adapters app.Adapters) error {
+		muteList, ok, err := adapters.MuteLists.Get(ctx, registered)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, registeredMuteList.Id(), muteList.Event().Id())
+
+		_, ok, err = adapters.MuteLists.Get(ctx, unregistered)
+		require.NoError(t, err)
+		require.False(t, ok)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestSaveReceivedEventHandler_RelayListsAreOnlySavedForPublicKeysWithTokens(t *testing.T) {
+	ctx := fixtures.Context(t)
+
+	storage := newFakeStorage()
+	handler := app.NewSaveReceivedEventHandler(
+		adapters.NewMemoryEventWasAlreadySavedCache(time.Hour),
+		storage,
+		logging.NewDevNullLogger(),
+		fakeMetrics{},
+	)
+
+	registered, registeredSecretKey := fixtures.SomeKeyPair()
+	unregistered, unregisteredSecretKey := fixtures.SomeKeyPair()
+	relay := fixtures.SomeRelayAddress()
+
+	storage.state.tokens[registered] = []domain.RegisteredPushToken{
+		domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeVisible, domain.Preferences{}),
+	}
+
+	tags := nostr.Tags{{"r", "wss://example.com"}}
+	registeredRelayList := fixtures.RelayListEvent(t, registeredSecretKey, time.Unix(1000, 0), tags)
+	unregisteredRelayList := fixtures.RelayListEvent(t, unregisteredSecretKey, time.Unix(1000, 0), tags)
+
+	err := handler.Handle(ctx, app.NewSaveReceivedEvent(relay, registeredRelayList))
+	require.NoError(t, err)
+
+	err = handler.Handle(ctx, app.NewSaveReceivedEvent(relay, unregisteredRelayList))
+	require.NoError(t, err)
+
+	relayList, ok := storage.RelayList(registered)
+	require.True(t, ok)
+	require.Equal(t, registeredRelayList.Id(), relayList.Event().Id())
+
+	_, ok = storage.RelayList(unregistered)
+	require.False(t, ok)
+
+	require.Empty(t, storage.state.events, "relay lists shouldn't be processed like other events")
+}
@@ -37,7 +37,7 @@ func (h *SaveRegistrationHandler) Handle(ctx context.Context, cmd SaveRegistrati
 	defer h.metrics.StartApplicationCall("saveRegistration").End(&err)
 
 	h.logger.Debug().
-		WithField("apnsToken", cmd.registration.APNSToken().Hex()).
+		WithField("pushToken", cmd.registration.PushToken().String()).
 		WithField("publicKey", cmd.registration.PublicKey().Hex()).
 		WithField("relays", cmd.registration.Relays()).
 		Message("saving registration")
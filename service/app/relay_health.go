@@ -0,0 +1,218 @@
+package app
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	initialReconnectBackoff = 5 * time.Second
+	maxReconnectBackoff     = 30 * time.Minute
+
+	// Connections which are dropped sooner than this are considered to be
+	// failures so that relays which accept connections and immediately close
+	// them also end up backing off.
+	connectionIsStableAfter = 1 * time.Minute
+
+	quarantineAfterFailures = 10
+	quarantineFor           = 6 * time.Hour
+)
+
+// RelayHealth describes how reliably we can download events from a relay.
+type RelayHealth struct {
+	address             domain.RelayAddress
+	state               RelayDownloaderState
+	connectLatency      time.Duration
+	consecutiveFailures int
+	eventsPerMinute     int
+	lastEOSE            time.Time
+	quarantinedUntil    time.Time
+}
+
+func (h RelayHealth) Address() domain.RelayAddress {
+	return h.address
+}
+
+func (h RelayHealth) State() RelayDownloaderState {
+	return h.state
+}
+
+// ConnectLatency is the time it took to establish the last successful
+// connection. Zero if we never connected.
+func (h RelayHealth) ConnectLatency() time.Duration {
+	return h.connectLatency
+}
+
+func (h RelayHealth) ConsecutiveFailures() int {
+	return h.consecutiveFailures
+}
+
+// EventsPerMinute is the number of events received during the last full
+// minute.
+func (h RelayHealth) EventsPerMinute() int {
+	return h.eventsPerMinute
+}
+
+// LastEOSE returns false if the relay never sent an EOSE message.
+func (h RelayHealth) LastEOSE() (time.Time, bool) {
+	return h.lastEOSE, !h.lastEOSE.IsZero()
+}
+
+// QuarantinedUntil returns false if the relay isn't quarantined.
+func (h RelayHealth) QuarantinedUntil() (time.Time, bool) {
+	return h.quarantinedUntil, !h.quarantinedUntil.IsZero()
+}
+
+// relayHealthTracker records connection attempts and received messages and
+// decides when we should try to reconnect to a relay.
+type relayHealthTracker struct {
+	lock sync.Mutex
+
+	connectLatency      time.Duration
+	connectedAt         time.Time
+	consecutiveFailures int
+	lastEOSE            time.Time
+	quarantinedUntil    time.Time
+
+	currentMinute         time.Time
+	eventsInCurrentMinute int
+	eventsInLastMinute    int
+}
+
+func newRelayHealthTracker() *relayHealthTracker {
+	return &relayHealthTracker{}
+}
+
+func (t *relayHealthTracker) Connected(now time.Time, latency time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.connectedAt = now
+	t.connectLatency = latency
+}
+
+func (t *relayHealthTracker) Disconnected(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.resetFailuresIfStable(now)
+	if t.connectedAt.IsZero() || now.Sub(t.connectedAt) < connectionIsStableAfter {
+		t.failed(now)
+	}
+	t.connectedAt = time.Time{}
+}
+
+// ConnectionFailed should be called if the relay couldn't be dialed.
+func (t *relayHealthTracker) ConnectionFailed(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.failed(now)
+}
+
+func (t *relayHealthTracker) EventReceived(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.rotateMinutes(now)
+	t.eventsInCurrentMinute++
+}
+
+func (t *relayHealthTracker) EOSEReceived(now time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.lastEOSE = now
+}
+
+// WaitBeforeReconnecting returns how long we should wait before connecting to
+// the relay again.
+func (t *relayHealthTracker) WaitBeforeReconnecting(now time.Time) time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.quarantinedUntil.After(now) {
+		return t.quarantinedUntil.Sub(now)
+	}
+
+	return reconnectBackoff(t.consecutiveFailures, rand.Float64())
+}
+
+func (t *relayHealthTracker) Quarantined(now time.Time) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.quarantinedUntil.After(now)
+}
+
+func (t *relayHealthTracker) Health(now time.Time, address domain.RelayAddress, state RelayDownloaderState) RelayHealth {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.resetFailuresIfStable(now)
+	t.rotateMinutes(now)
+
+	health := RelayHealth{
+		address:             address,
+		state:               state,
+		connectLatency:      t.connectLatency,
+		consecutiveFailures: t.consecutiveFailures,
+		eventsPerMinute:     t.eventsInLastMinute,
+		lastEOSE:            t.lastEOSE,
+	}
+
+	if t.quarantinedUntil.After(now) {
+		health.quarantinedUntil = t.quarantinedUntil
+	}
+
+	return health
+}
+
+func (t *relayHealthTracker) failed(now time.Time) {
+	t.consecutiveFailures++
+	if t.consecutiveFailures >= quarantineAfterFailures {
+		t.quarantinedUntil = now.Add(quarantineFor)
+	}
+}
+
+func (t *relayHealthTracker) resetFailuresIfStable(now time.Time) {
+	if !t.connectedAt.IsZero() && now.Sub(t.connectedAt) >= connectionIsStableAfter {
+		t.consecutiveFailures = 0
+		t.quarantinedUntil = time.Time{}
+	}
+}
+
+func (t *relayHealthTracker) rotateMinutes(now time.Time) {
+	minute := now.Truncate(time.Minute)
+	if minute.Equal(t.currentMinute) {
+		return
+	}
+
+	if minute.Equal(t.currentMinute.Add(time.Minute)) {
+		t.eventsInLastMinute = t.eventsInCurrentMinute
+	} else {
+		t.eventsInLastMinute = 0
+	}
+
+	t.currentMinute = minute
+	t.eventsInCurrentMinute = 0
+}
+
+// reconnectBackoff grows exponentially with the number of consecutive failures.
+// Random should be in range [0, 1) and is used to add jitter so that we don't
+// reconnect to all relays at the same time e.g. after a network outage.
+func reconnectBackoff(consecutiveFailures int, random float64) time.Duration {
+	backoff := initialReconnectBackoff
+	for i := 0; i < consecutiveFailures && backoff < maxReconnectBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxReconnectBackoff {
+		backoff = maxReconnectBackoff
+	}
+
+	return backoff/2 + time.Duration(random*float64(backoff/2))
+}
@@ -0,0 +1,141 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconnectBackoff(t *testing.T) {
+	testCases := []struct {
+		Name                string
+		ConsecutiveFailures int
+
+		ExpectedMin time.Duration
+		ExpectedMax time.Duration
+	}{
+		{
+			Name:                "no_failures",
+			ConsecutiveFailures: 0,
+			ExpectedMin:         initialReconnectBackoff / 2,
+			ExpectedMax:         initialReconnectBackoff,
+		},
+		{
+			Name:                "one_failure",
+			ConsecutiveFailures: 1,
+			ExpectedMin:         initialReconnectBackoff,
+			ExpectedMax:         2 * initialReconnectBackoff,
+		},
+		{
+			Name:                "three_failures",
+			ConsecutiveFailures: 3,
+			ExpectedMin:         4 * initialReconnectBackoff,
+			ExpectedMax:         8 * initialReconnectBackoff,
+		},
+		{
+			Name:                "capped",
+			ConsecutiveFailures: 1000,
+			ExpectedMin:         maxReconnectBackoff / 2,
+			ExpectedMax:         maxReconnectBackoff,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			require.Equal(t, testCase.ExpectedMin, reconnectBackoff(testCase.ConsecutiveFailures, 0))
+			require.Less(t, reconnectBackoff(testCase.ConsecutiveFailures, 0.999), testCase.ExpectedMax)
+			require.Greater(t, reconnectBackoff(testCase.ConsecutiveFailures, 0.5), testCase.ExpectedMin)
+		})
+	}
+}
+
+func TestRelayHealthTracker_UnreachableRelaysAreQuarantined(t *testing.T) {
+	tracker := newRelayHealthTracker()
+	address := fixtures.SomeRelayAddress()
+	now := time.Now()
+
+	for i := 0; i < quarantineAfterFailures-1; i++ {
+		tracker.ConnectionFailed(now)
+		require.False(t, tracker.Quarantined(now))
+		require.LessOrEqual(t, tracker.WaitBeforeReconnecting(now), maxReconnectBackoff)
+	}
+
+	tracker.ConnectionFailed(now)
+	require.True(t, tracker.Quarantined(now))
+	require.Equal(t, quarantineFor, tracker.WaitBeforeReconnecting(now))
+
+	health := tracker.Health(now, address, RelayDownloaderStateQuarantined)
+	require.Equal(t, quarantineAfterFailures, health.ConsecutiveFailures())
+	quarantinedUntil, ok := health.QuarantinedUntil()
+	require.True(t, ok)
+	require.Equal(t, now.Add(quarantineFor), quarantinedUntil)
+
+	afterQuarantine := now.Add(quarantineFor)
+	require.False(t, tracker.Quarantined(afterQuarantine))
+
+	tracker.ConnectionFailed(afterQuarantine)
+	require.True(t, tracker.Quarantined(afterQuarantine), "a single failed attempt after the quarantine should quarantine the relay again")
+}
+
+func TestRelayHealthTracker_StableConnectionsResetFailures(t *testing.T) {
+	tracker := newRelayHealthTracker()
+	address := fixtures.SomeRelayAddress()
+	now := time.Now()
+
+	for i := 0; i < quarantineAfterFailures; i++ {
+		tracker.ConnectionFailed(now)
+	}
+	require.True(t, tracker.Quarantined(now))
+
+	tracker.Connected(now, 100*time.Millisecond)
+	require.Equal(t, quarantineAfterFailures, tracker.Health(now, address, RelayDownloaderStateConnected).ConsecutiveFailures())
+
+	now = now.Add(connectionIsStableAfter)
+	health := tracker.Health(now, address, RelayDownloaderStateConnected)
+	require.Equal(t, 0, health.ConsecutiveFailures())
+	require.Equal(t, 100*time.Millisecond, health.ConnectLatency())
+	_, ok := health.QuarantinedUntil()
+	require.False(t, ok)
+
+	tracker.Disconnected(now)
+	require.Equal(t, 0, tracker.Health(now, address, RelayDownloaderStateDisconnected).ConsecutiveFailures())
+}
+
+func TestRelayHealthTracker_ConnectionsWhichAreDroppedQuicklyAreFailures(t *testing.T) {
+	tracker := newRelayHealthTracker()
+	address := fixtures.SomeRelayAddress()
+	now := time.Now()
+
+	tracker.Connected(now, time.Millisecond)
+	tracker.Disconnected(now.Add(time.Second))
+
+	require.Equal(t, 1, tracker.Health(now, address, RelayDownloaderStateDisconnected).ConsecutiveFailures())
+}
+
+func TestRelayHealthTracker_EventsPerMinuteAndLastEOSE(t *testing.T) {
+	tracker := newRelayHealthTracker()
+	address := fixtures.SomeRelayAddress()
+	minute := time.Now().Truncate(time.Minute)
+
+	_, ok := tracker.Health(minute, address, RelayDownloaderStateConnected).LastEOSE()
+	require.False(t, ok)
+
+	for i := 0; i < 3; i++ {
+		tracker.EventReceived(minute.Add(time.Duration(i) * time.Second))
+	}
+	tracker.EOSEReceived(minute.Add(10 * time.Second))
+
+	require.Equal(t, 0, tracker.Health(minute.Add(30*time.Second), address, RelayDownloaderStateConnected).EventsPerMinute(),
+		"the current minute is not over yet",
+	)
+
+	health := tracker.Health(minute.Add(90*time.Second), address, RelayDownloaderStateConnected)
+	require.Equal(t, 3, health.EventsPerMinute())
+	lastEOSE, ok := health.LastEOSE()
+	require.True(t, ok)
+	require.Equal(t, minute.Add(10*time.Second), lastEOSE)
+
+	require.Equal(t, 0, tracker.Health(minute.Add(3*time.Minute), address, RelayDownloaderStateConnected).EventsPerMinute())
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	refreshRelayInformationEvery = 24 * time.Hour
+
+	// Relays often don't serve relay information documents at all so we
+	// shouldn't try to retrieve them too often.
+	retryRelayInformationAfter = 1 * time.Hour
+)
+
+// RelayInformationCache remembers relay information documents so that they
+// don't have to be retrieved every time we connect to a relay.
+type RelayInformationCache struct {
+	provider RelayInformationProvider
+	logger   logging.Logger
+
+	entries     map[domain.RelayAddress]relayInformationCacheEntry
+	entriesLock sync.Mutex
+}
+
+func NewRelayInformationCache(
+	provider RelayInformationProvider,
+	logger logging.Logger,
+) *RelayInformationCache {
+	return &RelayInformationCache{
+		provider: provider,
+		logger:   logger.New("relayInformationCache"),
+		entries:  make(map[domain.RelayAddress]relayInformationCacheEntry),
+	}
+}
+
+// Get retrieves the relay information document if it isn't cached or is
+// stale. It returns false if the relay information is not available.
+func (c *RelayInformationCache) Get(ctx context.Context, address domain.RelayAddress) (domain.RelayInformation, bool) {
+	now := time.Now()
+
+	c.entriesLock.Lock()
+	entry, ok := c.entries[address]
+	c.entriesLock.Unlock()
+
+	if ok && !entry.Stale(now) {
+		return entry.information, entry.ok
+	}
+
+	information, err := c.provider.GetRelayInformation(ctx, address)
+	if err != nil {
+		c.logger.Debug().
+			WithError(err).
+			WithField("relay", address.String()).
+			Message("error getting relay information")
+	}
+
+	entry = relayInformationCacheEntry{
+		information: information,
+		ok:          err == nil,
+		retrievedAt: now,
+	}
+
+	c.entriesLock.Lock()
+	c.entries[address] = entry
+	c.entriesLock.Unlock()
+
+	return entry.information, entry.ok
+}
+
+// GetCached never retrieves relay information documents. It returns false if
+// the relay information is not available.
+func (c *RelayInformationCache) GetCached(address domain.RelayAddress) (domain.RelayInformation, bool) {
+	c.entriesLock.Lock()
+	defer c.entriesLock.Unlock()
+
+	entry, ok := c.entries[address]
+	if !ok {
+		return domain.RelayInformation{}, false
+	}
+	return entry.information, entry.ok
+}
+
+type relayInformationCacheEntry struct {
+	information domain.RelayInformation
+	ok          bool
+	retrievedAt time.Time
+}
+
+func (e relayInformationCacheEntry) Stale(now time.Time) bool {
+	if e.ok {
+		return now.Sub(e.retrievedAt) >= refreshRelayInformationEvery
+	}
+	return now.Sub(e.retrievedAt) >= retryRelayInformationAfter
+}
@@ -0,0 +1,52 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayInformationCache_InformationIsRetrievedOnce(t *testing.T) {
+	ctx := fixtures.Context(t)
+	address := fixtures.SomeRelayAddress()
+
+	provider := newFakeRelayInformationProvider()
+	provider.SetInformation(domain.NewRelayInformation([]int{1, 11}, domain.RelayLimitations{}))
+	cache := newRelayInformationCache(provider)
+
+	_, ok := cache.GetCached(address)
+	require.False(t, ok)
+	require.Equal(t, 0, provider.Calls())
+
+	for i := 0; i < 3; i++ {
+		information, ok := cache.Get(ctx, address)
+		require.True(t, ok)
+		require.Equal(t, []int{1, 11}, information.SupportedNIPs())
+	}
+	require.Equal(t, 1, provider.Calls())
+
+	information, ok := cache.GetCached(address)
+	require.True(t, ok)
+	require.True(t, information.SupportsNIP(11))
+}
+
+func TestRelayInformationCache_FailuresAreCached(t *testing.T) {
+	ctx := fixtures.Context(t)
+	address := fixtures.SomeRelayAddress()
+
+	provider := newFakeRelayInformationProvider()
+	provider.SetError(errors.New("some error"))
+	cache := newRelayInformationCache(provider)
+
+	for i := 0; i < 3; i++ {
+		_, ok := cache.Get(ctx, address)
+		require.False(t, ok)
+	}
+	require.Equal(t, 1, provider.Calls())
+
+	_, ok := cache.GetCached(address)
+	require.False(t, ok)
+}
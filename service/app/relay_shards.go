@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/google/uuid"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	// Replicas which don't renew their lease within this time are considered
+	// to be gone and their relays are taken over by other replicas. It has to
+	// be longer than rebalanceRelaysEvery.
+	replicaLeaseDuration = 45 * time.Second
+
+	releaseReplicaLeaseTimeout = 5 * time.Second
+)
+
+type ReplicaID struct {
+	s string
+}
+
+func NewReplicaID(s string) (ReplicaID, error) {
+	if s == "" {
+		return ReplicaID{}, errors.New("replica id can't be empty")
+	}
+	return ReplicaID{s: s}, nil
+}
+
+// NewRandomReplicaID returns a new replica id prefixed with the given name e.g.
+// a hostname to make it easier to tell replicas apart.
+func NewRandomReplicaID(name string) ReplicaID {
+	if name == "" {
+		return ReplicaID{s: uuid.New().String()}
+	}
+	return ReplicaID{s: name + "-" + uuid.New().String()}
+}
+
+func (r ReplicaID) String() string {
+	return r.s
+}
+
+// ReplicaRegistry keeps track of replicas of the service which are currently
+// running.
+type ReplicaRegistry interface {
+	// Renew extends the lease of the replica until the given time.
+	Renew(ctx context.Context, replica ReplicaID, until time.Time) error
+
+	// Release ends the lease of the replica immediately so that other
+	// replicas can take over its relays without waiting for it to expire.
+	Release(ctx context.Context, replica ReplicaID) error
+
+	// List returns replicas whose leases are still valid at the given time.
+	List(ctx context.Context, now time.Time) ([]ReplicaID, error)
+}
+
+// RelayShards splits relays between replicas of the service so that only one
+// of them downloads events from each relay. Relays are assigned using
+// rendezvous hashing which means that only relays owned by replicas which left
+// or relays which are taken over by new replicas are moved around.
+//
+// Assignments are recalculated periodically by each replica on its own so
+// while replicas join or leave a relay may briefly be owned by two replicas
+// or by none.
+type RelayShards struct {
+	registry ReplicaRegistry
+	replica  ReplicaID
+	logger   logging.Logger
+
+	replicas     []ReplicaID
+	replicasLock sync.Mutex
+}
+
+func NewRelayShards(
+	registry ReplicaRegistry,
+	replica ReplicaID,
+	logger logging.Logger,
+) *RelayShards {
+	return &RelayShards{
+		registry: registry,
+		replica:  replica,
+		logger:   logger.New("relayShards"),
+		replicas: []ReplicaID{replica},
+	}
+}
+
+// Refresh renews the lease of this replica and updates the list of replicas
+// which relays are split between. If the registry can't be reached the last
+// known list of replicas continues to be used.
+func (s *RelayShards) Refresh(ctx context.Context, now time.Time) error {
+	if err := s.registry.Renew(ctx, s.replica, now.Add(replicaLeaseDuration)); err != nil {
+		return errors.Wrap(err, "error renewing the lease")
+	}
+
+	replicas, err := s.registry.List(ctx, now)
+	if err != nil {
+		return errors.Wrap(err, "error listing replicas")
+	}
+
+	s.replicasLock.Lock()
+	defer s.replicasLock.Unlock()
+
+	// Our lease may have expired if renewing it took too long but we are
+	// clearly still running.
+	s.replicas = append([]ReplicaID{s.replica}, removeReplica(replicas, s.replica)...)
+	return nil
+}
+
+// Release should be called when this replica is shutting down.
+func (s *RelayShards) Release() {
+	ctx, cancel := context.WithTimeout(context.Background(), releaseReplicaLeaseTimeout)
+	defer cancel()
+
+	if err := s.registry.Release(ctx, s.replica); err != nil {
+		s.logger.Error().WithError(err).Message("error releasing the lease")
+	}
+}
+
+// Owned returns relays which this replica should download events from.
+func (s *RelayShards) Owned(relays []domain.RelayAddress) []domain.RelayAddress {
+	s.replicasLock.Lock()
+	defer s.replicasLock.Unlock()
+
+	var result []domain.RelayAddress
+	for _, relay := range relays {
+		if relayOwner(relay, s.replicas) == s.replica {
+			result = append(result, relay)
+		}
+	}
+	return result
+}
+
+func relayOwner(relay domain.RelayAddress, replicas []ReplicaID) ReplicaID {
+	var owner ReplicaID
+	var ownerScore uint64
+	for _, replica := range replicas {
+		score := relayScore(relay, replica)
+		if owner == (ReplicaID{}) || score > ownerScore || (score == ownerScore && replica.s < owner.s) {
+			owner = replica
+			ownerScore = score
+		}
+	}
+	return owner
+}
+
+func relayScore(relay domain.RelayAddress, replica ReplicaID) uint64 {
+	h := sha256.New()
+	h.Write([]byte(replica.String()))
+	h.Write([]byte{0})
+	h.Write([]byte(relay.String()))
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+func removeReplica(replicas []ReplicaID, replica ReplicaID) []ReplicaID {
+	var result []ReplicaID
+	for _, v := range replicas {
+		if v != replica {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package app_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/adapters"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayShards_SingleReplicaOwnsAllRelays(t *testing.T) {
+	ctx := fixtures.Context(t)
+	relays := someRelayAddresses(10)
+
+	shards := newRelayShards(adapters.NewMemoryReplicaRegistry())
+	require.Equal(t, relays, shards.Owned(relays), "replicas should own all relays before they find other replicas")
+
+	err := shards.Refresh(ctx, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, relays, shards.Owned(relays))
+}
+
+func TestRelayShards_EachRelayIsOwnedByExactlyOneReplica(t *testing.T) {
+	registry := adapters.NewMemoryReplicaRegistry()
+	relays := someRelayAddresses(100)
+	now := time.Now()
+
+	replicas := []*app.RelayShards{
+		newRelayShards(registry),
+		newRelayShards(registry),
+		newRelayShards(registry),
+	}
+	refreshReplicas(t, replicas, now)
+
+	owners := requireEachRelayHasOneOwner(t, relays, replicas)
+	for i := range replicas {
+		require.NotEmpty(t, replicasOwnedRelays(owners, i), "relays should be split between all replicas")
+	}
+
+	t.Run("leaving", func(t *testing.T) {
+		replicas[2].Release()
+		refreshReplicas(t, replicas[:2], now)
+
+		ownersAfterLeaving := requireEachRelayHasOneOwner(t, relays, replicas[:2])
+		for relay, owner := range owners {
+			if owner != 2 {
+				require.Equal(t, owner, ownersAfterLeaving[relay], "only relays of the replica which left should be moved")
+			}
+		}
+
+		t.Run("joining", func(t *testing.T) {
+			replicas[2] = newRelayShards(registry)
+			refreshReplicas(t, replicas, now)
+
+			ownersAfterJoining := requireEachRelayHasOneOwner(t, relays, replicas)
+			require.NotEmpty(t, replicasOwnedRelays(ownersAfterJoining, 2))
+			for relay, owner := range ownersAfterJoining {
+				if owner != 2 {
+					require.Equal(t, ownersAfterLeaving[relay], owner, "relays should only be moved to the replica which joined")
+				}
+			}
+		})
+	})
+}
+
+func TestRelayShards_RelaysOfReplicasWhoseLeasesExpiredAreTakenOver(t *testing.T) {
+	ctx := fixtures.Context(t)
+	registry := adapters.NewMemoryReplicaRegistry()
+	relays := someRelayAddresses(100)
+	now := time.Now()
+
+	replicas := []*app.RelayShards{
+		newRelayShards(registry),
+		newRelayShards(registry),
+	}
+	refreshReplicas(t, replicas, now)
+	requireEachRelayHasOneOwner(t, relays, replicas)
+
+	later := now.Add(time.Hour)
+	err := replicas[0].Refresh(ctx, later)
+	require.NoError(t, err)
+	require.Equal(t, relays, replicas[0].Owned(relays))
+}
+
+func newRelayShards(registry app.ReplicaRegistry) *app.RelayShards {
+	return app.NewRelayShards(registry, app.NewRandomReplicaID("replica"), logging.NewDevNullLogger())
+}
+
+func refreshReplicas(t *testing.T, replicas []*app.RelayShards, now time.Time) {
+	ctx := fixtures.Context(t)
+
+	// Replicas which refreshed earlier wouldn't know about replicas which
+	// refreshed after them.
+	for i := 0; i < 2; i++ {
+		for _, replica := range replicas {
+			err := replica.Refresh(ctx, now)
+			require.NoError(t, err)
+		}
+	}
+}
+
+// requireEachRelayHasOneOwner returns indexes of replicas which own each relay.
+func requireEachRelayHasOneOwner(t *testing.T, relays []domain.RelayAddress, replicas []*app.RelayShards) map[domain.RelayAddress]int {
+	owners := make(map[domain.RelayAddress]int)
+	for i, replica := range replicas {
+		for _, relay := range replica.Owned(relays) {
+			_, ok := owners[relay]
+			require.False(t, ok, "relay '%s' is owned by more than one replica", relay.String())
+			owners[relay] = i
+		}
+	}
+	require.Len(t, owners, len(relays))
+	return owners
+}
+
+func replicasOwnedRelays(owners map[domain.RelayAddress]int, replica int) []domain.RelayAddress {
+	var result []domain.RelayAddress
+	for relay, owner := range owners {
+		if owner == replica {
+			result = append(result, relay)
+		}
+	}
+	return result
+}
+
+func someRelayAddresses(n int) []domain.RelayAddress {
+	var result []domain.RelayAddress
+	for i := 0; i < n; i++ {
+		result = append(result, fixtures.SomeRelayAddress())
+	}
+	return result
+}
@@ -0,0 +1,212 @@
+package app
+
+import (
+	"sort"
+
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	// Used if relays don't advertise their limitations.
+	defaultRelayMaxSubscriptions = 10
+	defaultRelayMaxFilters       = 10
+	defaultRelayMaxMessageLength = 64 * 1024
+
+	maxPublicKeysPerFilter = 500
+
+	// reqOverheadLength is a rough upper bound of the length of a REQ
+	// message which doesn't contain any public keys.
+	reqOverheadLength = 1024
+
+	// publicKeyInFilterLength is the length of a hex encoded public key
+	// surrounded by quotes and followed by a comma.
+	publicKeyInFilterLength = 67
+)
+
+// subscriptionPlan describes how public keys should be split into
+// subscriptions so that the limitations of a relay are not exceeded. Each
+// batch of public keys uses the same filters which are sent using one or more
+// REQ messages.
+type subscriptionPlan struct {
+	filtersPerRequest  int
+	requestsPerBatch   int
+	publicKeysPerBatch int
+	maxBatches         int
+}
+
+func newSubscriptionPlan(limitations domain.RelayLimitations, filtersPerBatch int) subscriptionPlan {
+	maxSubscriptions, ok := limitations.MaxSubscriptions()
+	if !ok {
+		maxSubscriptions = defaultRelayMaxSubscriptions
+	}
+
+	maxFilters, ok := limitations.MaxFilters()
+	if !ok {
+		maxFilters = defaultRelayMaxFilters
+	}
+
+	maxMessageLength, ok := limitations.MaxMessageLength()
+	if !ok {
+		maxMessageLength = defaultRelayMaxMessageLength
+	}
+
+	filtersPerRequest := filtersPerBatch
+	if filtersPerRequest > maxFilters {
+		filtersPerRequest = maxFilters
+	}
+	requestsPerBatch := (filtersPerBatch + filtersPerRequest - 1) / filtersPerRequest
+
+	publicKeysPerBatch := (maxMessageLength - reqOverheadLength) / (publicKeyInFilterLength * filtersPerRequest)
+	if publicKeysPerBatch > maxPublicKeysPerFilter {
+		publicKeysPerBatch = maxPublicKeysPerFilter
+	}
+	if publicKeysPerBatch < 1 {
+		publicKeysPerBatch = 1
+	}
+
+	maxBatches := maxSubscriptions / requestsPerBatch
+	if maxBatches < 1 {
+		maxBatches = 1
+	}
+
+	return subscriptionPlan{
+		filtersPerRequest:  filtersPerRequest,
+		requestsPerBatch:   requestsPerBatch,
+		publicKeysPerBatch: publicKeysPerBatch,
+		maxBatches:         maxBatches,
+	}
+}
+
+type subscriptionBatch struct {
+	id         int
+	publicKeys []domain.PublicKey
+}
+
+type subscriptionBatchesUpdate struct {
+	// changed batches have to be (re)sent
+	changed []subscriptionBatch
+
+	// closed batches have to be closed
+	closed []int
+
+	// skipped is the number of public keys which didn't fit in any batch
+	skipped int
+}
+
+// subscriptionBatches packs public keys into batches. Batches are updated
+// incrementally so that only batches which contain public keys which were
+// added or removed have to be sent again.
+type subscriptionBatches struct {
+	batches    map[int]*internal.Set[domain.PublicKey]
+	keyToBatch map[domain.PublicKey]int
+	nextID     int
+}
+
+func newSubscriptionBatches() *subscriptionBatches {
+	return &subscriptionBatches{
+		batches:    make(map[int]*internal.Set[domain.PublicKey]),
+		keyToBatch: make(map[domain.PublicKey]int),
+	}
+}
+
+func (b *subscriptionBatches) Update(publicKeys *internal.Set[domain.PublicKey], plan subscriptionPlan) subscriptionBatchesUpdate {
+	changed := internal.NewEmptySet[int]()
+	var closed []int
+	var pending []domain.PublicKey
+
+	for publicKey, id := range b.keyToBatch {
+		if !publicKeys.Contains(publicKey) {
+			b.remove(publicKey, id)
+			changed.Put(id)
+		}
+	}
+
+	for _, id := range b.ids() {
+		batch := b.batches[id]
+		if excess := batch.Len() - plan.publicKeysPerBatch; excess > 0 {
+			for _, publicKey := range sortPublicKeys(batch.List())[:excess] {
+				b.remove(publicKey, id)
+				pending = append(pending, publicKey)
+			}
+			changed.Put(id)
+		}
+	}
+
+	for _, publicKey := range publicKeys.List() {
+		if _, ok := b.keyToBatch[publicKey]; !ok {
+			pending = append(pending, publicKey)
+		}
+	}
+	pending = sortPublicKeys(pending)
+
+	for _, id := range b.ids() {
+		for len(pending) > 0 && b.batches[id].Len() < plan.publicKeysPerBatch {
+			b.put(pending[0], id)
+			pending = pending[1:]
+			changed.Put(id)
+		}
+	}
+
+	for len(pending) > 0 && len(b.batches) < plan.maxBatches {
+		id := b.nextID
+		b.nextID++
+		b.batches[id] = internal.NewEmptySet[domain.PublicKey]()
+
+		for len(pending) > 0 && b.batches[id].Len() < plan.publicKeysPerBatch {
+			b.put(pending[0], id)
+			pending = pending[1:]
+		}
+		changed.Put(id)
+	}
+
+	for _, id := range b.ids() {
+		if b.batches[id].Len() == 0 {
+			delete(b.batches, id)
+			changed.Delete(id)
+			closed = append(closed, id)
+		}
+	}
+
+	update := subscriptionBatchesUpdate{
+		closed:  closed,
+		skipped: len(pending),
+	}
+
+	changedIDs := changed.List()
+	sort.Ints(changedIDs)
+	for _, id := range changedIDs {
+		update.changed = append(update.changed, subscriptionBatch{
+			id:         id,
+			publicKeys: sortPublicKeys(b.batches[id].List()),
+		})
+	}
+
+	return update
+}
+
+func (b *subscriptionBatches) put(publicKey domain.PublicKey, id int) {
+	b.batches[id].Put(publicKey)
+	b.keyToBatch[publicKey] = id
+}
+
+func (b *subscriptionBatches) remove(publicKey domain.PublicKey, id int) {
+	b.batches[id].Delete(publicKey)
+	delete(b.keyToBatch, publicKey)
+}
+
+func (b *subscriptionBatches) ids() []int {
+	var ids []int
+	for id := range b.batches {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func sortPublicKeys(publicKeys []domain.PublicKey) []domain.PublicKey {
+	sort.Slice(publicKeys, func(i, j int) bool {
+		return publicKeys[i].Hex() < publicKeys[j].Hex()
+	})
+	return publicKeys
+}
@@ -0,0 +1,169 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSubscriptionPlan(t *testing.T) {
+	testCases := []struct {
+		Name             string
+		MaxMessageLength int
+		MaxSubscriptions int
+		MaxFilters       int
+
+		ExpectedPlan subscriptionPlan
+	}{
+		{
+			Name: "defaults",
+			ExpectedPlan: subscriptionPlan{
+				filtersPerRequest:  3,
+				requestsPerBatch:   1,
+				publicKeysPerBatch: (defaultRelayMaxMessageLength - reqOverheadLength) / (3 * publicKeyInFilterLength),
+				maxBatches:         defaultRelayMaxSubscriptions,
+			},
+		},
+		{
+			Name:             "long_messages",
+			MaxMessageLength: 1024 * 1024,
+			MaxSubscriptions: 50,
+			ExpectedPlan: subscriptionPlan{
+				filtersPerRequest:  3,
+				requestsPerBatch:   1,
+				publicKeysPerBatch: maxPublicKeysPerFilter,
+				maxBatches:         50,
+			},
+		},
+		{
+			Name:             "filters_split_between_requests",
+			MaxMessageLength: 8192,
+			MaxSubscriptions: 5,
+			MaxFilters:       2,
+			ExpectedPlan: subscriptionPlan{
+				filtersPerRequest:  2,
+				requestsPerBatch:   2,
+				publicKeysPerBatch: (8192 - reqOverheadLength) / (2 * publicKeyInFilterLength),
+				maxBatches:         2,
+			},
+		},
+		{
+			Name:             "very_strict_limits",
+			MaxMessageLength: 100,
+			MaxSubscriptions: 1,
+			MaxFilters:       1,
+			ExpectedPlan: subscriptionPlan{
+				filtersPerRequest:  1,
+				requestsPerBatch:   3,
+				publicKeysPerBatch: 1,
+				maxBatches:         1,
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			limitations, err := domain.NewRelayLimitations(testCase.MaxMessageLength, testCase.MaxSubscriptions, testCase.MaxFilters, false, false)
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.ExpectedPlan, newSubscriptionPlan(limitations, 3))
+		})
+	}
+}
+
+func TestSubscriptionBatches_OnlyBatchesWhichChangedAreReturned(t *testing.T) {
+	plan := subscriptionPlan{publicKeysPerBatch: 4, maxBatches: 5}
+	batches := newSubscriptionBatches()
+
+	publicKeys := somePublicKeys(10)
+	update := batches.Update(internal.NewSet(publicKeys), plan)
+	require.Empty(t, update.closed)
+	require.Zero(t, update.skipped)
+	require.Equal(t, []int{4, 4, 2}, batchSizes(update.changed))
+	requireBatchesContain(t, update.changed, publicKeys)
+
+	newPublicKey, _ := fixtures.SomeKeyPair()
+	publicKeys = append(publicKeys, newPublicKey)
+	update = batches.Update(internal.NewSet(publicKeys), plan)
+	require.Empty(t, update.closed)
+	require.Len(t, update.changed, 1)
+	require.Equal(t, 2, update.changed[0].id, "the batch which had space should be reused")
+	require.Contains(t, update.changed[0].publicKeys, newPublicKey)
+
+	update = batches.Update(internal.NewSet(publicKeys), plan)
+	require.Empty(t, update.changed)
+	require.Empty(t, update.closed)
+
+	removedPublicKey := publicKeys[0]
+	publicKeys = publicKeys[1:]
+	update = batches.Update(internal.NewSet(publicKeys), plan)
+	require.Empty(t, update.closed)
+	require.Len(t, update.changed, 1)
+	require.NotContains(t, update.changed[0].publicKeys, removedPublicKey)
+}
+
+func TestSubscriptionBatches_EmptyBatchesAreClosed(t *testing.T) {
+	plan := subscriptionPlan{publicKeysPerBatch: 2, maxBatches: 5}
+	batches := newSubscriptionBatches()
+
+	publicKeys := somePublicKeys(4)
+	update := batches.Update(internal.NewSet(publicKeys), plan)
+	require.Len(t, update.changed, 2)
+
+	var remaining []domain.PublicKey
+	remaining = append(remaining, update.changed[0].publicKeys...)
+
+	update = batches.Update(internal.NewSet(remaining), plan)
+	require.Empty(t, update.changed)
+	require.Equal(t, []int{1}, update.closed)
+}
+
+func TestSubscriptionBatches_PublicKeysWhichDontFitAreSkipped(t *testing.T) {
+	plan := subscriptionPlan{publicKeysPerBatch: 2, maxBatches: 2}
+	batches := newSubscriptionBatches()
+
+	update := batches.Update(internal.NewSet(somePublicKeys(5)), plan)
+	require.Equal(t, []int{2, 2}, batchSizes(update.changed))
+	require.Equal(t, 1, update.skipped)
+}
+
+func TestSubscriptionBatches_BatchesAreSplitIfTheyAreTooLarge(t *testing.T) {
+	batches := newSubscriptionBatches()
+
+	publicKeys := somePublicKeys(6)
+	update := batches.Update(internal.NewSet(publicKeys), subscriptionPlan{publicKeysPerBatch: 10, maxBatches: 5})
+	require.Equal(t, []int{6}, batchSizes(update.changed))
+
+	update = batches.Update(internal.NewSet(publicKeys), subscriptionPlan{publicKeysPerBatch: 4, maxBatches: 5})
+	require.Equal(t, []int{4, 2}, batchSizes(update.changed))
+	require.Zero(t, update.skipped)
+	requireBatchesContain(t, update.changed, publicKeys)
+}
+
+func somePublicKeys(n int) []domain.PublicKey {
+	var result []domain.PublicKey
+	for i := 0; i < n; i++ {
+		publicKey, _ := fixtures.SomeKeyPair()
+		result = append(result, publicKey)
+	}
+	return result
+}
+
+func batchSizes(batches []subscriptionBatch) []int {
+	var result []int
+	for _, batch := range batches {
+		result = append(result, len(batch.publicKeys))
+	}
+	return result
+}
+
+func requireBatchesContain(t *testing.T, batches []subscriptionBatch, publicKeys []domain.PublicKey) {
+	var result []domain.PublicKey
+	for _, batch := range batches {
+		result = append(result, batch.publicKeys...)
+	}
+	require.ElementsMatch(t, publicKeys, result)
+}
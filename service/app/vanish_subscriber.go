@@ -13,15 +13,13 @@ import (
 )
 
 type VanishSubscriber struct {
-	rdb                 *redis.Client
-	transactionProvider TransactionProvider
-	queries             Queries
-	logger              logging.Logger
+	rdb             *redis.Client
+	deletePublicKey *DeletePublicKeyHandler
+	logger          logging.Logger
 }
 
 func NewVanishSubscriber(
-	transactionProvider TransactionProvider,
-	queries Queries,
+	deletePublicKey *DeletePublicKeyHandler,
 	logger logging.Logger,
 ) *VanishSubscriber {
 	log := logger.New("vanishSubscriber")
@@ -35,10 +33,9 @@ func NewVanishSubscriber(
 	rdb := redis.NewClient(options)
 
 	return &VanishSubscriber{
-		rdb:                 rdb,
-		transactionProvider: transactionProvider,
-		queries:             queries,
-		logger:              log,
+		rdb:             rdb,
+		deletePublicKey: deletePublicKey,
+		logger:          log,
 	}
 }
 
@@ -96,9 +93,9 @@ func (f *VanishSubscriber) Run(ctx context.Context) error {
 						break
 					}
 
-					err = f.removePubkeyInfo(ctx, pubkey)
+					err = f.deletePublicKey.Handle(ctx, NewDeletePublicKey(pubkey))
 					if err != nil {
-						f.logger.Error().WithField("streamId", streamID).Message("Failed to process entry")
+						f.logger.Error().WithField("streamId", streamID).WithError(err).Message("Failed to process entry")
 						continue
 					}
 
@@ -115,30 +112,3 @@ func (f *VanishSubscriber) Run(ctx context.Context) error {
 		}
 	}
 }
-
-// Deletes the public key and associated tokens, events, and notifications
-func (f *VanishSubscriber) removePubkeyInfo(ctx context.Context, pubkey domain.PublicKey) error {
-	err := f.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
-		if err := adapters.PublicKeys.DeleteByPublicKey(ctx, pubkey); err != nil {
-			f.logger.Error().WithField("pubkey", pubkey.Hex()).WithError(err).Message("Error deleting public key")
-			return err
-		}
-
-		f.logger.Debug().WithField("pubkey", pubkey.Hex()).Message("Successfully deleted public key and associated tokens")
-		return nil
-	})
-
-	if err != nil {
-		return err
-	}
-
-	return f.transactionProvider.Transact(ctx, func(ctx context.Context, adapters Adapters) error {
-		if err := adapters.Events.DeleteByPublicKey(ctx, pubkey); err != nil {
-			f.logger.Error().WithField("pubkey", pubkey.Hex()).WithError(err).Message("Error deleting events and notifications for public key")
-			return err
-		}
-
-		f.logger.Debug().WithField("pubkey", pubkey.Hex()).Message("Successfully deleted events and notifications for public key")
-		return nil
-	})
-}
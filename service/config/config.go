@@ -2,8 +2,11 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
+	"time"
 
 	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
 	"github.com/planetary-social/go-notification-service/internal/logging"
 )
 
@@ -20,6 +23,75 @@ var (
 	EnvironmentDevelopment = Environment{"development"}
 )
 
+type StorageBackend struct {
+	s string
+}
+
+func (s StorageBackend) String() string {
+	return s.s
+}
+
+var (
+	StorageBackendFirestore = StorageBackend{"firestore"}
+	StorageBackendPostgres  = StorageBackend{"postgres"}
+	StorageBackendBolt      = StorageBackend{"bolt"}
+)
+
+type EventCacheBackend struct {
+	s string
+}
+
+func (b EventCacheBackend) String() string {
+	return b.s
+}
+
+var (
+	EventCacheBackendMemory = EventCacheBackend{"memory"}
+	EventCacheBackendRedis  = EventCacheBackend{"redis"}
+)
+
+type ReplicaRegistryBackend struct {
+	s string
+}
+
+func (b ReplicaRegistryBackend) String() string {
+	return b.s
+}
+
+var (
+	ReplicaRegistryBackendMemory = ReplicaRegistryBackend{"memory"}
+	ReplicaRegistryBackendRedis  = ReplicaRegistryBackend{"redis"}
+)
+
+// RateLimit configures a token bucket which is refilled at the given rate and
+// holds at most burst tokens.
+type RateLimit struct {
+	perSecond float64
+	burst     int
+}
+
+func NewRateLimit(perSecond float64, burst int) (RateLimit, error) {
+	if perSecond <= 0 {
+		return RateLimit{}, errors.New("rate must be positive")
+	}
+	if burst <= 0 {
+		return RateLimit{}, errors.New("burst must be positive")
+	}
+	return RateLimit{perSecond: perSecond, burst: burst}, nil
+}
+
+func (r RateLimit) PerSecond() float64 {
+	return r.perSecond
+}
+
+func (r RateLimit) Burst() int {
+	return r.burst
+}
+
+func (r RateLimit) IsZero() bool {
+	return r == RateLimit{}
+}
+
 type Config struct {
 	nostrListenAddress   string
 	metricsListenAddress string
@@ -37,6 +109,41 @@ type Config struct {
 	googlePubSubEnabled         bool
 	googlePubSubProjectID       string
 	googlePubSubCredentialsJSON []byte
+
+	fcmEnabled         bool
+	fcmProjectID       string
+	fcmCredentialsJSON []byte
+
+	webPushEnabled         bool
+	webPushVAPIDPublicKey  string
+	webPushVAPIDPrivateKey string
+	webPushSubscriber      string
+
+	storageBackend StorageBackend
+	postgresURL    string
+	boltPath       string
+
+	metadataRelays []string
+
+	ipRateLimit                   RateLimit
+	publicKeyRateLimit            RateLimit
+	connectionRateLimit           RateLimit
+	trustedProxies                []netip.Prefix
+	maxSubscriptionsPerConnection int
+	maxFiltersPerSubscription     int
+
+	adminListenAddress string
+	adminToken         string
+
+	eventCacheBackend EventCacheBackend
+	eventCacheTTL     time.Duration
+	redisURL          string
+
+	replicaRegistryBackend ReplicaRegistryBackend
+
+	downloaderMaxLookback   time.Duration
+	downloaderMaxCursorAge  time.Duration
+	downloaderCursorOverlap time.Duration
 }
 
 func NewConfig(
@@ -52,20 +159,72 @@ func NewConfig(
 	googlePubSubEnabled bool,
 	googlePubSubProjectID string,
 	googlePubSubCredentialsJSON []byte,
+	fcmEnabled bool,
+	fcmProjectID string,
+	fcmCredentialsJSON []byte,
+	webPushEnabled bool,
+	webPushVAPIDPublicKey string,
+	webPushVAPIDPrivateKey string,
+	webPushSubscriber string,
+	storageBackend StorageBackend,
+	postgresURL string,
+	boltPath string,
+	metadataRelays []string,
+	ipRateLimit RateLimit,
+	publicKeyRateLimit RateLimit,
+	connectionRateLimit RateLimit,
+	trustedProxies []netip.Prefix,
+	maxSubscriptionsPerConnection int,
+	maxFiltersPerSubscription int,
+	adminListenAddress string,
+	adminToken string,
+	eventCacheBackend EventCacheBackend,
+	eventCacheTTL time.Duration,
+	redisURL string,
+	replicaRegistryBackend ReplicaRegistryBackend,
+	downloaderMaxLookback time.Duration,
+	downloaderMaxCursorAge time.Duration,
+	downloaderCursorOverlap time.Duration,
 ) (Config, error) {
 	c := Config{
-		nostrListenAddress:          nostrListenAddress,
-		metricsListenAddress:        metricsListenAddress,
-		firestoreProjectID:          firestoreProjectID,
-		firestoreCredentialsJSON:    firestoreCredentialsJSON,
-		apnsTopic:                   apnsTopic,
-		apnsCertificatePath:         apnsCertificatePath,
-		apnsCertificatePassword:     apnsCertificatePassword,
-		environment:                 environment,
-		logLevel:                    logLevel,
-		googlePubSubEnabled:         googlePubSubEnabled,
-		googlePubSubProjectID:       googlePubSubProjectID,
-		googlePubSubCredentialsJSON: googlePubSubCredentialsJSON,
+		nostrListenAddress:            nostrListenAddress,
+		metricsListenAddress:          metricsListenAddress,
+		firestoreProjectID:            firestoreProjectID,
+		firestoreCredentialsJSON:      firestoreCredentialsJSON,
+		apnsTopic:                     apnsTopic,
+		apnsCertificatePath:           apnsCertificatePath,
+		apnsCertificatePassword:       apnsCertificatePassword,
+		environment:                   environment,
+		logLevel:                      logLevel,
+		googlePubSubEnabled:           googlePubSubEnabled,
+		googlePubSubProjectID:         googlePubSubProjectID,
+		googlePubSubCredentialsJSON:   googlePubSubCredentialsJSON,
+		fcmEnabled:                    fcmEnabled,
+		fcmProjectID:                  fcmProjectID,
+		fcmCredentialsJSON:            fcmCredentialsJSON,
+		webPushEnabled:                webPushEnabled,
+		webPushVAPIDPublicKey:         webPushVAPIDPublicKey,
+		webPushVAPIDPrivateKey:        webPushVAPIDPrivateKey,
+		webPushSubscriber:             webPushSubscriber,
+		storageBackend:                storageBackend,
+		postgresURL:                   postgresURL,
+		boltPath:                      boltPath,
+		metadataRelays:                metadataRelays,
+		ipRateLimit:                   ipRateLimit,
+		publicKeyRateLimit:            publicKeyRateLimit,
+		connectionRateLimit:           connectionRateLimit,
+		trustedProxies:                trustedProxies,
+		maxSubscriptionsPerConnection: maxSubscriptionsPerConnection,
+		maxFiltersPerSubscription:     maxFiltersPerSubscription,
+		adminListenAddress:            adminListenAddress,
+		adminToken:                    adminToken,
+		eventCacheBackend:             eventCacheBackend,
+		eventCacheTTL:                 eventCacheTTL,
+		redisURL:                      redisURL,
+		replicaRegistryBackend:        replicaRegistryBackend,
+		downloaderMaxLookback:         downloaderMaxLookback,
+		downloaderMaxCursorAge:        downloaderMaxCursorAge,
+		downloaderCursorOverlap:       downloaderCursorOverlap,
 	}
 
 	c.setDefaults()
@@ -124,6 +283,135 @@ func (c *Config) GooglePubSubCredentialsJSON() []byte {
 	return c.googlePubSubCredentialsJSON
 }
 
+func (c *Config) FCMEnabled() bool {
+	return c.fcmEnabled
+}
+
+func (c *Config) FCMProjectID() string {
+	return c.fcmProjectID
+}
+
+func (c *Config) FCMCredentialsJSON() []byte {
+	return c.fcmCredentialsJSON
+}
+
+func (c *Config) WebPushEnabled() bool {
+	return c.webPushEnabled
+}
+
+func (c *Config) WebPushVAPIDPublicKey() string {
+	return c.webPushVAPIDPublicKey
+}
+
+func (c *Config) WebPushVAPIDPrivateKey() string {
+	return c.webPushVAPIDPrivateKey
+}
+
+func (c *Config) WebPushSubscriber() string {
+	return c.webPushSubscriber
+}
+
+func (c *Config) StorageBackend() StorageBackend {
+	return c.storageBackend
+}
+
+func (c *Config) PostgresURL() string {
+	return c.postgresURL
+}
+
+func (c *Config) BoltPath() string {
+	return c.boltPath
+}
+
+// MetadataRelays are used to look up the metadata of users who aren't
+// registered e.g. to display their names in notifications.
+func (c *Config) MetadataRelays() []string {
+	return internal.CopySlice(c.metadataRelays)
+}
+
+// IPRateLimit limits the number of messages sent by all connections from a
+// single IP address.
+func (c *Config) IPRateLimit() RateLimit {
+	return c.ipRateLimit
+}
+
+// PublicKeyRateLimit limits the number of messages sent by all connections
+// authenticated as a single public key.
+func (c *Config) PublicKeyRateLimit() RateLimit {
+	return c.publicKeyRateLimit
+}
+
+// ConnectionRateLimit limits the number of messages sent over a single
+// connection.
+func (c *Config) ConnectionRateLimit() RateLimit {
+	return c.connectionRateLimit
+}
+
+// TrustedProxies are addresses of load balancers and proxies which are
+// trusted to report addresses of clients using the X-Forwarded-For header.
+func (c *Config) TrustedProxies() []netip.Prefix {
+	return internal.CopySlice(c.trustedProxies)
+}
+
+func (c *Config) MaxSubscriptionsPerConnection() int {
+	return c.maxSubscriptionsPerConnection
+}
+
+func (c *Config) MaxFiltersPerSubscription() int {
+	return c.maxFiltersPerSubscription
+}
+
+func (c *Config) AdminListenAddress() string {
+	return c.adminListenAddress
+}
+
+// AdminToken has to be sent by operators to use the admin API. The admin API
+// is disabled if the token is empty.
+func (c *Config) AdminToken() string {
+	return c.adminToken
+}
+
+// EventCacheBackend selects where we remember which events were already
+// saved. Replicas should share a Redis cache to avoid checking the storage for
+// the same events over and over.
+func (c *Config) EventCacheBackend() EventCacheBackend {
+	return c.eventCacheBackend
+}
+
+// EventCacheTTL is how long saved events are remembered for.
+func (c *Config) EventCacheTTL() time.Duration {
+	return c.eventCacheTTL
+}
+
+func (c *Config) RedisURL() string {
+	return c.redisURL
+}
+
+// ReplicaRegistryBackend selects how replicas of the service find each other
+// to split relays between them. Relays aren't split if only the memory backend
+// is used.
+func (c *Config) ReplicaRegistryBackend() ReplicaRegistryBackend {
+	return c.replicaRegistryBackend
+}
+
+// DownloaderMaxLookback is how far into the past events are downloaded for
+// public keys which events weren't downloaded for yet.
+func (c *Config) DownloaderMaxLookback() time.Duration {
+	return c.downloaderMaxLookback
+}
+
+// DownloaderMaxCursorAge limits how far into the past downloads are resumed
+// for public keys which events were already downloaded before.
+func (c *Config) DownloaderMaxCursorAge() time.Duration {
+	return c.downloaderMaxCursorAge
+}
+
+// DownloaderCursorOverlap is how long before the newest received event
+// downloads are resumed.
+func (c *Config) DownloaderCursorOverlap() time.Duration {
+	return c.downloaderCursorOverlap
+}
+
 func (c *Config) setDefaults() {
 	if c.nostrListenAddress == "" {
 		c.nostrListenAddress = ":8008"
@@ -132,6 +420,65 @@ func (c *Config) setDefaults() {
 	if c.metricsListenAddress == "" {
 		c.metricsListenAddress = ":8009"
 	}
+
+	if c.adminListenAddress == "" {
+		c.adminListenAddress = ":8010"
+	}
+
+	if c.storageBackend == (StorageBackend{}) {
+		c.storageBackend = StorageBackendFirestore
+	}
+
+	if c.eventCacheBackend == (EventCacheBackend{}) {
+		c.eventCacheBackend = EventCacheBackendMemory
+	}
+
+	if c.replicaRegistryBackend == (ReplicaRegistryBackend{}) {
+		c.replicaRegistryBackend = ReplicaRegistryBackendMemory
+	}
+
+	if c.eventCacheTTL == 0 {
+		c.eventCacheTTL = 60 * time.Minute
+	}
+
+	if c.downloaderMaxLookback == 0 {
+		c.downloaderMaxLookback = 24 * time.Hour
+	}
+
+	if c.downloaderMaxCursorAge == 0 {
+		c.downloaderMaxCursorAge = 7 * 24 * time.Hour
+	}
+
+	if c.downloaderCursorOverlap == 0 {
+		c.downloaderCursorOverlap = 10 * time.Minute
+	}
+
+	if len(c.metadataRelays) == 0 {
+		c.metadataRelays = []string{
+			"wss://purplepag.es",
+			"wss://relay.nos.social",
+		}
+	}
+
+	if c.ipRateLimit.IsZero() {
+		c.ipRateLimit = RateLimit{perSecond: 20, burst: 100}
+	}
+
+	if c.publicKeyRateLimit.IsZero() {
+		c.publicKeyRateLimit = RateLimit{perSecond: 5, burst: 20}
+	}
+
+	if c.connectionRateLimit.IsZero() {
+		c.connectionRateLimit = RateLimit{perSecond: 10, burst: 50}
+	}
+
+	if c.maxSubscriptionsPerConnection == 0 {
+		c.maxSubscriptionsPerConnection = 20
+	}
+
+	if c.maxFiltersPerSubscription == 0 {
+		c.maxFiltersPerSubscription = 10
+	}
 }
 
 func (c *Config) validate() error {
@@ -143,8 +490,57 @@ func (c *Config) validate() error {
 		return errors.New("missing metrics listen address")
 	}
 
-	if c.firestoreProjectID == "" {
-		return errors.New("missing firestore project id")
+	switch c.storageBackend {
+	case StorageBackendFirestore:
+		if c.firestoreProjectID == "" {
+			return errors.New("missing firestore project id")
+		}
+	case StorageBackendPostgres:
+		if c.postgresURL == "" {
+			return errors.New("missing postgres url")
+		}
+	case StorageBackendBolt:
+		if c.boltPath == "" {
+			return errors.New("missing bolt path")
+		}
+	default:
+		return fmt.Errorf("unknown storage backend '%+v'", c.storageBackend)
+	}
+
+	switch c.eventCacheBackend {
+	case EventCacheBackendMemory:
+	case EventCacheBackendRedis:
+		if c.redisURL == "" {
+			return errors.New("missing redis url")
+		}
+	default:
+		return fmt.Errorf("unknown event cache backend '%+v'", c.eventCacheBackend)
+	}
+
+	switch c.replicaRegistryBackend {
+	case ReplicaRegistryBackendMemory:
+	case ReplicaRegistryBackendRedis:
+		if c.redisURL == "" {
+			return errors.New("missing redis url")
+		}
+	default:
+		return fmt.Errorf("unknown replica registry backend '%+v'", c.replicaRegistryBackend)
+	}
+
+	if c.eventCacheTTL < 0 {
+		return errors.New("event cache ttl can't be negative")
+	}
+
+	if c.downloaderMaxLookback < 0 {
+		return errors.New("downloader max lookback can't be negative")
+	}
+
+	if c.downloaderMaxCursorAge < 0 {
+		return errors.New("downloader max cursor age can't be negative")
+	}
+
+	if c.downloaderCursorOverlap < 0 {
+		return errors.New("downloader cursor overlap can't be negative")
 	}
 
 	if c.apnsTopic == "" {
@@ -172,5 +568,37 @@ func (c *Config) validate() error {
 		}
 	}
 
+	if c.fcmEnabled {
+		if c.fcmProjectID == "" {
+			return errors.New("missing FCM project id")
+		}
+
+		if len(c.fcmCredentialsJSON) == 0 {
+			return errors.New("missing FCM credentials json")
+		}
+	}
+
+	if c.maxSubscriptionsPerConnection < 0 {
+		return errors.New("max subscriptions per connection can't be negative")
+	}
+
+	if c.maxFiltersPerSubscription < 0 {
+		return errors.New("max filters per subscription can't be negative")
+	}
+
+	if c.webPushEnabled {
+		if c.webPushVAPIDPublicKey == "" {
+			return errors.New("missing web push VAPID public key")
+		}
+
+		if c.webPushVAPIDPrivateKey == "" {
+			return errors.New("missing web push VAPID private key")
+		}
+
+		if c.webPushSubscriber == "" {
+			return errors.New("missing web push subscriber")
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,65 @@
+package domain
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/boreq/errors"
+)
+
+// AuthenticationMaxClockSkew is how far the creation time of an authentication
+// event can be from the current time.
+const AuthenticationMaxClockSkew = 10 * time.Minute
+
+var (
+	tagRelay     = MustNewEventTagName("relay")
+	tagChallenge = MustNewEventTagName("challenge")
+)
+
+// Authentication is created from events of kind 22242 which clients send in
+// response to an authentication challenge, see NIP-42. The relay tag is
+// compared with the host the client connected to.
+type Authentication struct {
+	publicKey PublicKey
+}
+
+func NewAuthenticationFromEvent(event Event, challenge string, host string, now time.Time) (Authentication, error) {
+	if event.Kind() != EventKindClientAuthentication {
+		return Authentication{}, fmt.Errorf("invalid event kind '%d'", event.Kind().Int())
+	}
+
+	if skew := now.Sub(event.CreatedAt()).Abs(); skew > AuthenticationMaxClockSkew {
+		return Authentication{}, fmt.Errorf("event was created too far from the current time (%s)", skew)
+	}
+
+	challengeTag, ok := findTag(event.Tags(), tagChallenge)
+	if !ok {
+		return Authentication{}, errors.New("missing challenge tag")
+	}
+
+	if challengeTag.FirstValue() != challenge {
+		return Authentication{}, errors.New("challenge doesn't match")
+	}
+
+	relayTag, ok := findTag(event.Tags(), tagRelay)
+	if !ok {
+		return Authentication{}, errors.New("missing relay tag")
+	}
+
+	relay, err := url.Parse(relayTag.FirstValue())
+	if err != nil {
+		return Authentication{}, errors.Wrap(err, "error parsing the relay url")
+	}
+
+	if !strings.EqualFold(relay.Host, host) {
+		return Authentication{}, fmt.Errorf("relay '%s' doesn't match host '%s'", relayTag.FirstValue(), host)
+	}
+
+	return Authentication{publicKey: event.PubKey()}, nil
+}
+
+func (a Authentication) PublicKey() PublicKey {
+	return a.publicKey
+}
@@ -0,0 +1,142 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAuthenticationFromEvent(t *testing.T) {
+	const (
+		challenge = "some-challenge"
+		host      = "notifications.example.com"
+	)
+
+	now := time.Now()
+
+	testCases := []struct {
+		Name string
+
+		Kind      domain.EventKind
+		CreatedAt time.Time
+		Tags      nostr.Tags
+
+		ExpectedError bool
+	}{
+		{
+			Name:      "valid",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"relay", "wss://notifications.example.com"},
+				{"challenge", challenge},
+			},
+		},
+		{
+			Name:      "host_is_compared_case_insensitively",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"relay", "wss://Notifications.Example.com/"},
+				{"challenge", challenge},
+			},
+		},
+		{
+			Name:      "invalid_kind",
+			Kind:      domain.EventKindNote,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"relay", "wss://notifications.example.com"},
+				{"challenge", challenge},
+			},
+			ExpectedError: true,
+		},
+		{
+			Name:      "too_old",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now.Add(-domain.AuthenticationMaxClockSkew - time.Minute),
+			Tags: nostr.Tags{
+				{"relay", "wss://notifications.example.com"},
+				{"challenge", challenge},
+			},
+			ExpectedError: true,
+		},
+		{
+			Name:      "from_the_future",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now.Add(domain.AuthenticationMaxClockSkew + time.Minute),
+			Tags: nostr.Tags{
+				{"relay", "wss://notifications.example.com"},
+				{"challenge", challenge},
+			},
+			ExpectedError: true,
+		},
+		{
+			Name:      "other_challenge",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"relay", "wss://notifications.example.com"},
+				{"challenge", "other-challenge"},
+			},
+			ExpectedError: true,
+		},
+		{
+			Name:      "missing_challenge",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"relay", "wss://notifications.example.com"},
+			},
+			ExpectedError: true,
+		},
+		{
+			Name:      "other_relay",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"relay", "wss://relay.example.com"},
+				{"challenge", challenge},
+			},
+			ExpectedError: true,
+		},
+		{
+			Name:      "missing_relay",
+			Kind:      domain.EventKindClientAuthentication,
+			CreatedAt: now,
+			Tags: nostr.Tags{
+				{"challenge", challenge},
+			},
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			publicKey, secretKey := fixtures.SomeKeyPair()
+
+			libevent := nostr.Event{
+				CreatedAt: nostr.Timestamp(testCase.CreatedAt.Unix()),
+				Kind:      testCase.Kind.Int(),
+				Tags:      testCase.Tags,
+			}
+			err := libevent.Sign(secretKey)
+			require.NoError(t, err)
+
+			event, err := domain.NewEvent(libevent)
+			require.NoError(t, err)
+
+			authentication, err := domain.NewAuthenticationFromEvent(event, challenge, host, now)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, publicKey, authentication.PublicKey())
+		})
+	}
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/boreq/errors"
@@ -30,14 +31,11 @@ func NewEventFromRaw(raw []byte) (Event, error) {
 	return NewEvent(libevent)
 }
 
+// NewEvent recomputes the id of the event and verifies its signature, if
+// either check fails an *InvalidEventError is returned.
 func NewEvent(libevent nostr.Event) (Event, error) {
-	ok, err := libevent.CheckSignature()
-	if err != nil {
-		return Event{}, errors.Wrap(err, "error checking signature")
-	}
-
-	if !ok {
-		return Event{}, errors.New("invalid signature")
+	if err := verifyEvent(libevent); err != nil {
+		return Event{}, errors.Wrap(err, "error verifying the event")
 	}
 
 	id, err := NewEventId(libevent.ID)
@@ -84,6 +82,25 @@ func NewEvent(libevent nostr.Event) (Event, error) {
 	}, nil
 }
 
+func verifyEvent(libevent nostr.Event) error {
+	if libevent.GetID() != libevent.ID {
+		return NewInvalidEventError(InvalidEventReasonID, nil)
+	}
+
+	// CheckSignature hashes the serialized event again instead of using the id
+	// so it can't be relied on to verify the id.
+	ok, err := libevent.CheckSignature()
+	if err != nil {
+		return NewInvalidEventError(InvalidEventReasonSignature, err)
+	}
+
+	if !ok {
+		return NewInvalidEventError(InvalidEventReasonSignature, nil)
+	}
+
+	return nil
+}
+
 func (e Event) Id() EventId {
 	return e.id
 }
@@ -131,3 +148,44 @@ func (e Event) Raw() []byte {
 func (e Event) String() string {
 	return string(e.Raw())
 }
+
+type InvalidEventReason struct {
+	s string
+}
+
+func (r InvalidEventReason) String() string {
+	return r.s
+}
+
+var (
+	InvalidEventReasonID        = InvalidEventReason{"id"}
+	InvalidEventReasonSignature = InvalidEventReason{"signature"}
+)
+
+// InvalidEventError is returned if the id of an event doesn't match its
+// contents or if its signature can't be verified. Such events must never be
+// processed.
+type InvalidEventError struct {
+	reason InvalidEventReason
+	err    error
+}
+
+func NewInvalidEventError(reason InvalidEventReason, err error) *InvalidEventError {
+	return &InvalidEventError{reason: reason, err: err}
+}
+
+// Reason describes which check failed.
+func (e *InvalidEventError) Reason() InvalidEventReason {
+	return e.reason
+}
+
+func (e *InvalidEventError) Error() string {
+	if e.err != nil {
+		return fmt.Sprintf("invalid event %s: %s", e.reason, e.err)
+	}
+	return fmt.Sprintf("invalid event %s", e.reason)
+}
+
+func (e *InvalidEventError) Unwrap() error {
+	return e.err
+}
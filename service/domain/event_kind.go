@@ -1,24 +1,64 @@
 package domain
 
 import (
+	"time"
+
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/internal"
 )
 
-var eventKindsToDownload = internal.NewSet([]EventKind{EventKindNote})
+var eventKindsToDownload = internal.NewSet([]EventKind{
+	EventKindNote,
+	EventKindReaction,
+	EventKindRepost,
+	EventKindZapReceipt,
+	EventKindEncryptedDirectMessage,
+	EventKindGiftWrap,
+})
 
+// EventKindsToDownload returns kinds of events which are downloaded if they
+// mention the registered public keys. Mute lists and relay lists published by
+// the registered public keys are downloaded as well.
 func EventKindsToDownload() []EventKind {
 	return eventKindsToDownload.List()
 }
 
 func ShouldDownloadEventKind(eventKind EventKind) bool {
-	return eventKindsToDownload.Contains(eventKind)
+	return eventKindsToDownload.Contains(eventKind) || eventKind == EventKindMuteList || eventKind == EventKindRelayList
+}
+
+// giftWrapMaxBackdating is how far into the past timestamps of gift wraps can
+// be randomized to hide when messages were sent, see NIP-59.
+const giftWrapMaxBackdating = 2 * 24 * time.Hour
+
+// MaxBackdating returns how much older than the time when they were published
+// events of the given kind can claim to be.
+func MaxBackdating(eventKind EventKind) time.Duration {
+	if eventKind == EventKindGiftWrap {
+		return giftWrapMaxBackdating
+	}
+	return 0
 }
 
 var (
+	EventKindMetadata               = MustNewEventKind(0)
 	EventKindNote                   = MustNewEventKind(1)
 	EventKindReaction               = MustNewEventKind(7)
+	EventKindRepost                 = MustNewEventKind(6)
 	EventKindEncryptedDirectMessage = MustNewEventKind(4)
+	EventKindZapReceipt             = MustNewEventKind(9735)
+	EventKindGiftWrap               = MustNewEventKind(1059)
+	EventKindMuteList               = MustNewEventKind(10000)
+	EventKindRelayList              = MustNewEventKind(10002)
+
+	// EventKindUnregistration is used by clients to remove push tokens which
+	// they previously registered. Registrations are accepted regardless of
+	// their kind.
+	EventKindUnregistration = MustNewEventKind(12346)
+
+	// EventKindClientAuthentication is used by clients to respond to
+	// authentication challenges, see NIP-42.
+	EventKindClientAuthentication = MustNewEventKind(22242)
 )
 
 type EventKind struct {
@@ -0,0 +1,66 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEvent(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Libevent func(tb testing.TB) nostr.Event
+
+		ExpectedReason *domain.InvalidEventReason
+	}{
+		{
+			Name:     "valid",
+			Libevent: fixtures.SomeLibevent,
+		},
+		{
+			Name:           "tampered_id",
+			Libevent:       fixtures.LibeventWithTamperedID,
+			ExpectedReason: &domain.InvalidEventReasonID,
+		},
+		{
+			Name:           "tampered_content",
+			Libevent:       fixtures.LibeventWithTamperedContent,
+			ExpectedReason: &domain.InvalidEventReasonID,
+		},
+		{
+			Name:           "tampered_signature",
+			Libevent:       fixtures.LibeventWithTamperedSignature,
+			ExpectedReason: &domain.InvalidEventReasonSignature,
+		},
+		{
+			Name: "malformed_signature",
+			Libevent: func(tb testing.TB) nostr.Event {
+				libevent := fixtures.SomeLibevent(tb)
+				libevent.Sig = "not-hex"
+				return libevent
+			},
+			ExpectedReason: &domain.InvalidEventReasonSignature,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			libevent := testCase.Libevent(t)
+
+			event, err := domain.NewEvent(libevent)
+			if testCase.ExpectedReason == nil {
+				require.NoError(t, err)
+				require.Equal(t, libevent.ID, event.Id().Hex())
+				return
+			}
+
+			var invalidEventErr *domain.InvalidEventError
+			require.True(t, errors.As(err, &invalidEventErr))
+			require.Equal(t, *testCase.ExpectedReason, invalidEventErr.Reason())
+		})
+	}
+}
@@ -127,6 +127,20 @@ func (f Filter) Search() string {
 	return f.search
 }
 
+// WithUntilAndLimit returns a copy of the filter which only matches events
+// created at or before until and returns at most limit events. Until is
+// truncated to seconds.
+func (f Filter) WithUntilAndLimit(until *time.Time, limit int) (Filter, error) {
+	libfilter := f.libfilter
+	libfilter.Until = nil
+	if until != nil {
+		t := nostr.Timestamp(until.Unix())
+		libfilter.Until = &t
+	}
+	libfilter.Limit = limit
+	return NewFilter(libfilter)
+}
+
 type Filters struct {
 	filters []Filter
 }
@@ -146,6 +160,14 @@ func NewFilters(f nostr.Filters) (Filters, error) {
 	}, nil
 }
 
+// NewFiltersFromFilters creates filters which match events matching any of the
+// given filters.
+func NewFiltersFromFilters(filters ...Filter) Filters {
+	return Filters{
+		filters: filters,
+	}
+}
+
 func (f Filters) Match(event Event) bool {
 	for _, filter := range f.filters {
 		if filter.Matches(event) {
@@ -155,6 +177,29 @@ func (f Filters) Match(event Event) bool {
 	return false
 }
 
+// OnlyMatchEventsTagging returns true if all filters require events to p-tag
+// the given public key.
+func (f Filters) OnlyMatchEventsTagging(publicKey PublicKey) bool {
+	if len(f.filters) == 0 {
+		return false
+	}
+
+	for _, filter := range f.filters {
+		values := filter.tags[tagProfile]
+		if len(values) == 0 {
+			return false
+		}
+
+		for _, value := range values {
+			if value != publicKey.Hex() {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
 func (f Filters) Filters() []Filter {
 	return f.filters
 }
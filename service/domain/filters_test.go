@@ -0,0 +1,101 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilters_OnlyMatchEventsTagging(t *testing.T) {
+	publicKey, _ := fixtures.SomeKeyPair()
+	otherPublicKey, _ := fixtures.SomeKeyPair()
+
+	testCases := []struct {
+		Name    string
+		Filters nostr.Filters
+
+		Expected bool
+	}{
+		{
+			Name: "tagging_public_key",
+			Filters: nostr.Filters{
+				{Tags: nostr.TagMap{"p": {publicKey.Hex()}}},
+			},
+			Expected: true,
+		},
+		{
+			Name: "all_filters_tagging_public_key",
+			Filters: nostr.Filters{
+				{Tags: nostr.TagMap{"p": {publicKey.Hex()}}, Kinds: []int{domain.EventKindNote.Int()}},
+				{Tags: nostr.TagMap{"p": {publicKey.Hex()}}, Kinds: []int{domain.EventKindReaction.Int()}},
+			},
+			Expected: true,
+		},
+		{
+			Name: "one_filter_without_tags",
+			Filters: nostr.Filters{
+				{Tags: nostr.TagMap{"p": {publicKey.Hex()}}},
+				{Kinds: []int{domain.EventKindNote.Int()}},
+			},
+			Expected: false,
+		},
+		{
+			Name: "tagging_someone_else_as_well",
+			Filters: nostr.Filters{
+				{Tags: nostr.TagMap{"p": {publicKey.Hex(), otherPublicKey.Hex()}}},
+			},
+			Expected: false,
+		},
+		{
+			Name: "tagging_someone_else",
+			Filters: nostr.Filters{
+				{Tags: nostr.TagMap{"p": {otherPublicKey.Hex()}}},
+			},
+			Expected: false,
+		},
+		{
+			Name:     "no_filters",
+			Filters:  nostr.Filters{},
+			Expected: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			filters, err := domain.NewFilters(testCase.Filters)
+			require.NoError(t, err)
+			require.Equal(t, testCase.Expected, filters.OnlyMatchEventsTagging(publicKey))
+		})
+	}
+}
+
+func TestFilter_WithUntilAndLimit(t *testing.T) {
+	publicKey, _ := fixtures.SomeKeyPair()
+	since := nostr.Timestamp(1000)
+
+	filter, err := domain.NewFilter(nostr.Filter{
+		Tags:  nostr.TagMap{"p": {publicKey.Hex()}},
+		Since: &since,
+		Limit: 10,
+	})
+	require.NoError(t, err)
+
+	until := time.Unix(2000, 500)
+	page, err := filter.WithUntilAndLimit(&until, 5)
+	require.NoError(t, err)
+	require.Equal(t, filter.Tags(), page.Tags())
+	require.Equal(t, filter.Since(), page.Since())
+	require.Equal(t, time.Unix(2000, 0), *page.Until())
+	require.Equal(t, 5, page.Limit())
+
+	require.Nil(t, filter.Until(), "original filter shouldn't be modified")
+	require.Equal(t, 10, filter.Limit(), "original filter shouldn't be modified")
+
+	page, err = page.WithUntilAndLimit(nil, 5)
+	require.NoError(t, err)
+	require.Nil(t, page.Until())
+}
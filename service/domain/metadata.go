@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/boreq/errors"
+)
+
+// Metadata is created from events of kind 0, see NIP-01 and NIP-24.
+type Metadata struct {
+	publicKey   PublicKey
+	displayName string
+}
+
+func NewMetadata(event Event) (Metadata, error) {
+	if event.Kind() != EventKindMetadata {
+		return Metadata{}, fmt.Errorf("invalid event kind '%d'", event.Kind().Int())
+	}
+
+	var v metadataTransport
+	if err := json.Unmarshal([]byte(event.Content()), &v); err != nil {
+		return Metadata{}, errors.Wrap(err, "error unmarshaling content")
+	}
+
+	// display_name is preferred as name is supposed to be a short handle,
+	// displayName is deprecated but some clients still only set it
+	var displayName string
+	for _, candidate := range []string{v.DisplayName, v.DeprecatedDisplayName, v.Name} {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			displayName = candidate
+			break
+		}
+	}
+
+	return Metadata{
+		publicKey:   event.PubKey(),
+		displayName: displayName,
+	}, nil
+}
+
+func (m Metadata) PublicKey() PublicKey {
+	return m.publicKey
+}
+
+// DisplayName returns an empty string if the user didn't set a name.
+func (m Metadata) DisplayName() string {
+	return m.displayName
+}
+
+type metadataTransport struct {
+	Name                  string `json:"name"`
+	DisplayName           string `json:"display_name"`
+	DeprecatedDisplayName string `json:"displayName"`
+}
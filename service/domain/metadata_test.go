@@ -0,0 +1,75 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMetadata(t *testing.T) {
+	testCases := []struct {
+		Name string
+
+		Content string
+
+		ExpectedDisplayName string
+		ExpectedError       bool
+	}{
+		{
+			Name:                "display_name_is_preferred",
+			Content:             `{"name": "handle", "display_name": "Display Name", "displayName": "Deprecated"}`,
+			ExpectedDisplayName: "Display Name",
+		},
+		{
+			Name:                "deprecated_display_name",
+			Content:             `{"name": "handle", "displayName": "Deprecated"}`,
+			ExpectedDisplayName: "Deprecated",
+		},
+		{
+			Name:                "name",
+			Content:             `{"name": "handle", "display_name": "  "}`,
+			ExpectedDisplayName: "handle",
+		},
+		{
+			Name:                "no_name",
+			Content:             `{"about": "something"}`,
+			ExpectedDisplayName: "",
+		},
+		{
+			Name:          "malformed",
+			Content:       `not json`,
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			publicKey, sk := fixtures.SomeKeyPair()
+
+			libevent := nostr.Event{
+				CreatedAt: nostr.Now(),
+				Kind:      domain.EventKindMetadata.Int(),
+				Tags:      nostr.Tags{},
+				Content:   testCase.Content,
+			}
+			err := libevent.Sign(sk)
+			require.NoError(t, err)
+
+			event, err := domain.NewEvent(libevent)
+			require.NoError(t, err)
+
+			metadata, err := domain.NewMetadata(event)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, publicKey, metadata.PublicKey())
+			require.Equal(t, testCase.ExpectedDisplayName, metadata.DisplayName())
+		})
+	}
+}
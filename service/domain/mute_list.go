@@ -0,0 +1,116 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/planetary-social/go-notification-service/internal"
+)
+
+var (
+	tagHashtag = MustNewEventTagName("t")
+	tagWord    = MustNewEventTagName("word")
+)
+
+// MuteList is created from events of kind 10000, see NIP-51. Only public items
+// are read as private items are encrypted to the author. Malformed items are
+// skipped so that a single bad entry doesn't disable the entire list.
+type MuteList struct {
+	event Event
+
+	publicKeys *internal.Set[PublicKey]
+	threads    *internal.Set[EventId]
+	hashtags   *internal.Set[string]
+	words      []string
+}
+
+func NewMuteList(event Event) (MuteList, error) {
+	if event.Kind() != EventKindMuteList {
+		return MuteList{}, fmt.Errorf("invalid event kind '%d'", event.Kind().Int())
+	}
+
+	muteList := MuteList{
+		event:      event,
+		publicKeys: internal.NewEmptySet[PublicKey](),
+		threads:    internal.NewEmptySet[EventId](),
+		hashtags:   internal.NewEmptySet[string](),
+	}
+
+	for _, tag := range event.Tags() {
+		switch tag.Name() {
+		case tagProfile:
+			if v, err := tag.Profile(); err == nil {
+				muteList.publicKeys.Put(v)
+			}
+		case tagEvent:
+			if v, err := NewEventId(tag.FirstValue()); err == nil {
+				muteList.threads.Put(v)
+			}
+		case tagHashtag:
+			if v := normalizeMutedText(tag.FirstValue()); v != "" {
+				muteList.hashtags.Put(v)
+			}
+		case tagWord:
+			if v := normalizeMutedText(tag.FirstValue()); v != "" {
+				muteList.words = append(muteList.words, v)
+			}
+		}
+	}
+
+	return muteList, nil
+}
+
+// Event returns the event from which the mute list was created.
+func (m MuteList) Event() Event {
+	return m.event
+}
+
+func (m MuteList) Author() PublicKey {
+	return m.event.PubKey()
+}
+
+// Mutes checks if a notification about the event should be suppressed. The
+// author is passed separately as for some events e.g. zap receipts it isn't
+// the person who published the event. Muted event ids are compared with the
+// id of the event and the ids of all events that it references so that muting
+// a thread mutes replies to it. Zero value doesn't mute anything.
+func (m MuteList) Mutes(author PublicKey, event Event) bool {
+	if m.publicKeys == nil {
+		return false
+	}
+
+	if m.publicKeys.Contains(author) {
+		return true
+	}
+
+	if m.threads.Contains(event.Id()) {
+		return true
+	}
+
+	for _, id := range GetReferencedEventsFromTags(event.Tags()) {
+		if m.threads.Contains(id) {
+			return true
+		}
+	}
+
+	for _, tag := range event.Tags() {
+		if tag.Name() == tagHashtag && m.hashtags.Contains(normalizeMutedText(tag.FirstValue())) {
+			return true
+		}
+	}
+
+	if len(m.words) > 0 {
+		content := normalizeMutedText(event.Content())
+		for _, word := range m.words {
+			if strings.Contains(content, word) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func normalizeMutedText(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
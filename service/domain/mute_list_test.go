@@ -0,0 +1,162 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuteList_Mutes(t *testing.T) {
+	_, ownerSecretKey := fixtures.SomeKeyPair()
+	mutedAuthor, _ := fixtures.SomeKeyPair()
+	otherAuthor, otherAuthorSecretKey := fixtures.SomeKeyPair()
+	mutedThread := fixtures.SomeEventID()
+
+	muteList, err := domain.NewMuteList(fixtures.MuteListEvent(t, ownerSecretKey, time.Now(), nostr.Tags{
+		{"p", mutedAuthor.Hex()},
+		{"e", mutedThread.Hex()},
+		{"t", "Bitcoin"},
+		{"word", "Spoiler"},
+	}))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		Name string
+
+		Author  domain.PublicKey
+		Tags    nostr.Tags
+		Content string
+
+		ExpectedMuted bool
+	}{
+		{
+			Name:          "nothing_muted",
+			Author:        otherAuthor,
+			Content:       "hello",
+			ExpectedMuted: false,
+		},
+		{
+			Name:          "author",
+			Author:        mutedAuthor,
+			Content:       "hello",
+			ExpectedMuted: true,
+		},
+		{
+			Name:          "thread",
+			Author:        otherAuthor,
+			Tags:          nostr.Tags{{"e", mutedThread.Hex(), "", "root"}},
+			Content:       "hello",
+			ExpectedMuted: true,
+		},
+		{
+			Name:          "hashtag_ignores_case",
+			Author:        otherAuthor,
+			Tags:          nostr.Tags{{"t", "bitcoin"}},
+			Content:       "hello",
+			ExpectedMuted: true,
+		},
+		{
+			Name:          "other_hashtag",
+			Author:        otherAuthor,
+			Tags:          nostr.Tags{{"t", "nostr"}},
+			Content:       "hello",
+			ExpectedMuted: false,
+		},
+		{
+			Name:          "word_ignores_case",
+			Author:        otherAuthor,
+			Content:       "SPOILER: it was a dream",
+			ExpectedMuted: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			libevent := nostr.Event{
+				CreatedAt: nostr.Now(),
+				Kind:      domain.EventKindNote.Int(),
+				Tags:      testCase.Tags,
+				Content:   testCase.Content,
+			}
+			err := libevent.Sign(otherAuthorSecretKey)
+			require.NoError(t, err)
+
+			event, err := domain.NewEvent(libevent)
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.ExpectedMuted, muteList.Mutes(testCase.Author, event))
+		})
+	}
+}
+
+func TestMuteList_MutesEventId(t *testing.T) {
+	_, ownerSecretKey := fixtures.SomeKeyPair()
+	author, authorSecretKey := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags:      nostr.Tags{},
+	}
+	err := libevent.Sign(authorSecretKey)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	muteList, err := domain.NewMuteList(fixtures.MuteListEvent(t, ownerSecretKey, time.Now(), nostr.Tags{
+		{"e", event.Id().Hex()},
+	}))
+	require.NoError(t, err)
+
+	require.True(t, muteList.Mutes(author, event))
+	require.False(t, domain.MuteList{}.Mutes(author, event))
+}
+
+func TestNewMuteList_SkipsMalformedItems(t *testing.T) {
+	owner, ownerSecretKey := fixtures.SomeKeyPair()
+	mutedAuthor, _ := fixtures.SomeKeyPair()
+
+	muteList, err := domain.NewMuteList(fixtures.MuteListEvent(t, ownerSecretKey, time.Now(), nostr.Tags{
+		{"p", "notapublickey"},
+		{"e", "notaneventid"},
+		{"p", mutedAuthor.Hex()},
+	}))
+	require.NoError(t, err)
+	require.Equal(t, owner, muteList.Author())
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags:      nostr.Tags{},
+	}
+	err = libevent.Sign(ownerSecretKey)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	require.True(t, muteList.Mutes(mutedAuthor, event))
+}
+
+func TestNewMuteList_RejectsOtherKinds(t *testing.T) {
+	_, sk := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags:      nostr.Tags{},
+	}
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	_, err = domain.NewMuteList(event)
+	require.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package notifications
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	alertPreviewMaxLength = 100
+	alertPreviewEllipsis  = "…"
+)
+
+// Alert is a notification which is displayed by the operating system instead
+// of waking up the app in the background. The text is localized by the
+// clients using the key and the arguments.
+type Alert struct {
+	locKey   string
+	locArgs  []string
+	threadID string
+}
+
+func NewAlert(locKey string, locArgs []string, threadID string) (Alert, error) {
+	if locKey == "" {
+		return Alert{}, errors.New("empty loc key")
+	}
+	return Alert{
+		locKey:   locKey,
+		locArgs:  internal.CopySlice(locArgs),
+		threadID: threadID,
+	}, nil
+}
+
+func (a Alert) IsZero() bool {
+	return a.locKey == ""
+}
+
+func (a Alert) LocKey() string {
+	return a.locKey
+}
+
+func (a Alert) LocArgs() []string {
+	return internal.CopySlice(a.locArgs)
+}
+
+// ThreadID is used to group notifications about the same conversation.
+func (a Alert) ThreadID() string {
+	return a.threadID
+}
+
+// newAlert returns a zero value if the notification shouldn't be displayed.
+// Alerts which are always displayed never include the content of the event as
+// those are used for private messages.
+func newAlert(token domain.RegisteredPushToken, event domain.Event, classified classifiedEvent, authorName string) Alert {
+	if classified.alertLocKey != "" {
+		return Alert{locKey: classified.alertLocKey}
+	}
+
+	if token.NotificationMode() != domain.NotificationModeVisible || classified.visibleAlertLocKey == "" {
+		return Alert{}
+	}
+
+	preview := newAlertPreview(event.Content())
+
+	if authorName == "" {
+		return Alert{
+			locKey:   classified.visibleAlertLocKey,
+			locArgs:  []string{preview},
+			threadID: classified.threadID,
+		}
+	}
+
+	return Alert{
+		locKey:   classified.namedVisibleAlertLocKey,
+		locArgs:  []string{authorName, preview},
+		threadID: classified.threadID,
+	}
+}
+
+func newAlertPreview(content string) string {
+	preview := strings.Join(strings.Fields(content), " ")
+	if utf8.RuneCountInString(preview) <= alertPreviewMaxLength {
+		return preview
+	}
+	runes := []rune(preview)
+	return strings.TrimSpace(string(runes[:alertPreviewMaxLength])) + alertPreviewEllipsis
+}
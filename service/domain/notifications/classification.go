@@ -0,0 +1,155 @@
+package notifications
+
+import (
+	"strconv"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+const (
+	reactionLike = "+"
+
+	apnsDefaultSound = "default"
+
+	alertLocKeyNewDirectMessage = "newDirectMessage"
+	alertLocKeyNewMention       = "newMention"
+	alertLocKeyNamedNewMention  = "namedNewMention"
+	alertLocKeyNewReply         = "newReply"
+	alertLocKeyNamedNewReply    = "namedNewReply"
+
+	payloadFieldType     = "type"
+	payloadFieldReaction = "reaction"
+	payloadFieldSender   = "sender"
+	payloadFieldAmount   = "amount"
+	payloadFieldLocKey   = "locKey"
+	payloadFieldLocArgs  = "locArgs"
+	payloadFieldThreadID = "threadId"
+)
+
+type EventClass struct {
+	notificationType domain.NotificationType
+}
+
+func (c EventClass) String() string {
+	return c.notificationType.String()
+}
+
+func (c EventClass) NotificationType() domain.NotificationType {
+	return c.notificationType
+}
+
+var (
+	EventClassMention  = EventClass{domain.NotificationTypeMention}
+	EventClassReply    = EventClass{domain.NotificationTypeReply}
+	EventClassReaction = EventClass{domain.NotificationTypeReaction}
+	EventClassRepost   = EventClass{domain.NotificationTypeRepost}
+	EventClassZap      = EventClass{domain.NotificationTypeZap}
+
+	// EventClassDirectMessage covers NIP-04 direct messages and NIP-59 gift
+	// wraps which are used by NIP-17 direct messages.
+	EventClassDirectMessage = EventClass{domain.NotificationTypeDirectMessage}
+)
+
+// ClassifyEvent determines why the tagged users should be notified about the
+// event. Events of unknown kinds are treated as mentions.
+func ClassifyEvent(event domain.Event) EventClass {
+	switch event.Kind() {
+	case domain.EventKindReaction:
+		return EventClassReaction
+	case domain.EventKindRepost:
+		return EventClassRepost
+	case domain.EventKindZapReceipt:
+		return EventClassZap
+	case domain.EventKindEncryptedDirectMessage, domain.EventKindGiftWrap:
+		return EventClassDirectMessage
+	case domain.EventKindNote:
+		for _, tag := range event.Tags() {
+			if tag.IsReply() {
+				return EventClassReply
+			}
+		}
+		return EventClassMention
+	default:
+		return EventClassMention
+	}
+}
+
+type classifiedEvent struct {
+	class EventClass
+
+	// author is the person who caused the notification which for zaps isn't
+	// the author of the event
+	author domain.PublicKey
+
+	// fields are added to the payload
+	fields map[string]string
+
+	priority Priority
+
+	// alertLocKey is set for classes which should always display an alert
+	alertLocKey string
+
+	// visibleAlertLocKey and namedVisibleAlertLocKey are set for classes which
+	// display an alert if the user selected the visible notification mode
+	visibleAlertLocKey      string
+	namedVisibleAlertLocKey string
+
+	// threadID groups visible alerts
+	threadID string
+}
+
+func classifyEvent(event domain.Event) (classifiedEvent, error) {
+	result := classifiedEvent{
+		class:    ClassifyEvent(event),
+		author:   event.PubKey(),
+		fields:   make(map[string]string),
+		priority: PriorityNormal,
+	}
+
+	result.fields[payloadFieldType] = result.class.String()
+
+	switch result.class {
+	case EventClassMention:
+		result.visibleAlertLocKey = alertLocKeyNewMention
+		result.namedVisibleAlertLocKey = alertLocKeyNamedNewMention
+		result.threadID = threadID(event)
+	case EventClassReply:
+		result.visibleAlertLocKey = alertLocKeyNewReply
+		result.namedVisibleAlertLocKey = alertLocKeyNamedNewReply
+		result.threadID = threadID(event)
+	case EventClassReaction:
+		reaction := event.Content()
+		if reaction == "" {
+			reaction = reactionLike
+		}
+		result.fields[payloadFieldReaction] = reaction
+	case EventClassZap:
+		receipt, err := domain.NewZapReceipt(event)
+		if err != nil {
+			return classifiedEvent{}, errors.Wrap(err, "error reading the zap receipt")
+		}
+
+		result.author = receipt.Sender()
+		result.fields[payloadFieldSender] = receipt.Sender().Hex()
+		if amount, ok := receipt.Amount(); ok {
+			result.fields[payloadFieldAmount] = strconv.FormatInt(amount, 10)
+		}
+	case EventClassDirectMessage:
+		result.priority = PriorityHigh
+		result.alertLocKey = alertLocKeyNewDirectMessage
+	}
+
+	return result, nil
+}
+
+// threadID returns the id of the root of the thread or the id of the event if
+// the event doesn't belong to a thread. Events with malformed tags are grouped
+// on their own.
+func threadID(event domain.Event) string {
+	root, ok, err := domain.GetThreadRootFromTags(event.Tags())
+	if err != nil || !ok {
+		return event.Id().Hex()
+	}
+	return root.Hex()
+}
@@ -0,0 +1,34 @@
+package notifications
+
+import (
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+// Delivery records that notifications generated for a mention found in an
+// event were already sent to a specific token. Processing the same event again
+// must not result in sending the same notifications again.
+type Delivery struct {
+	eventId domain.EventId
+	mention domain.PublicKey
+	token   domain.PushToken
+}
+
+func NewDelivery(eventId domain.EventId, mention domain.PublicKey, token domain.PushToken) Delivery {
+	return Delivery{
+		eventId: eventId,
+		mention: mention,
+		token:   token,
+	}
+}
+
+func (d Delivery) EventId() domain.EventId {
+	return d.eventId
+}
+
+func (d Delivery) Mention() domain.PublicKey {
+	return d.mention
+}
+
+func (d Delivery) PushToken() domain.PushToken {
+	return d.token
+}
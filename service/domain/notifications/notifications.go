@@ -1,6 +1,8 @@
 package notifications
 
 import (
+	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/boreq/errors"
@@ -20,22 +22,45 @@ func NewGenerator(logger logging.Logger) *Generator {
 	}
 }
 
-func (g *Generator) Generate(mention domain.PublicKey, token domain.APNSToken, event domain.Event) ([]Notification, error) {
-	payloadJSON, err := g.createPayload(mention, event)
+// Generate creates notifications for the given token. The mute list belongs to
+// the mentioned person and can be a zero value if they don't have one. The name
+// of the author is only used for visible alerts and can be empty if it isn't
+// known.
+func (g *Generator) Generate(mention domain.PublicKey, token domain.RegisteredPushToken, muteList domain.MuteList, event domain.Event, authorName string) ([]Notification, error) {
+	classified, err := classifyEvent(event)
 	if err != nil {
-		return nil, errors.Wrap(err, "error creating the payload")
+		g.logger.Debug().
+			WithError(err).
+			WithField("event.id", event.Id().Hex()).
+			Message("skipping an event which couldn't be classified")
+		return nil, nil
+	}
+
+	if g.mentionedThemself(mention, classified) {
+		return nil, nil
+	}
+
+	if muteList.Mutes(classified.author, event) {
+		return nil, nil
 	}
 
-	if payloadJSON == nil {
+	if !g.preferencesAllow(token, event, classified) {
 		return nil, nil
 	}
 
+	alert := newAlert(token, event, classified, authorName)
+
+	payloadJSON, err := g.createPayload(token.PushToken(), event, classified, alert)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating the payload")
+	}
+
 	id, err := NewNotificationUUID()
 	if err != nil {
 		return nil, errors.Wrap(err, "error generating a notification id")
 	}
 
-	notification, err := NewNotification(event, id, token, payloadJSON, time.Now())
+	notification, err := NewNotification(event, id, token.PushToken(), payloadJSON, classified.priority, alert, time.Now())
 	if err != nil {
 		return nil, errors.Wrap(err, "error creating a notification")
 	}
@@ -43,12 +68,50 @@ func (g *Generator) Generate(mention domain.PublicKey, token domain.APNSToken, e
 	return []Notification{notification}, nil
 }
 
-func (g *Generator) createPayload(mention domain.PublicKey, event domain.Event) ([]byte, error) {
-	if g.mentionedThemself(mention, event) {
-		return nil, nil
+// UsesAuthorName checks if the name of the author would be used when generating
+// notifications for the given token so that it is only looked up if needed.
+func (g *Generator) UsesAuthorName(token domain.RegisteredPushToken, event domain.Event) bool {
+	if token.NotificationMode() != domain.NotificationModeVisible {
+		return false
 	}
 
-	notificationPayload := payload.NewPayload().ContentAvailable()
+	classified, err := classifyEvent(event)
+	if err != nil {
+		return false
+	}
+
+	return classified.alertLocKey == "" && classified.visibleAlertLocKey != ""
+}
+
+func (g *Generator) createPayload(token domain.PushToken, event domain.Event, classified classifiedEvent, alert Alert) ([]byte, error) {
+	switch token.Platform() {
+	case domain.PushTokenPlatformAPNS:
+		return g.createAPNSPayload(classified, alert)
+	case domain.PushTokenPlatformFCM, domain.PushTokenPlatformWebPush:
+		return g.createDataPayload(event, classified, alert)
+	default:
+		return nil, fmt.Errorf("unsupported platform '%s'", token.Platform().String())
+	}
+}
+
+// APNs payloads are silent unless an alert should be displayed.
+func (g *Generator) createAPNSPayload(classified classifiedEvent, alert Alert) ([]byte, error) {
+	notificationPayload := payload.NewPayload()
+	if alert.IsZero() {
+		notificationPayload.ContentAvailable()
+	} else {
+		notificationPayload.AlertLocKey(alert.LocKey()).Sound(apnsDefaultSound)
+		if args := alert.LocArgs(); len(args) > 0 {
+			notificationPayload.AlertLocArgs(args)
+		}
+		if alert.ThreadID() != "" {
+			notificationPayload.ThreadID(alert.ThreadID())
+		}
+	}
+
+	for key, value := range classified.fields {
+		notificationPayload.Custom(key, value)
+	}
 
 	payloadJSON, err := notificationPayload.MarshalJSON()
 	if err != nil {
@@ -58,34 +121,100 @@ func (g *Generator) createPayload(mention domain.PublicKey, event domain.Event)
 	return payloadJSON, nil
 }
 
-func (g *Generator) mentionedThemself(mention domain.PublicKey, event domain.Event) bool {
-	return mention == event.PubKey()
+// FCM payloads are used as the data of a data message so all values must be
+// strings. Web Push payloads are passed to the service worker as is so they use
+// the same format.
+func (g *Generator) createDataPayload(event domain.Event, classified classifiedEvent, alert Alert) ([]byte, error) {
+	notificationPayload := map[string]string{
+		"eventId": event.Id().Hex(),
+	}
+	for key, value := range classified.fields {
+		notificationPayload[key] = value
+	}
+
+	if !alert.IsZero() {
+		notificationPayload[payloadFieldLocKey] = alert.LocKey()
+
+		if args := alert.LocArgs(); len(args) > 0 {
+			locArgs, err := json.Marshal(args)
+			if err != nil {
+				return nil, errors.Wrap(err, "error marshaling loc args")
+			}
+			notificationPayload[payloadFieldLocArgs] = string(locArgs)
+		}
+
+		if alert.ThreadID() != "" {
+			notificationPayload[payloadFieldThreadID] = alert.ThreadID()
+		}
+	}
+
+	payloadJSON, err := json.Marshal(notificationPayload)
+	if err != nil {
+		return nil, errors.Wrap(err, "error marshaling payload")
+	}
+
+	return payloadJSON, nil
+}
+
+func (g *Generator) mentionedThemself(mention domain.PublicKey, classified classifiedEvent) bool {
+	return mention == classified.author
+}
+
+func (g *Generator) preferencesAllow(token domain.RegisteredPushToken, event domain.Event, classified classifiedEvent) bool {
+	events := append([]domain.EventId{event.Id()}, domain.GetReferencedEventsFromTags(event.Tags())...)
+	return token.Preferences().Allows(classified.class.NotificationType(), classified.author, events, time.Now())
 }
 
+type Priority struct {
+	s string
+}
+
+func (p Priority) String() string {
+	return p.s
+}
+
+var (
+	// PriorityNormal notifications can be delayed by the push services e.g.
+	// to save battery.
+	PriorityNormal = Priority{"normal"}
+
+	// PriorityHigh notifications should be delivered immediately.
+	PriorityHigh = Priority{"high"}
+)
+
 type Notification struct {
 	event domain.Event
 
 	uuid      NotificationUUID
-	token     domain.APNSToken
+	token     domain.PushToken
 	payload   []byte
+	priority  Priority
+	alert     Alert
 	createdAt *time.Time // old notifications don't have this value
 }
 
 func NewNotification(
 	event domain.Event,
 	uuid NotificationUUID,
-	token domain.APNSToken,
+	token domain.PushToken,
 	payload []byte,
+	priority Priority,
+	alert Alert,
 	createdAt time.Time,
 ) (Notification, error) {
 	if len(payload) == 0 {
 		return Notification{}, errors.New("empty payload")
 	}
+	if priority == (Priority{}) {
+		return Notification{}, errors.New("zero value of priority")
+	}
 	return Notification{
 		event:     event,
 		uuid:      uuid,
 		token:     token,
 		payload:   payload,
+		priority:  priority,
+		alert:     alert,
 		createdAt: &createdAt,
 	}, nil
 }
@@ -93,7 +222,7 @@ func NewNotification(
 func NewNotificationFromHistory(
 	event domain.Event,
 	uuid NotificationUUID,
-	token domain.APNSToken,
+	token domain.PushToken,
 	payload []byte,
 	createdAt *time.Time,
 ) (Notification, error) {
@@ -105,6 +234,8 @@ func NewNotificationFromHistory(
 		uuid:      uuid,
 		token:     token,
 		payload:   payload,
+		priority:  PriorityNormal, // only matters when sending so it isn't persisted
+		alert:     Alert{},        // only matters when sending so it isn't persisted
 		createdAt: createdAt,
 	}, nil
 }
@@ -117,7 +248,7 @@ func (n Notification) UUID() NotificationUUID {
 	return n.uuid
 }
 
-func (n Notification) APNSToken() domain.APNSToken {
+func (n Notification) PushToken() domain.PushToken {
 	return n.token
 }
 
@@ -125,6 +256,16 @@ func (n Notification) Payload() []byte {
 	return n.payload
 }
 
+func (n Notification) Priority() Priority {
+	return n.priority
+}
+
+// Alert returns a zero value if the notification shouldn't be displayed by the
+// operating system.
+func (n Notification) Alert() Alert {
+	return n.alert
+}
+
 func (n Notification) CreatedAt() *time.Time {
 	return n.createdAt
 }
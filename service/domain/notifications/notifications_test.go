@@ -1,6 +1,7 @@
 package notifications_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -17,21 +18,94 @@ func TestGenerator(t *testing.T) {
 		Name string
 
 		EventKind domain.EventKind
+		Tags      func(mention domain.PublicKey) nostr.Tags
+		Content   string
+
+		ExpectedPayload string
 	}{
 		{
 			Name: "note",
 
 			EventKind: domain.EventKindNote,
+
+			ExpectedPayload: `{"aps":{"content-available":1},"type":"mention"}`,
+		},
+		{
+			Name: "reply",
+
+			EventKind: domain.EventKindNote,
+			Tags: func(mention domain.PublicKey) nostr.Tags {
+				return nostr.Tags{
+					nostr.Tag{"e", fixtures.SomeHexBytesOfLen(32), "", "root"},
+					nostr.Tag{"p", mention.Hex()},
+				}
+			},
+
+			ExpectedPayload: `{"aps":{"content-available":1},"type":"reply"}`,
+		},
+		{
+			Name: "reply_using_positional_tags",
+
+			EventKind: domain.EventKindNote,
+			Tags: func(mention domain.PublicKey) nostr.Tags {
+				return nostr.Tags{
+					nostr.Tag{"e", fixtures.SomeHexBytesOfLen(32)},
+					nostr.Tag{"p", mention.Hex()},
+				}
+			},
+
+			ExpectedPayload: `{"aps":{"content-available":1},"type":"reply"}`,
+		},
+		{
+			Name: "note_citing_another_note",
+
+			EventKind: domain.EventKindNote,
+			Tags: func(mention domain.PublicKey) nostr.Tags {
+				return nostr.Tags{
+					nostr.Tag{"e", fixtures.SomeHexBytesOfLen(32), "", "mention"},
+					nostr.Tag{"p", mention.Hex()},
+				}
+			},
+
+			ExpectedPayload: `{"aps":{"content-available":1},"type":"mention"}`,
 		},
 		{
 			Name: "reaction",
 
 			EventKind: domain.EventKindReaction,
+			Content:   "🤙",
+
+			ExpectedPayload: `{"aps":{"content-available":1},"reaction":"🤙","type":"reaction"}`,
+		},
+		{
+			Name: "reaction_without_content",
+
+			EventKind: domain.EventKindReaction,
+
+			ExpectedPayload: `{"aps":{"content-available":1},"reaction":"+","type":"reaction"}`,
+		},
+		{
+			Name: "repost",
+
+			EventKind: domain.EventKindRepost,
+
+			ExpectedPayload: `{"aps":{"content-available":1},"type":"repost"}`,
 		},
 		{
 			Name: "edm",
 
 			EventKind: domain.EventKindEncryptedDirectMessage,
+			Content:   "encrypted content",
+
+			ExpectedPayload: `{"aps":{"alert":{"loc-key":"newDirectMessage"},"sound":"default"},"type":"directMessage"}`,
+		},
+		{
+			Name: "gift_wrap",
+
+			EventKind: domain.EventKindGiftWrap,
+			Content:   "encrypted content",
+
+			ExpectedPayload: `{"aps":{"alert":{"loc-key":"newDirectMessage"},"sound":"default"},"type":"directMessage"}`,
 		},
 	}
 
@@ -43,14 +117,19 @@ func TestGenerator(t *testing.T) {
 			pk1, _ := fixtures.SomeKeyPair()
 			pk2, sk2 := fixtures.SomeKeyPair()
 
+			tags := nostr.Tags{
+				nostr.Tag{"p", pk1.Hex()},
+			}
+			if testCase.Tags != nil {
+				tags = testCase.Tags(pk1)
+			}
+
 			libevent := nostr.Event{
 				PubKey:    pk2.Hex(),
 				CreatedAt: nostr.Timestamp(time.Now().Unix()),
 				Kind:      testCase.EventKind.Int(),
-				Tags: nostr.Tags{
-					nostr.Tag{"p", pk1.Hex()},
-				},
-				Content: "some content",
+				Tags:      tags,
+				Content:   testCase.Content,
 			}
 
 			err := libevent.Sign(sk2)
@@ -59,20 +138,515 @@ func TestGenerator(t *testing.T) {
 			event, err := domain.NewEvent(libevent)
 			require.NoError(t, err)
 
-			token := fixtures.SomeAPNSToken()
+			token := fixtures.SomeAPNSPushToken()
 
-			result, err := g.Generate(pk1, token, event)
+			result, err := g.Generate(pk1, silent(token), domain.MuteList{}, event, "")
 			require.NoError(t, err)
 
 			require.Len(t, result, 1)
 
 			notification := result[0]
 			require.Equal(t,
-				`{"aps":{"content-available":1}}`,
+				testCase.ExpectedPayload,
 				string(notification.Payload()),
 			)
-			require.Equal(t, token, notification.APNSToken())
+			require.Equal(t, token, notification.PushToken())
 			require.Equal(t, event, notification.Event())
 		})
 	}
 }
+
+func TestGenerator_Zap(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	recipient, _ := fixtures.SomeKeyPair()
+	sender, senderSecretKey := fixtures.SomeKeyPair()
+
+	event := fixtures.ZapReceipt(t, senderSecretKey, recipient, "lnbc210n1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq")
+
+	result, err := g.Generate(recipient, silent(fixtures.SomeAPNSPushToken()), domain.MuteList{}, event, "")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Equal(t,
+		`{"amount":"21000","aps":{"content-available":1},"sender":"`+sender.Hex()+`","type":"zap"}`,
+		string(result[0].Payload()),
+	)
+
+	result, err = g.Generate(recipient, silent(fixtures.SomeFCMPushToken()), domain.MuteList{}, event, "")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+	require.Equal(t,
+		`{"amount":"21000","eventId":"`+event.Id().Hex()+`","sender":"`+sender.Hex()+`","type":"zap"}`,
+		string(result[0].Payload()),
+	)
+}
+
+func TestGenerator_ZappingThemselvesDoesNotGenerateNotifications(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	pk, sk := fixtures.SomeKeyPair()
+
+	event := fixtures.ZapReceipt(t, sk, pk, "lnbc210n1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq")
+
+	result, err := g.Generate(pk, silent(fixtures.SomeAPNSPushToken()), domain.MuteList{}, event, "")
+	require.NoError(t, err)
+	require.Empty(t, result)
+}
+
+func TestGenerator_InvalidZapReceiptsDoNotGenerateNotifications(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	recipient, _ := fixtures.SomeKeyPair()
+	_, walletSecretKey := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindZapReceipt.Int(),
+		Tags: nostr.Tags{
+			nostr.Tag{"p", recipient.Hex()},
+			nostr.Tag{"description", "not a zap request"},
+		},
+	}
+
+	err := libevent.Sign(walletSecretKey)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	result, err := g.Generate(recipient, silent(fixtures.SomeAPNSPushToken()), domain.MuteList{}, event, "")
+	require.NoError(t, err)
+	require.Empty(t, result)
+}
+
+func TestGenerator_FCM(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	pk1, _ := fixtures.SomeKeyPair()
+	pk2, sk2 := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		PubKey:    pk2.Hex(),
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Kind:      domain.EventKindNote.Int(),
+		Tags: nostr.Tags{
+			nostr.Tag{"p", pk1.Hex()},
+		},
+		Content: "some content",
+	}
+
+	err := libevent.Sign(sk2)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	token := fixtures.SomeFCMPushToken()
+
+	result, err := g.Generate(pk1, silent(token), domain.MuteList{}, event, "")
+	require.NoError(t, err)
+
+	require.Len(t, result, 1)
+
+	notification := result[0]
+	require.Equal(t,
+		`{"eventId":"`+event.Id().Hex()+`","type":"mention"}`,
+		string(notification.Payload()),
+	)
+	require.Equal(t, token, notification.PushToken())
+}
+
+func TestGenerator_DirectMessagesAreSentWithHighPriority(t *testing.T) {
+	testCases := []struct {
+		Name string
+
+		EventKind domain.EventKind
+
+		ExpectedPriority notifications.Priority
+	}{
+		{
+			Name:             "note",
+			EventKind:        domain.EventKindNote,
+			ExpectedPriority: notifications.PriorityNormal,
+		},
+		{
+			Name:             "edm",
+			EventKind:        domain.EventKindEncryptedDirectMessage,
+			ExpectedPriority: notifications.PriorityHigh,
+		},
+		{
+			Name:             "gift_wrap",
+			EventKind:        domain.EventKindGiftWrap,
+			ExpectedPriority: notifications.PriorityHigh,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			logger := logging.NewDevNullLogger()
+			g := notifications.NewGenerator(logger)
+
+			pk1, _ := fixtures.SomeKeyPair()
+			_, sk2 := fixtures.SomeKeyPair()
+
+			libevent := nostr.Event{
+				CreatedAt: nostr.Now(),
+				Kind:      testCase.EventKind.Int(),
+				Tags: nostr.Tags{
+					nostr.Tag{"p", pk1.Hex()},
+				},
+				Content: "some content",
+			}
+
+			err := libevent.Sign(sk2)
+			require.NoError(t, err)
+
+			event, err := domain.NewEvent(libevent)
+			require.NoError(t, err)
+
+			for _, token := range []domain.PushToken{fixtures.SomeAPNSPushToken(), fixtures.SomeFCMPushToken()} {
+				result, err := g.Generate(pk1, silent(token), domain.MuteList{}, event, "")
+				require.NoError(t, err)
+				require.Len(t, result, 1)
+
+				notification := result[0]
+				require.Equal(t, testCase.ExpectedPriority, notification.Priority())
+				require.NotContains(t, string(notification.Payload()), libevent.Content)
+			}
+		})
+	}
+}
+
+func TestGenerator_VisibleAlerts(t *testing.T) {
+	root := fixtures.SomeHexBytesOfLen(32)
+
+	testCases := []struct {
+		Name string
+
+		EventKind  domain.EventKind
+		Tags       func(mention domain.PublicKey) nostr.Tags
+		Content    string
+		AuthorName string
+
+		ExpectedAPNSPayload func(event domain.Event) string
+		ExpectedDataPayload func(event domain.Event) string
+	}{
+		{
+			Name: "mention",
+
+			EventKind:  domain.EventKindNote,
+			Content:    "hello   there\nfriend",
+			AuthorName: "Some Name",
+
+			ExpectedAPNSPayload: func(event domain.Event) string {
+				return `{"aps":{"alert":{"loc-args":["Some Name","hello there friend"],"loc-key":"namedNewMention"},"sound":"default","thread-id":"` + event.Id().Hex() + `"},"type":"mention"}`
+			},
+			ExpectedDataPayload: func(event domain.Event) string {
+				return `{"eventId":"` + event.Id().Hex() + `","locArgs":"[\"Some Name\",\"hello there friend\"]","locKey":"namedNewMention","threadId":"` + event.Id().Hex() + `","type":"mention"}`
+			},
+		},
+		{
+			Name: "mention_without_author_name",
+
+			EventKind: domain.EventKindNote,
+			Content:   "hello",
+
+			ExpectedAPNSPayload: func(event domain.Event) string {
+				return `{"aps":{"alert":{"loc-args":["hello"],"loc-key":"newMention"},"sound":"default","thread-id":"` + event.Id().Hex() + `"},"type":"mention"}`
+			},
+			ExpectedDataPayload: func(event domain.Event) string {
+				return `{"eventId":"` + event.Id().Hex() + `","locArgs":"[\"hello\"]","locKey":"newMention","threadId":"` + event.Id().Hex() + `","type":"mention"}`
+			},
+		},
+		{
+			Name: "reply_is_grouped_by_root",
+
+			EventKind: domain.EventKindNote,
+			Tags: func(mention domain.PublicKey) nostr.Tags {
+				return nostr.Tags{
+					nostr.Tag{"e", fixtures.SomeHexBytesOfLen(32), "", "reply"},
+					nostr.Tag{"e", root, "", "root"},
+					nostr.Tag{"p", mention.Hex()},
+				}
+			},
+			Content:    "hello",
+			AuthorName: "Some Name",
+
+			ExpectedAPNSPayload: func(event domain.Event) string {
+				return `{"aps":{"alert":{"loc-args":["Some Name","hello"],"loc-key":"namedNewReply"},"sound":"default","thread-id":"` + root + `"},"type":"reply"}`
+			},
+			ExpectedDataPayload: func(event domain.Event) string {
+				return `{"eventId":"` + event.Id().Hex() + `","locArgs":"[\"Some Name\",\"hello\"]","locKey":"namedNewReply","threadId":"` + root + `","type":"reply"}`
+			},
+		},
+		{
+			Name: "reply_using_positional_tags_is_grouped_by_root",
+
+			EventKind: domain.EventKindNote,
+			Tags: func(mention domain.PublicKey) nostr.Tags {
+				return nostr.Tags{
+					nostr.Tag{"e", root},
+					nostr.Tag{"e", fixtures.SomeHexBytesOfLen(32)},
+					nostr.Tag{"p", mention.Hex()},
+				}
+			},
+			Content: "hello",
+
+			ExpectedAPNSPayload: func(event domain.Event) string {
+				return `{"aps":{"alert":{"loc-args":["hello"],"loc-key":"newReply"},"sound":"default","thread-id":"` + root + `"},"type":"reply"}`
+			},
+			ExpectedDataPayload: func(event domain.Event) string {
+				return `{"eventId":"` + event.Id().Hex() + `","locArgs":"[\"hello\"]","locKey":"newReply","threadId":"` + root + `","type":"reply"}`
+			},
+		},
+		{
+			Name: "reactions_stay_silent",
+
+			EventKind:  domain.EventKindReaction,
+			Content:    "+",
+			AuthorName: "Some Name",
+
+			ExpectedAPNSPayload: func(event domain.Event) string {
+				return `{"aps":{"content-available":1},"reaction":"+","type":"reaction"}`
+			},
+			ExpectedDataPayload: func(event domain.Event) string {
+				return `{"eventId":"` + event.Id().Hex() + `","reaction":"+","type":"reaction"}`
+			},
+		},
+		{
+			Name: "direct_messages_never_include_content",
+
+			EventKind:  domain.EventKindEncryptedDirectMessage,
+			Content:    "encrypted content",
+			AuthorName: "Some Name",
+
+			ExpectedAPNSPayload: func(event domain.Event) string {
+				return `{"aps":{"alert":{"loc-key":"newDirectMessage"},"sound":"default"},"type":"directMessage"}`
+			},
+			ExpectedDataPayload: func(event domain.Event) string {
+				return `{"eventId":"` + event.Id().Hex() + `","locKey":"newDirectMessage","type":"directMessage"}`
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			logger := logging.NewDevNullLogger()
+			g := notifications.NewGenerator(logger)
+
+			pk1, _ := fixtures.SomeKeyPair()
+			_, sk2 := fixtures.SomeKeyPair()
+
+			tags := nostr.Tags{
+				nostr.Tag{"p", pk1.Hex()},
+			}
+			if testCase.Tags != nil {
+				tags = testCase.Tags(pk1)
+			}
+
+			libevent := nostr.Event{
+				CreatedAt: nostr.Now(),
+				Kind:      testCase.EventKind.Int(),
+				Tags:      tags,
+				Content:   testCase.Content,
+			}
+
+			err := libevent.Sign(sk2)
+			require.NoError(t, err)
+
+			event, err := domain.NewEvent(libevent)
+			require.NoError(t, err)
+
+			result, err := g.Generate(pk1, visible(fixtures.SomeAPNSPushToken()), domain.MuteList{}, event, testCase.AuthorName)
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			require.Equal(t, testCase.ExpectedAPNSPayload(event), string(result[0].Payload()))
+
+			result, err = g.Generate(pk1, visible(fixtures.SomeFCMPushToken()), domain.MuteList{}, event, testCase.AuthorName)
+			require.NoError(t, err)
+			require.Len(t, result, 1)
+			require.Equal(t, testCase.ExpectedDataPayload(event), string(result[0].Payload()))
+		})
+	}
+}
+
+func TestGenerator_VisibleAlertsTruncateContent(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	pk1, _ := fixtures.SomeKeyPair()
+	_, sk2 := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags: nostr.Tags{
+			nostr.Tag{"p", pk1.Hex()},
+		},
+		Content: strings.Repeat("ą", 150),
+	}
+
+	err := libevent.Sign(sk2)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	result, err := g.Generate(pk1, visible(fixtures.SomeAPNSPushToken()), domain.MuteList{}, event, "")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+
+	alert := result[0].Alert()
+	require.Equal(t, []string{strings.Repeat("ą", 100) + "…"}, alert.LocArgs())
+}
+
+func TestGenerator_UsesAuthorName(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	pk1, _ := fixtures.SomeKeyPair()
+
+	note := someEventWithKind(t, pk1, domain.EventKindNote)
+	reaction := someEventWithKind(t, pk1, domain.EventKindReaction)
+	directMessage := someEventWithKind(t, pk1, domain.EventKindEncryptedDirectMessage)
+
+	require.True(t, g.UsesAuthorName(visible(fixtures.SomeAPNSPushToken()), note))
+	require.False(t, g.UsesAuthorName(silent(fixtures.SomeAPNSPushToken()), note))
+	require.False(t, g.UsesAuthorName(visible(fixtures.SomeAPNSPushToken()), reaction))
+	require.False(t, g.UsesAuthorName(visible(fixtures.SomeAPNSPushToken()), directMessage))
+}
+
+func TestGenerator_Preferences(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	mention, _ := fixtures.SomeKeyPair()
+	author, authorSecretKey := fixtures.SomeKeyPair()
+	thread := fixtures.SomeEventID()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags: nostr.Tags{
+			nostr.Tag{"p", mention.Hex()},
+			nostr.Tag{"e", thread.Hex(), "", "root"},
+		},
+		Content: "some content",
+	}
+
+	err := libevent.Sign(authorSecretKey)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		Name string
+
+		Preferences domain.Preferences
+
+		ExpectedNotifications bool
+	}{
+		{
+			Name:                  "default",
+			Preferences:           domain.Preferences{},
+			ExpectedNotifications: true,
+		},
+		{
+			Name:                  "type_enabled",
+			Preferences:           domain.NewPreferences([]domain.NotificationType{domain.NotificationTypeReply}, nil, nil, nil),
+			ExpectedNotifications: true,
+		},
+		{
+			Name:                  "type_disabled",
+			Preferences:           domain.NewPreferences([]domain.NotificationType{domain.NotificationTypeMention}, nil, nil, nil),
+			ExpectedNotifications: false,
+		},
+		{
+			Name:                  "author_muted",
+			Preferences:           domain.NewPreferences(nil, []domain.PublicKey{author}, nil, nil),
+			ExpectedNotifications: false,
+		},
+		{
+			Name:                  "thread_muted",
+			Preferences:           domain.NewPreferences(nil, nil, []domain.EventId{thread}, nil),
+			ExpectedNotifications: false,
+		},
+		{
+			Name:                  "event_muted",
+			Preferences:           domain.NewPreferences(nil, nil, []domain.EventId{event.Id()}, nil),
+			ExpectedNotifications: false,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			token := domain.MustNewRegisteredPushToken(fixtures.SomeAPNSPushToken(), domain.NotificationModeSilent, testCase.Preferences)
+
+			result, err := g.Generate(mention, token, domain.MuteList{}, event, "")
+			require.NoError(t, err)
+			if testCase.ExpectedNotifications {
+				require.Len(t, result, 1)
+			} else {
+				require.Empty(t, result)
+			}
+		})
+	}
+}
+
+func TestGenerator_MuteList(t *testing.T) {
+	logger := logging.NewDevNullLogger()
+	g := notifications.NewGenerator(logger)
+
+	recipient, recipientSecretKey := fixtures.SomeKeyPair()
+	sender, senderSecretKey := fixtures.SomeKeyPair()
+
+	zap := fixtures.ZapReceipt(t, senderSecretKey, recipient, "lnbc210n1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq")
+	note := someEventWithKind(t, recipient, domain.EventKindNote)
+
+	muteList, err := domain.NewMuteList(fixtures.MuteListEvent(t, recipientSecretKey, time.Now(), nostr.Tags{
+		{"p", sender.Hex()},
+	}))
+	require.NoError(t, err)
+
+	result, err := g.Generate(recipient, silent(fixtures.SomeAPNSPushToken()), muteList, zap, "")
+	require.NoError(t, err)
+	require.Empty(t, result, "zap receipts are published by wallets but the sender is muted")
+
+	result, err = g.Generate(recipient, silent(fixtures.SomeAPNSPushToken()), muteList, note, "")
+	require.NoError(t, err)
+	require.Len(t, result, 1)
+}
+
+func someEventWithKind(t *testing.T, mention domain.PublicKey, kind domain.EventKind) domain.Event {
+	_, sk := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      kind.Int(),
+		Tags: nostr.Tags{
+			nostr.Tag{"p", mention.Hex()},
+		},
+		Content: "some content",
+	}
+
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	return event
+}
+
+func silent(token domain.PushToken) domain.RegisteredPushToken {
+	return domain.MustNewRegisteredPushToken(token, domain.NotificationModeSilent, domain.Preferences{})
+}
+
+func visible(token domain.PushToken) domain.RegisteredPushToken {
+	return domain.MustNewRegisteredPushToken(token, domain.NotificationModeVisible, domain.Preferences{})
+}
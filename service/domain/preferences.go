@@ -0,0 +1,335 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/planetary-social/go-notification-service/internal"
+)
+
+var (
+	NotificationTypeMention       = NotificationType{"mention"}
+	NotificationTypeReply         = NotificationType{"reply"}
+	NotificationTypeReaction      = NotificationType{"reaction"}
+	NotificationTypeRepost        = NotificationType{"repost"}
+	NotificationTypeZap           = NotificationType{"zap"}
+	NotificationTypeDirectMessage = NotificationType{"directMessage"}
+	NotificationTypeFollow        = NotificationType{"follow"}
+)
+
+// NotificationType describes why the user is being notified. Users can disable
+// notifications of certain types.
+type NotificationType struct {
+	s string
+}
+
+func NewNotificationType(s string) (NotificationType, error) {
+	switch s {
+	case NotificationTypeMention.s:
+		return NotificationTypeMention, nil
+	case NotificationTypeReply.s:
+		return NotificationTypeReply, nil
+	case NotificationTypeReaction.s:
+		return NotificationTypeReaction, nil
+	case NotificationTypeRepost.s:
+		return NotificationTypeRepost, nil
+	case NotificationTypeZap.s:
+		return NotificationTypeZap, nil
+	case NotificationTypeDirectMessage.s:
+		return NotificationTypeDirectMessage, nil
+	case NotificationTypeFollow.s:
+		return NotificationTypeFollow, nil
+	default:
+		return NotificationType{}, fmt.Errorf("unknown notification type '%s'", s)
+	}
+}
+
+func (t NotificationType) String() string {
+	return t.s
+}
+
+// Preferences are sent by the clients together with the registration. Zero
+// value permits all notifications.
+type Preferences struct {
+	enabledTypes    *internal.Set[NotificationType] // nil means that all types are enabled
+	mutedPublicKeys *internal.Set[PublicKey]
+	mutedThreads    *internal.Set[EventId]
+	quietHours      *QuietHours
+}
+
+func NewPreferences(
+	enabledTypes []NotificationType,
+	mutedPublicKeys []PublicKey,
+	mutedThreads []EventId,
+	quietHours *QuietHours,
+) Preferences {
+	p := Preferences{
+		quietHours: quietHours,
+	}
+	if enabledTypes != nil {
+		p.enabledTypes = internal.NewSet(enabledTypes)
+	}
+	if len(mutedPublicKeys) > 0 {
+		p.mutedPublicKeys = internal.NewSet(mutedPublicKeys)
+	}
+	if len(mutedThreads) > 0 {
+		p.mutedThreads = internal.NewSet(mutedThreads)
+	}
+	return p
+}
+
+func (p Preferences) TypeEnabled(notificationType NotificationType) bool {
+	return p.enabledTypes == nil || p.enabledTypes.Contains(notificationType)
+}
+
+func (p Preferences) PublicKeyMuted(publicKey PublicKey) bool {
+	return p.mutedPublicKeys != nil && p.mutedPublicKeys.Contains(publicKey)
+}
+
+func (p Preferences) ThreadMuted(id EventId) bool {
+	return p.mutedThreads != nil && p.mutedThreads.Contains(id)
+}
+
+func (p Preferences) InQuietHours(t time.Time) bool {
+	return p.quietHours != nil && p.quietHours.Contains(t)
+}
+
+// Allows checks if a notification about something caused by the author should
+// be sent right now. Muted threads are identified by the ids of their roots so
+// the ids of the event and of all events that it references should be passed.
+func (p Preferences) Allows(notificationType NotificationType, author PublicKey, events []EventId, now time.Time) bool {
+	if !p.TypeEnabled(notificationType) {
+		return false
+	}
+
+	if p.PublicKeyMuted(author) {
+		return false
+	}
+
+	for _, event := range events {
+		if p.ThreadMuted(event) {
+			return false
+		}
+	}
+
+	return !p.InQuietHours(now)
+}
+
+// FilterFollowChange removes muted followers from the batch. It returns false
+// if no notification should be sent.
+func (p Preferences) FilterFollowChange(followChange FollowChangeBatch, now time.Time) (FollowChangeBatch, bool) {
+	if !p.TypeEnabled(NotificationTypeFollow) || p.InQuietHours(now) {
+		return FollowChangeBatch{}, false
+	}
+
+	var follows []PublicKey
+	for _, follow := range followChange.Follows {
+		if !p.PublicKeyMuted(follow) {
+			follows = append(follows, follow)
+		}
+	}
+
+	if len(follows) == 0 {
+		return FollowChangeBatch{}, false
+	}
+
+	if len(follows) == len(followChange.Follows) {
+		return followChange, true
+	}
+
+	// the friendly name of the remaining follower isn't known, clients
+	// display a generic message if it is an npub
+	friendlyFollower := ""
+	if len(follows) == 1 {
+		npub, err := nip19.EncodePublicKey(follows[0].Hex())
+		if err != nil {
+			return FollowChangeBatch{}, false
+		}
+		friendlyFollower = npub
+	}
+
+	return FollowChangeBatch{
+		Followee:         followChange.Followee,
+		FriendlyFollower: friendlyFollower,
+		Follows:          follows,
+	}, true
+}
+
+func (p Preferences) MarshalJSON() ([]byte, error) {
+	var transport preferencesTransport
+
+	if p.enabledTypes != nil {
+		transport.EnabledTypes = make([]string, 0, p.enabledTypes.Len())
+		for _, v := range p.enabledTypes.List() {
+			transport.EnabledTypes = append(transport.EnabledTypes, v.String())
+		}
+	}
+
+	if p.mutedPublicKeys != nil {
+		for _, v := range p.mutedPublicKeys.List() {
+			transport.MutedPublicKeys = append(transport.MutedPublicKeys, v.Hex())
+		}
+	}
+
+	if p.mutedThreads != nil {
+		for _, v := range p.mutedThreads.List() {
+			transport.MutedThreads = append(transport.MutedThreads, v.Hex())
+		}
+	}
+
+	if p.quietHours != nil {
+		transport.QuietHours = &quietHoursTransport{
+			Start:    p.quietHours.start.String(),
+			End:      p.quietHours.end.String(),
+			Timezone: p.quietHours.location.String(),
+		}
+	}
+
+	return json.Marshal(transport)
+}
+
+func (p *Preferences) UnmarshalJSON(data []byte) error {
+	var transport preferencesTransport
+	if err := json.Unmarshal(data, &transport); err != nil {
+		return errors.Wrap(err, "error unmarshaling")
+	}
+
+	var enabledTypes []NotificationType
+	if transport.EnabledTypes != nil {
+		enabledTypes = make([]NotificationType, 0, len(transport.EnabledTypes))
+		for _, s := range transport.EnabledTypes {
+			v, err := NewNotificationType(s)
+			if err != nil {
+				return errors.Wrap(err, "error creating a notification type")
+			}
+			enabledTypes = append(enabledTypes, v)
+		}
+	}
+
+	var mutedPublicKeys []PublicKey
+	for _, s := range transport.MutedPublicKeys {
+		v, err := NewPublicKeyFromHex(s)
+		if err != nil {
+			return errors.Wrap(err, "error creating a muted public key")
+		}
+		mutedPublicKeys = append(mutedPublicKeys, v)
+	}
+
+	var mutedThreads []EventId
+	for _, s := range transport.MutedThreads {
+		v, err := NewEventId(s)
+		if err != nil {
+			return errors.Wrap(err, "error creating a muted thread")
+		}
+		mutedThreads = append(mutedThreads, v)
+	}
+
+	var quietHours *QuietHours
+	if transport.QuietHours != nil {
+		v, err := NewQuietHours(transport.QuietHours.Start, transport.QuietHours.End, transport.QuietHours.Timezone)
+		if err != nil {
+			return errors.Wrap(err, "error creating quiet hours")
+		}
+		quietHours = &v
+	}
+
+	*p = NewPreferences(enabledTypes, mutedPublicKeys, mutedThreads, quietHours)
+	return nil
+}
+
+// QuietHours is a daily period during which notifications aren't sent. The
+// period can span midnight e.g. from 22:00 to 07:00.
+type QuietHours struct {
+	start    timeOfDay
+	end      timeOfDay
+	location *time.Location
+}
+
+// NewQuietHours accepts times in the HH:MM format and an IANA timezone name
+// e.g. Europe/Warsaw.
+func NewQuietHours(start, end, timezone string) (QuietHours, error) {
+	startTime, err := newTimeOfDay(start)
+	if err != nil {
+		return QuietHours{}, errors.Wrap(err, "error parsing start")
+	}
+
+	endTime, err := newTimeOfDay(end)
+	if err != nil {
+		return QuietHours{}, errors.Wrap(err, "error parsing end")
+	}
+
+	if startTime == endTime {
+		return QuietHours{}, errors.New("start and end can't be equal")
+	}
+
+	if timezone == "" {
+		return QuietHours{}, errors.New("missing timezone")
+	}
+
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return QuietHours{}, errors.Wrap(err, "error loading the timezone")
+	}
+
+	return QuietHours{
+		start:    startTime,
+		end:      endTime,
+		location: location,
+	}, nil
+}
+
+// Contains checks if the given time falls within quiet hours. Start is
+// inclusive and end is exclusive.
+func (q QuietHours) Contains(t time.Time) bool {
+	t = t.In(q.location)
+	now := timeOfDay(t.Hour()*60 + t.Minute())
+
+	if q.start < q.end {
+		return now >= q.start && now < q.end
+	}
+	return now >= q.start || now < q.end
+}
+
+// timeOfDay is the number of minutes since midnight.
+type timeOfDay int
+
+func newTimeOfDay(s string) (timeOfDay, error) {
+	hours, minutes, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid time '%s'", s)
+	}
+
+	h, err := strconv.Atoi(hours)
+	if err != nil || h < 0 || h > 23 {
+		return 0, fmt.Errorf("invalid hour '%s'", hours)
+	}
+
+	m, err := strconv.Atoi(minutes)
+	if err != nil || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid minute '%s'", minutes)
+	}
+
+	return timeOfDay(h*60 + m), nil
+}
+
+func (t timeOfDay) String() string {
+	return fmt.Sprintf("%02d:%02d", int(t)/60, int(t)%60)
+}
+
+type preferencesTransport struct {
+	EnabledTypes    []string             `json:"enabledTypes"`
+	MutedPublicKeys []string             `json:"mutedPublicKeys,omitempty"`
+	MutedThreads    []string             `json:"mutedThreads,omitempty"`
+	QuietHours      *quietHoursTransport `json:"quietHours,omitempty"`
+}
+
+type quietHoursTransport struct {
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	Timezone string `json:"timezone"`
+}
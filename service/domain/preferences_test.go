@@ -0,0 +1,230 @@
+package domain_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuietHours_Contains(t *testing.T) {
+	testCases := []struct {
+		Name string
+
+		Start    string
+		End      string
+		Timezone string
+		Time     time.Time
+
+		Expected bool
+	}{
+		{
+			Name:     "within",
+			Start:    "09:00",
+			End:      "17:00",
+			Timezone: "UTC",
+			Time:     time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+			Expected: true,
+		},
+		{
+			Name:     "start_is_inclusive",
+			Start:    "09:00",
+			End:      "17:00",
+			Timezone: "UTC",
+			Time:     time.Date(2023, 1, 1, 9, 0, 0, 0, time.UTC),
+			Expected: true,
+		},
+		{
+			Name:     "end_is_exclusive",
+			Start:    "09:00",
+			End:      "17:00",
+			Timezone: "UTC",
+			Time:     time.Date(2023, 1, 1, 17, 0, 0, 0, time.UTC),
+			Expected: false,
+		},
+		{
+			Name:     "spanning_midnight_before_midnight",
+			Start:    "22:00",
+			End:      "07:00",
+			Timezone: "UTC",
+			Time:     time.Date(2023, 1, 1, 23, 30, 0, 0, time.UTC),
+			Expected: true,
+		},
+		{
+			Name:     "spanning_midnight_after_midnight",
+			Start:    "22:00",
+			End:      "07:00",
+			Timezone: "UTC",
+			Time:     time.Date(2023, 1, 1, 6, 59, 0, 0, time.UTC),
+			Expected: true,
+		},
+		{
+			Name:     "spanning_midnight_outside",
+			Start:    "22:00",
+			End:      "07:00",
+			Timezone: "UTC",
+			Time:     time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC),
+			Expected: false,
+		},
+		{
+			Name:     "timezone",
+			Start:    "22:00",
+			End:      "07:00",
+			Timezone: "Asia/Tokyo",
+			Time:     time.Date(2023, 1, 1, 14, 0, 0, 0, time.UTC), // 23:00 in Tokyo
+			Expected: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			quietHours, err := domain.NewQuietHours(testCase.Start, testCase.End, testCase.Timezone)
+			require.NoError(t, err)
+			require.Equal(t, testCase.Expected, quietHours.Contains(testCase.Time))
+		})
+	}
+}
+
+func TestNewQuietHours_Invalid(t *testing.T) {
+	testCases := []struct {
+		Name string
+
+		Start    string
+		End      string
+		Timezone string
+	}{
+		{Name: "malformed_start", Start: "9", End: "17:00", Timezone: "UTC"},
+		{Name: "invalid_hour", Start: "24:00", End: "17:00", Timezone: "UTC"},
+		{Name: "invalid_minute", Start: "09:60", End: "17:00", Timezone: "UTC"},
+		{Name: "equal", Start: "09:00", End: "09:00", Timezone: "UTC"},
+		{Name: "missing_timezone", Start: "09:00", End: "17:00", Timezone: ""},
+		{Name: "unknown_timezone", Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			_, err := domain.NewQuietHours(testCase.Start, testCase.End, testCase.Timezone)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestPreferences_Allows(t *testing.T) {
+	author, _ := fixtures.SomeKeyPair()
+	mutedAuthor, _ := fixtures.SomeKeyPair()
+	event := fixtures.SomeEventID()
+	mutedThread := fixtures.SomeEventID()
+
+	quietHours, err := domain.NewQuietHours("22:00", "07:00", "UTC")
+	require.NoError(t, err)
+
+	preferences := domain.NewPreferences(
+		[]domain.NotificationType{domain.NotificationTypeMention},
+		[]domain.PublicKey{mutedAuthor},
+		[]domain.EventId{mutedThread},
+		&quietHours,
+	)
+
+	day := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2023, 1, 1, 23, 0, 0, 0, time.UTC)
+
+	require.True(t, preferences.Allows(domain.NotificationTypeMention, author, []domain.EventId{event}, day))
+	require.False(t, preferences.Allows(domain.NotificationTypeReaction, author, []domain.EventId{event}, day))
+	require.False(t, preferences.Allows(domain.NotificationTypeMention, mutedAuthor, []domain.EventId{event}, day))
+	require.False(t, preferences.Allows(domain.NotificationTypeMention, author, []domain.EventId{event, mutedThread}, day))
+	require.False(t, preferences.Allows(domain.NotificationTypeMention, author, []domain.EventId{event}, night))
+
+	require.True(t, domain.Preferences{}.Allows(domain.NotificationTypeReaction, mutedAuthor, []domain.EventId{mutedThread}, night))
+}
+
+func TestPreferences_FilterFollowChange(t *testing.T) {
+	followee, _ := fixtures.SomeKeyPair()
+	follower1, _ := fixtures.SomeKeyPair()
+	follower2, _ := fixtures.SomeKeyPair()
+
+	batch := domain.FollowChangeBatch{
+		Followee: followee,
+		Follows:  []domain.PublicKey{follower1, follower2},
+	}
+
+	result, ok := domain.Preferences{}.FilterFollowChange(batch, time.Now())
+	require.True(t, ok)
+	require.Equal(t, batch, result)
+
+	muted := domain.NewPreferences(nil, []domain.PublicKey{follower1}, nil, nil)
+	result, ok = muted.FilterFollowChange(batch, time.Now())
+	require.True(t, ok)
+	require.Equal(t, []domain.PublicKey{follower2}, result.Follows)
+	require.Regexp(t, "^npub", result.FriendlyFollower)
+
+	allMuted := domain.NewPreferences(nil, []domain.PublicKey{follower1, follower2}, nil, nil)
+	_, ok = allMuted.FilterFollowChange(batch, time.Now())
+	require.False(t, ok)
+
+	disabled := domain.NewPreferences([]domain.NotificationType{domain.NotificationTypeMention}, nil, nil, nil)
+	_, ok = disabled.FilterFollowChange(batch, time.Now())
+	require.False(t, ok)
+}
+
+func TestPreferences_JSONRoundTrip(t *testing.T) {
+	mutedAuthor, _ := fixtures.SomeKeyPair()
+	mutedThread := fixtures.SomeEventID()
+
+	quietHours, err := domain.NewQuietHours("22:00", "07:30", "Europe/Warsaw")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		Name        string
+		Preferences domain.Preferences
+	}{
+		{
+			Name:        "zero_value",
+			Preferences: domain.Preferences{},
+		},
+		{
+			Name:        "all_types_disabled",
+			Preferences: domain.NewPreferences([]domain.NotificationType{}, nil, nil, nil),
+		},
+		{
+			Name: "everything_set",
+			Preferences: domain.NewPreferences(
+				[]domain.NotificationType{domain.NotificationTypeMention, domain.NotificationTypeZap},
+				[]domain.PublicKey{mutedAuthor},
+				[]domain.EventId{mutedThread},
+				&quietHours,
+			),
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			b, err := json.Marshal(testCase.Preferences)
+			require.NoError(t, err)
+
+			var result domain.Preferences
+			err = json.Unmarshal(b, &result)
+			require.NoError(t, err)
+
+			for _, notificationType := range []domain.NotificationType{
+				domain.NotificationTypeMention,
+				domain.NotificationTypeReply,
+				domain.NotificationTypeReaction,
+				domain.NotificationTypeRepost,
+				domain.NotificationTypeZap,
+				domain.NotificationTypeDirectMessage,
+				domain.NotificationTypeFollow,
+			} {
+				require.Equal(t, testCase.Preferences.TypeEnabled(notificationType), result.TypeEnabled(notificationType))
+			}
+
+			require.Equal(t, testCase.Preferences.PublicKeyMuted(mutedAuthor), result.PublicKeyMuted(mutedAuthor))
+			require.Equal(t, testCase.Preferences.ThreadMuted(mutedThread), result.ThreadMuted(mutedThread))
+
+			night := time.Date(2023, 1, 1, 22, 30, 0, 0, time.UTC) // 23:30 in Warsaw
+			require.Equal(t, testCase.Preferences.InQuietHours(night), result.InQuietHours(night))
+		})
+	}
+}
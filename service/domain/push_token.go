@@ -0,0 +1,135 @@
+package domain
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/boreq/errors"
+)
+
+var (
+	PushTokenPlatformAPNS    = PushTokenPlatform{"apns"}
+	PushTokenPlatformFCM     = PushTokenPlatform{"fcm"}
+	PushTokenPlatformWebPush = PushTokenPlatform{"webpush"}
+)
+
+type PushTokenPlatform struct {
+	s string
+}
+
+func NewPushTokenPlatform(s string) (PushTokenPlatform, error) {
+	switch s {
+	case PushTokenPlatformAPNS.s:
+		return PushTokenPlatformAPNS, nil
+	case PushTokenPlatformFCM.s:
+		return PushTokenPlatformFCM, nil
+	case PushTokenPlatformWebPush.s:
+		return PushTokenPlatformWebPush, nil
+	default:
+		return PushTokenPlatform{}, fmt.Errorf("unknown platform '%s'", s)
+	}
+}
+
+func (p PushTokenPlatform) String() string {
+	return p.s
+}
+
+// PushToken identifies a device to which notifications can be delivered using
+// one of the supported platforms.
+type PushToken struct {
+	platform PushTokenPlatform
+	token    string
+}
+
+func NewPushToken(platform PushTokenPlatform, token string) (PushToken, error) {
+	switch platform {
+	case PushTokenPlatformAPNS:
+		apnsToken, err := NewAPNSTokenFromHex(token)
+		if err != nil {
+			return PushToken{}, errors.Wrap(err, "error creating an apns token")
+		}
+		return NewAPNSPushToken(apnsToken), nil
+	case PushTokenPlatformFCM:
+		return NewFCMPushToken(token)
+	case PushTokenPlatformWebPush:
+		subscription, err := NewWebPushSubscriptionFromJSON([]byte(token))
+		if err != nil {
+			return PushToken{}, errors.Wrap(err, "error creating a web push subscription")
+		}
+		return NewWebPushPushToken(subscription)
+	default:
+		return PushToken{}, errors.New("zero value of platform")
+	}
+}
+
+func NewAPNSPushToken(token APNSToken) PushToken {
+	return PushToken{
+		platform: PushTokenPlatformAPNS,
+		token:    token.Hex(),
+	}
+}
+
+// NewFCMPushToken creates a token from an FCM registration token. Those tokens
+// are opaque so we only perform basic sanity checks.
+func NewFCMPushToken(token string) (PushToken, error) {
+	if token == "" {
+		return PushToken{}, errors.New("fcm token can't be empty")
+	}
+
+	if strings.ContainsAny(token, " \t\r\n/") {
+		return PushToken{}, errors.New("fcm token contains invalid characters")
+	}
+
+	return PushToken{
+		platform: PushTokenPlatformFCM,
+		token:    token,
+	}, nil
+}
+
+// NewWebPushPushToken creates a token from a Web Push subscription. The token
+// is the subscription encoded as JSON.
+func NewWebPushPushToken(subscription WebPushSubscription) (PushToken, error) {
+	j, err := subscription.MarshalJSON()
+	if err != nil {
+		return PushToken{}, errors.Wrap(err, "error marshaling the subscription")
+	}
+
+	return PushToken{
+		platform: PushTokenPlatformWebPush,
+		token:    string(j),
+	}, nil
+}
+
+func MustNewPushToken(platform PushTokenPlatform, token string) PushToken {
+	v, err := NewPushToken(platform, token)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (t PushToken) Platform() PushTokenPlatform {
+	return t.platform
+}
+
+func (t PushToken) Token() string {
+	return t.token
+}
+
+func (t PushToken) APNSToken() (APNSToken, error) {
+	if t.platform != PushTokenPlatformAPNS {
+		return APNSToken{}, fmt.Errorf("this is a token for platform '%s'", t.platform.String())
+	}
+	return NewAPNSTokenFromHex(t.token)
+}
+
+func (t PushToken) WebPushSubscription() (WebPushSubscription, error) {
+	if t.platform != PushTokenPlatformWebPush {
+		return WebPushSubscription{}, fmt.Errorf("this is a token for platform '%s'", t.platform.String())
+	}
+	return NewWebPushSubscriptionFromJSON([]byte(t.token))
+}
+
+func (t PushToken) String() string {
+	return fmt.Sprintf("%s:%s", t.platform.String(), t.token)
+}
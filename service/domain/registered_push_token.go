@@ -0,0 +1,78 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/boreq/errors"
+)
+
+var (
+	// NotificationModeSilent notifications wake up the app which then decides
+	// what to display. iOS can throttle them or drop them entirely if the app
+	// was killed.
+	NotificationModeSilent = NotificationMode{"silent"}
+
+	// NotificationModeVisible notifications are displayed by the operating
+	// system without involving the app.
+	NotificationModeVisible = NotificationMode{"visible"}
+)
+
+type NotificationMode struct {
+	s string
+}
+
+// NewNotificationMode returns the silent mode if the string is empty as
+// clients which were released before the mode could be selected don't send it.
+func NewNotificationMode(s string) (NotificationMode, error) {
+	switch s {
+	case "", NotificationModeSilent.s:
+		return NotificationModeSilent, nil
+	case NotificationModeVisible.s:
+		return NotificationModeVisible, nil
+	default:
+		return NotificationMode{}, fmt.Errorf("unknown notification mode '%s'", s)
+	}
+}
+
+func (m NotificationMode) String() string {
+	return m.s
+}
+
+// RegisteredPushToken is a push token together with the settings which were
+// sent with the registration which created it.
+type RegisteredPushToken struct {
+	pushToken        PushToken
+	notificationMode NotificationMode
+	preferences      Preferences
+}
+
+func NewRegisteredPushToken(pushToken PushToken, notificationMode NotificationMode, preferences Preferences) (RegisteredPushToken, error) {
+	if notificationMode == (NotificationMode{}) {
+		return RegisteredPushToken{}, errors.New("zero value of notification mode")
+	}
+	return RegisteredPushToken{
+		pushToken:        pushToken,
+		notificationMode: notificationMode,
+		preferences:      preferences,
+	}, nil
+}
+
+func MustNewRegisteredPushToken(pushToken PushToken, notificationMode NotificationMode, preferences Preferences) RegisteredPushToken {
+	v, err := NewRegisteredPushToken(pushToken, notificationMode, preferences)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+func (t RegisteredPushToken) PushToken() PushToken {
+	return t.pushToken
+}
+
+func (t RegisteredPushToken) NotificationMode() NotificationMode {
+	return t.notificationMode
+}
+
+func (t RegisteredPushToken) Preferences() Preferences {
+	return t.preferences
+}
@@ -10,11 +10,21 @@ import (
 	"github.com/planetary-social/go-notification-service/internal"
 )
 
+var relaySuffixesToSkip = []string{
+	"127.0.0.1",
+	"localhost",
+	"nostr.band",
+	"nostrja-kari-nip50.heguro.com",
+	"nostr.sebastix.social",
+}
+
 // todo make sure that the registration was sent by one of those public keys?
 type Registration struct {
-	apnsToken APNSToken
-	publicKey PublicKey
-	relays    []RelayAddress
+	pushToken        PushToken
+	notificationMode NotificationMode
+	preferences      Preferences
+	publicKey        PublicKey
+	relays           []RelayAddress
 }
 
 func NewRegistrationFromEvent(event Event) (Registration, error) {
@@ -23,9 +33,14 @@ func NewRegistrationFromEvent(event Event) (Registration, error) {
 		return Registration{}, errors.Wrap(err, "error unmarshaling content")
 	}
 
-	apnsToken, err := NewAPNSTokenFromHex(v.APNSToken)
+	pushToken, err := newPushToken(v.APNSToken, v.PushToken)
 	if err != nil {
-		return Registration{}, errors.Wrap(err, "error creating an apns token")
+		return Registration{}, errors.Wrap(err, "error creating a push token")
+	}
+
+	notificationMode, err := NewNotificationMode(v.NotificationMode)
+	if err != nil {
+		return Registration{}, errors.Wrap(err, "error creating the notification mode")
 	}
 
 	publicKey, err := NewPublicKeyFromHex(v.PublicKey)
@@ -42,32 +57,104 @@ func NewRegistrationFromEvent(event Event) (Registration, error) {
 		return Registration{}, errors.New("public key doesn't match public key from event")
 	}
 
+	var preferences Preferences
+	if v.Preferences != nil {
+		preferences = *v.Preferences
+	}
+
 	return Registration{
-		apnsToken: apnsToken,
-		publicKey: publicKey,
-		relays:    relays,
+		pushToken:        pushToken,
+		notificationMode: notificationMode,
+		preferences:      preferences,
+		publicKey:        publicKey,
+		relays:           relays,
 	}, nil
 }
 
+// Older clients only send an APNs token while newer ones specify the platform
+// explicitly. Browsers send their Web Push subscription instead of a token.
+func newPushToken(apnsTokenHex string, pushToken *pushTokenTransport) (PushToken, error) {
+	switch {
+	case pushToken != nil && apnsTokenHex != "":
+		return PushToken{}, errors.New("both the apns token and the push token are set")
+	case pushToken != nil:
+		platform, err := NewPushTokenPlatform(pushToken.Platform)
+		if err != nil {
+			return PushToken{}, errors.Wrap(err, "error creating a platform")
+		}
+		if platform == PushTokenPlatformWebPush {
+			return newWebPushPushToken(pushToken)
+		}
+		return NewPushToken(platform, pushToken.Token)
+	default:
+		apnsToken, err := NewAPNSTokenFromHex(apnsTokenHex)
+		if err != nil {
+			return PushToken{}, errors.Wrap(err, "error creating an apns token")
+		}
+		return NewAPNSPushToken(apnsToken), nil
+	}
+}
+
+func newWebPushPushToken(v *pushTokenTransport) (PushToken, error) {
+	if v.Subscription == nil {
+		return PushToken{}, errors.New("missing web push subscription")
+	}
+
+	if v.Token != "" {
+		return PushToken{}, errors.New("web push registrations shouldn't set the token")
+	}
+
+	subscription, err := NewWebPushSubscription(
+		v.Subscription.Endpoint,
+		v.Subscription.Keys.P256dh,
+		v.Subscription.Keys.Auth,
+	)
+	if err != nil {
+		return PushToken{}, errors.Wrap(err, "error creating a web push subscription")
+	}
+
+	return NewWebPushPushToken(subscription)
+}
+
 func newRelays(v registrationTransport) ([]RelayAddress, error) {
+	relays, err := newRelayAddresses(v.Relays)
+	if err != nil {
+		return nil, errors.Wrap(err, "error creating relay addresses")
+	}
+
+	if len(relays) == 0 {
+		return nil, errors.New("missing relays")
+	}
+
+	return relays, nil
+}
+
+func newRelayAddresses(transports []relayTransport) ([]RelayAddress, error) {
 	var relays []RelayAddress
-	for _, relayTransport := range v.Relays {
+	for _, relayTransport := range transports {
 		address, err := NewRelayAddress(relayTransport.Address)
 		if err != nil {
 			return nil, errors.Wrap(err, "error creating relay address")
 		}
 		relays = append(relays, address)
 	}
+	return relays, nil
+}
 
-	if len(relays) == 0 {
-		return nil, errors.New("missing relays")
-	}
+func (r Registration) PushToken() PushToken {
+	return r.pushToken
+}
 
-	return relays, nil
+func (r Registration) NotificationMode() NotificationMode {
+	return r.notificationMode
+}
+
+func (r Registration) Preferences() Preferences {
+	return r.preferences
 }
 
-func (r Registration) APNSToken() APNSToken {
-	return r.apnsToken
+func (r Registration) RegisteredPushToken() RegisteredPushToken {
+	return MustNewRegisteredPushToken(r.pushToken, r.notificationMode, r.preferences)
 }
 
 func (p Registration) PublicKey() PublicKey {
@@ -121,10 +208,36 @@ func (r RelayAddress) HostWithoutPort() string {
 	return r.hostWithoutPort
 }
 
+// ShouldBeSkipped returns true if the service shouldn't connect to this relay
+// even if it was registered by one of the clients.
+func (r RelayAddress) ShouldBeSkipped() bool {
+	return endsWithAny(r.hostWithoutPort, relaySuffixesToSkip)
+}
+
+// endsWithAny checks if the given string ends with any of the strings in the
+// list.
+func endsWithAny(s string, list []string) bool {
+	for _, suffix := range list {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 type registrationTransport struct {
-	APNSToken string           `json:"apnsToken"`
-	PublicKey string           `json:"publicKey"`
-	Relays    []relayTransport `json:"relays"`
+	APNSToken        string              `json:"apnsToken"`
+	PushToken        *pushTokenTransport `json:"pushToken"`
+	NotificationMode string              `json:"notificationMode"`
+	Preferences      *Preferences        `json:"preferences"`
+	PublicKey        string              `json:"publicKey"`
+	Relays           []relayTransport    `json:"relays"`
+}
+
+type pushTokenTransport struct {
+	Platform     string                        `json:"platform"`
+	Token        string                        `json:"token"`
+	Subscription *webPushSubscriptionTransport `json:"subscription"`
 }
 
 type relayTransport struct {
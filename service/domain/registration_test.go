@@ -0,0 +1,239 @@
+package domain_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRegistrationFromEvent_PushTokens(t *testing.T) {
+	apnsToken := fixtures.SomeAPNSPushToken()
+	fcmToken := fixtures.SomeFCMPushToken()
+	webPushSubscription := fixtures.SomeWebPushSubscription()
+	webPushToken, err := domain.NewWebPushPushToken(webPushSubscription)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		Name string
+
+		Token string
+
+		ExpectedToken domain.PushToken
+		ExpectedError bool
+	}{
+		{
+			Name:          "legacy_apns_token",
+			Token:         fmt.Sprintf(`"apnsToken": "%s"`, apnsToken.Token()),
+			ExpectedToken: apnsToken,
+		},
+		{
+			Name:          "apns_push_token",
+			Token:         fmt.Sprintf(`"pushToken": {"platform": "apns", "token": "%s"}`, apnsToken.Token()),
+			ExpectedToken: apnsToken,
+		},
+		{
+			Name:          "fcm_push_token",
+			Token:         fmt.Sprintf(`"pushToken": {"platform": "fcm", "token": "%s"}`, fcmToken.Token()),
+			ExpectedToken: fcmToken,
+		},
+		{
+			Name: "web_push_subscription",
+			Token: fmt.Sprintf(
+				`"pushToken": {"platform": "webpush", "subscription": {"endpoint": "%s", "keys": {"p256dh": "%s", "auth": "%s"}}}`,
+				webPushSubscription.Endpoint(),
+				webPushSubscription.P256dh(),
+				webPushSubscription.Auth(),
+			),
+			ExpectedToken: webPushToken,
+		},
+		{
+			Name: "web_push_subscription_with_padded_keys",
+			Token: fmt.Sprintf(
+				`"pushToken": {"platform": "webpush", "subscription": {"endpoint": "%s", "keys": {"p256dh": "%s=", "auth": "%s=="}}}`,
+				webPushSubscription.Endpoint(),
+				webPushSubscription.P256dh(),
+				webPushSubscription.Auth(),
+			),
+			ExpectedToken: webPushToken,
+		},
+		{
+			Name:          "web_push_without_subscription",
+			Token:         `"pushToken": {"platform": "webpush", "token": "some-token"}`,
+			ExpectedError: true,
+		},
+		{
+			Name: "web_push_subscription_with_invalid_key",
+			Token: fmt.Sprintf(
+				`"pushToken": {"platform": "webpush", "subscription": {"endpoint": "%s", "keys": {"p256dh": "invalid", "auth": "%s"}}}`,
+				webPushSubscription.Endpoint(),
+				webPushSubscription.Auth(),
+			),
+			ExpectedError: true,
+		},
+		{
+			Name:          "unknown_platform",
+			Token:         fmt.Sprintf(`"pushToken": {"platform": "unknown", "token": "%s"}`, fcmToken.Token()),
+			ExpectedError: true,
+		},
+		{
+			Name:          "both_tokens",
+			Token:         fmt.Sprintf(`"apnsToken": "%s", "pushToken": {"platform": "fcm", "token": "%s"}`, apnsToken.Token(), fcmToken.Token()),
+			ExpectedError: true,
+		},
+		{
+			Name:          "missing_token",
+			Token:         `"apnsToken": ""`,
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			publicKey, secretKey := fixtures.SomeKeyPair()
+
+			event := someRegistrationEvent(t, publicKey, secretKey, testCase.Token)
+
+			registration, err := domain.NewRegistrationFromEvent(event)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, testCase.ExpectedToken, registration.PushToken())
+			require.Equal(t, publicKey, registration.PublicKey())
+		})
+	}
+}
+
+func TestNewRegistrationFromEvent_NotificationMode(t *testing.T) {
+	apnsToken := fixtures.SomeAPNSPushToken()
+
+	testCases := []struct {
+		Name string
+
+		NotificationMode string
+
+		ExpectedNotificationMode domain.NotificationMode
+		ExpectedError            bool
+	}{
+		{
+			Name:                     "missing",
+			ExpectedNotificationMode: domain.NotificationModeSilent,
+		},
+		{
+			Name:                     "silent",
+			NotificationMode:         `, "notificationMode": "silent"`,
+			ExpectedNotificationMode: domain.NotificationModeSilent,
+		},
+		{
+			Name:                     "visible",
+			NotificationMode:         `, "notificationMode": "visible"`,
+			ExpectedNotificationMode: domain.NotificationModeVisible,
+		},
+		{
+			Name:             "unknown",
+			NotificationMode: `, "notificationMode": "loud"`,
+			ExpectedError:    true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			publicKey, secretKey := fixtures.SomeKeyPair()
+
+			token := fmt.Sprintf(`"apnsToken": "%s"`, apnsToken.Token()) + testCase.NotificationMode
+			event := someRegistrationEvent(t, publicKey, secretKey, token)
+
+			registration, err := domain.NewRegistrationFromEvent(event)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, testCase.ExpectedNotificationMode, registration.NotificationMode())
+		})
+	}
+}
+
+func someRegistrationEvent(t *testing.T, publicKey domain.PublicKey, secretKey string, token string) domain.Event {
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      12345,
+		Content: fmt.Sprintf(
+			`{"publicKey": "%s", "relays": [{"address": "%s"}], %s}`,
+			publicKey.Hex(),
+			fixtures.SomeRelayAddress().String(),
+			token,
+		),
+	}
+
+	err := libevent.Sign(secretKey)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	return event
+}
+
+func TestNewRegistrationFromEvent_Preferences(t *testing.T) {
+	apnsToken := fixtures.SomeAPNSPushToken()
+	mutedPublicKey, _ := fixtures.SomeKeyPair()
+
+	testCases := []struct {
+		Name string
+
+		Preferences string
+
+		ExpectedReactionsEnabled bool
+		ExpectedMuted            bool
+		ExpectedError            bool
+	}{
+		{
+			Name:                     "missing",
+			ExpectedReactionsEnabled: true,
+		},
+		{
+			Name:                     "set",
+			Preferences:              fmt.Sprintf(`, "preferences": {"enabledTypes": ["mention"], "mutedPublicKeys": ["%s"]}`, mutedPublicKey.Hex()),
+			ExpectedReactionsEnabled: false,
+			ExpectedMuted:            true,
+		},
+		{
+			Name:          "unknown_type",
+			Preferences:   `, "preferences": {"enabledTypes": ["something"]}`,
+			ExpectedError: true,
+		},
+		{
+			Name:          "invalid_quiet_hours",
+			Preferences:   `, "preferences": {"quietHours": {"start": "22:00", "end": "07:00", "timezone": "Mars/Olympus"}}`,
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			publicKey, secretKey := fixtures.SomeKeyPair()
+
+			token := fmt.Sprintf(`"apnsToken": "%s"`, apnsToken.Token()) + testCase.Preferences
+			event := someRegistrationEvent(t, publicKey, secretKey, token)
+
+			registration, err := domain.NewRegistrationFromEvent(event)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, testCase.ExpectedReactionsEnabled, registration.Preferences().TypeEnabled(domain.NotificationTypeReaction))
+			require.Equal(t, testCase.ExpectedMuted, registration.Preferences().PublicKeyMuted(mutedPublicKey))
+			require.Equal(t, registration.Preferences(), registration.RegisteredPushToken().Preferences())
+		})
+	}
+}
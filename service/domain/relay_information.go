@@ -0,0 +1,104 @@
+package domain
+
+import (
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+)
+
+// RelayInformation is retrieved from NIP-11 relay information documents.
+type RelayInformation struct {
+	supportedNIPs []int
+	limitations   RelayLimitations
+}
+
+func NewRelayInformation(supportedNIPs []int, limitations RelayLimitations) RelayInformation {
+	return RelayInformation{
+		supportedNIPs: internal.CopySlice(supportedNIPs),
+		limitations:   limitations,
+	}
+}
+
+func (i RelayInformation) SupportedNIPs() []int {
+	return internal.CopySlice(i.supportedNIPs)
+}
+
+func (i RelayInformation) SupportsNIP(nip int) bool {
+	for _, v := range i.supportedNIPs {
+		if v == nip {
+			return true
+		}
+	}
+	return false
+}
+
+func (i RelayInformation) Limitations() RelayLimitations {
+	return i.limitations
+}
+
+// ShouldBeSkipped returns true if the service can't download events from this
+// relay as it doesn't authenticate with or pay relays.
+func (i RelayInformation) ShouldBeSkipped() bool {
+	return i.limitations.authRequired || i.limitations.paymentRequired
+}
+
+// RelayLimitations are advertised by relays in their NIP-11 relay information
+// documents. Zero values mean that the relay didn't specify the limitation.
+type RelayLimitations struct {
+	maxMessageLength int
+	maxSubscriptions int
+	maxFilters       int
+	authRequired     bool
+	paymentRequired  bool
+}
+
+func NewRelayLimitations(
+	maxMessageLength int,
+	maxSubscriptions int,
+	maxFilters int,
+	authRequired bool,
+	paymentRequired bool,
+) (RelayLimitations, error) {
+	if maxMessageLength < 0 {
+		return RelayLimitations{}, errors.New("max message length can't be negative")
+	}
+	if maxSubscriptions < 0 {
+		return RelayLimitations{}, errors.New("max subscriptions can't be negative")
+	}
+	if maxFilters < 0 {
+		return RelayLimitations{}, errors.New("max filters can't be negative")
+	}
+	return RelayLimitations{
+		maxMessageLength: maxMessageLength,
+		maxSubscriptions: maxSubscriptions,
+		maxFilters:       maxFilters,
+		authRequired:     authRequired,
+		paymentRequired:  paymentRequired,
+	}, nil
+}
+
+// MaxMessageLength returns false if the relay didn't specify it.
+func (l RelayLimitations) MaxMessageLength() (int, bool) {
+	return l.maxMessageLength, l.maxMessageLength > 0
+}
+
+// MaxSubscriptions returns false if the relay didn't specify it.
+func (l RelayLimitations) MaxSubscriptions() (int, bool) {
+	return l.maxSubscriptions, l.maxSubscriptions > 0
+}
+
+// MaxFilters returns false if the relay didn't specify it.
+func (l RelayLimitations) MaxFilters() (int, bool) {
+	return l.maxFilters, l.maxFilters > 0
+}
+
+// AuthRequired is true if the relay requires NIP-42 authentication before
+// any other actions can be performed.
+func (l RelayLimitations) AuthRequired() bool {
+	return l.authRequired
+}
+
+// PaymentRequired is true if the relay requires payment before any other
+// actions can be performed.
+func (l RelayLimitations) PaymentRequired() bool {
+	return l.paymentRequired
+}
@@ -0,0 +1,106 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/planetary-social/go-notification-service/internal"
+)
+
+var tagRelayListEntry = MustNewEventTagName("r")
+
+// relayMarkerRead marks relays which are only used for reading, see NIP-65.
+// Relays without a marker are used for both reading and writing.
+const relayMarkerRead = "read"
+
+// maxRelayListReadRelays limits how many relays are used per relay list as
+// NIP-65 recommends keeping the lists small and every relay means another
+// connection.
+const maxRelayListReadRelays = 10
+
+// RelaySource describes how a relay was discovered.
+type RelaySource struct {
+	s string
+}
+
+func (s RelaySource) String() string {
+	return s.s
+}
+
+var (
+	// RelaySourceRegistration marks relays which were listed in registrations.
+	RelaySourceRegistration = RelaySource{"registration"}
+
+	// RelaySourceRelayList marks relays which registered public keys listed
+	// as their read relays in their NIP-65 relay lists.
+	RelaySourceRelayList = RelaySource{"nip65"}
+)
+
+// RelayList is created from events of kind 10002, see NIP-65. Only read relays
+// are kept as those are the relays which other people publish mentions of the
+// author to. Malformed relay addresses are skipped.
+type RelayList struct {
+	event      Event
+	readRelays []RelayAddress
+}
+
+func NewRelayList(event Event) (RelayList, error) {
+	if event.Kind() != EventKindRelayList {
+		return RelayList{}, fmt.Errorf("invalid event kind '%d'", event.Kind().Int())
+	}
+
+	relayList := RelayList{
+		event: event,
+	}
+
+	for _, tag := range event.Tags() {
+		if tag.Name() != tagRelayListEntry || !isReadRelayTag(tag) {
+			continue
+		}
+
+		address, err := NewRelayAddress(tag.FirstValue())
+		if err != nil {
+			continue
+		}
+
+		if relayList.containsReadRelay(address) {
+			continue
+		}
+
+		relayList.readRelays = append(relayList.readRelays, address)
+		if len(relayList.readRelays) >= maxRelayListReadRelays {
+			break
+		}
+	}
+
+	return relayList, nil
+}
+
+// Event returns the event from which the relay list was created.
+func (l RelayList) Event() Event {
+	return l.event
+}
+
+func (l RelayList) Author() PublicKey {
+	return l.event.PubKey()
+}
+
+func (l RelayList) ReadRelays() []RelayAddress {
+	return internal.CopySlice(l.readRelays)
+}
+
+func (l RelayList) containsReadRelay(address RelayAddress) bool {
+	for _, v := range l.readRelays {
+		if v == address {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadRelayTag(tag EventTag) bool {
+	if len(tag.tag) < 3 {
+		return true
+	}
+	marker := tag.tag[2]
+	return marker == "" || marker == relayMarkerRead
+}
@@ -0,0 +1,61 @@
+package domain_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRelayList_OnlyReadRelaysAreKept(t *testing.T) {
+	author, secretKey := fixtures.SomeKeyPair()
+
+	relayList, err := domain.NewRelayList(fixtures.RelayListEvent(t, secretKey, time.Now(), nostr.Tags{
+		{"r", "wss://read-and-write.example.com"},
+		{"r", "wss://read.example.com", "read"},
+		{"r", "wss://write.example.com", "write"},
+		{"r", "wss://read.example.com/"},
+		{"r", "https://invalid.example.com"},
+		{"p", author.Hex()},
+	}))
+	require.NoError(t, err)
+
+	require.Equal(t, author, relayList.Author())
+	require.Equal(t,
+		[]domain.RelayAddress{
+			relayAddress(t, "wss://read-and-write.example.com"),
+			relayAddress(t, "wss://read.example.com"),
+		},
+		relayList.ReadRelays(),
+	)
+}
+
+func TestNewRelayList_NumberOfRelaysIsLimited(t *testing.T) {
+	_, secretKey := fixtures.SomeKeyPair()
+
+	var tags nostr.Tags
+	for i := 0; i < 100; i++ {
+		tags = append(tags, nostr.Tag{"r", fmt.Sprintf("wss://relay%d.example.com", i)})
+	}
+
+	relayList, err := domain.NewRelayList(fixtures.RelayListEvent(t, secretKey, time.Now(), tags))
+	require.NoError(t, err)
+	require.Len(t, relayList.ReadRelays(), 10)
+}
+
+func TestNewRelayList_OtherKindsAreRejected(t *testing.T) {
+	_, secretKey := fixtures.SomeKeyPair()
+
+	_, err := domain.NewRelayList(fixtures.MuteListEvent(t, secretKey, time.Now(), nil))
+	require.Error(t, err)
+}
+
+func relayAddress(tb testing.TB, s string) domain.RelayAddress {
+	address, err := domain.NewRelayAddress(s)
+	require.NoError(tb, err)
+	return address
+}
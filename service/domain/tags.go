@@ -6,7 +6,20 @@ import (
 	"github.com/boreq/errors"
 )
 
-var tagProfile = MustNewEventTagName("p")
+var (
+	tagProfile = MustNewEventTagName("p")
+	tagEvent   = MustNewEventTagName("e")
+)
+
+// Event tag markers, see NIP-10.
+const (
+	// eventTagMarkerMention marks event tags which cite events instead of
+	// replying to them.
+	eventTagMarkerMention = "mention"
+
+	// eventTagMarkerRoot marks the event which started the thread.
+	eventTagMarkerRoot = "root"
+)
 
 func GetMentionsFromTags(tags []EventTag) ([]PublicKey, error) {
 	var mentions []PublicKey
@@ -24,6 +37,54 @@ func GetMentionsFromTags(tags []EventTag) ([]PublicKey, error) {
 	return mentions, nil
 }
 
+// GetReferencedEventsFromTags returns ids of all events referenced using event
+// tags. Malformed tags are skipped.
+func GetReferencedEventsFromTags(tags []EventTag) []EventId {
+	var result []EventId
+	for _, tag := range tags {
+		if !tag.IsEvent() {
+			continue
+		}
+		id, err := NewEventId(tag.FirstValue())
+		if err != nil {
+			continue
+		}
+		result = append(result, id)
+	}
+	return result
+}
+
+// GetThreadRootFromTags returns the id of the event which started the thread
+// that the event with the given tags replies to. Under the deprecated
+// positional scheme the first event tag points to the root.
+func GetThreadRootFromTags(tags []EventTag) (EventId, bool, error) {
+	var root *EventTag
+	for i := range tags {
+		tag := tags[i]
+		if !tag.IsReply() {
+			continue
+		}
+		if tag.EventMarker() == eventTagMarkerRoot {
+			root = &tag
+			break
+		}
+		if root == nil && tag.EventMarker() == "" {
+			root = &tag
+		}
+	}
+
+	if root == nil {
+		return EventId{}, false, nil
+	}
+
+	id, err := NewEventId(root.FirstValue())
+	if err != nil {
+		return EventId{}, false, errors.Wrapf(err, "error creating an event id from tag '%+v'", root)
+	}
+
+	return id, true, nil
+}
+
 type EventTag struct {
 	name EventTagName
 	tag  []string
@@ -65,6 +126,26 @@ func (e EventTag) Profile() (PublicKey, error) {
 	return NewPublicKeyFromHex(e.tag[1])
 }
 
+func (e EventTag) IsEvent() bool {
+	return e.name == tagEvent
+}
+
+// EventMarker returns the marker of an event tag or an empty string if the
+// tag uses the deprecated positional scheme.
+func (e EventTag) EventMarker() string {
+	if len(e.tag) < 4 {
+		return ""
+	}
+	return e.tag[3]
+}
+
+// IsReply checks if the event tag marks the event as a reply according to
+// NIP-10. Unmarked tags are treated as replies as that is what they meant
+// under the deprecated positional scheme.
+func (e EventTag) IsReply() bool {
+	return e.IsEvent() && e.EventMarker() != eventTagMarkerMention
+}
+
 type EventTagName struct {
 	s string
 }
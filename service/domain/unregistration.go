@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal"
+)
+
+// Unregistration is sent by clients which no longer want to receive
+// notifications on a device e.g. because the user logged out. The public key
+// is removed from the specified relays and from all relays once it has no push
+// tokens left.
+type Unregistration struct {
+	pushToken PushToken
+	publicKey PublicKey
+	relays    []RelayAddress
+}
+
+func NewUnregistrationFromEvent(event Event) (Unregistration, error) {
+	if event.Kind() != EventKindUnregistration {
+		return Unregistration{}, fmt.Errorf("invalid event kind '%d'", event.Kind().Int())
+	}
+
+	var v unregistrationTransport
+	if err := json.Unmarshal([]byte(event.Content()), &v); err != nil {
+		return Unregistration{}, errors.Wrap(err, "error unmarshaling content")
+	}
+
+	pushToken, err := newPushToken(v.APNSToken, v.PushToken)
+	if err != nil {
+		return Unregistration{}, errors.Wrap(err, "error creating a push token")
+	}
+
+	publicKey, err := NewPublicKeyFromHex(v.PublicKey)
+	if err != nil {
+		return Unregistration{}, errors.Wrap(err, "error creating a public key")
+	}
+
+	relays, err := newRelayAddresses(v.Relays)
+	if err != nil {
+		return Unregistration{}, errors.Wrap(err, "error creating relay addresses")
+	}
+
+	if event.PubKey() != publicKey {
+		return Unregistration{}, errors.New("public key doesn't match public key from event")
+	}
+
+	return Unregistration{
+		pushToken: pushToken,
+		publicKey: publicKey,
+		relays:    relays,
+	}, nil
+}
+
+func (u Unregistration) PushToken() PushToken {
+	return u.pushToken
+}
+
+func (u Unregistration) PublicKey() PublicKey {
+	return u.publicKey
+}
+
+// Relays returns an empty list if the relays weren't specified.
+func (u Unregistration) Relays() []RelayAddress {
+	return internal.CopySlice(u.relays)
+}
+
+type unregistrationTransport struct {
+	APNSToken string              `json:"apnsToken"`
+	PushToken *pushTokenTransport `json:"pushToken"`
+	PublicKey string              `json:"publicKey"`
+	Relays    []relayTransport    `json:"relays"`
+}
@@ -0,0 +1,105 @@
+package domain_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewUnregistrationFromEvent(t *testing.T) {
+	apnsToken := fixtures.SomeAPNSPushToken()
+	relayAddress := fixtures.SomeRelayAddress()
+
+	testCases := []struct {
+		Name string
+
+		Content func(publicKey domain.PublicKey) string
+		Kind    domain.EventKind
+
+		ExpectedRelays []domain.RelayAddress
+		ExpectedError  bool
+	}{
+		{
+			Name: "without_relays",
+			Content: func(publicKey domain.PublicKey) string {
+				return fmt.Sprintf(`{"publicKey": "%s", "apnsToken": "%s"}`, publicKey.Hex(), apnsToken.Token())
+			},
+			Kind:           domain.EventKindUnregistration,
+			ExpectedRelays: nil,
+		},
+		{
+			Name: "with_relays",
+			Content: func(publicKey domain.PublicKey) string {
+				return fmt.Sprintf(`{"publicKey": "%s", "apnsToken": "%s", "relays": [{"address": "%s"}]}`, publicKey.Hex(), apnsToken.Token(), relayAddress.String())
+			},
+			Kind:           domain.EventKindUnregistration,
+			ExpectedRelays: []domain.RelayAddress{relayAddress},
+		},
+		{
+			Name: "invalid_relay",
+			Content: func(publicKey domain.PublicKey) string {
+				return fmt.Sprintf(`{"publicKey": "%s", "apnsToken": "%s", "relays": [{"address": "https://example.com"}]}`, publicKey.Hex(), apnsToken.Token())
+			},
+			Kind:          domain.EventKindUnregistration,
+			ExpectedError: true,
+		},
+		{
+			Name: "public_key_of_someone_else",
+			Content: func(publicKey domain.PublicKey) string {
+				someoneElse, _ := fixtures.SomeKeyPair()
+				return fmt.Sprintf(`{"publicKey": "%s", "apnsToken": "%s"}`, someoneElse.Hex(), apnsToken.Token())
+			},
+			Kind:          domain.EventKindUnregistration,
+			ExpectedError: true,
+		},
+		{
+			Name: "missing_token",
+			Content: func(publicKey domain.PublicKey) string {
+				return fmt.Sprintf(`{"publicKey": "%s"}`, publicKey.Hex())
+			},
+			Kind:          domain.EventKindUnregistration,
+			ExpectedError: true,
+		},
+		{
+			Name: "other_kind",
+			Content: func(publicKey domain.PublicKey) string {
+				return fmt.Sprintf(`{"publicKey": "%s", "apnsToken": "%s"}`, publicKey.Hex(), apnsToken.Token())
+			},
+			Kind:          domain.MustNewEventKind(12345),
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			publicKey, secretKey := fixtures.SomeKeyPair()
+
+			libevent := nostr.Event{
+				CreatedAt: nostr.Now(),
+				Kind:      testCase.Kind.Int(),
+				Content:   testCase.Content(publicKey),
+			}
+
+			err := libevent.Sign(secretKey)
+			require.NoError(t, err)
+
+			event, err := domain.NewEvent(libevent)
+			require.NoError(t, err)
+
+			unregistration, err := domain.NewUnregistrationFromEvent(event)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, publicKey, unregistration.PublicKey())
+			require.Equal(t, apnsToken, unregistration.PushToken())
+			require.ElementsMatch(t, testCase.ExpectedRelays, unregistration.Relays())
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"crypto/ecdh"
+	"encoding/base64"
+	"encoding/json"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/boreq/errors"
+)
+
+const webPushAuthSecretLength = 16
+
+// WebPushSubscription is the PushSubscription object created by a browser
+// using the Push API. See RFC 8030 and RFC 8291.
+type WebPushSubscription struct {
+	endpoint string
+	p256dh   string
+	auth     string
+}
+
+func NewWebPushSubscription(endpoint, p256dh, auth string) (WebPushSubscription, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return WebPushSubscription{}, errors.Wrap(err, "error parsing the endpoint")
+	}
+
+	if u.Scheme != "https" {
+		return WebPushSubscription{}, errors.New("endpoint must be an https url")
+	}
+
+	if u.Hostname() == "" {
+		return WebPushSubscription{}, errors.New("endpoint is missing the host")
+	}
+
+	if !webPushHostIsAllowed(u.Hostname()) {
+		return WebPushSubscription{}, errors.New("endpoint must be a public host")
+	}
+
+	p256dhBytes, err := decodeWebPushKey(p256dh)
+	if err != nil {
+		return WebPushSubscription{}, errors.Wrap(err, "error decoding the p256dh key")
+	}
+
+	if _, err := ecdh.P256().NewPublicKey(p256dhBytes); err != nil {
+		return WebPushSubscription{}, errors.Wrap(err, "p256dh is not a valid P-256 public key")
+	}
+
+	authBytes, err := decodeWebPushKey(auth)
+	if err != nil {
+		return WebPushSubscription{}, errors.Wrap(err, "error decoding the auth secret")
+	}
+
+	if len(authBytes) != webPushAuthSecretLength {
+		return WebPushSubscription{}, errors.New("invalid auth secret length")
+	}
+
+	return WebPushSubscription{
+		endpoint: endpoint,
+		p256dh:   base64.RawURLEncoding.EncodeToString(p256dhBytes),
+		auth:     base64.RawURLEncoding.EncodeToString(authBytes),
+	}, nil
+}
+
+func NewWebPushSubscriptionFromJSON(b []byte) (WebPushSubscription, error) {
+	var v webPushSubscriptionTransport
+	if err := json.Unmarshal(b, &v); err != nil {
+		return WebPushSubscription{}, errors.Wrap(err, "error unmarshaling")
+	}
+	return NewWebPushSubscription(v.Endpoint, v.Keys.P256dh, v.Keys.Auth)
+}
+
+func (s WebPushSubscription) Endpoint() string {
+	return s.endpoint
+}
+
+// P256dh returns the public key of the user agent encoded using unpadded
+// base64url.
+func (s WebPushSubscription) P256dh() string {
+	return s.p256dh
+}
+
+// Auth returns the authentication secret encoded using unpadded base64url.
+func (s WebPushSubscription) Auth() string {
+	return s.auth
+}
+
+func (s WebPushSubscription) MarshalJSON() ([]byte, error) {
+	return json.Marshal(webPushSubscriptionTransport{
+		Endpoint: s.endpoint,
+		Keys: webPushSubscriptionKeysTransport{
+			P256dh: s.p256dh,
+			Auth:   s.auth,
+		},
+	})
+}
+
+// webPushHostIsAllowed rejects hosts which are most likely internal so that
+// registrations can't be used to make the service send requests to them. Names
+// can still resolve to internal addresses so the addresses should be checked
+// again when connecting.
+func webPushHostIsAllowed(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return WebPushAddressIsAllowed(addr)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if !strings.Contains(host, ".") {
+		return false
+	}
+
+	for _, suffix := range internalHostSuffixes {
+		if strings.HasSuffix(host, suffix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var internalHostSuffixes = []string{
+	".localhost",
+	".local",
+	".localdomain",
+	".internal",
+	".home.arpa",
+}
+
+// WebPushAddressIsAllowed returns false for addresses which aren't globally
+// routable e.g. loopback, private and link-local addresses.
+func WebPushAddressIsAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() &&
+		addr.IsGlobalUnicast() &&
+		!addr.IsPrivate() &&
+		!addr.IsLoopback() &&
+		!addr.IsLinkLocalUnicast() &&
+		!sharedAddressSpace.Contains(addr)
+}
+
+// Carrier-grade NAT, see RFC 6598.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// Browsers are inconsistent when it comes to padding and alphabets.
+func decodeWebPushKey(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	s = strings.NewReplacer("+", "-", "/", "_").Replace(s)
+	return base64.RawURLEncoding.DecodeString(s)
+}
+
+type webPushSubscriptionTransport struct {
+	Endpoint string                           `json:"endpoint"`
+	Keys     webPushSubscriptionKeysTransport `json:"keys"`
+}
+
+type webPushSubscriptionKeysTransport struct {
+	P256dh string `json:"p256dh"`
+	Auth   string `json:"auth"`
+}
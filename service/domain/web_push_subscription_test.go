@@ -0,0 +1,77 @@
+package domain_test
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWebPushSubscription_Endpoint(t *testing.T) {
+	keys := fixtures.SomeWebPushSubscription()
+
+	testCases := []struct {
+		Endpoint      string
+		ExpectedError bool
+	}{
+		{Endpoint: "https://fcm.googleapis.com/fcm/send/some-id"},
+		{Endpoint: "https://updates.push.services.mozilla.com/wpush/v2/some-id"},
+		{Endpoint: "https://push.example.com:8443/some-id"},
+		{Endpoint: "https://8.8.8.8/some-id"},
+		{Endpoint: "https://[2001:4860:4860::8888]/some-id"},
+		{Endpoint: "http://push.example.com/some-id", ExpectedError: true},
+		{Endpoint: "https:///some-id", ExpectedError: true},
+		{Endpoint: "https://localhost/some-id", ExpectedError: true},
+		{Endpoint: "https://something.localhost/some-id", ExpectedError: true},
+		{Endpoint: "https://metadata.google.internal/some-id", ExpectedError: true},
+		{Endpoint: "https://METADATA.GOOGLE.INTERNAL./some-id", ExpectedError: true},
+		{Endpoint: "https://printer.local/some-id", ExpectedError: true},
+		{Endpoint: "https://metadata/some-id", ExpectedError: true},
+		{Endpoint: "https://127.0.0.1/some-id", ExpectedError: true},
+		{Endpoint: "https://10.0.0.1/some-id", ExpectedError: true},
+		{Endpoint: "https://172.16.0.1/some-id", ExpectedError: true},
+		{Endpoint: "https://192.168.0.1/some-id", ExpectedError: true},
+		{Endpoint: "https://169.254.169.254/some-id", ExpectedError: true},
+		{Endpoint: "https://100.64.0.1/some-id", ExpectedError: true},
+		{Endpoint: "https://0.0.0.0/some-id", ExpectedError: true},
+		{Endpoint: "https://[::1]/some-id", ExpectedError: true},
+		{Endpoint: "https://[fe80::1]/some-id", ExpectedError: true},
+		{Endpoint: "https://[fd00::1]/some-id", ExpectedError: true},
+		{Endpoint: "https://[::ffff:127.0.0.1]/some-id", ExpectedError: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Endpoint, func(t *testing.T) {
+			_, err := domain.NewWebPushSubscription(testCase.Endpoint, keys.P256dh(), keys.Auth())
+			if testCase.ExpectedError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestWebPushAddressIsAllowed(t *testing.T) {
+	testCases := []struct {
+		Address  string
+		Expected bool
+	}{
+		{Address: "8.8.8.8", Expected: true},
+		{Address: "2001:4860:4860::8888", Expected: true},
+		{Address: "127.0.0.1"},
+		{Address: "10.1.2.3"},
+		{Address: "169.254.169.254"},
+		{Address: "::1"},
+		{Address: "::ffff:10.0.0.1"},
+		{Address: "224.0.0.1"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Address, func(t *testing.T) {
+			require.Equal(t, testCase.Expected, domain.WebPushAddressIsAllowed(netip.MustParseAddr(testCase.Address)))
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/boreq/errors"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+var (
+	tagZapSender      = MustNewEventTagName("P")
+	tagZapBolt11      = MustNewEventTagName("bolt11")
+	tagZapDescription = MustNewEventTagName("description")
+	tagZapAmount      = MustNewEventTagName("amount")
+)
+
+// ZapReceipt is created from events of kind 9735, see NIP-57. Receipts are
+// published by the recipient's wallet so the sender has to be read from the
+// zap request embedded in them.
+type ZapReceipt struct {
+	sender PublicKey
+
+	// amount in millisatoshis
+	amount    int64
+	hasAmount bool
+}
+
+func NewZapReceipt(event Event) (ZapReceipt, error) {
+	if event.Kind() != EventKindZapReceipt {
+		return ZapReceipt{}, fmt.Errorf("invalid event kind '%d'", event.Kind().Int())
+	}
+
+	var (
+		receipt ZapReceipt
+		request *nostr.Event
+	)
+
+	for _, tag := range event.Tags() {
+		switch tag.Name() {
+		case tagZapDescription:
+			var v nostr.Event
+			if err := json.Unmarshal([]byte(tag.FirstValue()), &v); err != nil {
+				return ZapReceipt{}, errors.Wrap(err, "error unmarshaling the zap request")
+			}
+			request = &v
+		case tagZapBolt11:
+			amount, ok, err := bolt11Amount(tag.FirstValue())
+			if err != nil {
+				return ZapReceipt{}, errors.Wrap(err, "error reading the invoice amount")
+			}
+			receipt.amount, receipt.hasAmount = amount, ok
+		}
+	}
+
+	if request == nil {
+		return ZapReceipt{}, errors.New("missing zap request")
+	}
+
+	ok, err := request.CheckSignature()
+	if err != nil {
+		return ZapReceipt{}, errors.Wrap(err, "error checking the zap request signature")
+	}
+	if !ok {
+		return ZapReceipt{}, errors.New("invalid zap request signature")
+	}
+
+	sender, err := NewPublicKeyFromHex(request.PubKey)
+	if err != nil {
+		return ZapReceipt{}, errors.Wrap(err, "error reading the sender")
+	}
+	receipt.sender = sender
+
+	if senderTag, ok := findTag(event.Tags(), tagZapSender); ok && senderTag.FirstValue() != sender.Hex() {
+		return ZapReceipt{}, errors.New("sender tag doesn't match the zap request")
+	}
+
+	if !receipt.hasAmount {
+		if amountTag := request.Tags.GetFirst([]string{tagZapAmount.String()}); amountTag != nil && len(*amountTag) > 1 {
+			amount, ok := new(big.Int).SetString((*amountTag)[1], 10)
+			if !ok || !amount.IsInt64() || amount.Sign() < 0 {
+				return ZapReceipt{}, fmt.Errorf("invalid zap request amount '%s'", (*amountTag)[1])
+			}
+			receipt.amount, receipt.hasAmount = amount.Int64(), true
+		}
+	}
+
+	return receipt, nil
+}
+
+func (z ZapReceipt) Sender() PublicKey {
+	return z.sender
+}
+
+// Amount returns the amount in millisatoshis if it is known.
+func (z ZapReceipt) Amount() (int64, bool) {
+	return z.amount, z.hasAmount
+}
+
+func findTag(tags []EventTag, name EventTagName) (EventTag, bool) {
+	for _, tag := range tags {
+		if tag.Name() == name {
+			return tag, true
+		}
+	}
+	return EventTag{}, false
+}
+
+// bolt11Multipliers convert amounts to millisatoshis. Amounts in picobitcoin
+// have to be divided instead.
+var bolt11Multipliers = map[byte]int64{
+	'm': 100_000_000,
+	'u': 100_000,
+	'n': 100,
+}
+
+const (
+	bolt11MsatsPerBitcoin = 100_000_000_000
+	bolt11PicoPerMsat     = 10
+)
+
+// bolt11Amount reads the amount in millisatoshis from the human readable part
+// of a BOLT 11 invoice. Invoices don't have to specify an amount.
+func bolt11Amount(invoice string) (int64, bool, error) {
+	invoice = strings.ToLower(invoice)
+
+	separator := strings.LastIndex(invoice, "1")
+	if !strings.HasPrefix(invoice, "ln") || separator < 0 {
+		return 0, false, errors.New("malformed invoice")
+	}
+
+	hrp := invoice[len("ln"):separator]
+	amountStart := strings.IndexAny(hrp, "0123456789")
+	if amountStart < 0 {
+		return 0, false, nil
+	}
+
+	digits := hrp[amountStart:]
+	unit := digits[len(digits)-1]
+	if unit < '0' || unit > '9' {
+		digits = digits[:len(digits)-1]
+	}
+
+	amount, ok := new(big.Int).SetString(digits, 10)
+	if !ok {
+		return 0, false, fmt.Errorf("invalid amount '%s'", hrp[amountStart:])
+	}
+
+	switch {
+	case unit >= '0' && unit <= '9':
+		amount.Mul(amount, big.NewInt(bolt11MsatsPerBitcoin))
+	case unit == 'p':
+		remainder := new(big.Int)
+		amount.DivMod(amount, big.NewInt(bolt11PicoPerMsat), remainder)
+		if remainder.Sign() != 0 {
+			return 0, false, errors.New("amount is not a whole number of millisatoshis")
+		}
+	case bolt11Multipliers[unit] != 0:
+		amount.Mul(amount, big.NewInt(bolt11Multipliers[unit]))
+	default:
+		return 0, false, fmt.Errorf("unknown multiplier '%c'", unit)
+	}
+
+	if !amount.IsInt64() {
+		return 0, false, errors.New("amount is too large")
+	}
+
+	return amount.Int64(), true, nil
+}
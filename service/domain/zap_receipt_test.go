@@ -0,0 +1,114 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/planetary-social/go-notification-service/internal/fixtures"
+	"github.com/planetary-social/go-notification-service/service/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewZapReceipt(t *testing.T) {
+	testCases := []struct {
+		Name string
+
+		Bolt11 string
+
+		ExpectedAmount    int64
+		ExpectedHasAmount bool
+		ExpectedError     bool
+	}{
+		{
+			Name:              "bitcoin",
+			Bolt11:            "lnbc211pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedAmount:    2_100_000_000_000,
+			ExpectedHasAmount: true,
+		},
+		{
+			Name:              "milli",
+			Bolt11:            "lnbc20m1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedAmount:    2_000_000_000,
+			ExpectedHasAmount: true,
+		},
+		{
+			Name:              "micro",
+			Bolt11:            "lnbc2500u1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedAmount:    250_000_000,
+			ExpectedHasAmount: true,
+		},
+		{
+			Name:              "nano",
+			Bolt11:            "lnbc210n1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedAmount:    21_000,
+			ExpectedHasAmount: true,
+		},
+		{
+			Name:              "pico",
+			Bolt11:            "lnbc10p1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedAmount:    1,
+			ExpectedHasAmount: true,
+		},
+		{
+			Name:          "pico_not_a_whole_millisatoshi",
+			Bolt11:        "lnbc15p1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedError: true,
+		},
+		{
+			Name:              "testnet",
+			Bolt11:            "lntb20m1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedAmount:    2_000_000_000,
+			ExpectedHasAmount: true,
+		},
+		{
+			Name:              "no_amount",
+			Bolt11:            "lnbc1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypq",
+			ExpectedHasAmount: false,
+		},
+		{
+			Name:          "malformed",
+			Bolt11:        "notaninvoice",
+			ExpectedError: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			sender, senderSecretKey := fixtures.SomeKeyPair()
+			recipient, _ := fixtures.SomeKeyPair()
+
+			event := fixtures.ZapReceipt(t, senderSecretKey, recipient, testCase.Bolt11)
+
+			receipt, err := domain.NewZapReceipt(event)
+			if testCase.ExpectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, sender, receipt.Sender())
+
+			amount, ok := receipt.Amount()
+			require.Equal(t, testCase.ExpectedHasAmount, ok)
+			require.Equal(t, testCase.ExpectedAmount, amount)
+		})
+	}
+}
+
+func TestNewZapReceipt_RejectsOtherKinds(t *testing.T) {
+	_, sk := fixtures.SomeKeyPair()
+
+	libevent := nostr.Event{
+		CreatedAt: nostr.Now(),
+		Kind:      domain.EventKindNote.Int(),
+		Tags:      nostr.Tags{},
+	}
+	err := libevent.Sign(sk)
+	require.NoError(t, err)
+
+	event, err := domain.NewEvent(libevent)
+	require.NoError(t, err)
+
+	_, err = domain.NewZapReceipt(event)
+	require.Error(t, err)
+}
@@ -8,7 +8,7 @@ import (
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/boreq/errors"
 	"github.com/planetary-social/go-notification-service/internal/logging"
-	"github.com/planetary-social/go-notification-service/service/adapters/firestore"
+	"github.com/planetary-social/go-notification-service/service/adapters/pubsub"
 	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/domain"
 )
@@ -25,20 +25,21 @@ type Metrics interface {
 	ReportSubscriptionQueueLength(topic string, n int)
 }
 
-type FirestoreSubscriber interface {
+// Subscriber is implemented by the queues of all storage adapters.
+type Subscriber interface {
 	message.Subscriber
 	QueueLength(topic string) (int, error)
 }
 
 type EventSavedSubscriber struct {
-	subscriber FirestoreSubscriber
+	subscriber Subscriber
 	handler    ProcessSavedEventHandler
 	metrics    Metrics
 	logger     logging.Logger
 }
 
 func NewEventSavedSubscriber(
-	subscriber FirestoreSubscriber,
+	subscriber Subscriber,
 	handler ProcessSavedEventHandler,
 	metrics Metrics,
 	logger logging.Logger,
@@ -54,7 +55,7 @@ func NewEventSavedSubscriber(
 func (p *EventSavedSubscriber) Run(ctx context.Context) error {
 	go p.gatherMetricsLoop(ctx)
 
-	ch, err := p.subscriber.Subscribe(ctx, firestore.PubsubTopicEventSaved)
+	ch, err := p.subscriber.Subscribe(ctx, pubsub.TopicEventSaved)
 	if err != nil {
 		return errors.Wrap(err, "error subscribing")
 	}
@@ -81,12 +82,12 @@ func (p *EventSavedSubscriber) gatherMetricsLoop(ctx context.Context) {
 }
 
 func (p *EventSavedSubscriber) gatherMetrics() error {
-	n, err := p.subscriber.QueueLength(firestore.PubsubTopicEventSaved)
+	n, err := p.subscriber.QueueLength(pubsub.TopicEventSaved)
 	if err != nil {
 		return errors.Wrap(err, "error checking queue length")
 	}
 
-	p.metrics.ReportSubscriptionQueueLength(firestore.PubsubTopicEventSaved, n)
+	p.metrics.ReportSubscriptionQueueLength(pubsub.TopicEventSaved, n)
 	return nil
 }
 
@@ -100,7 +101,7 @@ func (p *EventSavedSubscriber) handleMessage(ctx context.Context, msg *message.M
 }
 
 func (p *EventSavedSubscriber) runHandler(ctx context.Context, msg *message.Message) error {
-	var payload firestore.EventSavedPayload
+	var payload pubsub.EventSavedPayload
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
 		return errors.Wrap(err, "error unmarshaling event payload")
 	}
@@ -0,0 +1,97 @@
+package http
+
+import (
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientIP(t *testing.T) {
+	trustedProxies := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("35.191.0.0/16"),
+	}
+
+	testCases := []struct {
+		Name         string
+		RemoteAddr   string
+		ForwardedFor []string
+
+		ExpectedIP string
+	}{
+		{
+			Name:       "direct_connection",
+			RemoteAddr: "1.2.3.4:1234",
+			ExpectedIP: "1.2.3.4",
+		},
+		{
+			Name:         "header_is_ignored_if_not_sent_by_trusted_proxy",
+			RemoteAddr:   "1.2.3.4:1234",
+			ForwardedFor: []string{"5.6.7.8"},
+			ExpectedIP:   "1.2.3.4",
+		},
+		{
+			Name:         "trusted_proxy",
+			RemoteAddr:   "10.0.0.1:1234",
+			ForwardedFor: []string{"5.6.7.8"},
+			ExpectedIP:   "5.6.7.8",
+		},
+		{
+			Name:         "multiple_trusted_proxies",
+			RemoteAddr:   "10.0.0.1:1234",
+			ForwardedFor: []string{"5.6.7.8, 35.191.1.1"},
+			ExpectedIP:   "5.6.7.8",
+		},
+		{
+			Name:         "addresses_set_by_client_are_ignored",
+			RemoteAddr:   "10.0.0.1:1234",
+			ForwardedFor: []string{"9.9.9.9, 5.6.7.8"},
+			ExpectedIP:   "5.6.7.8",
+		},
+		{
+			Name:         "multiple_headers",
+			RemoteAddr:   "10.0.0.1:1234",
+			ForwardedFor: []string{"9.9.9.9", "5.6.7.8"},
+			ExpectedIP:   "5.6.7.8",
+		},
+		{
+			Name:       "trusted_proxy_without_header",
+			RemoteAddr: "10.0.0.1:1234",
+			ExpectedIP: "10.0.0.1",
+		},
+		{
+			Name:         "invalid_address_in_header",
+			RemoteAddr:   "10.0.0.1:1234",
+			ForwardedFor: []string{"5.6.7.8, invalid"},
+			ExpectedIP:   "10.0.0.1",
+		},
+		{
+			Name:         "ipv6",
+			RemoteAddr:   "10.0.0.1:1234",
+			ForwardedFor: []string{"2001:db8::1"},
+			ExpectedIP:   "2001:db8::1",
+		},
+		{
+			Name:         "ipv4_mapped_ipv6_proxy",
+			RemoteAddr:   "[::ffff:10.0.0.1]:1234",
+			ForwardedFor: []string{"5.6.7.8"},
+			ExpectedIP:   "5.6.7.8",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			r, err := http.NewRequest(http.MethodGet, "/", nil)
+			require.NoError(t, err)
+
+			r.RemoteAddr = testCase.RemoteAddr
+			for _, v := range testCase.ForwardedFor {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			require.Equal(t, testCase.ExpectedIP, clientIP(r, trustedProxies))
+		})
+	}
+}
@@ -2,9 +2,15 @@ package http
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/boreq/errors"
 	"github.com/gorilla/websocket"
@@ -13,23 +19,43 @@ import (
 	"github.com/planetary-social/go-notification-service/service/app"
 	"github.com/planetary-social/go-notification-service/service/config"
 	"github.com/planetary-social/go-notification-service/service/domain"
+	"golang.org/x/time/rate"
 )
 
+const (
+	challengeLength = 16
+
+	headerForwardedFor = "X-Forwarded-For"
+)
+
+type Metrics interface {
+	ReportRateLimitHit(limit string)
+}
+
 type Server struct {
-	config config.Config
-	app    app.Application
-	logger logging.Logger
+	config  config.Config
+	app     app.Application
+	metrics Metrics
+	logger  logging.Logger
+
+	ipLimiter        *keyedLimiter
+	publicKeyLimiter *keyedLimiter
 }
 
 func NewServer(
 	config config.Config,
 	app app.Application,
+	metrics Metrics,
 	logger logging.Logger,
 ) Server {
 	return Server{
-		config: config,
-		app:    app,
-		logger: logger.New("server"),
+		config:  config,
+		app:     app,
+		metrics: metrics,
+		logger:  logger.New("server"),
+
+		ipLimiter:        newKeyedLimiter(config.IPRateLimit()),
+		publicKeyLimiter: newKeyedLimiter(config.PublicKeyRateLimit()),
 	}
 }
 
@@ -81,7 +107,9 @@ func (s *Server) serveWs(ctx context.Context, rw http.ResponseWriter, r *http.Re
 		}
 	}()
 
-	if err := s.handleConnection(ctx, conn); err != nil {
+	ip := clientIP(r, s.config.TrustedProxies())
+
+	if err := s.handleConnection(ctx, conn, r.Host, ip); err != nil {
 		closeErr := &websocket.CloseError{}
 		if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseNormalClosure {
 			s.logger.Error().WithError(err).Message("error handling the connection")
@@ -89,73 +117,256 @@ func (s *Server) serveWs(ctx context.Context, rw http.ResponseWriter, r *http.Re
 	}
 }
 
-func (s *Server) handleConnection(ctx context.Context, conn *websocket.Conn) error {
+// clientIP returns the address of the client which sent the request. If the
+// request was sent by a trusted proxy then the address is taken from the
+// X-Forwarded-For header. Proxies append addresses to the header so the
+// rightmost address which doesn't belong to a trusted proxy is used as all
+// addresses to the left of it could have been set by the client.
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	if !isTrustedProxy(ip, trustedProxies) {
+		return ip
+	}
+
+	var forwardedFor []string
+	for _, header := range r.Header.Values(headerForwardedFor) {
+		for _, v := range strings.Split(header, ",") {
+			forwardedFor = append(forwardedFor, strings.TrimSpace(v))
+		}
+	}
+
+	for i := len(forwardedFor) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(forwardedFor[i])
+		if err != nil {
+			return ip
+		}
+
+		ip = addr.Unmap().String()
+		if !isTrustedProxy(ip, trustedProxies) {
+			return ip
+		}
+	}
+
+	return ip
+}
+
+func isTrustedProxy(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleConnection(ctx context.Context, wsConn *websocket.Conn, host string, ip string) error {
 	s.logger.Debug().Message("accepted websocket connection")
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	subscriptions := make(map[string]context.CancelFunc)
+	conn, err := newConnection(wsConn, host, ip, s.config.ConnectionRateLimit())
+	if err != nil {
+		return errors.Wrap(err, "error creating the connection")
+	}
+	subscriptions := newSubscriptions()
+
+	challenge := conn.Challenge()
+	if err := conn.WriteJSON(nostr.AuthEnvelope{Challenge: &challenge}); err != nil {
+		return errors.Wrap(err, "error writing AUTH")
+	}
 
 	for {
-		_, messageBytes, err := conn.ReadMessage()
+		_, messageBytes, err := wsConn.ReadMessage()
 		if err != nil {
 			return errors.Wrap(err, "error reading the websocket message")
 		}
 
-		message := nostr.ParseMessage(messageBytes)
-		if message == nil {
-			return errors.New("failed to parse the message")
+		if err := s.handleMessage(ctx, conn, subscriptions, messageBytes); err != nil {
+			return errors.Wrap(err, "error handling the message")
 		}
+	}
+}
 
-		switch v := message.(type) {
-		case *nostr.EventEnvelope:
-			event, err := domain.NewEvent(v.Event)
-			if err != nil {
-				return errors.Wrap(err, "error creating an event")
+// handleMessage returns an error only if the connection should be closed.
+// Problems with the message itself are reported to the client.
+func (s *Server) handleMessage(ctx context.Context, conn *connection, subscriptions *subscriptions, messageBytes []byte) error {
+	switch v := nostr.ParseMessage(messageBytes).(type) {
+	case *nostr.AuthEnvelope:
+		if limit, ok := s.allowMessage(conn); !ok {
+			if err := conn.WriteJSON(newRejectedOKEnvelope(v.Event.ID, prefixRateLimited, limitMessage(limit))); err != nil {
+				return errors.Wrap(err, "error writing OK")
 			}
+			return nil
+		}
 
-			registration, err := domain.NewRegistrationFromEvent(event)
-			if err != nil {
-				return errors.Wrap(err, "error creating a registration")
+		if err := conn.WriteJSON(s.handleAuth(conn, v.Event)); err != nil {
+			return errors.Wrap(err, "error writing OK")
+		}
+	case *nostr.EventEnvelope:
+		if limit, ok := s.allowMessage(conn); !ok {
+			if err := conn.WriteJSON(newRejectedOKEnvelope(v.Event.ID, prefixRateLimited, limitMessage(limit))); err != nil {
+				return errors.Wrap(err, "error writing OK")
 			}
+			return nil
+		}
 
-			cmd := app.NewSaveRegistration(
-				registration,
-			)
+		if err := conn.WriteJSON(s.handleEvent(ctx, conn, v.Event)); err != nil {
+			return errors.Wrap(err, "error writing OK")
+		}
+	case *nostr.ReqEnvelope:
+		subscriptions.Close(v.SubscriptionID)
 
-			if err := s.app.Commands.SaveRegistration.Handle(ctx, cmd); err != nil {
-				return errors.Wrap(err, "error handling the registration command")
-			}
-		case *nostr.ReqEnvelope:
-			filters, err := domain.NewFilters(v.Filters)
-			if err != nil {
-				return errors.Wrap(err, "error creating filters")
+		filters, closed, ok := s.handleReq(conn, subscriptions, v)
+		if !ok {
+			if err := conn.WriteJSON(closed); err != nil {
+				return errors.Wrap(err, "error writing CLOSED")
 			}
+			return nil
+		}
+
+		subCtx, sub := subscriptions.Open(ctx, v.SubscriptionID)
+		go func() {
+			defer subscriptions.Remove(v.SubscriptionID, sub)
+			s.sendEvents(subCtx, conn, filters, v.SubscriptionID)
+		}()
+	case *nostr.CloseEnvelope:
+		subscriptions.Close(string(*v))
+	case nil:
+		if err := conn.WriteJSON(newNoticeEnvelope(prefixInvalid, "failed to parse the message")); err != nil {
+			return errors.Wrap(err, "error writing NOTICE")
+		}
+	default:
+		s.logger.Error().WithField("message", v).Message("received an unknown message")
+		if err := conn.WriteJSON(newNoticeEnvelope(prefixInvalid, fmt.Sprintf("unsupported message '%s'", v.Label()))); err != nil {
+			return errors.Wrap(err, "error writing NOTICE")
+		}
+	}
+	return nil
+}
+
+func (s *Server) handleAuth(conn *connection, libevent nostr.Event) nostr.OKEnvelope {
+	event, err := domain.NewEvent(libevent)
+	if err != nil {
+		return newRejectedOKEnvelope(libevent.ID, prefixInvalid, err.Error())
+	}
+
+	authentication, err := domain.NewAuthenticationFromEvent(event, conn.Challenge(), conn.Host(), time.Now())
+	if err != nil {
+		return newRejectedOKEnvelope(libevent.ID, prefixInvalid, err.Error())
+	}
+
+	conn.Authenticate(authentication.PublicKey())
+	return newAcceptedOKEnvelope(libevent.ID)
+}
+
+// handleReq returns false and a CLOSED envelope if the subscription shouldn't
+// be served. Authenticated clients can only query events which tag them.
+func (s *Server) handleReq(conn *connection, subscriptions *subscriptions, req *nostr.ReqEnvelope) (domain.Filters, closedEnvelope, bool) {
+	if len(req.Filters) > s.config.MaxFiltersPerSubscription() {
+		s.metrics.ReportRateLimitHit(limitFilters)
+		return domain.Filters{}, newClosedEnvelope(req.SubscriptionID, prefixRateLimited, limitMessage(limitFilters)), false
+	}
+
+	if subscriptions.Len() >= s.config.MaxSubscriptionsPerConnection() {
+		s.metrics.ReportRateLimitHit(limitSubscriptions)
+		return domain.Filters{}, newClosedEnvelope(req.SubscriptionID, prefixRateLimited, limitMessage(limitSubscriptions)), false
+	}
 
-			s.closeSubscription(subscriptions, v.SubscriptionID)
+	if limit, ok := s.allowMessage(conn); !ok {
+		return domain.Filters{}, newClosedEnvelope(req.SubscriptionID, prefixRateLimited, limitMessage(limit)), false
+	}
+
+	publicKey, ok := conn.AuthenticatedPublicKey()
+	if !ok {
+		return domain.Filters{}, newClosedEnvelope(req.SubscriptionID, prefixAuthRequired, "authenticate to query events"), false
+	}
+
+	filters, err := domain.NewFilters(req.Filters)
+	if err != nil {
+		return domain.Filters{}, newClosedEnvelope(req.SubscriptionID, prefixInvalid, err.Error()), false
+	}
+
+	if !filters.OnlyMatchEventsTagging(publicKey) {
+		return domain.Filters{}, newClosedEnvelope(req.SubscriptionID, prefixRestricted, "you can only query events which p-tag the authenticated key"), false
+	}
 
-			subCtx, subCancel := context.WithCancel(ctx)
-			go s.sendEvents(subCtx, conn, filters, v.SubscriptionID)
-			subscriptions[v.SubscriptionID] = subCancel
-		case *nostr.CloseEnvelope:
-			s.closeSubscription(subscriptions, string(*v))
-		default:
-			s.logger.Error().WithField("message", message).Message("received an unknown message")
+	return filters, closedEnvelope{}, true
+}
+
+func (s *Server) handleEvent(ctx context.Context, conn *connection, libevent nostr.Event) nostr.OKEnvelope {
+	event, err := domain.NewEvent(libevent)
+	if err != nil {
+		return newRejectedOKEnvelope(libevent.ID, prefixInvalid, err.Error())
+	}
+
+	publicKey, ok := conn.AuthenticatedPublicKey()
+	if !ok {
+		return newRejectedOKEnvelope(libevent.ID, prefixAuthRequired, "authenticate to register")
+	}
+
+	if event.PubKey() != publicKey {
+		return newRejectedOKEnvelope(libevent.ID, prefixRestricted, "event must be signed by the authenticated key")
+	}
+
+	if event.Kind() == domain.EventKindUnregistration {
+		unregistration, err := domain.NewUnregistrationFromEvent(event)
+		if err != nil {
+			return newRejectedOKEnvelope(libevent.ID, prefixInvalid, err.Error())
+		}
+
+		cmd := app.NewRemoveRegistration(
+			unregistration,
+		)
+
+		if err := s.app.Commands.RemoveRegistration.Handle(ctx, cmd); err != nil {
+			s.logger.Error().WithError(err).Message("error handling the remove registration command")
+			return newRejectedOKEnvelope(libevent.ID, prefixError, "could not remove the registration")
 		}
+
+		return newAcceptedOKEnvelope(libevent.ID)
 	}
+
+	registration, err := domain.NewRegistrationFromEvent(event)
+	if err != nil {
+		return newRejectedOKEnvelope(libevent.ID, prefixInvalid, err.Error())
+	}
+
+	cmd := app.NewSaveRegistration(
+		registration,
+	)
+
+	if err := s.app.Commands.SaveRegistration.Handle(ctx, cmd); err != nil {
+		s.logger.Error().WithError(err).Message("error handling the registration command")
+		return newRejectedOKEnvelope(libevent.ID, prefixError, "could not save the registration")
+	}
+
+	return newAcceptedOKEnvelope(libevent.ID)
 }
 
-func (s *Server) sendEvents(ctx context.Context, conn *websocket.Conn, filters domain.Filters, subscriptionName string) {
+func (s *Server) sendEvents(ctx context.Context, conn *connection, filters domain.Filters, subscriptionName string) {
 	if err := s.sendEventsErr(ctx, conn, filters, subscriptionName); err != nil {
 		if !errors.Is(err, context.Canceled) {
 			s.logger.Error().WithError(err).Message("get events returned an error")
+			if err := conn.WriteJSON(newClosedEnvelope(subscriptionName, prefixError, "could not get the events")); err != nil {
+				s.logger.Error().WithError(err).Message("error writing CLOSED")
+			}
 			return
 		}
 	}
 }
 
-func (s *Server) sendEventsErr(ctx context.Context, conn *websocket.Conn, filters domain.Filters, subscriptionName string) error {
+func (s *Server) sendEventsErr(ctx context.Context, conn *connection, filters domain.Filters, subscriptionName string) error {
 	for event := range s.app.Queries.GetEvents.Handle(ctx, filters) {
 		if err := event.Err(); err != nil {
 			return errors.Wrap(err, "received an error")
@@ -184,9 +395,94 @@ func (s *Server) sendEventsErr(ctx context.Context, conn *websocket.Conn, filter
 	return nil
 }
 
-func (s *Server) closeSubscription(subscriptions map[string]context.CancelFunc, subscriptionName string) {
-	if cancel, ok := subscriptions[subscriptionName]; ok {
-		cancel()
-		delete(subscriptions, subscriptionName)
+// allowMessage consumes tokens from all buckets which apply to the connection
+// and returns the limit which was hit if any of them were empty.
+func (s *Server) allowMessage(conn *connection) (string, bool) {
+	now := time.Now()
+
+	limit, ok := s.checkLimits(conn, now)
+	if !ok {
+		s.metrics.ReportRateLimitHit(limit)
 	}
+	return limit, ok
+}
+
+func (s *Server) checkLimits(conn *connection, now time.Time) (string, bool) {
+	if !conn.AllowMessage(now) {
+		return limitConnection, false
+	}
+
+	if !s.ipLimiter.Allow(conn.IP(), now) {
+		return limitIP, false
+	}
+
+	if publicKey, ok := conn.AuthenticatedPublicKey(); ok {
+		if !s.publicKeyLimiter.Allow(publicKey.Hex(), now) {
+			return limitPublicKey, false
+		}
+	}
+
+	return "", true
+}
+
+// connection serializes writes as websocket connections don't support
+// concurrent writers and subscriptions are served in separate goroutines. The
+// authentication state is only accessed by the goroutine reading messages.
+type connection struct {
+	conn *websocket.Conn
+	lock sync.Mutex
+
+	host                   string
+	ip                     string
+	limiter                *rate.Limiter
+	challenge              string
+	authenticatedPublicKey *domain.PublicKey
+}
+
+func newConnection(conn *websocket.Conn, host string, ip string, limit config.RateLimit) (*connection, error) {
+	challenge := make([]byte, challengeLength)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, errors.Wrap(err, "error generating the challenge")
+	}
+
+	return &connection{
+		conn:      conn,
+		host:      host,
+		ip:        ip,
+		limiter:   newLimiter(limit),
+		challenge: hex.EncodeToString(challenge),
+	}, nil
+}
+
+func (c *connection) IP() string {
+	return c.ip
+}
+
+func (c *connection) AllowMessage(now time.Time) bool {
+	return c.limiter.AllowN(now, 1)
+}
+
+func (c *connection) Host() string {
+	return c.host
+}
+
+func (c *connection) Challenge() string {
+	return c.challenge
+}
+
+func (c *connection) Authenticate(publicKey domain.PublicKey) {
+	c.authenticatedPublicKey = &publicKey
+}
+
+func (c *connection) AuthenticatedPublicKey() (domain.PublicKey, bool) {
+	if c.authenticatedPublicKey == nil {
+		return domain.PublicKey{}, false
+	}
+	return *c.authenticatedPublicKey, true
+}
+
+func (c *connection) WriteJSON(v any) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.conn.WriteJSON(v)
 }
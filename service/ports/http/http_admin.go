@@ -0,0 +1,324 @@
+package http
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/boreq/errors"
+	"github.com/planetary-social/go-notification-service/internal/logging"
+	"github.com/planetary-social/go-notification-service/service/app"
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/planetary-social/go-notification-service/service/domain"
+)
+
+// AdminServer exposes an API which lets operators inspect and manage the
+// service without having to use production credentials directly.
+type AdminServer struct {
+	config      config.Config
+	app         app.Application
+	logger      logging.Logger
+	auditLogger logging.Logger
+}
+
+func NewAdminServer(
+	config config.Config,
+	app app.Application,
+	logger logging.Logger,
+) AdminServer {
+	return AdminServer{
+		config:      config,
+		app:         app,
+		logger:      logger.New("adminServer"),
+		auditLogger: logger.New("audit"),
+	}
+}
+
+func (s *AdminServer) ListenAndServe(ctx context.Context) error {
+	if s.config.AdminToken() == "" {
+		s.logger.Debug().Message("admin token is not set, the admin API is disabled")
+		<-ctx.Done()
+		return nil
+	}
+
+	mux := s.createMux()
+
+	var listenConfig net.ListenConfig
+	listener, err := listenConfig.Listen(ctx, "tcp", s.config.AdminListenAddress())
+	if err != nil {
+		return errors.Wrap(err, "error listening")
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := listener.Close(); err != nil {
+			s.logger.Error().WithError(err).Message("error closing listener")
+		}
+	}()
+
+	return http.Serve(listener, s.authenticate(mux))
+}
+
+func (s *AdminServer) createMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/relays", s.handle(http.MethodGet, s.listRelays))
+	mux.HandleFunc("/tokens", s.handle(http.MethodGet, s.listTokens))
+	mux.HandleFunc("/notifications", s.handle(http.MethodGet, s.listNotifications))
+	mux.HandleFunc("/delete-public-key", s.handle(http.MethodPost, s.deletePublicKey))
+	mux.HandleFunc("/reprocess-event", s.handle(http.MethodPost, s.reprocessEvent))
+	return mux
+}
+
+func (s *AdminServer) authenticate(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + s.config.AdminToken())
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			s.writeError(w, http.StatusUnauthorized, errors.New("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *AdminServer) listRelays(r *http.Request) (any, error) {
+	health, err := s.app.Queries.GetRelayHealth.Handle(r.Context())
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting relay health")
+	}
+
+	relays := make([]adminRelayTransport, 0, len(health))
+	for _, h := range health {
+		relay := adminRelayTransport{
+			Address:               h.Address().String(),
+			State:                 h.State().String(),
+			ConnectLatencySeconds: h.ConnectLatency().Seconds(),
+			ConsecutiveFailures:   h.ConsecutiveFailures(),
+			EventsPerMinute:       h.EventsPerMinute(),
+		}
+
+		if lastEOSE, ok := h.LastEOSE(); ok {
+			relay.LastEOSE = &lastEOSE
+		}
+
+		if quarantinedUntil, ok := h.QuarantinedUntil(); ok {
+			relay.QuarantinedUntil = &quarantinedUntil
+		}
+
+		relays = append(relays, relay)
+	}
+
+	return relays, nil
+}
+
+func (s *AdminServer) listTokens(r *http.Request) (any, error) {
+	publicKey, err := s.publicKeyFromRequest(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the public key")
+	}
+
+	tokens, err := s.app.Queries.GetTokens.Handle(r.Context(), publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting tokens")
+	}
+
+	result := make([]adminTokenTransport, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, adminTokenTransport{
+			Token:            token.PushToken().String(),
+			Platform:         token.PushToken().Platform().String(),
+			NotificationMode: token.NotificationMode().String(),
+		})
+	}
+
+	return result, nil
+}
+
+func (s *AdminServer) listNotifications(r *http.Request) (any, error) {
+	publicKey, err := s.publicKeyFromRequest(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the public key")
+	}
+
+	events, err := s.app.Queries.GetRecentNotifications.Handle(r.Context(), publicKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "error getting recent notifications")
+	}
+
+	result := make([]adminEventTransport, 0, len(events))
+	for _, event := range events {
+		notifications := make([]adminNotificationTransport, 0, len(event.Notifications()))
+		for _, notification := range event.Notifications() {
+			notifications = append(notifications, adminNotificationTransport{
+				UUID:      notification.UUID().String(),
+				Token:     notification.PushToken().String(),
+				Platform:  notification.PushToken().Platform().String(),
+				Priority:  notification.Priority().String(),
+				CreatedAt: notification.CreatedAt(),
+			})
+		}
+
+		result = append(result, adminEventTransport{
+			Event:         event.Event().Raw(),
+			Notifications: notifications,
+		})
+	}
+
+	return result, nil
+}
+
+func (s *AdminServer) deletePublicKey(r *http.Request) (any, error) {
+	publicKey, err := s.publicKeyFromRequest(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "error reading the public key")
+	}
+
+	if err := s.app.Commands.DeletePublicKey.Handle(r.Context(), app.NewDeletePublicKey(publicKey)); err != nil {
+		return nil, errors.Wrap(err, "error deleting the public key")
+	}
+
+	s.auditLogger.Debug().
+		WithField("action", "deletePublicKey").
+		WithField("publicKey", publicKey.Hex()).
+		WithField("remoteAddr", r.RemoteAddr).
+		Message("operator deleted a public key")
+
+	return nil, nil
+}
+
+func (s *AdminServer) reprocessEvent(r *http.Request) (any, error) {
+	eventId, err := domain.NewEventId(r.URL.Query().Get("id"))
+	if err != nil {
+		return nil, newBadRequestError(errors.Wrap(err, "invalid event id"))
+	}
+
+	if err := s.app.Commands.ReprocessEvent.Handle(r.Context(), app.NewReprocessEvent(eventId)); err != nil {
+		return nil, errors.Wrap(err, "error reprocessing the event")
+	}
+
+	s.auditLogger.Debug().
+		WithField("action", "reprocessEvent").
+		WithField("event.id", eventId.Hex()).
+		WithField("remoteAddr", r.RemoteAddr).
+		Message("operator requested reprocessing of an event")
+
+	return nil, nil
+}
+
+func (s *AdminServer) publicKeyFromRequest(r *http.Request) (domain.PublicKey, error) {
+	npub := strings.TrimSpace(r.URL.Query().Get("npub"))
+	if npub == "" {
+		return domain.PublicKey{}, newBadRequestError(errors.New("missing npub"))
+	}
+
+	publicKey, err := domain.NewPublicKeyFromNpub(npub)
+	if err != nil {
+		return domain.PublicKey{}, newBadRequestError(errors.Wrap(err, "invalid npub"))
+	}
+
+	return publicKey, nil
+}
+
+type adminHandlerFunc func(r *http.Request) (any, error)
+
+func (s *AdminServer) handle(method string, f adminHandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			s.writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+
+		result, err := f(r)
+		if err != nil {
+			s.writeError(w, s.statusCode(err), err)
+			return
+		}
+
+		if result == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		s.writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *AdminServer) statusCode(err error) int {
+	var badRequestErr *badRequestError
+	if errors.As(err, &badRequestErr) {
+		return http.StatusBadRequest
+	}
+
+	if errors.Is(err, app.ErrEventNotFound) {
+		return http.StatusNotFound
+	}
+
+	s.logger.Error().WithError(err).Message("admin API call failed")
+	return http.StatusInternalServerError
+}
+
+func (s *AdminServer) writeError(w http.ResponseWriter, statusCode int, err error) {
+	s.writeJSON(w, statusCode, adminErrorTransport{Error: err.Error()})
+}
+
+func (s *AdminServer) writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Error().WithError(err).Message("error writing the response")
+	}
+}
+
+type badRequestError struct {
+	err error
+}
+
+func newBadRequestError(err error) *badRequestError {
+	return &badRequestError{err: err}
+}
+
+func (e *badRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *badRequestError) Unwrap() error {
+	return e.err
+}
+
+type adminErrorTransport struct {
+	Error string `json:"error"`
+}
+
+type adminRelayTransport struct {
+	Address               string     `json:"address"`
+	State                 string     `json:"state"`
+	ConnectLatencySeconds float64    `json:"connectLatencySeconds"`
+	ConsecutiveFailures   int        `json:"consecutiveFailures"`
+	EventsPerMinute       int        `json:"eventsPerMinute"`
+	LastEOSE              *time.Time `json:"lastEOSE,omitempty"`
+	QuarantinedUntil      *time.Time `json:"quarantinedUntil,omitempty"`
+}
+
+type adminTokenTransport struct {
+	Token            string `json:"token"`
+	Platform         string `json:"platform"`
+	NotificationMode string `json:"notificationMode"`
+}
+
+type adminEventTransport struct {
+	Event         json.RawMessage              `json:"event"`
+	Notifications []adminNotificationTransport `json:"notifications"`
+}
+
+type adminNotificationTransport struct {
+	UUID      string     `json:"uuid"`
+	Token     string     `json:"token"`
+	Platform  string     `json:"platform"`
+	Priority  string     `json:"priority"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
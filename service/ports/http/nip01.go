@@ -0,0 +1,58 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Machine-readable prefixes of messages sent in OK and CLOSED envelopes, see
+// NIP-01 and NIP-42.
+const (
+	prefixInvalid      = "invalid"
+	prefixError        = "error"
+	prefixAuthRequired = "auth-required"
+	prefixRestricted   = "restricted"
+	prefixRateLimited  = "rate-limited"
+)
+
+func newAcceptedOKEnvelope(eventID string) nostr.OKEnvelope {
+	return nostr.OKEnvelope{
+		EventID: eventID,
+		OK:      true,
+	}
+}
+
+func newRejectedOKEnvelope(eventID string, prefix string, message string) nostr.OKEnvelope {
+	return nostr.OKEnvelope{
+		EventID: eventID,
+		OK:      false,
+		Reason:  prefixedMessage(prefix, message),
+	}
+}
+
+func newNoticeEnvelope(prefix string, message string) nostr.NoticeEnvelope {
+	return nostr.NoticeEnvelope(prefixedMessage(prefix, message))
+}
+
+// closedEnvelope is missing from the version of go-nostr that we use.
+type closedEnvelope struct {
+	subscriptionID string
+	reason         string
+}
+
+func newClosedEnvelope(subscriptionID string, prefix string, message string) closedEnvelope {
+	return closedEnvelope{
+		subscriptionID: subscriptionID,
+		reason:         prefixedMessage(prefix, message),
+	}
+}
+
+func (v closedEnvelope) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]string{"CLOSED", v.subscriptionID, v.reason})
+}
+
+func prefixedMessage(prefix string, message string) string {
+	return fmt.Sprintf("%s: %s", prefix, message)
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"sync"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/service/config"
+	"golang.org/x/time/rate"
+)
+
+const cleanupLimitersEvery = 1 * time.Minute
+
+// Limits which can be hit by clients, used for reporting.
+const (
+	limitIP            = "ip"
+	limitPublicKey     = "publicKey"
+	limitConnection    = "connection"
+	limitSubscriptions = "subscriptions"
+	limitFilters       = "filters"
+)
+
+func limitMessage(limit string) string {
+	switch limit {
+	case limitSubscriptions:
+		return "too many concurrent subscriptions"
+	case limitFilters:
+		return "too many filters"
+	default:
+		return "slow down"
+	}
+}
+
+func newLimiter(limit config.RateLimit) *rate.Limiter {
+	return rate.NewLimiter(rate.Limit(limit.PerSecond()), limit.Burst())
+}
+
+// keyedLimiter maintains a separate token bucket for each key e.g. an IP
+// address so that limits can be shared between connections.
+type keyedLimiter struct {
+	limit config.RateLimit
+
+	lock        sync.Mutex
+	limiters    map[string]*rate.Limiter
+	lastCleanup time.Time
+}
+
+func newKeyedLimiter(limit config.RateLimit) *keyedLimiter {
+	return &keyedLimiter{
+		limit:    limit,
+		limiters: make(map[string]*rate.Limiter),
+	}
+}
+
+// Allow consumes a token from the bucket for the given key and returns false
+// if the bucket was empty.
+func (l *keyedLimiter) Allow(key string, now time.Time) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.cleanup(now)
+
+	limiter, ok := l.limiters[key]
+	if !ok {
+		limiter = newLimiter(l.limit)
+		l.limiters[key] = limiter
+	}
+
+	return limiter.AllowN(now, 1)
+}
+
+// cleanup forgets full buckets as they are identical to new ones.
+func (l *keyedLimiter) cleanup(now time.Time) {
+	if now.Sub(l.lastCleanup) < cleanupLimitersEvery {
+		return
+	}
+	l.lastCleanup = now
+
+	for key, limiter := range l.limiters {
+		if limiter.TokensAt(now) >= float64(limiter.Burst()) {
+			delete(l.limiters, key)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package http
+
+import (
+	"testing"
+	"time"
+
+	"github.com/planetary-social/go-notification-service/service/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyedLimiter_KeysHaveSeparateBuckets(t *testing.T) {
+	limit, err := config.NewRateLimit(1, 2)
+	require.NoError(t, err)
+
+	limiter := newKeyedLimiter(limit)
+	now := time.Now()
+
+	require.True(t, limiter.Allow("a", now))
+	require.True(t, limiter.Allow("a", now))
+	require.False(t, limiter.Allow("a", now))
+
+	require.True(t, limiter.Allow("b", now))
+
+	require.True(t, limiter.Allow("a", now.Add(time.Second)))
+	require.False(t, limiter.Allow("a", now.Add(time.Second)))
+}
+
+func TestKeyedLimiter_FullBucketsAreForgotten(t *testing.T) {
+	limit, err := config.NewRateLimit(1, 2)
+	require.NoError(t, err)
+
+	limiter := newKeyedLimiter(limit)
+	now := time.Now()
+
+	require.True(t, limiter.Allow("a", now))
+	require.True(t, limiter.Allow("b", now))
+	require.True(t, limiter.Allow("b", now))
+	require.Len(t, limiter.limiters, 2)
+
+	require.True(t, limiter.Allow("c", now.Add(cleanupLimitersEvery)))
+	require.Len(t, limiter.limiters, 1, "buckets which were refilled should be removed")
+}
@@ -0,0 +1,66 @@
+package http
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriptions are opened and closed by the goroutine reading messages and
+// removed by the goroutines serving them once they stop e.g. due to an error.
+type subscriptions struct {
+	lock          sync.Mutex
+	subscriptions map[string]*subscription
+}
+
+type subscription struct {
+	cancel context.CancelFunc
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		subscriptions: make(map[string]*subscription),
+	}
+}
+
+func (s *subscriptions) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.subscriptions)
+}
+
+// Open replaces the subscription with the same id if it exists.
+func (s *subscriptions) Open(ctx context.Context, id string) (context.Context, *subscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.close(id)
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &subscription{cancel: cancel}
+	s.subscriptions[id] = sub
+	return ctx, sub
+}
+
+func (s *subscriptions) Close(id string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.close(id)
+}
+
+// Remove closes the subscription unless it was already replaced.
+func (s *subscriptions) Remove(id string, sub *subscription) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.subscriptions[id] == sub {
+		s.close(id)
+	}
+}
+
+func (s *subscriptions) close(id string) {
+	if sub, ok := s.subscriptions[id]; ok {
+		sub.cancel()
+		delete(s.subscriptions, id)
+	}
+}